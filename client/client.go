@@ -3,13 +3,19 @@ package client
 import (
 	"crypto/ed25519"
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/threefoldtech/tfexplorer/models/generated/directory"
 	"github.com/threefoldtech/tfexplorer/models/generated/phonebook"
 	"github.com/threefoldtech/tfexplorer/models/generated/workloads"
 	"github.com/threefoldtech/tfexplorer/pkg/capacity/types"
+	escrow "github.com/threefoldtech/tfexplorer/pkg/escrow/types"
+	pbtypes "github.com/threefoldtech/tfexplorer/pkg/phonebook/types"
 	wrklds "github.com/threefoldtech/tfexplorer/pkg/workloads"
+	wrkldstypes "github.com/threefoldtech/tfexplorer/pkg/workloads/types"
 	"github.com/threefoldtech/tfexplorer/schema"
 	"github.com/threefoldtech/zos/pkg/capacity"
 	"github.com/threefoldtech/zos/pkg/capacity/dmi"
@@ -45,6 +51,10 @@ type (
 		FarmGet(id schema.ID) (farm directory.Farm, err error)
 		Farms(cacheSize int) FarmIter
 
+		FarmPayouts(id schema.ID, from, to string, page *Pager) (statements []escrow.FarmerPayoutStatement, err error)
+		FarmPayoutTotals(id schema.ID, from, to string) (totals []escrow.FarmerPayoutTotal, err error)
+		FarmPayoutsExport(id schema.ID, from, to, format, report string, w io.Writer) error
+
 		GatewayRegister(Gateway directory.Gateway) error
 		GatewayList(tid schema.ID, name string, page *Pager) (farms []directory.Gateway, err error)
 		GatewayGet(id string) (farm directory.Gateway, err error)
@@ -82,6 +92,15 @@ type (
 		Get(id schema.ID) (phonebook.User, error)
 		// Update() #TODO
 		Validate(id schema.ID, message, signature string) (bool, error)
+		ValidateAt(id schema.ID, message, signature string, at time.Time) (bool, error)
+
+		RotateKey(id schema.ID, rotation pbtypes.KeyRotation) error
+		Keys(id schema.ID) ([]pbtypes.UserKey, error)
+		SetGuardians(id schema.ID, guardians []schema.ID, threshold int64) error
+
+		TokenCreate(token pbtypes.Token) (pbtypes.Token, error)
+		Tokens(id schema.ID) ([]pbtypes.Token, error)
+		TokenRevoke(id schema.ID, tokenID string) error
 	}
 
 	// Workloads interface
@@ -89,13 +108,39 @@ type (
 		Create(reservation workloads.Workloader) (resp wrklds.ReservationCreateResponse, err error)
 		List(nextAction *workloads.NextActionEnum, customerTid int64, page *Pager) (reservation []workloads.Reservation, err error)
 		Get(id schema.ID) (reservation workloads.Workloader, err error)
+		Search(filter WorkloadFilter, page *Pager) (result []workloads.Workloader, err error)
 
 		SignProvision(id schema.ID, user schema.ID, signature string) error
 		SignDelete(id schema.ID, user schema.ID, signature string) error
+		BulkDelete(request wrkldstypes.BulkDelete) (results []wrkldstypes.BulkDeleteResult, err error)
+
+		Update(id schema.ID, workload workloads.Workloader) (resp wrklds.ReservationCreateResponse, err error)
+		Versions(id schema.ID) (versions []workloads.Workloader, err error)
+		Events(id schema.ID) (events []wrkldstypes.WorkloadEvent, err error)
+		SetLabels(id schema.ID, update wrkldstypes.LabelsUpdate) (workload workloads.Workloader, err error)
+		Extend(id schema.ID, extension wrkldstypes.ExpiryExtension) (workload workloads.Workloader, err error)
+
+		GroupCreate(group wrkldstypes.DeploymentGroup) (resp wrklds.DeploymentGroupCreateResponse, err error)
+		GroupGet(id schema.ID) (group wrkldstypes.DeploymentGroup, err error)
+
+		Approvals(kind wrkldstypes.ApprovalKind) (approvals []wrkldstypes.Approval, err error)
+		Approve(id schema.ID, request wrkldstypes.ApprovalRequest) (approval wrkldstypes.Approval, err error)
+		Reject(id schema.ID, request wrkldstypes.ApprovalRequest) (approval wrkldstypes.Approval, err error)
+
+		ConversionList() (workloads []workloads.Workloader, err error)
+		ConversionPost(workloads []workloads.Workloader) error
 
 		PoolCreate(reservation types.Reservation) (resp wrklds.CapacityPoolCreateResponse, err error)
 		PoolGet(poolID string) (result types.Pool, err error)
 		PoolsGetByOwner(ownerID string) (result []types.Pool, err error)
+		PoolSetLabels(poolID schema.ID, update wrkldstypes.LabelsUpdate) (result types.Pool, err error)
+		PoolReceipts(page *Pager) (receipts []escrow.CapacityReceipt, err error)
+		PoolReceiptGet(reservationID schema.ID) (receipt escrow.CapacityReceipt, err error)
+
+		PricingRules(farmID schema.ID) (rules []escrow.PricingRule, err error)
+		PricingRuleCreate(rule escrow.PricingRule) (result escrow.PricingRule, err error)
+		PricingRuleSetEnabled(id schema.ID, enabled bool) error
+		PricingRuleDelete(id schema.ID) error
 
 		NodeWorkloads(nodeID string, from uint64) ([]workloads.Workloader, uint64, error)
 		NodeWorkloadGet(gwid string) (result workloads.Workloader, err error)
@@ -111,10 +156,14 @@ type (
 		PrivateKey() ed25519.PrivateKey
 	}
 
-	// Pager for listing
+	// Pager for listing, by page number or by cursor
 	Pager struct {
 		p int
 		s int
+
+		cursor   string
+		byCursor bool
+		next     string
 	}
 )
 
@@ -123,16 +172,38 @@ func (p *Pager) apply(v url.Values) {
 		return
 	}
 
+	if p.s == 0 {
+		p.s = 10
+	}
+	v.Set("size", fmt.Sprint(p.s))
+
+	if p.byCursor {
+		v.Set("cursor", p.cursor)
+		return
+	}
+
 	if p.p < 1 {
 		p.p = 1
 	}
+	v.Set("page", fmt.Sprint(p.p))
+}
 
-	if p.s == 0 {
-		p.s = 10
+// update records the cursor of the next page from the list response
+func (p *Pager) update(response *http.Response) {
+	if p == nil {
+		return
 	}
 
-	v.Set("page", fmt.Sprint(p.p))
-	v.Set("size", fmt.Sprint(p.s))
+	p.next = ""
+	if response != nil {
+		p.next = response.Header.Get("Next-Cursor")
+	}
+}
+
+// Next returns the cursor of the page following the last page listed with
+// the pager, it is empty if it was the last page
+func (p *Pager) Next() string {
+	return p.next
 }
 
 // Page returns a pager
@@ -140,6 +211,13 @@ func Page(page, size int) *Pager {
 	return &Pager{p: page, s: size}
 }
 
+// Cursor returns a pager listing the items after the cursor. An empty cursor
+// starts at the first item, the cursor of the next page is returned by Next
+// after each list call
+func Cursor(cursor string, size int) *Pager {
+	return &Pager{cursor: cursor, byCursor: true, s: size}
+}
+
 // NewClient creates a new client, if identity is not nil, it will be used
 // to authenticate requests against the server
 func NewClient(u string, id Identity) (*Client, error) {
@@ -2,11 +2,13 @@ package client
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 
 	"github.com/pkg/errors"
 	"github.com/threefoldtech/tfexplorer/models/generated/directory"
+	escrow "github.com/threefoldtech/tfexplorer/pkg/escrow/types"
 	"github.com/threefoldtech/tfexplorer/schema"
 	"github.com/threefoldtech/zos/pkg/capacity"
 	"github.com/threefoldtech/zos/pkg/capacity/dmi"
@@ -20,7 +22,7 @@ type (
 	httpNodeIter struct {
 		cl       *httpDirectory
 		proofs   bool
-		page     int
+		cursor   string
 		size     int
 		cache    []directory.Node
 		cacheIdx int
@@ -29,7 +31,7 @@ type (
 
 	httpFarmIter struct {
 		cl       *httpDirectory
-		page     int
+		cursor   string
 		size     int
 		cache    []directory.Farm
 		cacheIdx int
@@ -60,7 +62,8 @@ func (d *httpDirectory) FarmList(tid schema.ID, name string, page *Pager) (farms
 	if len(name) != 0 {
 		query.Set("name", name)
 	}
-	_, err = d.get(d.url("farms"), query, &farms, http.StatusOK)
+	response, err := d.get(d.url("farms"), query, &farms, http.StatusOK)
+	page.update(response)
 	return
 }
 
@@ -69,9 +72,39 @@ func (d *httpDirectory) FarmGet(id schema.ID) (farm directory.Farm, err error) {
 	return
 }
 
+func payoutsQuery(from, to string) url.Values {
+	query := url.Values{}
+	if len(from) != 0 {
+		query.Set("from", from)
+	}
+	if len(to) != 0 {
+		query.Set("to", to)
+	}
+	return query
+}
+
+func (d *httpDirectory) FarmPayouts(id schema.ID, from, to string, page *Pager) (statements []escrow.FarmerPayoutStatement, err error) {
+	query := payoutsQuery(from, to)
+	page.apply(query)
+	response, err := d.get(d.url("farms", fmt.Sprint(id), "payouts"), query, &statements, http.StatusOK)
+	page.update(response)
+	return
+}
+
+func (d *httpDirectory) FarmPayoutTotals(id schema.ID, from, to string) (totals []escrow.FarmerPayoutTotal, err error) {
+	_, err = d.get(d.url("farms", fmt.Sprint(id), "payouts", "monthly"), payoutsQuery(from, to), &totals, http.StatusOK)
+	return
+}
+
+func (d *httpDirectory) FarmPayoutsExport(id schema.ID, from, to, format, report string, w io.Writer) error {
+	query := payoutsQuery(from, to)
+	query.Set("format", format)
+	query.Set("report", report)
+	return d.download(d.url("farms", fmt.Sprint(id), "payouts", "export"), query, w)
+}
+
 func (d *httpDirectory) Farms(cacheSize int) FarmIter {
-	// pages start at index 1
-	return &httpFarmIter{cl: d, size: cacheSize, page: 1}
+	return &httpFarmIter{cl: d, size: cacheSize}
 }
 
 func (fi *httpFarmIter) Next() (*directory.Farm, error) {
@@ -81,7 +114,7 @@ func (fi *httpFarmIter) Next() (*directory.Farm, error) {
 			return nil, nil
 		}
 		// pull new data in cache
-		pager := Page(fi.page, fi.size)
+		pager := Cursor(fi.cursor, fi.size)
 		farms, err := fi.cl.FarmList(0, "", pager)
 		if err != nil {
 			return nil, errors.Wrap(err, "could not get farms")
@@ -92,8 +125,8 @@ func (fi *httpFarmIter) Next() (*directory.Farm, error) {
 		}
 		fi.cache = farms
 		fi.cacheIdx = 0
-		fi.page++
-		if len(farms) < fi.size {
+		fi.cursor = pager.Next()
+		if len(fi.cursor) == 0 {
 			fi.finished = true
 		}
 	}
@@ -110,7 +143,8 @@ func (d *httpDirectory) NodeList(filter NodeFilter, pager *Pager) (nodes []direc
 	query := url.Values{}
 	pager.apply(query)
 	filter.Apply(query)
-	_, err = d.get(d.url("nodes"), query, &nodes, http.StatusOK)
+	response, err := d.get(d.url("nodes"), query, &nodes, http.StatusOK)
+	pager.update(response)
 	return
 }
 
@@ -197,8 +231,7 @@ func (d *httpDirectory) NodeSetFreeToUse(id string, free bool) error {
 }
 
 func (d *httpDirectory) Nodes(cacheSize int, proofs bool) NodeIter {
-	// pages start at index 1
-	return &httpNodeIter{cl: d, size: cacheSize, page: 1, proofs: proofs}
+	return &httpNodeIter{cl: d, size: cacheSize, proofs: proofs}
 }
 
 func (ni *httpNodeIter) Next() (*directory.Node, error) {
@@ -208,7 +241,7 @@ func (ni *httpNodeIter) Next() (*directory.Node, error) {
 			return nil, nil
 		}
 		// pull new data in cache
-		pager := Page(ni.page, ni.size)
+		pager := Cursor(ni.cursor, ni.size)
 		filter := NodeFilter{}.WithProofs(ni.proofs)
 		nodes, err := ni.cl.NodeList(filter, pager)
 		if err != nil {
@@ -220,8 +253,8 @@ func (ni *httpNodeIter) Next() (*directory.Node, error) {
 		}
 		ni.cache = nodes
 		ni.cacheIdx = 0
-		ni.page++
-		if len(nodes) < ni.size {
+		ni.cursor = pager.Next()
+		if len(ni.cursor) == 0 {
 			ni.finished = true
 		}
 	}
@@ -239,7 +272,8 @@ func (d *httpDirectory) GatewayList(tid schema.ID, name string, page *Pager) (Ga
 	if len(name) != 0 {
 		query.Set("name", name)
 	}
-	_, err = d.get(d.url("gateways"), query, &Gateways, http.StatusOK)
+	response, err := d.get(d.url("gateways"), query, &Gateways, http.StatusOK)
+	page.update(response)
 	return
 }
 
@@ -3,6 +3,8 @@ package client
 import (
 	"fmt"
 	"net/url"
+
+	"github.com/threefoldtech/tfexplorer/schema"
 )
 
 // NodeFilter used to build a query for node list
@@ -16,6 +18,7 @@ type NodeFilter struct {
 	hru     *int64
 	proofs  *bool
 	deleted *bool
+	sort    *string
 }
 
 // WithFarm filter with farm
@@ -66,6 +69,17 @@ func (n NodeFilter) WithDeleted(deleted bool) NodeFilter {
 	return n
 }
 
+// WithSortByPrice sorts the nodes on the price of their farm for a unit
+// (cu, su or ipv4u), from the cheapest unless desc is set
+func (n NodeFilter) WithSortByPrice(unit string, desc bool) NodeFilter {
+	sort := fmt.Sprintf("%s_price", unit)
+	if desc {
+		sort = "-" + sort
+	}
+	n.sort = &sort
+	return n
+}
+
 // Apply fills query
 func (n NodeFilter) Apply(query url.Values) {
 
@@ -104,4 +118,59 @@ func (n NodeFilter) Apply(query url.Values) {
 	if n.deleted != nil {
 		query.Set("deleted", fmt.Sprint(*n.deleted))
 	}
+
+	if n.sort != nil {
+		query.Set("sort", *n.sort)
+	}
+}
+
+// WorkloadFilter used to build a query for workload search
+type WorkloadFilter struct {
+	customer *int64
+	pool     *int64
+	labels   schema.Labels
+	active   *bool
+}
+
+// WithCustomer filter with customer
+func (w WorkloadFilter) WithCustomer(tid int64) WorkloadFilter {
+	w.customer = &tid
+	return w
+}
+
+// WithPool filter with pool
+func (w WorkloadFilter) WithPool(id int64) WorkloadFilter {
+	w.pool = &id
+	return w
+}
+
+// WithLabels filter the workloads which have all the labels
+func (w WorkloadFilter) WithLabels(labels schema.Labels) WorkloadFilter {
+	w.labels = labels
+	return w
+}
+
+// WithActive filter the workloads which are not deleted or being deleted
+func (w WorkloadFilter) WithActive(active bool) WorkloadFilter {
+	w.active = &active
+	return w
+}
+
+// Apply fills query
+func (w WorkloadFilter) Apply(query url.Values) {
+	if w.customer != nil {
+		query.Set("customer_tid", fmt.Sprint(*w.customer))
+	}
+
+	if w.pool != nil {
+		query.Set("pool_id", fmt.Sprint(*w.pool))
+	}
+
+	if len(w.labels) != 0 {
+		query.Set("labels", w.labels.String())
+	}
+
+	if w.active != nil {
+		query.Set("active", fmt.Sprint(*w.active))
+	}
 }
@@ -2,8 +2,14 @@ package client
 
 import (
 	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -29,7 +35,7 @@ var (
 type httpClient struct {
 	u        *url.URL
 	cl       http.Client
-	signer   *httpsig.Signer
+	key      ed25519.PrivateKey
 	identity string
 }
 
@@ -64,7 +70,7 @@ func newHTTPClient(raw string, id Identity) (*httpClient, error) {
 	}
 
 	if id != nil {
-		client.signer = httpsig.NewSigner(id.Identity(), id.PrivateKey(), httpsig.Ed25519, []string{"(created)", "date", "threebot-id"})
+		client.key = id.PrivateKey()
 		client.identity = id.Identity()
 	}
 
@@ -78,13 +84,30 @@ func (c *httpClient) url(p ...string) string {
 	return b.String()
 }
 
-func (c *httpClient) sign(r *http.Request) error {
-	if c.signer == nil {
+// sign the request. Besides the headers required by the explorer, the
+// signature covers the request target, a random nonce so signatures are
+// never reused, and the digest of the body if any
+func (c *httpClient) sign(r *http.Request, body []byte) error {
+	if c.key == nil {
 		return nil
 	}
 
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return errors.Wrap(err, "failed to generate nonce")
+	}
+
 	r.Header.Set(http.CanonicalHeaderKey("threebot-id"), c.identity)
-	return c.signer.Sign(r)
+	r.Header.Set("Nonce", hex.EncodeToString(nonce))
+	headers := []string{"(created)", "date", "threebot-id", "(request-target)", "nonce"}
+
+	if body != nil {
+		sum := sha256.Sum256(body)
+		r.Header.Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(sum[:]))
+		headers = append(headers, "digest")
+	}
+
+	return httpsig.NewSigner(c.identity, c.key, httpsig.Ed25519, headers).Sign(r)
 }
 
 func (c *httpClient) process(response *http.Response, output interface{}, expect ...int) error {
@@ -144,12 +167,13 @@ func (c *httpClient) post(u string, input interface{}, output interface{}, expec
 		return nil, errors.Wrap(err, "failed to serialize request body")
 	}
 
+	body := buf.Bytes()
 	req, err := http.NewRequest(http.MethodPost, u, &buf)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create new HTTP request")
 	}
 
-	if err := c.sign(req); err != nil {
+	if err := c.sign(req, body); err != nil {
 		return nil, errors.Wrap(err, "failed to sign HTTP request")
 	}
 	response, err := c.cl.Do(req)
@@ -165,12 +189,13 @@ func (c *httpClient) put(u string, input interface{}, output interface{}, expect
 	if err := json.NewEncoder(&buf).Encode(input); err != nil {
 		return nil, errors.Wrap(err, "failed to serialize request body")
 	}
+	body := buf.Bytes()
 	req, err := http.NewRequest(http.MethodPut, u, &buf)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to build request")
 	}
 
-	if err := c.sign(req); err != nil {
+	if err := c.sign(req, body); err != nil {
 		return nil, errors.Wrap(err, "failed to sign HTTP request")
 	}
 
@@ -192,7 +217,7 @@ func (c *httpClient) get(u string, query url.Values, output interface{}, expect
 		return nil, errors.Wrap(err, "failed to create new HTTP request")
 	}
 
-	if err := c.sign(req); err != nil {
+	if err := c.sign(req, nil); err != nil {
 		return nil, errors.Wrap(err, "failed to sign HTTP request")
 	}
 
@@ -204,16 +229,60 @@ func (c *httpClient) get(u string, query url.Values, output interface{}, expect
 	return response, c.process(response, output, expect...)
 }
 
-func (c *httpClient) delete(u string, query url.Values, output interface{}, expect ...int) (*http.Response, error) {
+// download is like get, but copies the raw response body to w instead of
+// decoding it
+func (c *httpClient) download(u string, query url.Values, w io.Writer) error {
+	if len(query) > 0 {
+		u = fmt.Sprintf("%s?%s", u, query.Encode())
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to create new HTTP request")
+	}
+
+	if err := c.sign(req, nil); err != nil {
+		return errors.Wrap(err, "failed to sign HTTP request")
+	}
+
+	response, err := c.cl.Do(req)
+	if err != nil {
+		return errors.Wrapf(ErrRequestFailure, "reason: %s", err)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return c.process(response, nil, http.StatusOK)
+	}
+	defer response.Body.Close()
+
+	_, err = io.Copy(w, response.Body)
+	return errors.Wrap(err, "failed to read response body")
+}
+
+func (c *httpClient) delete(u string, query url.Values, input interface{}, output interface{}, expect ...int) (*http.Response, error) {
 	if len(query) > 0 {
 		u = fmt.Sprintf("%s?%s", u, query.Encode())
 	}
-	req, err := http.NewRequest(http.MethodDelete, u, nil)
+
+	var (
+		body   []byte
+		reader io.Reader
+	)
+	if input != nil {
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(input); err != nil {
+			return nil, errors.Wrap(err, "failed to serialize request body")
+		}
+		body = buf.Bytes()
+		reader = &buf
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, u, reader)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to build request")
 	}
 
-	if err := c.sign(req); err != nil {
+	if err := c.sign(req, body); err != nil {
 		return nil, errors.Wrap(err, "failed to sign HTTP request")
 	}
 
@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/threefoldtech/tfexplorer/models/generated/phonebook"
+	pbtypes "github.com/threefoldtech/tfexplorer/pkg/phonebook/types"
 	"github.com/threefoldtech/tfexplorer/schema"
 )
 
@@ -32,7 +34,8 @@ func (p *httpPhonebook) List(name, email string, page *Pager) (output []phoneboo
 		query.Set("email", email)
 	}
 
-	_, err = p.get(p.url("users"), query, &output, http.StatusOK)
+	response, err := p.get(p.url("users"), query, &output, http.StatusOK)
+	page.update(response)
 
 	return
 }
@@ -44,12 +47,23 @@ func (p *httpPhonebook) Get(id schema.ID) (user phonebook.User, err error) {
 
 // Validate the signature of this message for the user, signature and message are hex encoded
 func (p *httpPhonebook) Validate(id schema.ID, message, signature string) (bool, error) {
+	return p.validate(id, message, signature, 0)
+}
+
+// ValidateAt validates the signature with the key the user had at the given time
+func (p *httpPhonebook) ValidateAt(id schema.ID, message, signature string, at time.Time) (bool, error) {
+	return p.validate(id, message, signature, at.Unix())
+}
+
+func (p *httpPhonebook) validate(id schema.ID, message, signature string, at int64) (bool, error) {
 	var input struct {
-		S string `json:"signature"`
-		M string `json:"payload"`
+		S  string `json:"signature"`
+		M  string `json:"payload"`
+		At int64  `json:"at,omitempty"`
 	}
 	input.S = signature
 	input.M = message
+	input.At = at
 
 	var output struct {
 		V bool `json:"is_valid"`
@@ -62,3 +76,41 @@ func (p *httpPhonebook) Validate(id schema.ID, message, signature string) (bool,
 
 	return output.V, nil
 }
+
+func (p *httpPhonebook) RotateKey(id schema.ID, rotation pbtypes.KeyRotation) error {
+	_, err := p.post(p.url("users", fmt.Sprint(id), "rotate"), rotation, nil, http.StatusOK)
+	return err
+}
+
+func (p *httpPhonebook) Keys(id schema.ID) (keys []pbtypes.UserKey, err error) {
+	_, err = p.get(p.url("users", fmt.Sprint(id), "keys"), nil, &keys, http.StatusOK)
+	return
+}
+
+func (p *httpPhonebook) SetGuardians(id schema.ID, guardians []schema.ID, threshold int64) error {
+	input := struct {
+		Guardians []schema.ID `json:"guardians"`
+		Threshold int64       `json:"threshold"`
+	}{
+		Guardians: guardians,
+		Threshold: threshold,
+	}
+
+	_, err := p.put(p.url("users", fmt.Sprint(id), "guardians"), input, nil, http.StatusOK)
+	return err
+}
+
+func (p *httpPhonebook) TokenCreate(token pbtypes.Token) (result pbtypes.Token, err error) {
+	_, err = p.post(p.url("users", fmt.Sprint(token.UserID), "tokens"), token, &result, http.StatusCreated)
+	return
+}
+
+func (p *httpPhonebook) Tokens(id schema.ID) (tokens []pbtypes.Token, err error) {
+	_, err = p.get(p.url("users", fmt.Sprint(id), "tokens"), nil, &tokens, http.StatusOK)
+	return
+}
+
+func (p *httpPhonebook) TokenRevoke(id schema.ID, tokenID string) error {
+	_, err := p.delete(p.url("users", fmt.Sprint(id), "tokens", tokenID), nil, nil, nil, http.StatusNoContent)
+	return err
+}
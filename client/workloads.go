@@ -5,10 +5,12 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/stellar/go/support/errors"
 	"github.com/threefoldtech/tfexplorer/models/generated/workloads"
 	"github.com/threefoldtech/tfexplorer/pkg/capacity/types"
+	escrow "github.com/threefoldtech/tfexplorer/pkg/escrow/types"
 	wrklds "github.com/threefoldtech/tfexplorer/pkg/workloads"
 	wrkldstypes "github.com/threefoldtech/tfexplorer/pkg/workloads/types"
 	"github.com/threefoldtech/tfexplorer/schema"
@@ -33,7 +35,8 @@ func (w *httpWorkloads) List(nextAction *workloads.NextActionEnum, customerTid i
 	}
 	page.apply(query)
 
-	_, err = w.get(w.url("workloads"), query, &reservations, http.StatusOK)
+	response, err := w.get(w.url("workloads"), query, &reservations, http.StatusOK)
+	page.update(response)
 	return
 }
 
@@ -42,6 +45,34 @@ func (w *httpWorkloads) Get(id schema.ID) (workload workloads.Workloader, err er
 	return
 }
 
+func (w *httpWorkloads) Search(filter WorkloadFilter, page *Pager) (result []workloads.Workloader, err error) {
+	query := url.Values{}
+	filter.Apply(query)
+	page.apply(query)
+
+	var list []wrkldstypes.WorkloaderType
+	response, err := w.get(w.url("reservations", "workloads"), query, &list, http.StatusOK)
+	page.update(response)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, workload := range list {
+		result = append(result, workload.Workloader)
+	}
+
+	return result, nil
+}
+
+func (w *httpWorkloads) BulkDelete(request wrkldstypes.BulkDelete) (results []wrkldstypes.BulkDeleteResult, err error) {
+	var response wrklds.BulkDeleteResponse
+	if _, err = w.post(w.url("reservations", "workloads", "delete"), request, &response, http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	return response.Results, nil
+}
+
 func (w *httpWorkloads) SignProvision(id schema.ID, user schema.ID, signature string) error {
 	_, err := w.post(
 		w.url("workloads", fmt.Sprint(id), "sign", "provision"),
@@ -70,6 +101,104 @@ func (w *httpWorkloads) SignDelete(id schema.ID, user schema.ID, signature strin
 	return err
 }
 
+func (w *httpWorkloads) Update(id schema.ID, workload workloads.Workloader) (resp wrklds.ReservationCreateResponse, err error) {
+	_, err = w.post(w.url("reservations", "workloads", fmt.Sprint(id), "update"), workload, &resp, http.StatusCreated)
+	return
+}
+
+func (w *httpWorkloads) Versions(id schema.ID) (versions []workloads.Workloader, err error) {
+	var list []wrkldstypes.WorkloaderType
+	if _, err = w.get(w.url("reservations", "workloads", fmt.Sprint(id), "versions"), nil, &list, http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	for _, workload := range list {
+		versions = append(versions, workload.Workloader)
+	}
+
+	return versions, nil
+}
+
+func (w *httpWorkloads) Events(id schema.ID) (events []wrkldstypes.WorkloadEvent, err error) {
+	_, err = w.get(w.url("reservations", "workloads", fmt.Sprint(id), "events"), nil, &events, http.StatusOK)
+	return
+}
+
+func (w *httpWorkloads) SetLabels(id schema.ID, update wrkldstypes.LabelsUpdate) (workload workloads.Workloader, err error) {
+	var result wrkldstypes.WorkloaderType
+	if _, err = w.put(w.url("reservations", "workloads", fmt.Sprint(id), "labels"), update, &result, http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	return result.Workloader, nil
+}
+
+func (w *httpWorkloads) Extend(id schema.ID, extension wrkldstypes.ExpiryExtension) (workload workloads.Workloader, err error) {
+	var result wrkldstypes.WorkloaderType
+	if _, err = w.put(w.url("reservations", "workloads", fmt.Sprint(id), "expiry"), extension, &result, http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	return result.Workloader, nil
+}
+
+func (w *httpWorkloads) GroupCreate(group wrkldstypes.DeploymentGroup) (resp wrklds.DeploymentGroupCreateResponse, err error) {
+	_, err = w.post(w.url("reservations", "groups"), group, &resp, http.StatusCreated)
+	return
+}
+
+func (w *httpWorkloads) GroupGet(id schema.ID) (group wrkldstypes.DeploymentGroup, err error) {
+	_, err = w.get(w.url("reservations", "groups", fmt.Sprint(id)), nil, &group, http.StatusOK)
+	return
+}
+
+func (w *httpWorkloads) Approvals(kind wrkldstypes.ApprovalKind) (approvals []wrkldstypes.Approval, err error) {
+	query := url.Values{}
+	if kind != "" {
+		query.Set("kind", string(kind))
+	}
+
+	_, err = w.get(w.url("reservations", "approvals"), query, &approvals, http.StatusOK)
+	return
+}
+
+func (w *httpWorkloads) Approve(id schema.ID, request wrkldstypes.ApprovalRequest) (approval wrkldstypes.Approval, err error) {
+	_, err = w.post(w.url("reservations", "approvals", fmt.Sprint(id), "approve"), request, &approval, http.StatusOK)
+	return
+}
+
+func (w *httpWorkloads) Reject(id schema.ID, request wrkldstypes.ApprovalRequest) (approval wrkldstypes.Approval, err error) {
+	_, err = w.post(w.url("reservations", "approvals", fmt.Sprint(id), "reject"), request, &approval, http.StatusOK)
+	return
+}
+
+// ConversionList returns the workloads the legacy reservations of the user
+// are converted to, they need to be signed by the user and posted back with
+// ConversionPost. Nothing is returned once the reservations are converted
+func (w *httpWorkloads) ConversionList() ([]workloads.Workloader, error) {
+	var list []wrkldstypes.WorkloaderType
+	response, err := w.get(w.url("reservations", "convert"), nil, &list, http.StatusOK, http.StatusNoContent)
+	if response != nil && response.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	output := make([]workloads.Workloader, len(list))
+	for i, w := range list {
+		output[i] = w.Workloader
+	}
+
+	return output, nil
+}
+
+func (w *httpWorkloads) ConversionPost(workloads []workloads.Workloader) error {
+	_, err := w.post(w.url("reservations", "convert"), workloads, nil, http.StatusOK)
+	return err
+}
+
 func (w *httpWorkloads) NodeWorkloads(nodeID string, from uint64) ([]workloads.Workloader, uint64, error) {
 	query := url.Values{}
 	query.Set("from", fmt.Sprint(from))
@@ -123,10 +252,27 @@ func (w *httpWorkloads) NodeWorkloadPutResult(nodeID, gwid string, result worklo
 }
 
 func (w *httpWorkloads) NodeWorkloadPutDeleted(nodeID, gwid string) error {
-	_, err := w.delete(w.url("reservations", "nodes", nodeID, "workloads", gwid), nil, nil, http.StatusOK)
+	if w.key == nil {
+		return fmt.Errorf("the deletion of a workload must be signed by the node")
+	}
+
+	report := wrkldstypes.DeletedReport{
+		WorkloadId: gwid,
+		Epoch:      schema.Date{Time: time.Now()},
+	}
+	if err := report.Sign(w.key); err != nil {
+		return errors.Wrap(err, "failed to sign the deletion report")
+	}
+
+	_, err := w.delete(w.url("reservations", "nodes", nodeID, "workloads", gwid), nil, report, nil, http.StatusOK)
 	return err
 }
 
+func (w *httpWorkloads) PoolSetLabels(poolID schema.ID, update wrkldstypes.LabelsUpdate) (result types.Pool, err error) {
+	_, err = w.put(w.url("reservations", "pools", fmt.Sprint(poolID), "labels"), update, &result, http.StatusOK)
+	return
+}
+
 func (w *httpWorkloads) PoolCreate(reservation types.Reservation) (resp wrklds.CapacityPoolCreateResponse, err error) {
 	_, err = w.post(w.url("reservations", "pools"), reservation, &resp, http.StatusCreated)
 	return
@@ -142,6 +288,46 @@ func (w *httpWorkloads) PoolGet(poolID string) (result types.Pool, err error) {
 	return pool, nil
 }
 
+func (w *httpWorkloads) PoolReceipts(page *Pager) (receipts []escrow.CapacityReceipt, err error) {
+	query := url.Values{}
+	page.apply(query)
+	response, err := w.get(w.url("reservations", "pools", "receipts"), query, &receipts, http.StatusOK)
+	page.update(response)
+	return
+}
+
+func (w *httpWorkloads) PoolReceiptGet(reservationID schema.ID) (receipt escrow.CapacityReceipt, err error) {
+	_, err = w.get(w.url("reservations", "pools", "receipts", fmt.Sprint(reservationID)), nil, &receipt, http.StatusOK)
+	return
+}
+
+func (w *httpWorkloads) PricingRules(farmID schema.ID) (rules []escrow.PricingRule, err error) {
+	query := url.Values{}
+	if farmID != 0 {
+		query.Set("farm", fmt.Sprint(farmID))
+	}
+	_, err = w.get(w.url("pricing", "rules"), query, &rules, http.StatusOK)
+	return
+}
+
+func (w *httpWorkloads) PricingRuleCreate(rule escrow.PricingRule) (result escrow.PricingRule, err error) {
+	_, err = w.post(w.url("pricing", "rules"), rule, &result, http.StatusCreated)
+	return
+}
+
+func (w *httpWorkloads) PricingRuleSetEnabled(id schema.ID, enabled bool) error {
+	update := struct {
+		Enabled bool `json:"enabled"`
+	}{Enabled: enabled}
+	_, err := w.put(w.url("pricing", "rules", fmt.Sprint(id)), update, nil, http.StatusOK)
+	return err
+}
+
+func (w *httpWorkloads) PricingRuleDelete(id schema.ID) error {
+	_, err := w.delete(w.url("pricing", "rules", fmt.Sprint(id)), nil, nil, nil, http.StatusNoContent)
+	return err
+}
+
 func (w *httpWorkloads) PoolsGetByOwner(ownerID string) (result []types.Pool, err error) {
 	var pools []types.Pool
 	_, err = w.get(w.url("reservations", "pools", "owner", ownerID), nil, &pools, http.StatusOK)
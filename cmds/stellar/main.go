@@ -9,6 +9,27 @@ import (
 	"github.com/urfave/cli"
 )
 
+var (
+	mongoFlags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "mongo",
+			Usage: "connection string to the explorer mongo database",
+			Value: "mongodb://localhost:27017",
+		},
+		cli.StringFlag{
+			Name:  "name",
+			Usage: "explorer database name",
+			Value: "explorer",
+		},
+	}
+
+	planFlag = cli.StringFlag{
+		Name:     "plan",
+		Usage:    "Recovery plan file",
+		Required: true,
+	}
+)
+
 func main() {
 	app := cli.NewApp()
 	app.Usage = "Create and sign Stellar multisig transactions"
@@ -90,6 +111,102 @@ func main() {
 			},
 			Action: signAndSubmit,
 		},
+		{
+			Name:  "recover",
+			Usage: "Recover the funds of all the escrow accounts with the backup signers",
+			Subcommands: []cli.Command{
+				{
+					Name:  "export",
+					Usage: "Export the escrow accounts from the explorer database",
+					Flags: append(mongoFlags,
+						cli.StringFlag{
+							Name:     "output",
+							Usage:    "File to write the export to",
+							Required: true,
+						},
+					),
+					Action: recoveryExport,
+				},
+				{
+					Name:  "plan",
+					Usage: "Create the recovery transactions for every escrow account with a balance, and print a dry run report",
+					Flags: append(mongoFlags,
+						cli.StringFlag{
+							Name:  "export",
+							Usage: "Escrow export to use instead of the explorer database",
+						},
+						cli.StringFlag{
+							Name:     "network",
+							Usage:    "Stellar network type",
+							Required: true,
+						},
+						cli.StringFlag{
+							Name:  "horizon",
+							Usage: "Horizon server URL to use instead of the default of the network",
+						},
+						cli.StringFlag{
+							Name:     "source",
+							Usage:    "Address of the account paying the transaction fees, it must sign every transaction",
+							Required: true,
+						},
+						cli.StringFlag{
+							Name:  "mode",
+							Usage: "sweep sends all the funds to --destination, refund sends the funds of open reservations back to the customers",
+							Value: modeRefund,
+						},
+						cli.StringFlag{
+							Name:  "destination",
+							Usage: "Destination address of a sweep",
+						},
+						cli.IntFlag{
+							Name:  "batch",
+							Usage: "Maximum amount of payments per transaction",
+							Value: maxOperationsPerTx,
+						},
+						cli.StringFlag{
+							Name:  "output",
+							Usage: "File to write the plan to, if not set only the report is printed",
+						},
+					),
+					Action: recoveryPlanCreate,
+				},
+				{
+					Name:   "report",
+					Usage:  "Print the payments and the signatures of a recovery plan",
+					Flags:  []cli.Flag{planFlag},
+					Action: recoveryPlanReport,
+				},
+				{
+					Name:  "sign",
+					Usage: "Sign all the transactions of a recovery plan, this does not need network access",
+					Flags: []cli.Flag{
+						planFlag,
+						cli.StringFlag{
+							Name:     "seed",
+							Usage:    "Stellar secret key of a backup signer or of the fee source",
+							Required: true,
+						},
+					},
+					Action: recoveryPlanSign,
+				},
+				{
+					Name:  "submit",
+					Usage: "Submit the signed transactions of a recovery plan in order",
+					Flags: []cli.Flag{
+						planFlag,
+						cli.StringFlag{
+							Name:  "horizon",
+							Usage: "Horizon server URL to use instead of the default of the network",
+						},
+						cli.BoolFlag{
+							Name:  "dry-run",
+							Usage: "Only print the transactions which would be submitted",
+						},
+					},
+					Action: recoveryPlanSubmit,
+				},
+			},
+		},
 	}
 
 	err := app.Run(os.Args)
@@ -0,0 +1,575 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/clients/horizonclient"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+	escrowtypes "github.com/threefoldtech/tfexplorer/pkg/escrow/types"
+	"github.com/threefoldtech/tfexplorer/pkg/stellar"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"github.com/urfave/cli"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// recovery modes
+	modeSweep  = "sweep"
+	modeRefund = "refund"
+
+	// maximum amount of operations in a stellar transaction
+	maxOperationsPerTx = 100
+
+	recoveryBaseFee = txnbuild.MinBaseFee * 2
+)
+
+type (
+	// escrowExport holds the content of the escrow collections needed to
+	// recover the funds of the escrow accounts. The encrypted secrets of the
+	// accounts are not exported since they can't be used without the explorer seed.
+	escrowExport struct {
+		Addresses []exportedAddress `json:"addresses"`
+		Escrows   []exportedEscrow  `json:"escrows"`
+	}
+
+	exportedAddress struct {
+		CustomerTid int64  `json:"customer_tid"`
+		Address     string `json:"address"`
+	}
+
+	// exportedEscrow is a capacity reservation which was not released nor
+	// canceled, and for which the customer might need a refund
+	exportedEscrow struct {
+		ReservationID schema.ID     `json:"reservation_id"`
+		Address       string        `json:"address"`
+		Asset         stellar.Asset `json:"asset"`
+		Paid          bool          `json:"paid"`
+		// Batches maps the sequence of batched payout transactions to the
+		// indices of the operations paying out this reservation
+		Batches map[string][]int `json:"batches,omitempty"`
+	}
+
+	// recoveryPlan is the list of transactions which recover the funds of
+	// the escrow accounts. It is written to a file so signatures can be
+	// collected offline, one backup signer after the other.
+	recoveryPlan struct {
+		Network string    `json:"network"`
+		Mode    string    `json:"mode"`
+		Source  string    `json:"source"`
+		Created time.Time `json:"created"`
+		// Threshold is the amount of backup signer signatures required on
+		// every transaction
+		Threshold    int                   `json:"threshold"`
+		Payments     []recoveryPayment     `json:"payments"`
+		Transactions []recoveryTransaction `json:"transactions"`
+	}
+
+	recoveryPayment struct {
+		From          string        `json:"from"`
+		To            string        `json:"to"`
+		Asset         stellar.Asset `json:"asset"`
+		Amount        string        `json:"amount"`
+		CustomerTid   int64         `json:"customer_tid"`
+		ReservationID schema.ID     `json:"reservation_id,omitempty"`
+		// Transaction is the index of the transaction doing the payment
+		Transaction int `json:"transaction"`
+	}
+
+	recoveryTransaction struct {
+		Envelope  string   `json:"envelope"`
+		Signers   []string `json:"signers"`
+		Hash      string   `json:"hash,omitempty"`
+		Submitted bool     `json:"submitted"`
+	}
+)
+
+func connectMongo(c *cli.Context) (*mongo.Database, func(), error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	client, err := mongo.NewClient(options.Client().ApplyURI(c.String("mongo")))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := client.Connect(ctx); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to connect to database")
+	}
+
+	disconnect := func() {
+		if err := client.Disconnect(context.Background()); err != nil {
+			log.Error().Err(err).Msg("failed to disconnect from database")
+		}
+	}
+
+	return client.Database(c.String("name")), disconnect, nil
+}
+
+// exportEscrows reads the escrow collections of the explorer database
+func exportEscrows(ctx context.Context, db *mongo.Database) (escrowExport, error) {
+	var export escrowExport
+
+	cur, err := db.Collection(escrowtypes.AddressCollection).Find(ctx, bson.M{})
+	if err != nil {
+		return export, errors.Wrap(err, "failed to list escrow addresses")
+	}
+
+	var addresses []escrowtypes.CustomerAddress
+	if err := cur.All(ctx, &addresses); err != nil {
+		return export, errors.Wrap(err, "failed to decode escrow addresses")
+	}
+
+	for _, a := range addresses {
+		export.Addresses = append(export.Addresses, exportedAddress{CustomerTid: a.CustomerTID, Address: a.Address})
+	}
+
+	cur, err = db.Collection(escrowtypes.CapacityEscrowCollection).Find(ctx, bson.M{"released": false, "canceled": false})
+	if err != nil {
+		return export, errors.Wrap(err, "failed to list capacity escrows")
+	}
+
+	var escrows []escrowtypes.CapacityReservationPaymentInformation
+	if err := cur.All(ctx, &escrows); err != nil {
+		return export, errors.Wrap(err, "failed to decode capacity escrows")
+	}
+
+	for _, e := range escrows {
+		infos, err := escrowtypes.CapacityMemoTextInfoGet(ctx, db, escrowtypes.CapacityReservationMemo(e.ReservationID))
+		if err != nil {
+			return export, err
+		}
+
+		exported := exportedEscrow{
+			ReservationID: e.ReservationID,
+			Address:       e.Address,
+			Asset:         e.Asset,
+			Paid:          e.Paid,
+		}
+		for _, info := range infos {
+			if exported.Batches == nil {
+				exported.Batches = make(map[string][]int)
+			}
+			exported.Batches[info.TxSequence] = info.OperationIDs
+		}
+		export.Escrows = append(export.Escrows, exported)
+	}
+
+	return export, nil
+}
+
+// loadEscrows loads the escrows from the export file if given, or else from the database
+func loadEscrows(c *cli.Context) (escrowExport, error) {
+	if path := c.String("export"); path != "" {
+		var export escrowExport
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return export, errors.Wrap(err, "failed to read escrow export")
+		}
+
+		return export, errors.Wrap(json.Unmarshal(data, &export), "failed to decode escrow export")
+	}
+
+	db, disconnect, err := connectMongo(c)
+	if err != nil {
+		return escrowExport{}, err
+	}
+	defer disconnect()
+
+	return exportEscrows(context.Background(), db)
+}
+
+func recoveryExport(c *cli.Context) error {
+	db, disconnect, err := connectMongo(c)
+	if err != nil {
+		return err
+	}
+	defer disconnect()
+
+	export, err := exportEscrows(context.Background(), db)
+	if err != nil {
+		return err
+	}
+
+	if err := writeJSON(c.String("output"), export); err != nil {
+		return err
+	}
+
+	fmt.Printf("exported %d escrow accounts and %d open capacity escrows to %s\n", len(export.Addresses), len(export.Escrows), c.String("output"))
+	return nil
+}
+
+func recoveryPlanCreate(c *cli.Context) error {
+	mode := c.String("mode")
+	destination := c.String("destination")
+
+	switch mode {
+	case modeSweep:
+		if _, err := keypair.ParseAddress(destination); err != nil {
+			return errors.Wrap(err, "sweep requires a valid --destination address")
+		}
+	case modeRefund:
+	default:
+		return fmt.Errorf("invalid mode '%s', supported modes are %s and %s", mode, modeSweep, modeRefund)
+	}
+
+	batch := c.Int("batch")
+	if batch <= 0 || batch > maxOperationsPerTx {
+		batch = maxOperationsPerTx
+	}
+
+	wallet, err := stellar.New("", c.String("network"), nil, c.String("horizon"))
+	if err != nil {
+		return err
+	}
+
+	export, err := loadEscrows(c)
+	if err != nil {
+		return err
+	}
+
+	source, err := wallet.GetAccountDetails(c.String("source"))
+	if err != nil {
+		return errors.Wrap(err, "failed to load the source account paying the transaction fees")
+	}
+
+	plan := recoveryPlan{
+		Network: c.String("network"),
+		Mode:    mode,
+		Source:  source.AccountID,
+		Created: time.Now(),
+	}
+
+	for _, address := range export.Addresses {
+		account, err := wallet.GetAccountDetails(address.Address)
+		if err != nil {
+			log.Error().Err(err).Str("address", address.Address).Msg("skipping escrow account")
+			continue
+		}
+
+		if threshold := int(account.Thresholds.MedThreshold); threshold > plan.Threshold {
+			plan.Threshold = threshold
+		}
+
+		// on chain balances of the account, payments are never planned above it
+		balances := make(map[stellar.Asset]xdr.Int64)
+		for _, b := range account.Balances {
+			if b.Type == "native" {
+				// lumens are needed for the account reserves
+				continue
+			}
+			value, err := amount.Parse(b.Balance)
+			if err != nil || value == 0 {
+				continue
+			}
+			balances[stellar.Asset(fmt.Sprintf("%s:%s", b.Code, b.Issuer))] = value
+		}
+
+		pay := func(to string, asset stellar.Asset, value xdr.Int64, reservation schema.ID) {
+			if value > balances[asset] {
+				value = balances[asset]
+			}
+			if value <= 0 {
+				return
+			}
+			balances[asset] -= value
+			plan.Payments = append(plan.Payments, recoveryPayment{
+				From:          address.Address,
+				To:            to,
+				Asset:         asset,
+				Amount:        amount.String(value),
+				CustomerTid:   address.CustomerTid,
+				ReservationID: reservation,
+			})
+		}
+
+		if mode == modeSweep {
+			for asset, value := range balances {
+				pay(destination, asset, value, 0)
+			}
+			continue
+		}
+
+		for _, escrow := range export.Escrows {
+			if escrow.Address != address.Address {
+				continue
+			}
+
+			batches := &stellar.BatchTransactionsInfo{Ops: escrow.Batches}
+			if batches.Ops == nil {
+				batches.Ops = make(map[string][]int)
+			}
+			value, funders, err := wallet.GetBalance(escrow.Address, escrowtypes.CapacityReservationMemo(escrow.ReservationID), escrow.Asset, batches)
+			if err != nil {
+				log.Error().Err(err).Int64("reservation", int64(escrow.ReservationID)).Msg("skipping capacity escrow")
+				continue
+			}
+			if len(funders) == 0 {
+				continue
+			}
+			pay(funders[0], escrow.Asset, value, escrow.ReservationID)
+		}
+	}
+
+	if err := plan.build(&source, wallet.GetNetworkPassPhrase(), batch); err != nil {
+		return err
+	}
+
+	plan.report(os.Stdout)
+
+	output := c.String("output")
+	if output == "" {
+		fmt.Println("\ndry run: no plan written, use --output to write the plan to a file")
+		return nil
+	}
+
+	return writeJSON(output, plan)
+}
+
+// build the transactions of the plan. The transactions use consecutive
+// sequence numbers of the source account, so they must be submitted in order.
+func (p *recoveryPlan) build(source txnbuild.Account, passphrase string, batch int) error {
+	// keep the payments of an escrow account together so an account signs
+	// as few transactions as possible
+	sort.SliceStable(p.Payments, func(i, j int) bool {
+		return p.Payments[i].From < p.Payments[j].From
+	})
+
+	for start := 0; start < len(p.Payments); start += batch {
+		end := start + batch
+		if end > len(p.Payments) {
+			end = len(p.Payments)
+		}
+
+		var ops []txnbuild.Operation
+		for i := start; i < end; i++ {
+			payment := &p.Payments[i]
+			payment.Transaction = len(p.Transactions)
+			ops = append(ops, &txnbuild.Payment{
+				Destination: payment.To,
+				Amount:      payment.Amount,
+				Asset: txnbuild.CreditAsset{
+					Code:   payment.Asset.Code(),
+					Issuer: payment.Asset.Issuer(),
+				},
+				SourceAccount: &txnbuild.SimpleAccount{AccountID: payment.From},
+			})
+		}
+
+		// signatures are collected offline, so the transactions can't expire
+		tx, err := txnbuild.NewTransaction(txnbuild.TransactionParams{
+			SourceAccount:        source,
+			IncrementSequenceNum: true,
+			Operations:           ops,
+			BaseFee:              recoveryBaseFee,
+			Timebounds:           txnbuild.NewInfiniteTimeout(),
+		})
+		if err != nil {
+			return errors.Wrap(err, "failed to build transaction")
+		}
+
+		envelope, err := tx.Base64()
+		if err != nil {
+			return errors.Wrap(err, "failed to encode transaction")
+		}
+
+		p.Transactions = append(p.Transactions, recoveryTransaction{Envelope: envelope})
+	}
+
+	return nil
+}
+
+// report prints the payments and the signing status of the plan
+func (p *recoveryPlan) report(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TX\tFROM\tTO\tAMOUNT\tASSET\tCUSTOMER\tRESERVATION")
+
+	totals := make(map[string]xdr.Int64)
+	for _, payment := range p.Payments {
+		reservation := "-"
+		if payment.ReservationID != 0 {
+			reservation = fmt.Sprint(payment.ReservationID)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%d\t%s\n", payment.Transaction, payment.From, payment.To, payment.Amount, payment.Asset.Code(), payment.CustomerTid, reservation)
+		totals[payment.Asset.Code()] += amount.MustParse(payment.Amount)
+	}
+	w.Flush()
+
+	fmt.Fprintf(out, "\nmode: %s, network: %s, fee source: %s\n", p.Mode, p.Network, p.Source)
+	fmt.Fprintf(out, "%d payments in %d transactions\n", len(p.Payments), len(p.Transactions))
+	for code, total := range totals {
+		fmt.Fprintf(out, "total %s: %s\n", code, amount.String(total))
+	}
+	fmt.Fprintf(out, "every transaction needs %d backup signer signatures and the signature of the fee source\n", p.Threshold)
+
+	for i, tx := range p.Transactions {
+		state := fmt.Sprintf("%d signatures", len(tx.Signers))
+		if tx.Submitted {
+			state = fmt.Sprintf("submitted (%s)", tx.Hash)
+		}
+		fmt.Fprintf(out, "transaction %d: %s\n", i, state)
+	}
+}
+
+func recoveryPlanSign(c *cli.Context) error {
+	kp, err := keypair.ParseFull(c.String("seed"))
+	if err != nil {
+		return err
+	}
+
+	plan, err := readPlan(c.String("plan"))
+	if err != nil {
+		return err
+	}
+
+	// the wallet is only used for the network passphrase, so signing works offline
+	wallet, err := stellar.New("", plan.Network, nil, "")
+	if err != nil {
+		return err
+	}
+
+	signed := 0
+	for i := range plan.Transactions {
+		tx := &plan.Transactions[i]
+		if tx.Submitted || contains(tx.Signers, kp.Address()) {
+			continue
+		}
+
+		envelope, err := signEnvelope(tx.Envelope, wallet.GetNetworkPassPhrase(), kp)
+		if err != nil {
+			return errors.Wrapf(err, "failed to sign transaction %d", i)
+		}
+
+		tx.Envelope = envelope
+		tx.Signers = append(tx.Signers, kp.Address())
+		signed++
+	}
+
+	if err := writeJSON(c.String("plan"), plan); err != nil {
+		return err
+	}
+
+	fmt.Printf("signed %d transactions with %s\n", signed, kp.Address())
+	return nil
+}
+
+func recoveryPlanSubmit(c *cli.Context) error {
+	path := c.String("plan")
+	plan, err := readPlan(path)
+	if err != nil {
+		return err
+	}
+
+	wallet, err := stellar.New("", plan.Network, nil, c.String("horizon"))
+	if err != nil {
+		return err
+	}
+
+	client, err := wallet.GetHorizonClient()
+	if err != nil {
+		return errors.Wrap(err, "failed to get horizon client")
+	}
+
+	for i := range plan.Transactions {
+		tx := &plan.Transactions[i]
+		if tx.Submitted {
+			continue
+		}
+
+		if c.Bool("dry-run") {
+			fmt.Printf("transaction %d: %d signatures, would be submitted\n", i, len(tx.Signers))
+			continue
+		}
+
+		result, err := client.SubmitTransactionXDR(tx.Envelope)
+		if err != nil {
+			if hError, ok := err.(*horizonclient.Error); ok {
+				log.Debug().Msgf("%+v", hError.Problem.Extras)
+				err = hError.Problem
+			}
+			// the next transactions use the following sequence numbers of the
+			// source account, so they would fail as well
+			return errors.Wrapf(err, "failed to submit transaction %d, perhaps more signatures are required", i)
+		}
+
+		tx.Hash = result.Hash
+		tx.Submitted = true
+		if err := writeJSON(path, plan); err != nil {
+			return err
+		}
+		fmt.Printf("transaction %d: submitted (%s)\n", i, result.Hash)
+	}
+
+	return nil
+}
+
+func recoveryPlanReport(c *cli.Context) error {
+	plan, err := readPlan(c.String("plan"))
+	if err != nil {
+		return err
+	}
+
+	plan.report(os.Stdout)
+	return nil
+}
+
+func signEnvelope(envelope, passphrase string, kp *keypair.Full) (string, error) {
+	generic, err := txnbuild.TransactionFromXDR(envelope)
+	if err != nil {
+		return "", errors.Wrap(err, "failed parse xdr to a transaction")
+	}
+
+	tx, ok := generic.Transaction()
+	if !ok {
+		return "", errors.New("failed to unwrap transaction")
+	}
+
+	tx, err = tx.Sign(passphrase, kp)
+	if err != nil {
+		return "", err
+	}
+
+	return tx.Base64()
+}
+
+func readPlan(path string) (recoveryPlan, error) {
+	var plan recoveryPlan
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return plan, errors.Wrap(err, "failed to read recovery plan")
+	}
+
+	return plan, errors.Wrap(json.Unmarshal(data, &plan), "failed to decode recovery plan")
+}
+
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrapf(ioutil.WriteFile(path, data, 0600), "failed to write %s", path)
+}
+
+func contains(l []string, s string) bool {
+	for _, e := range l {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
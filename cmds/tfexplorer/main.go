@@ -27,6 +27,7 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/rakyll/statik/fs"
 	"github.com/threefoldtech/tfexplorer/config"
+	generated "github.com/threefoldtech/tfexplorer/models/generated/directory"
 	"github.com/threefoldtech/tfexplorer/mw"
 	"github.com/threefoldtech/tfexplorer/pkg/capacity"
 	capacitydb "github.com/threefoldtech/tfexplorer/pkg/capacity/types"
@@ -34,6 +35,7 @@ import (
 	"github.com/threefoldtech/tfexplorer/pkg/escrow"
 	escrowdb "github.com/threefoldtech/tfexplorer/pkg/escrow/types"
 	"github.com/threefoldtech/tfexplorer/pkg/gridnetworks"
+	"github.com/threefoldtech/tfexplorer/pkg/openapi"
 	"github.com/threefoldtech/tfexplorer/pkg/phonebook"
 	"github.com/threefoldtech/tfexplorer/pkg/stellar"
 	"github.com/threefoldtech/tfexplorer/pkg/workloads"
@@ -74,6 +76,15 @@ func main() {
 	flag.BoolVar(&f.enablePProf, "pprof", false, "enable pprof")
 	flag.Int64Var(&f.prometheusPort, "prometheus-port", 3200, "port the run the prometheus server on")
 	flag.StringVar(&config.Config.HorizonURL, "horizon", "", "Horizon server URL to communicate with")
+	flag.Var(&config.Config.Admins, "admins", "comma separated list of the threebot ids allowed to use the admin endpoints")
+	flag.DurationVar(&config.Config.AuthMaxSkew, "auth-max-skew", config.Config.AuthMaxSkew, "maximum age of a signed request, signatures are remembered for this duration to refuse replays. 0 disables the check")
+	flag.BoolVar(&config.Config.AuthRequireDigest, "auth-require-digest", false, "require signed requests with a body to sign a Digest header of the body")
+	flag.BoolVar(&config.Config.RequireNodeSignatures, "require-node-signatures", false, "refuse the unsigned workload results, deletion reports and gateway registrations of the nodes")
+	flag.BoolVar(&config.Config.DisableLegacy, "disable-legacy", false, "disable the legacy reservations, the explorer refuses to start while active reservations are not converted to workloads")
+	flag.DurationVar(&config.Config.FetchTimeout, "fetch-timeout", config.Config.FetchTimeout, "flag the workloads not fetched by their node in this time after they are queued, while the node polls its workloads. 0 disables the check")
+	flag.Var(&config.Config.RateLimits, "rate-limits", "request budgets per signer or client IP of the route groups phonebook, directory and workloads, in requests per second and burst, e.g. workloads=10:30,directory=20:50. a rate of 0 disables the limit")
+	flag.BoolVar(&config.Config.TrustForwardedFor, "trust-forwarded-for", false, "use the X-Real-Ip and X-Forwarded-For headers as client IP, only enable when running behind a reverse proxy")
+	flag.Var(&config.Config.FarmPriceBounds, "farm-price-bounds", "bounds of the default prices farmers can set on their farm, in dollar per month, e.g. cu=5:20,su=4:16,ipv4u=3:12")
 
 	flag.Parse()
 
@@ -163,6 +174,10 @@ func createServer(f flags, client *mongo.Client, dropEscrowData bool) (*http.Ser
 	prom.Instrument()
 	router.Use(db.Middleware)
 
+	if err := mw.SetupUsedSignatures(context.Background(), db.Database()); err != nil {
+		log.Fatal().Err(err).Msg("failed to create used signatures index")
+	}
+
 	router.Path("/metrics").Handler(promhttp.Handler()).Name("metrics")
 	router.PathPrefix("/public/").Handler(http.StripPrefix("/public/", http.FileServer(statikFS)))
 
@@ -212,7 +227,13 @@ func createServer(f flags, client *mongo.Client, dropEscrowData bool) (*http.Ser
 		router.PathPrefix("/debug/").Handler(http.DefaultServeMux)
 	}
 
-	if err := directory.Setup(router, db.Database()); err != nil {
+	defaultPrice := generated.NodeCloudUnitPrice{
+		Currency: generated.PriceCurrencyUSD,
+		CU:       escrow.CuPriceDollarMonth,
+		SU:       escrow.SuPriceDollarMonth,
+		IPv4U:    escrow.IP4uPriceDollarMonth,
+	}
+	if err := directory.Setup(router, db.Database(), defaultPrice); err != nil {
 		log.Fatal().Err(err).Msg("failed to register directory package")
 	}
 
@@ -227,15 +248,27 @@ func createServer(f flags, client *mongo.Client, dropEscrowData bool) (*http.Ser
 	planner := capacity.NewNaivePlanner(e, db.Database())
 	go planner.Run(context.Background())
 	if err = workloads.Setup(router, db.Database(), gridnetworks.GridNetwork(config.Config.TFNetwork), e, planner); err != nil {
-		log.Error().Err(err).Msg("failed to register workloads package")
+		log.Fatal().Err(err).Msg("failed to register workloads package")
+	}
+	go workloads.WatchDeliveries(context.Background(), db.Database())
+
+	spec, err := openapi.Generate(router, openapi.Info{
+		Title:   "TF Explorer",
+		Version: version.Current().Short(),
+	}, "/api/v1", directory.Operations, phonebook.Operations, workloads.Operations)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to generate the openapi document")
 	}
+	router.Path("/api/v1/openapi.json").HandlerFunc(mw.AsHandlerFunc(func(r *http.Request) (interface{}, mw.Response) {
+		return spec, nil
+	})).Methods(http.MethodGet).Name("openapi")
 
 	log.Printf("start on %s\n", f.listen)
 	r := handlers.LoggingHandler(os.Stderr, router)
 	r = handlers.CORS(
 		handlers.AllowedOrigins([]string{"*"}),
 		handlers.AllowedHeaders([]string{"Content-Type"}),
-		handlers.ExposedHeaders([]string{"Pages"}),
+		handlers.ExposedHeaders([]string{"Pages", "Next-Cursor"}),
 	)(r)
 
 	return &http.Server{
@@ -153,6 +153,38 @@ You can specify multime time the ip and gw flag to configure multiple IP on the
 				},
 			},
 		},
+		{
+			Name:  "payouts",
+			Usage: "List or export the payout statements of a farm",
+			Flags: []cli.Flag{
+				cli.Int64Flag{
+					Name:     "farm",
+					Usage:    "farm ID",
+					Required: true,
+				},
+				cli.StringFlag{
+					Name:  "from",
+					Usage: "first month of the statements, in the format YYYY-MM",
+				},
+				cli.StringFlag{
+					Name:  "to",
+					Usage: "last month of the statements, in the format YYYY-MM",
+				},
+				cli.BoolFlag{
+					Name:  "monthly",
+					Usage: "show the monthly totals instead of every statement",
+				},
+				cli.StringFlag{
+					Name:  "format",
+					Usage: "export the statements as a document instead of printing them. supported formats are csv and pdf",
+				},
+				cli.StringFlag{
+					Name:  "output, o",
+					Usage: "file to write the exported document to, default to stdout",
+				},
+			},
+			Action: listPayouts,
+		},
 		{
 			Name:  "nodes",
 			Usage: "Manage nodes from a farm",
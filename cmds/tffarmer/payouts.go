@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/tfexplorer/client"
+	escrow "github.com/threefoldtech/tfexplorer/pkg/escrow/types"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"github.com/urfave/cli"
+)
+
+const payoutsPageSize = 100
+
+func listPayouts(c *cli.Context) error {
+	id := schema.ID(c.Int64("farm"))
+	from := c.String("from")
+	to := c.String("to")
+	report := "statements"
+	if c.Bool("monthly") {
+		report = "monthly"
+	}
+
+	if format := c.String("format"); format != "" {
+		return exportPayouts(c, id, from, to, format, report)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	if report == "monthly" {
+		totals, err := db.FarmPayoutTotals(id, from, to)
+		if err != nil {
+			return errors.Wrap(err, "failed to get monthly payout totals")
+		}
+
+		fmt.Fprintln(w, "MONTH\tASSET\tRESERVATIONS\tCU\tSU\tIPV4U\tGROSS\tFOUNDATION CUT\tNET")
+		for _, t := range totals {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%s\t%s\t%s\n",
+				t.Month, t.Asset.Code(), t.Reservations, t.CUs, t.SUs, t.IPv4Us,
+				escrow.FormatAmount(t.Gross), escrow.FormatAmount(t.FoundationCut), escrow.FormatAmount(t.Net),
+			)
+		}
+		return nil
+	}
+
+	fmt.Fprintln(w, "RESERVATION\tPOOL\tMONTH\tCU\tSU\tIPV4U\tASSET\tGROSS\tFOUNDATION CUT\tNET\tTRANSACTION")
+	for page := 1; ; page++ {
+		statements, err := db.FarmPayouts(id, from, to, client.Page(page, payoutsPageSize))
+		if err != nil {
+			return errors.Wrap(err, "failed to get payout statements")
+		}
+
+		for _, s := range statements {
+			transaction := s.Transaction
+			if !s.Paid {
+				transaction = "pending"
+			}
+			fmt.Fprintf(w, "%d\t%d\t%s\t%d\t%d\t%d\t%s\t%s\t%s\t%s\t%s\n",
+				s.ReservationID, s.PoolID, s.Month, s.CUs, s.SUs, s.IPv4Us, s.Asset.Code(),
+				escrow.FormatAmount(s.Gross), escrow.FormatAmount(s.FoundationCut), escrow.FormatAmount(s.Net),
+				transaction,
+			)
+		}
+
+		if len(statements) < payoutsPageSize {
+			return nil
+		}
+	}
+}
+
+func exportPayouts(c *cli.Context, id schema.ID, from, to, format, report string) error {
+	var out io.Writer = os.Stdout
+	if output := c.String("output"); output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create output file '%s'", output)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if err := db.FarmPayoutsExport(id, from, to, format, report, out); err != nil {
+		return errors.Wrap(err, "failed to export payout statements")
+	}
+
+	return nil
+}
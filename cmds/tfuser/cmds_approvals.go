@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	wrkldstypes "github.com/threefoldtech/tfexplorer/pkg/workloads/types"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"github.com/urfave/cli"
+)
+
+func cmdsListApprovals(c *cli.Context) error {
+	approvals, err := bcdb.Workloads.Approvals(wrkldstypes.ApprovalKind(c.String("kind")))
+	if err != nil {
+		return errors.Wrap(err, "failed to list approvals")
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tKIND\tTYPE\tCUSTOMER\tQUORUM\tDESCRIPTION")
+	for _, approval := range approvals {
+		workload := approval.Workload
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d/%d\t%s\n",
+			workload.GetID(),
+			approval.Kind,
+			workload.GetWorkloadType(),
+			workload.GetCustomerTid(),
+			approval.Signatures,
+			approval.QuorumMin,
+			workload.GetDescription(),
+		)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, approval := range approvals {
+		for _, decision := range approval.Decisions {
+			if decision.Comment == "" {
+				continue
+			}
+
+			verb := "approved"
+			if !decision.Approved {
+				verb = "rejected"
+			}
+			fmt.Printf("%d: user %d %s: %s\n", approval.Workload.GetID(), decision.Tid, verb, decision.Comment)
+		}
+	}
+
+	return nil
+}
+
+func cmdsApprove(c *cli.Context) error {
+	return decideApproval(c, true)
+}
+
+func cmdsReject(c *cli.Context) error {
+	return decideApproval(c, false)
+}
+
+func decideApproval(c *cli.Context, approve bool) error {
+	id := schema.ID(c.Int64("id"))
+	request := wrkldstypes.ApprovalRequest{
+		Kind:    wrkldstypes.ApprovalKind(c.String("kind")),
+		Comment: c.String("comment"),
+	}
+
+	if err := request.Kind.Valid(); err != nil {
+		return err
+	}
+
+	var (
+		approval wrkldstypes.Approval
+		err      error
+	)
+
+	if approve {
+		w, err := bcdb.Workloads.Get(id)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get workload %d", id)
+		}
+
+		workload := wrkldstypes.WorkloaderType{Workloader: w}
+		request.Signature, err = workload.SignatureRequestSign(request.Kind, int64(mainui.ThreebotID), mainui.Key().PrivateKey)
+		if err != nil {
+			return errors.Wrap(err, "failed to sign the workload")
+		}
+
+		approval, err = bcdb.Workloads.Approve(id, request)
+		if err != nil {
+			return errors.Wrapf(err, "failed to approve the %s of workload %d", request.Kind, id)
+		}
+	} else {
+		approval, err = bcdb.Workloads.Reject(id, request)
+		if err != nil {
+			return errors.Wrapf(err, "failed to reject the %s of workload %d", request.Kind, id)
+		}
+	}
+
+	fmt.Printf("Workload %d: %d/%d signatures, next action %s\n", id, approval.Signatures, approval.QuorumMin, approval.Workload.GetNextAction())
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/tfexplorer/models/generated/workloads"
+	wrkldstypes "github.com/threefoldtech/tfexplorer/pkg/workloads/types"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"github.com/urfave/cli"
+)
+
+func cmdsEvents(c *cli.Context) error {
+	id := schema.ID(c.Int64("id"))
+	events, err := bcdb.Workloads.Events(id)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get the events of workload %d", id)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tEVENT\tNODE\tUSER\tSTATE\tMESSAGE")
+	for _, event := range events {
+		user := ""
+		if event.Tid != 0 {
+			user = fmt.Sprint(event.Tid)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			event.Epoch.Format("2006-01-02 15:04:05"),
+			event.Kind,
+			event.NodeID,
+			user,
+			event.State,
+			event.Message,
+		)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if failure := deploymentFailure(events); failure != nil {
+		fmt.Printf("\nDeployment failed on node %s: %s\n", failure.NodeID, failure.Message)
+	} else if stuck := notFetched(events); stuck != nil {
+		fmt.Printf("\nNode %s polls its workloads but did not fetch the workload\n", stuck.NodeID)
+	}
+
+	return nil
+}
+
+// notFetched returns the last event flagging the workload as not fetched by
+// its node, if the node did not fetch it since
+func notFetched(events []wrkldstypes.WorkloadEvent) *wrkldstypes.WorkloadEvent {
+	var stuck *wrkldstypes.WorkloadEvent
+	for i := range events {
+		switch events[i].Kind {
+		case wrkldstypes.EventNotFetched:
+			stuck = &events[i]
+		case wrkldstypes.EventFetched, wrkldstypes.EventResult:
+			stuck = nil
+		}
+	}
+
+	return stuck
+}
+
+// deploymentFailure returns the last error result of the timeline, a
+// successful result reported later means the workload is deployed again
+func deploymentFailure(events []wrkldstypes.WorkloadEvent) *wrkldstypes.WorkloadEvent {
+	var failure *wrkldstypes.WorkloadEvent
+	for i := range events {
+		event := &events[i]
+		if event.Kind != wrkldstypes.EventResult {
+			continue
+		}
+
+		switch event.State {
+		case workloads.ResultStateError.String():
+			failure = event
+		case workloads.ResultStateOK.String():
+			failure = nil
+		}
+	}
+
+	return failure
+}
@@ -4,12 +4,18 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	"github.com/threefoldtech/tfexplorer"
 	"github.com/threefoldtech/tfexplorer/client"
 	"github.com/threefoldtech/tfexplorer/models/generated/phonebook"
+	pbtypes "github.com/threefoldtech/tfexplorer/pkg/phonebook/types"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"github.com/threefoldtech/zos/pkg/crypto"
 	"github.com/threefoldtech/zos/pkg/identity"
 	"github.com/urfave/cli"
 )
@@ -163,3 +169,135 @@ func cmdsShowID(c *cli.Context) error {
 
 	return nil
 }
+
+func cmdsRotateID(c *cli.Context) error {
+	output := c.String("output")
+
+	// the new identity is reused if it exists, which is the case when the
+	// rotation is finished with the signatures of the guardians
+	ui := &tfexplorer.UserIdentity{}
+	if err := ui.Load(output); err != nil {
+		if mnemonic := c.String("mnemonic"); mnemonic != "" {
+			if err := ui.FromMnemonic(mnemonic); err != nil {
+				return err
+			}
+		} else {
+			k, err := identity.GenerateKeyPair()
+			if err != nil {
+				return err
+			}
+			ui = tfexplorer.NewUserIdentity(k, 0)
+		}
+	}
+
+	ui.ThreebotID = c.Uint64("tid")
+	if mainui != nil {
+		ui.ThreebotID = mainui.ThreebotID
+	}
+	if ui.ThreebotID == 0 {
+		return fmt.Errorf("threebot id is required, use --seed or --tid")
+	}
+	id := schema.ID(ui.ThreebotID)
+
+	rotation := pbtypes.KeyRotation{
+		Pubkey:    hex.EncodeToString(ui.Key().PublicKey),
+		Timestamp: c.Int64("timestamp"),
+	}
+	if rotation.Timestamp == 0 {
+		rotation.Timestamp = time.Now().Unix()
+	}
+
+	// always save the new identity before rotating so the new key can't be lost
+	if err := ui.Save(output); err != nil {
+		return errors.Wrap(err, "failed to save seed")
+	}
+
+	guardians := c.StringSlice("guardian")
+	if len(guardians) == 0 && mainui == nil {
+		fmt.Printf("New identity saved to: %s\n", output)
+		fmt.Println("Ask your guardians to sign the rotation with:")
+		fmt.Printf("  tfuser --seed <guardian seed> id guardian-sign --user %d --pubkey %s --timestamp %d\n", id, rotation.Pubkey, rotation.Timestamp)
+		fmt.Println("Then finish the rotation with:")
+		fmt.Printf("  tfuser id rotate --tid %d --output %s --timestamp %d --guardian <id>:<signature> ...\n", id, output, rotation.Timestamp)
+		return nil
+	}
+
+	if len(guardians) == 0 {
+		current := hex.EncodeToString(mainui.Key().PublicKey)
+		signature, err := crypto.Sign(mainui.Key().PrivateKey, rotation.Challenge(id, current))
+		if err != nil {
+			return errors.Wrap(err, "failed to sign key rotation")
+		}
+		rotation.Signature = hex.EncodeToString(signature)
+	}
+
+	for _, g := range guardians {
+		parts := strings.SplitN(g, ":", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid guardian signature '%s', expected <id>:<signature>", g)
+		}
+
+		gid, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return errors.Wrapf(err, "invalid guardian id '%s'", parts[0])
+		}
+
+		rotation.Guardians = append(rotation.Guardians, pbtypes.GuardianSignature{
+			ID:        schema.ID(gid),
+			Signature: parts[1],
+		})
+	}
+
+	if err := bcdb.Phonebook.RotateKey(id, rotation); err != nil {
+		return errors.Wrap(err, "failed to rotate key")
+	}
+
+	fmt.Printf("Key of user %d rotated\n", id)
+	fmt.Printf("Public Key   : %s\n", rotation.Pubkey)
+	fmt.Printf("Seed saved to: %s\n", output)
+
+	return nil
+}
+
+func cmdsGuardianSign(c *cli.Context) error {
+	if mainui == nil {
+		return fmt.Errorf("seed of the guardian is required")
+	}
+
+	id := schema.ID(c.Int64("user"))
+	user, err := bcdb.Phonebook.Get(id)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get user %d", id)
+	}
+
+	rotation := pbtypes.KeyRotation{
+		Pubkey:    c.String("pubkey"),
+		Timestamp: c.Int64("timestamp"),
+	}
+
+	signature, err := crypto.Sign(mainui.Key().PrivateKey, rotation.Challenge(id, user.Pubkey))
+	if err != nil {
+		return errors.Wrap(err, "failed to sign key rotation")
+	}
+
+	fmt.Printf("%d:%s\n", mainui.ThreebotID, hex.EncodeToString(signature))
+	return nil
+}
+
+func cmdsSetGuardians(c *cli.Context) error {
+	if mainui == nil {
+		return fmt.Errorf("seed required")
+	}
+
+	var guardians []schema.ID
+	for _, g := range c.Int64Slice("guardian") {
+		guardians = append(guardians, schema.ID(g))
+	}
+
+	if err := bcdb.Phonebook.SetGuardians(schema.ID(mainui.ThreebotID), guardians, c.Int64("threshold")); err != nil {
+		return errors.Wrap(err, "failed to set guardians")
+	}
+
+	fmt.Printf("Guardians of user %d set\n", mainui.ThreebotID)
+	return nil
+}
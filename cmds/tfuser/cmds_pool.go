@@ -49,6 +49,7 @@ func cmdsCreatePool(c *cli.Context) error {
 		cus     = c.Uint64("cus")
 		nodeIDs = c.StringSlice("nodeIDs")
 		poolID  = c.Int64("poolID")
+		promo   = c.String("promo")
 		err     error
 	)
 
@@ -72,6 +73,10 @@ func cmdsCreatePool(c *cli.Context) error {
 		capacityBuilder.WithPoolID(poolID)
 	}
 
+	if promo != "" {
+		capacityBuilder.WithPromoCode(promo)
+	}
+
 	reservationClient := provision.NewReservationClient(bcdb, mainui)
 	if dryRun {
 		res, err := reservationClient.DryRunCapacity(capacityBuilder.Build(), assets)
@@ -96,6 +101,9 @@ func cmdsCreatePool(c *cli.Context) error {
 	fmt.Printf("Asset to pay: %s\n", response.EscrowInformation.Asset)
 	fmt.Printf("Reservation escrow address: %s \n", response.EscrowInformation.Address)
 	fmt.Printf("Reservation amount: %s %s\n", formatCurrency(response.EscrowInformation.Amount), response.EscrowInformation.Asset.Code())
+	for _, rule := range response.EscrowInformation.Rules {
+		fmt.Printf("  %s discount: -%s %s (%s)\n", rule.Type, formatCurrency(rule.Amount), response.EscrowInformation.Asset.Code(), rule.Description)
+	}
 
 	return nil
 }
@@ -6,11 +6,13 @@ import (
 	"io/ioutil"
 	"math/big"
 	"os"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/stellar/go/xdr"
 	"github.com/threefoldtech/tfexplorer/models/generated/workloads"
 	wrklds "github.com/threefoldtech/tfexplorer/pkg/workloads"
+	"github.com/threefoldtech/tfexplorer/schema"
 
 	"github.com/threefoldtech/tfexplorer/provision"
 	"github.com/urfave/cli"
@@ -69,6 +71,67 @@ func formatCurrency(amount xdr.Int64) string {
 }
 
 func cmdsDeleteReservation(c *cli.Context) error {
+	var (
+		id     = c.Int64("reservation")
+		poolID = c.Int64("pool")
+	)
+
+	labels, err := schema.ParseLabels(strings.Join(c.StringSlice("label"), ","))
+	if err != nil {
+		return err
+	}
+
+	selector := poolID != 0 || len(labels) != 0
+	if (id == 0) == !selector {
+		return fmt.Errorf("either --reservation or --pool/--label is required")
+	}
+
+	reservationClient := provision.NewReservationClient(bcdb, mainui)
+	if id != 0 {
+		return reservationClient.DeleteReservation(id)
+	}
+
+	results, err := reservationClient.DeleteWorkloads(poolID, labels)
+	if err != nil {
+		return err
+	}
+
+	if len(results) == 0 {
+		fmt.Println("no workload to delete")
+		return nil
+	}
+
+	for _, result := range results {
+		if len(result.Error) != 0 {
+			fmt.Printf("workload %d: %s (%s)\n", result.ID, result.State, result.Error)
+			continue
+		}
+		fmt.Printf("workload %d: %s\n", result.ID, result.State)
+	}
+
+	return nil
+}
+
+func cmdsMigrate(c *cli.Context) error {
 	reservationClient := provision.NewReservationClient(bcdb, mainui)
-	return reservationClient.DeleteReservation(c.Int64("reservation"))
+	converted, err := reservationClient.MigrateReservations()
+	if err != nil {
+		return err
+	}
+
+	if len(converted) == 0 {
+		fmt.Println("no reservation to convert")
+		return nil
+	}
+
+	pools := make(map[int64]int)
+	for _, workload := range converted {
+		pools[workload.GetPoolID()]++
+	}
+
+	for poolID, count := range pools {
+		fmt.Printf("pool %d: %d workloads converted\n", poolID, count)
+	}
+
+	return nil
 }
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/tfexplorer"
+	pbtypes "github.com/threefoldtech/tfexplorer/pkg/phonebook/types"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"github.com/threefoldtech/zos/pkg/crypto"
+	"github.com/threefoldtech/zos/pkg/identity"
+	"github.com/urfave/cli"
+)
+
+func cmdsCreateToken(c *cli.Context) error {
+	output := c.String("output")
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return errors.Wrap(err, "failed to generate token id")
+	}
+
+	k, err := identity.GenerateKeyPair()
+	if err != nil {
+		return err
+	}
+
+	token := pbtypes.Token{
+		ID:         hex.EncodeToString(buf),
+		UserID:     schema.ID(mainui.ThreebotID),
+		Pubkey:     hex.EncodeToString(k.PublicKey),
+		Expiration: schema.Date{Time: time.Now().Add(c.Duration("expires"))},
+	}
+
+	for _, s := range c.StringSlice("scope") {
+		token.Scopes = append(token.Scopes, pbtypes.Scope(s))
+	}
+	for _, p := range c.Int64Slice("pool") {
+		token.Pools = append(token.Pools, schema.ID(p))
+	}
+
+	signature, err := crypto.Sign(mainui.Key().PrivateKey, token.Encode())
+	if err != nil {
+		return errors.Wrap(err, "failed to sign token")
+	}
+	token.Signature = hex.EncodeToString(signature)
+
+	token, err = bcdb.Phonebook.TokenCreate(token)
+	if err != nil {
+		return errors.Wrap(err, "failed to register token")
+	}
+
+	ti := tfexplorer.NewTokenIdentity(token.ID, k, mainui.ThreebotID)
+	if err := ti.Save(output); err != nil {
+		return errors.Wrap(err, "failed to save token")
+	}
+
+	fmt.Printf("Token ID     : %s\n", token.ID)
+	fmt.Printf("Expiration   : %s\n", token.Expiration.Format(time.RFC3339))
+	fmt.Printf("Token saved to: %s\n", output)
+	return nil
+}
+
+func cmdsListTokens(c *cli.Context) error {
+	tokens, err := bcdb.Phonebook.Tokens(schema.ID(mainui.ThreebotID))
+	if err != nil {
+		return errors.Wrap(err, "failed to list tokens")
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSCOPES\tPOOLS\tEXPIRATION\tREVOKED")
+	for _, token := range tokens {
+		scopes := make([]string, 0, len(token.Scopes))
+		for _, s := range token.Scopes {
+			scopes = append(scopes, string(s))
+		}
+
+		pools := "any"
+		if len(token.Pools) != 0 {
+			pools = strings.Trim(fmt.Sprint(token.Pools), "[]")
+		}
+
+		revoked := "-"
+		if !token.Revoked.IsZero() {
+			revoked = token.Revoked.Format(time.RFC3339)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", token.ID, strings.Join(scopes, ","), pools, token.Expiration.Format(time.RFC3339), revoked)
+	}
+
+	return w.Flush()
+}
+
+func cmdsRevokeToken(c *cli.Context) error {
+	id := c.String("id")
+	if err := bcdb.Phonebook.TokenRevoke(schema.ID(mainui.ThreebotID), id); err != nil {
+		return errors.Wrap(err, "failed to revoke token")
+	}
+
+	fmt.Printf("Token %s revoked\n", id)
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/urfave/cli"
 )
@@ -165,6 +166,198 @@ func main() {
 					Usage:  "show user information from seed file",
 					Action: cmdsShowID,
 				},
+				{
+					Name:  "rotate",
+					Usage: "replace the key of the user, signed with the current key or by the guardians of the user",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:     "output,o",
+							Usage:    "output path of the new identity seed",
+							Required: true,
+						},
+						cli.StringFlag{
+							Name:  "mnemonic",
+							Usage: "generate the new key from given mnemonic",
+						},
+						cli.Uint64Flag{
+							Name:  "tid",
+							Usage: "threebot id, required when the current seed is lost",
+						},
+						cli.Int64Flag{
+							Name:  "timestamp",
+							Usage: "unix time of the rotation signed by the guardians",
+						},
+						cli.StringSliceFlag{
+							Name:  "guardian",
+							Usage: "signature of a guardian in the form <id>:<signature>, can be repeated",
+						},
+					},
+					Action: cmdsRotateID,
+				},
+				{
+					Name:  "guardian-sign",
+					Usage: "sign the key rotation of a user as one of its guardians",
+					Flags: []cli.Flag{
+						cli.Int64Flag{
+							Name:     "user",
+							Usage:    "threebot id of the user rotating its key",
+							Required: true,
+						},
+						cli.StringFlag{
+							Name:     "pubkey",
+							Usage:    "new public key of the user",
+							Required: true,
+						},
+						cli.Int64Flag{
+							Name:     "timestamp",
+							Usage:    "unix time of the rotation",
+							Required: true,
+						},
+					},
+					Action: cmdsGuardianSign,
+				},
+				{
+					Name:  "guardians",
+					Usage: "set the users which can rotate your key if your seed is lost",
+					Flags: []cli.Flag{
+						cli.Int64SliceFlag{
+							Name:  "guardian",
+							Usage: "threebot id of a guardian, can be repeated. Without guardians, recovery is disabled",
+						},
+						cli.Int64Flag{
+							Name:  "threshold",
+							Usage: "number of guardians which need to sign a rotation",
+						},
+					},
+					Action: cmdsSetGuardians,
+				},
+			},
+		},
+		{
+			Name:   "token",
+			Usage:  "Manage scoped API tokens, used instead of your seed by automated tools",
+			Before: requireSeed,
+			Subcommands: []cli.Command{
+				{
+					Name:  "create",
+					Usage: "mint a new token",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:     "output,o",
+							Usage:    "output path of the token",
+							Required: true,
+						},
+						cli.StringSliceFlag{
+							Name:  "scope",
+							Usage: "scope of the token (read, pool or deploy), can be repeated",
+						},
+						cli.Int64SliceFlag{
+							Name:  "pool",
+							Usage: "limit the token to a capacity pool, can be repeated",
+						},
+						cli.DurationFlag{
+							Name:  "expires",
+							Usage: "how long the token is valid",
+							Value: 30 * 24 * time.Hour,
+						},
+					},
+					Action: cmdsCreateToken,
+				},
+				{
+					Name:   "list",
+					Usage:  "list your tokens",
+					Action: cmdsListTokens,
+				},
+				{
+					Name:  "revoke",
+					Usage: "revoke a token",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:     "id",
+							Usage:    "id of the token",
+							Required: true,
+						},
+					},
+					Action: cmdsRevokeToken,
+				},
+			},
+		},
+		{
+			Name:   "migrate",
+			Usage:  "Convert your legacy reservations to workloads in capacity pools",
+			Before: requireSeed,
+			Action: cmdsMigrate,
+		},
+		{
+			Name:  "events",
+			Usage: "Show the timeline of a workload, and why its deployment failed",
+			Flags: []cli.Flag{
+				cli.Int64Flag{
+					Name:     "id",
+					Usage:    "id of the workload",
+					Required: true,
+				},
+			},
+			Action: cmdsEvents,
+		},
+		{
+			Name:   "approvals",
+			Usage:  "Sign or reject the workloads awaiting your signature",
+			Before: requireSeed,
+			Subcommands: []cli.Command{
+				{
+					Name:  "list",
+					Usage: "list the workloads awaiting your signature",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "kind",
+							Usage: "only list the provision or the delete signing requests",
+						},
+					},
+					Action: cmdsListApprovals,
+				},
+				{
+					Name:  "approve",
+					Usage: "sign the signing request of a workload",
+					Flags: []cli.Flag{
+						cli.Int64Flag{
+							Name:     "id",
+							Usage:    "id of the workload",
+							Required: true,
+						},
+						cli.StringFlag{
+							Name:  "kind",
+							Usage: "signing request of the workload (provision or delete)",
+							Value: "provision",
+						},
+						cli.StringFlag{
+							Name:  "comment",
+							Usage: "comment of your decision",
+						},
+					},
+					Action: cmdsApprove,
+				},
+				{
+					Name:  "reject",
+					Usage: "reject the signing request of a workload",
+					Flags: []cli.Flag{
+						cli.Int64Flag{
+							Name:     "id",
+							Usage:    "id of the workload",
+							Required: true,
+						},
+						cli.StringFlag{
+							Name:  "kind",
+							Usage: "signing request of the workload (provision or delete)",
+							Value: "provision",
+						},
+						cli.StringFlag{
+							Name:  "comment",
+							Usage: "comment of your decision",
+						},
+					},
+					Action: cmdsReject,
+				},
 			},
 		},
 		{
@@ -491,13 +684,20 @@ func main() {
 		},
 		{
 			Name:   "delete",
-			Usage:  "Mark a workload as to be deleted",
+			Usage:  "Mark a workload, or all the workloads of a pool or with labels, as to be deleted",
 			Before: requireSeed,
 			Flags: []cli.Flag{
 				cli.Int64Flag{
-					Name:     "reservation",
-					Usage:    "reservation id",
-					Required: true,
+					Name:  "reservation",
+					Usage: "reservation id",
+				},
+				cli.Int64Flag{
+					Name:  "pool",
+					Usage: "delete all the workloads of the pool",
+				},
+				cli.StringSliceFlag{
+					Name:  "label",
+					Usage: "delete all the workloads with the label, in the key=value format. Can be repeated",
 				},
 				cli.StringFlag{
 					Name:     "seed",
@@ -567,6 +767,10 @@ func main() {
 							Name:  "poolID",
 							Usage: "if pool id is given then it will extend the existing one",
 						},
+						cli.StringFlag{
+							Name:  "promo",
+							Usage: "promotion code to apply to the price of the reservation",
+						},
 					},
 					Action: cmdsCreatePool,
 				},
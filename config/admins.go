@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// IDList is a list of threebot ids. It implements flag.Value so it can be
+// set with a flag as a comma separated list
+type IDList []int64
+
+// String implements flag.Value
+func (l *IDList) String() string {
+	ids := make([]string, 0, len(*l))
+	for _, id := range *l {
+		ids = append(ids, strconv.FormatInt(id, 10))
+	}
+
+	return strings.Join(ids, ",")
+}
+
+// Set implements flag.Value
+func (l *IDList) Set(value string) error {
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if len(part) == 0 {
+			continue
+		}
+
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil || id <= 0 {
+			return fmt.Errorf("invalid threebot id '%s'", part)
+		}
+		*l = append(*l, id)
+	}
+
+	return nil
+}
+
+// Contains checks if the id is in the list
+func (l IDList) Contains(id int64) bool {
+	for _, i := range l {
+		if i == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsAdmin checks if the threebot id is one of the explorer admins
+func IsAdmin(tid int64) bool {
+	return Config.Admins.Contains(tid)
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/threefoldtech/tfexplorer/pkg/gridnetworks"
@@ -14,11 +15,44 @@ type Settings struct {
 	WalletNetwork string
 	TFNetwork     string
 	HorizonURL    string
+	// FarmPriceBounds limits the default prices a farmer can set on a farm
+	FarmPriceBounds PriceBounds
+	// Admins are the threebot ids of the foundation members allowed to use
+	// the admin endpoints
+	Admins IDList
+	// AuthMaxSkew is the maximum difference between the creation time of a
+	// signed request and the server time, 0 disables the check and the
+	// replay protection
+	AuthMaxSkew time.Duration
+	// AuthRequireDigest forces signed requests with a body to sign the
+	// digest of the body
+	AuthRequireDigest bool
+	// RequireNodeSignatures refuses the unsigned requests of the nodes on the
+	// routes which accepted them before, and the deletion reports which are
+	// not signed by the node. Unsigned requests are deprecated
+	RequireNodeSignatures bool
+	// DisableLegacy turns off the legacy reservation model once all the
+	// legacy reservations are converted to workloads, the nodes are only
+	// served the workloads
+	DisableLegacy bool
+	// FetchTimeout is the time after which a workload queued for a node
+	// which polls its workloads is flagged if the node did not fetch it, 0
+	// disables the check
+	FetchTimeout time.Duration
+	// RateLimits are the request budgets of the route groups, per identity
+	RateLimits RateLimits
+	// TrustForwardedFor makes the rate limiter use the client IP set by a
+	// reverse proxy in the X-Real-Ip or X-Forwarded-For headers
+	TrustForwardedFor bool
 }
 
 var (
 	// Config is global explorer config
-	Config Settings
+	Config = Settings{
+		FarmPriceBounds: DefaultPriceBounds,
+		AuthMaxSkew:     5 * time.Minute,
+		FetchTimeout:    10 * time.Minute,
+	}
 
 	possibleWalletNetworks = []string{stellar.NetworkProduction}
 )
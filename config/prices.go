@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PriceBound is the allowed range of the price of a unit, in dollar per month
+type PriceBound struct {
+	Min float64
+	Max float64
+}
+
+// PriceBounds are the foundation defined bounds of the default prices a
+// farmer can set on a farm. They implement flag.Value so they can be set
+// with a flag in the format "cu=5:20,su=4:16,ipv4u=3:12"
+type PriceBounds struct {
+	CU    PriceBound
+	SU    PriceBound
+	IPv4U PriceBound
+}
+
+// DefaultPriceBounds allow the farmers to set prices between half and
+// twice the grid wide prices
+var DefaultPriceBounds = PriceBounds{
+	CU:    PriceBound{Min: 5, Max: 20},
+	SU:    PriceBound{Min: 4, Max: 16},
+	IPv4U: PriceBound{Min: 3, Max: 12},
+}
+
+// Contains checks if price is in the bound
+func (b PriceBound) Contains(price float64) bool {
+	return price >= b.Min && price <= b.Max
+}
+
+func (b PriceBound) String() string {
+	return fmt.Sprintf("%s:%s", strconv.FormatFloat(b.Min, 'f', -1, 64), strconv.FormatFloat(b.Max, 'f', -1, 64))
+}
+
+// String implements flag.Value
+func (p *PriceBounds) String() string {
+	return fmt.Sprintf("cu=%s,su=%s,ipv4u=%s", p.CU, p.SU, p.IPv4U)
+}
+
+// Set implements flag.Value. Units which are not specified keep their
+// current bounds
+func (p *PriceBounds) Set(value string) error {
+	for _, part := range strings.Split(value, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid price bound '%s', expected format is unit=min:max", part)
+		}
+
+		minmax := strings.SplitN(kv[1], ":", 2)
+		if len(minmax) != 2 {
+			return fmt.Errorf("invalid price bound '%s', expected format is unit=min:max", part)
+		}
+
+		var (
+			bound PriceBound
+			err   error
+		)
+		if bound.Min, err = strconv.ParseFloat(minmax[0], 64); err != nil {
+			return fmt.Errorf("invalid minimum price '%s'", minmax[0])
+		}
+		if bound.Max, err = strconv.ParseFloat(minmax[1], 64); err != nil {
+			return fmt.Errorf("invalid maximum price '%s'", minmax[1])
+		}
+		if bound.Min < 0 || bound.Min > bound.Max {
+			return fmt.Errorf("invalid price bound '%s', minimum must be positive and lower than maximum", part)
+		}
+
+		switch strings.ToLower(kv[0]) {
+		case "cu":
+			p.CU = bound
+		case "su":
+			p.SU = bound
+		case "ipv4u":
+			p.IPv4U = bound
+		default:
+			return fmt.Errorf("unknown unit '%s', supported units are cu, su and ipv4u", kv[0])
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriceBoundsSet(t *testing.T) {
+	bounds := DefaultPriceBounds
+
+	err := bounds.Set("cu=1:2.5, ipv4u=0:4")
+	require.NoError(t, err)
+	assert.Equal(t, PriceBound{Min: 1, Max: 2.5}, bounds.CU)
+	assert.Equal(t, DefaultPriceBounds.SU, bounds.SU)
+	assert.Equal(t, PriceBound{Min: 0, Max: 4}, bounds.IPv4U)
+	assert.Equal(t, "cu=1:2.5,su=4:16,ipv4u=0:4", bounds.String())
+
+	for _, value := range []string{"cu", "cu=1", "cu=a:2", "cu=3:2", "nu=1:2"} {
+		assert.Error(t, bounds.Set(value), value)
+	}
+}
+
+func TestPriceBoundContains(t *testing.T) {
+	bound := PriceBound{Min: 5, Max: 20}
+
+	assert.True(t, bound.Contains(5))
+	assert.True(t, bound.Contains(20))
+	assert.False(t, bound.Contains(4.99))
+	assert.False(t, bound.Contains(21))
+}
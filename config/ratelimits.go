@@ -0,0 +1,96 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RateLimit is the request budget of an identity on a group of routes
+type RateLimit struct {
+	// Rate is the number of requests per second
+	Rate float64
+	// Burst is the number of requests which can be made at once
+	Burst int
+}
+
+func (r RateLimit) String() string {
+	return fmt.Sprintf("%s:%d", strconv.FormatFloat(r.Rate, 'f', -1, 64), r.Burst)
+}
+
+// RateLimits are the budgets of the route groups. They implement flag.Value
+// so they can be set with a flag in the format "workloads=10:20,directory=20:40"
+type RateLimits map[string]RateLimit
+
+// Route groups which are rate limited
+const (
+	RateLimitPhonebook = "phonebook"
+	RateLimitDirectory = "directory"
+	RateLimitWorkloads = "workloads"
+)
+
+// DefaultRateLimits are the budgets used for the groups which are not configured
+var DefaultRateLimits = RateLimits{
+	RateLimitPhonebook: {Rate: 5, Burst: 20},
+	RateLimitDirectory: {Rate: 20, Burst: 50},
+	RateLimitWorkloads: {Rate: 10, Burst: 30},
+}
+
+// Get the budget of a group, a zero rate means the group is not limited
+func (l RateLimits) Get(group string) RateLimit {
+	if limit, ok := l[group]; ok {
+		return limit
+	}
+
+	return DefaultRateLimits[group]
+}
+
+// String implements flag.Value
+func (l *RateLimits) String() string {
+	groups := make([]string, 0, len(*l))
+	for group, limit := range *l {
+		groups = append(groups, fmt.Sprintf("%s=%s", group, limit))
+	}
+	sort.Strings(groups)
+
+	return strings.Join(groups, ",")
+}
+
+// Set implements flag.Value. Groups which are not specified keep their
+// default budget, a rate of 0 disables the limit of a group
+func (l *RateLimits) Set(value string) error {
+	if *l == nil {
+		*l = RateLimits{}
+	}
+
+	for _, part := range strings.Split(value, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid rate limit '%s', expected format is group=rate:burst", part)
+		}
+
+		if _, ok := DefaultRateLimits[kv[0]]; !ok {
+			return fmt.Errorf("unknown rate limit group '%s'", kv[0])
+		}
+
+		rb := strings.SplitN(kv[1], ":", 2)
+		if len(rb) != 2 {
+			return fmt.Errorf("invalid rate limit '%s', expected format is group=rate:burst", part)
+		}
+
+		rate, err := strconv.ParseFloat(rb[0], 64)
+		if err != nil || rate < 0 {
+			return fmt.Errorf("invalid rate '%s' for group '%s'", rb[0], kv[0])
+		}
+
+		burst, err := strconv.Atoi(rb[1])
+		if err != nil || burst < 1 {
+			return fmt.Errorf("invalid burst '%s' for group '%s'", rb[1], kv[0])
+		}
+
+		(*l)[kv[0]] = RateLimit{Rate: rate, Burst: burst}
+	}
+
+	return nil
+}
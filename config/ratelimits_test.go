@@ -0,0 +1,23 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitsSet(t *testing.T) {
+	var limits RateLimits
+
+	err := limits.Set("workloads=2.5:10, directory=0:1")
+	require.NoError(t, err)
+	assert.Equal(t, RateLimit{Rate: 2.5, Burst: 10}, limits.Get(RateLimitWorkloads))
+	assert.Equal(t, RateLimit{Rate: 0, Burst: 1}, limits.Get(RateLimitDirectory))
+	assert.Equal(t, DefaultRateLimits[RateLimitPhonebook], limits.Get(RateLimitPhonebook))
+	assert.Equal(t, "directory=0:1,workloads=2.5:10", limits.String())
+
+	for _, value := range []string{"workloads", "workloads=1", "workloads=a:2", "workloads=-1:2", "workloads=1:0", "farms=1:2"} {
+		assert.Error(t, limits.Set(value), value)
+	}
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/threefoldtech/tfexplorer/schema"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// NextCursorHeader is the header of list responses holding the cursor
+	// of the next page. It is not set on the last page
+	NextCursorHeader = "Next-Cursor"
+
+	// cursorPrefix versions the cursor format, the cursor is opaque for
+	// the clients
+	cursorPrefix = "v1:"
+)
+
+// ErrBadCursor is returned if the cursor of a list request is invalid
+var ErrBadCursor = errors.New("invalid cursor")
+
+// Cursor is the position in a list paginated by cursor. Items are listed in
+// ascending id order, starting after the After id
+type Cursor struct {
+	After schema.ID
+	Size  int64
+}
+
+// EncodeCursor returns the opaque cursor of the items after id
+func EncodeCursor(id schema.ID) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%s%d", cursorPrefix, id)))
+}
+
+// DecodeCursor returns the id encoded in the cursor
+func DecodeCursor(cursor string) (schema.ID, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(data), cursorPrefix) {
+		return 0, ErrBadCursor
+	}
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(string(data), cursorPrefix), 10, 64)
+	if err != nil || id < 0 {
+		return 0, ErrBadCursor
+	}
+
+	return schema.ID(id), nil
+}
+
+// CursorFromRequest return the cursor information from the cursor & size url
+// params. It returns nil if the request is paginated by page number. An empty
+// cursor starts at the first item
+func CursorFromRequest(r *http.Request) (*Cursor, error) {
+	values, ok := r.URL.Query()["cursor"]
+	if !ok {
+		return nil, nil
+	}
+
+	cursor := Cursor{Size: DefaultPageSize}
+	if len(values[0]) != 0 {
+		after, err := DecodeCursor(values[0])
+		if err != nil {
+			return nil, err
+		}
+		cursor.After = after
+	}
+
+	if s := r.URL.Query().Get("size"); len(s) != 0 {
+		size, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || size < 1 {
+			return nil, fmt.Errorf("invalid page size '%s'", s)
+		}
+		cursor.Size = size
+	}
+
+	// make sure user doesn't kill the server by returning too much data
+	if cursor.Size > 1000 {
+		cursor.Size = 1000
+	}
+
+	return &cursor, nil
+}
+
+// Filter returns the filter element selecting the items after the cursor
+func (c *Cursor) Filter() bson.E {
+	return bson.E{Key: "_id", Value: bson.M{"$gt": c.After}}
+}
+
+// Pager returns the find options of the page following the cursor
+func (c *Cursor) Pager() Pager {
+	return options.Find().SetLimit(c.Size).SetSort(bson.D{{Key: "_id", Value: 1}})
+}
+
+// NextCursor returns the cursor of the page following a page of n items
+// ending with the item of id last. It is empty if there are no more items
+func NextCursor(pager Pager, n int, last schema.ID) string {
+	if n == 0 || pager.Limit == nil || int64(n) < *pager.Limit {
+		return ""
+	}
+
+	return EncodeCursor(last)
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/tfexplorer/schema"
+)
+
+func TestCursorEncoding(t *testing.T) {
+	id, err := DecodeCursor(EncodeCursor(42))
+	require.NoError(t, err)
+	assert.Equal(t, schema.ID(42), id)
+
+	for _, cursor := range []string{"42", "not base64!", EncodeCursor(42)[1:]} {
+		_, err := DecodeCursor(cursor)
+		assert.Error(t, err, cursor)
+	}
+}
+
+func TestCursorFromRequest(t *testing.T) {
+	cursor, err := CursorFromRequest(httptest.NewRequest("GET", "/?page=2", nil))
+	require.NoError(t, err)
+	assert.Nil(t, cursor)
+
+	cursor, err = CursorFromRequest(httptest.NewRequest("GET", "/?cursor=", nil))
+	require.NoError(t, err)
+	assert.Equal(t, &Cursor{After: 0, Size: DefaultPageSize}, cursor)
+
+	cursor, err = CursorFromRequest(httptest.NewRequest("GET", "/?size=5000&cursor="+EncodeCursor(10), nil))
+	require.NoError(t, err)
+	assert.Equal(t, &Cursor{After: 10, Size: 1000}, cursor)
+
+	_, err = CursorFromRequest(httptest.NewRequest("GET", "/?cursor=abc", nil))
+	assert.Error(t, err)
+	_, err = CursorFromRequest(httptest.NewRequest("GET", "/?cursor=&size=0", nil))
+	assert.Error(t, err)
+}
+
+func TestNextCursor(t *testing.T) {
+	pager := (&Cursor{Size: 2}).Pager()
+
+	assert.Empty(t, NextCursor(pager, 0, 0))
+	assert.Empty(t, NextCursor(pager, 1, 5))
+	assert.Equal(t, EncodeCursor(5), NextCursor(pager, 2, 5))
+}
@@ -32,6 +32,7 @@ const (
 	NextActionInvalid
 	NextActionDeleted
 	NextActionMigrated
+	NextActionUpdate
 )
 
 func (e NextActionEnum) String() string {
@@ -50,6 +51,10 @@ func (e NextActionEnum) String() string {
 		return "invalid"
 	case NextActionDeleted:
 		return "deleted"
+	case NextActionMigrated:
+		return "migrated"
+	case NextActionUpdate:
+		return "update"
 	}
 	return "UNKNOWN"
 }
@@ -114,3 +114,39 @@ func TestContainerSigningChalenge(t *testing.T) {
 	err = crypto.Verify(kp.PublicKey, msg[:], signature)
 	assert.Error(t, err)
 }
+
+func TestUpdateSigningChalenge(t *testing.T) {
+	v := &Volume{
+		ReservationInfo: ReservationInfo{
+			CustomerTid:  1,
+			ID:           2,
+			WorkloadId:   1,
+			PoolId:       1,
+			Epoch:        schema.Date{Time: time.Now()},
+			WorkloadType: WorkloadTypeVolume,
+			NodeId:       "node1",
+		},
+		Size: 1,
+		Type: VolumeTypeSSD,
+	}
+	original, err := v.SignatureChallenge()
+	require.NoError(t, err)
+
+	v.Original = 1
+	v.Revision = 1
+	update, err := v.SignatureChallenge()
+	require.NoError(t, err)
+	assert.NotEqual(t, original, update)
+
+	v.Revision = 2
+	next, err := v.SignatureChallenge()
+	require.NoError(t, err)
+	assert.NotEqual(t, update, next)
+
+	// the challenge of the workloads which are not an update is unchanged
+	v.Original = 0
+	v.Revision = 0
+	sc, err := v.SignatureChallenge()
+	require.NoError(t, err)
+	assert.Equal(t, original, sc)
+}
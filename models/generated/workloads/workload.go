@@ -56,6 +56,14 @@ type (
 		GetReference() string
 		GetVersion() int
 		SetVersion(version int)
+		GetOriginal() schema.ID
+		GetRevision() int64
+		GetLabels() schema.Labels
+		SetLabels(labels schema.Labels)
+		GetExpiresAt() schema.Date
+		SetExpiresAt(date schema.Date)
+		GetDependsOn() []schema.ID
+		SetDependsOn(ids []schema.ID)
 
 		Capaciter
 	}
@@ -236,6 +244,23 @@ type ReservationInfo struct {
 	Result              Result             `bson:"result" json:"result"`
 	WorkloadType        WorkloadTypeEnum   `bson:"workload_type" json:"workload_type"`
 	Version             int                `bson:"version" json:"version"`
+
+	// Original is the id of the first version of an updated workload, it is
+	// 0 for workloads which are not an update
+	Original schema.ID `bson:"original,omitempty" json:"original,omitempty"`
+	// Revision is the number of the update, the original workload being
+	// revision 0
+	Revision int64 `bson:"revision,omitempty" json:"revision,omitempty"`
+	// Labels are set by the customer to group the workloads, they are not
+	// part of the signature challenge
+	Labels schema.Labels `bson:"labels,omitempty" json:"labels,omitempty"`
+	// ExpiresAt is the time at which the workload is deleted, zero for
+	// workloads which live as long as their pool. It is not part of the
+	// signature challenge, so it can be extended
+	ExpiresAt schema.Date `bson:"expires_at,omitempty" json:"expires_at"`
+	// DependsOn are the ids of the workloads the workload references, they
+	// are resolved by the explorer when the workload is created
+	DependsOn []schema.ID `bson:"depends_on,omitempty" json:"depends_on,omitempty"`
 }
 
 func (i *ReservationInfo) WorkloadID() int64 {
@@ -410,6 +435,13 @@ func (i *ReservationInfo) SignatureChallenge() ([]byte, error) { //TODO: name of
 	if _, err := fmt.Fprintf(b, "%s", i.Metadata); err != nil {
 		return nil, err
 	}
+	// updates are signed with the workload they replace, the challenge of
+	// the other workloads is unchanged
+	if i.Original != 0 {
+		if _, err := fmt.Fprintf(b, "%d%d", i.Original, i.Revision); err != nil {
+			return nil, err
+		}
+	}
 
 	return b.Bytes(), nil
 }
@@ -446,6 +478,38 @@ func (i *ReservationInfo) SetVersion(version int) {
 	i.Version = version
 }
 
+func (i *ReservationInfo) GetOriginal() schema.ID {
+	return i.Original
+}
+
+func (i *ReservationInfo) GetRevision() int64 {
+	return i.Revision
+}
+
+func (i *ReservationInfo) GetLabels() schema.Labels {
+	return i.Labels
+}
+
+func (i *ReservationInfo) SetLabels(labels schema.Labels) {
+	i.Labels = labels
+}
+
+func (i *ReservationInfo) GetExpiresAt() schema.Date {
+	return i.ExpiresAt
+}
+
+func (i *ReservationInfo) SetExpiresAt(date schema.Date) {
+	i.ExpiresAt = date
+}
+
+func (i *ReservationInfo) GetDependsOn() []schema.ID {
+	return i.DependsOn
+}
+
+func (i *ReservationInfo) SetDependsOn(ids []schema.ID) {
+	i.DependsOn = ids
+}
+
 // Stub type not used (for now)
 type StatsAggregator struct {
 	// To be defined
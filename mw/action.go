@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/tfexplorer/models"
 )
 
 // Response interface
@@ -149,3 +150,19 @@ func Forbidden(err error) Response {
 func NoContent() Response {
 	return genericResponse{status: http.StatusNoContent}
 }
+
+// Page returns the ok response of a page of a list. Requests paginated by
+// page number get the number of pages in the Pages header. The cursor of the
+// next page, if any, is set in the Next-Cursor header
+func Page(pager models.Pager, cursor *models.Cursor, total int64, next string) Response {
+	response := Ok()
+	if cursor == nil {
+		response = response.WithHeader("Pages", fmt.Sprint(models.Pages(pager, total)))
+	}
+
+	if len(next) != 0 {
+		response = response.WithHeader(models.NextCursorHeader, next)
+	}
+
+	return response
+}
@@ -9,10 +9,12 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jbenet/go-base58"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/tfexplorer/config"
 	"github.com/threefoldtech/tfexplorer/pkg/phonebook/types"
 	"github.com/threefoldtech/tfexplorer/schema"
 	"github.com/zaibon/httpsig"
@@ -23,6 +25,9 @@ import (
 // UserKeyGetter implements httpsig.KeyGetter for the users collections
 type UserKeyGetter struct {
 	db *mongo.Database
+	// at is the time the request was signed, the key the user had at
+	// that time is returned
+	at time.Time
 }
 
 // NewUserKeyGetter create a httpsig.KeyGetter that uses the users collection
@@ -31,10 +36,34 @@ func NewUserKeyGetter(db *mongo.Database) UserKeyGetter {
 	return UserKeyGetter{db: db}
 }
 
-// GetKey implements httpsig.KeyGetter
+// At returns a key getter which finds the keys the users had at time t
+func (u UserKeyGetter) At(t time.Time) httpsig.KeyGetter {
+	u.at = t
+	return u
+}
+
+// GetKey implements httpsig.KeyGetter. The id is either a user id, or the
+// key id of a token of the user
 func (u UserKeyGetter) GetKey(id string) (interface{}, error) {
 	ctx := context.TODO()
 
+	if tokenID, ok := types.ParseTokenKeyID(id); ok {
+		token, err := types.TokenFilter{}.WithID(tokenID).Get(ctx, u.db)
+		if err != nil {
+			return nil, err
+		}
+
+		if !token.ValidAt(time.Now()) {
+			return nil, fmt.Errorf("token is expired or revoked")
+		}
+
+		pk, err := hex.DecodeString(token.Pubkey)
+		if err != nil {
+			return nil, err
+		}
+		return ed25519.PublicKey(pk), nil
+	}
+
 	uid, err := strconv.ParseInt(id, 10, 64)
 	if err != nil {
 		return nil, err
@@ -48,13 +77,27 @@ func (u UserKeyGetter) GetKey(id string) (interface{}, error) {
 		return nil, err
 	}
 
-	pk, err := hex.DecodeString(user.Pubkey)
+	pubkey := user.Pubkey
+	if !u.at.IsZero() {
+		pubkey, err = types.UserKeySignedAt(ctx, u.db, user, u.at)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	pk, err := hex.DecodeString(pubkey)
 	if err != nil {
 		return nil, err
 	}
 	return ed25519.PublicKey(pk), nil
 }
 
+// historyKeyGetter is a key getter which finds the key an identity had at a
+// given time
+type historyKeyGetter interface {
+	At(t time.Time) httpsig.KeyGetter
+}
+
 // NodeKeyGetter implements httpsig.KeyGetter for the nodes collections
 type NodeKeyGetter struct{}
 
@@ -74,10 +117,63 @@ func (m NodeKeyGetter) GetKey(id string) (interface{}, error) {
 // requiredHeaders are the parameters to be used to generated the http signature
 var requiredHeaders = []string{"(created)", "date", "threebot-id"}
 
+type tokenKey struct{}
+
+// WithToken adds the token used to authenticate the request to the context
+func WithToken(ctx context.Context, token types.Token) context.Context {
+	return context.WithValue(ctx, tokenKey{}, token)
+}
+
+// TokenFromContext returns the token used to authenticate the request, and
+// false if the request was signed with the user key
+func TokenFromContext(ctx context.Context) (types.Token, bool) {
+	token, ok := ctx.Value(tokenKey{}).(types.Token)
+	return token, ok
+}
+
+type verifiedKey struct{}
+
+// verifiedSignature is the signature of a request verified with the current
+// key of the signer by a middleware running before the auth middleware
+type verifiedSignature struct {
+	signature string
+	keyID     string
+}
+
+// withVerifiedSignature adds the signature of the request, verified with the
+// current key of keyID, to the context
+func withVerifiedSignature(ctx context.Context, req *http.Request, keyID string) context.Context {
+	params, err := parseSignatureParams(req)
+	if err != nil || len(params.signature) == 0 {
+		return ctx
+	}
+
+	return context.WithValue(ctx, verifiedKey{}, verifiedSignature{signature: params.signature, keyID: keyID})
+}
+
+// verifiedKeyID returns the key id of the signature of the request if it was
+// already verified
+func verifiedKeyID(req *http.Request) (string, bool) {
+	verified, ok := req.Context().Value(verifiedKey{}).(verifiedSignature)
+	if !ok {
+		return "", false
+	}
+
+	params, err := parseSignatureParams(req)
+	if err != nil || params.signature != verified.signature {
+		return "", false
+	}
+
+	return verified.keyID, true
+}
+
 // AuthMiddleware implements https://tools.ietf.org/html/draft-cavage-http-signatures-12
 // authentication scheme as an HTTP middleware
 type AuthMiddleware struct {
 	verifier *httpsig.Verifier
+	keys     historyKeyGetter
+	scopes   []types.Scope
+	verified Identity
 }
 
 // NewAuthMiddleware creates a new AuthMiddleware using the v httpsig.Verifier
@@ -88,6 +184,96 @@ func NewAuthMiddleware(v *httpsig.Verifier) *AuthMiddleware {
 	}
 }
 
+// AllowTokens lets requests signed with a token with one of the scopes
+// change data. Without it, tokens can only be used for read requests
+func (a *AuthMiddleware) AllowTokens(scopes ...types.Scope) *AuthMiddleware {
+	a.scopes = append(a.scopes, scopes...)
+	return a
+}
+
+// WithKeyHistory verifies the signatures with the key the signer had when
+// the request was created, so requests signed right before a key rotation are
+// still accepted
+func (a *AuthMiddleware) WithKeyHistory(keys historyKeyGetter) *AuthMiddleware {
+	a.keys = keys
+	return a
+}
+
+// ReuseVerified trusts the signatures of the identities of the kind (users
+// or nodes) which were already verified by a previous middleware, like the
+// rate limiter, instead of verifying them again. The signatures are still
+// protected against replays
+func (a *AuthMiddleware) ReuseVerified(identity Identity) *AuthMiddleware {
+	a.verified = identity
+	return a
+}
+
+// verifiedKeyID returns the key id of the signature of the request if a
+// previous middleware verified it for the kind of identity of a
+func (a *AuthMiddleware) verifiedKeyID(req *http.Request) (string, bool) {
+	if len(a.verified) == 0 {
+		return "", false
+	}
+
+	keyID, ok := verifiedKeyID(req)
+	if !ok || keyIdentity(keyID) != a.verified {
+		return "", false
+	}
+
+	return keyID, true
+}
+
+// verifierOf returns the verifier of the signature of the request
+func (a *AuthMiddleware) verifierOf(req *http.Request) *httpsig.Verifier {
+	// the creation time is only bound to the server time if the clock skew
+	// is checked
+	if a.keys == nil || config.Config.AuthMaxSkew <= 0 {
+		return a.verifier
+	}
+
+	params, err := parseSignatureParams(req)
+	if err != nil || params.created == 0 {
+		return a.verifier
+	}
+
+	v := httpsig.NewVerifier(a.keys.At(time.Unix(params.created, 0)))
+	v.SetRequiredHeaders(a.verifier.RequiredHeaders())
+	return v
+}
+
+// authorizeToken checks that the token can be used for the request
+func (a *AuthMiddleware) authorizeToken(req *http.Request, tokenID string) (types.Token, error) {
+	token, err := types.TokenFilter{}.WithID(tokenID).Get(req.Context(), Database(req))
+	if err != nil {
+		return token, err
+	}
+
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		return token, nil
+	}
+
+	for _, scope := range a.scopes {
+		if token.HasScope(scope) {
+			return token, nil
+		}
+	}
+
+	return token, fmt.Errorf("token is not allowed to %s %s", req.Method, req.URL.Path)
+}
+
+func writeAuthError(w http.ResponseWriter, status int, err error) {
+	w.WriteHeader(status)
+
+	object := struct {
+		Error string `json:"error"`
+	}{
+		Error: err.Error(),
+	}
+	if err := json.NewEncoder(w).Encode(object); err != nil {
+		log.Error().Err(err).Msg("failed to encode return object")
+	}
+}
+
 // Middleware implements mux.Middlware interface
 func (a *AuthMiddleware) Middleware(handler http.Handler) http.Handler {
 	var challengeParams []string
@@ -102,23 +288,36 @@ func (a *AuthMiddleware) Middleware(handler http.Handler) http.Handler {
 	}
 
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		keyID, err := a.verifier.Verify(req)
+		keyID, ok := a.verifiedKeyID(req)
+		var err error
+		if ok {
+			err = checkSignatureUse(req, keyID)
+		} else {
+			keyID, err = VerifyRequest(a.verifierOf(req), req)
+		}
 		if err != nil {
 			w.Header()["WWW-Authenticate"] = []string{challenge}
-			w.WriteHeader(http.StatusUnauthorized)
-
 			log.Error().Err(err).Msgf("unauthorized access to %s", req.URL.Path)
+			writeAuthError(w, http.StatusUnauthorized, errors.Wrap(err, "unauthorized access"))
+			return
+		}
 
-			object := struct {
-				Error string `json:"error"`
-			}{
-				Error: errors.Wrap(err, "unauthorized access").Error(),
+		ctx := req.Context()
+		// requests signed with a token are handled as requests of the user
+		// owning the token, the token is kept in the context for the
+		// handlers which further restrict it
+		if tokenID, ok := types.ParseTokenKeyID(keyID); ok {
+			token, err := a.authorizeToken(req, tokenID)
+			if err != nil {
+				log.Error().Err(err).Msgf("forbidden token access to %s", req.URL.Path)
+				writeAuthError(w, http.StatusForbidden, errors.Wrap(err, "forbidden access"))
+				return
 			}
-			if err := json.NewEncoder(w).Encode(object); err != nil {
-				log.Error().Err(err).Msg("failed to encode return object")
-			}
-			return
+
+			keyID = fmt.Sprint(int64(token.UserID))
+			ctx = WithToken(ctx, token)
 		}
-		handler.ServeHTTP(w, req.WithContext(httpsig.WithKeyID(req.Context(), keyID)))
+
+		handler.ServeHTTP(w, req.WithContext(httpsig.WithKeyID(ctx, keyID)))
 	})
 }
@@ -0,0 +1,64 @@
+package mw
+
+import (
+	"crypto/ed25519"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/tfexplorer/config"
+	"github.com/zaibon/httpsig"
+)
+
+// rotatedKeyGetter returns the old key before the rotation and the new key after
+type rotatedKeyGetter struct {
+	old, new ed25519.PublicKey
+	rotated  time.Time
+}
+
+func (g rotatedKeyGetter) At(t time.Time) httpsig.KeyGetter {
+	if t.Before(g.rotated) {
+		return staticKeyGetter{key: g.old}
+	}
+	return staticKeyGetter{key: g.new}
+}
+
+func TestAuthMiddlewareKeyHistory(t *testing.T) {
+	oldPk, oldSk, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	newPk, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	request := func(t *testing.T) *http.Request {
+		req, err := http.NewRequest(http.MethodGet, "http://explorer/api/v1/test", nil)
+		require.NoError(t, err)
+		req.Header.Set("threebot-id", "1")
+		require.NoError(t, httpsig.NewSigner("1", oldSk, httpsig.Ed25519, requiredHeaders).Sign(req))
+		return req
+	}
+
+	auth := NewAuthMiddleware(httpsig.NewVerifier(staticKeyGetter{key: newPk}))
+
+	_, err = VerifyRequest(auth.verifierOf(request(t)), request(t))
+	assert.Error(t, err, "without history the current key is used")
+
+	auth.WithKeyHistory(rotatedKeyGetter{old: oldPk, new: newPk, rotated: time.Now().Add(time.Minute)})
+	req := request(t)
+	_, err = VerifyRequest(auth.verifierOf(req), req)
+	assert.NoError(t, err, "a request created before the rotation uses the old key")
+
+	auth.WithKeyHistory(rotatedKeyGetter{old: oldPk, new: newPk, rotated: time.Now().Add(-time.Minute)})
+	req = request(t)
+	_, err = VerifyRequest(auth.verifierOf(req), req)
+	assert.Error(t, err, "a request created after the rotation uses the new key")
+
+	skew := config.Config.AuthMaxSkew
+	defer func() { config.Config.AuthMaxSkew = skew }()
+	config.Config.AuthMaxSkew = 0
+
+	auth.WithKeyHistory(rotatedKeyGetter{old: oldPk, new: newPk, rotated: time.Now().Add(time.Minute)})
+	req = request(t)
+	assert.Equal(t, auth.verifier, auth.verifierOf(req), "the creation time is not trusted without a clock skew check")
+}
@@ -0,0 +1,185 @@
+package mw
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/tfexplorer/config"
+	"github.com/threefoldtech/tfexplorer/pkg/phonebook/types"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"github.com/zaibon/httpsig"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Identity is the kind of identity which must sign the requests of a route
+type Identity string
+
+const (
+	// Anonymous routes accept unsigned requests. The handler might still
+	// verify a signature of the payload
+	Anonymous Identity = "anonymous"
+	// User routes require a request signed by a user, or by a token of the
+	// user. If the route has a user_id variable, the request must be signed
+	// by this user
+	User Identity = "user"
+	// Node routes require a request signed by a node or a gateway. If the
+	// route has a node_id variable, the request must be signed by this node
+	Node Identity = "node"
+	// Farmer routes require a request signed by a user. If the route has a
+	// farm_id variable, the user must own the farm, otherwise the handler
+	// checks the user owns the farm of the resource
+	Farmer Identity = "farmer"
+)
+
+// ErrNoPolicy is returned when a route has no authorization policy
+var ErrNoPolicy = errors.New("route has no authorization policy")
+
+// Policy declares who can call a route
+type Policy struct {
+	Identity Identity
+	// Scopes are the scopes of the tokens allowed to change data through
+	// the route. Without scopes, tokens can only be used to read
+	Scopes []types.Scope
+	// KeyOnly refuses the requests signed with a token, for the routes
+	// managing the keys and the tokens of the user
+	KeyOnly bool
+	// AllowUnsigned accepts the unsigned requests of the routes which did
+	// not require a signature before, until config.Config.RequireNodeSignatures
+	// is set. Signed requests are still verified
+	AllowUnsigned bool
+}
+
+// Policies declares the policy of the routes by route name
+type Policies map[string]Policy
+
+// FarmOwnerGetter returns the id of the owner of a farm
+type FarmOwnerGetter func(r *http.Request, farmID schema.ID) (int64, error)
+
+// Authorizer enforces the policy of the matched route before calling the
+// handler. Requests to a route without policy are refused
+type Authorizer struct {
+	policies Policies
+	auth     map[string]*AuthMiddleware
+	farms    FarmOwnerGetter
+}
+
+// NewAuthorizer creates an authorizer for the routes of the policies. The
+// farms getter is required to authorize the Farmer routes with a farm_id
+// variable, it can be nil otherwise
+func NewAuthorizer(db *mongo.Database, policies Policies, farms FarmOwnerGetter) *Authorizer {
+	keys := NewUserKeyGetter(db)
+	users := httpsig.NewVerifier(keys)
+	nodes := httpsig.NewVerifier(NewNodeKeyGetter())
+
+	auth := make(map[string]*AuthMiddleware)
+	for name, policy := range policies {
+		switch policy.Identity {
+		case Anonymous:
+		case User, Farmer:
+			auth[name] = NewAuthMiddleware(users).WithKeyHistory(keys).AllowTokens(policy.Scopes...).ReuseVerified(User)
+		case Node:
+			auth[name] = NewAuthMiddleware(nodes).ReuseVerified(Node)
+		default:
+			panic(fmt.Sprintf("invalid identity '%s' in the policy of route %s", policy.Identity, name))
+		}
+	}
+
+	return &Authorizer{
+		policies: policies,
+		auth:     auth,
+		farms:    farms,
+	}
+}
+
+// authorize checks that the signer of the request is allowed to call the
+// route. It is called once the request is authenticated
+func (a *Authorizer) authorize(req *http.Request, policy Policy) (int, error) {
+	keyID := httpsig.KeyIDFromContext(req.Context())
+	vars := mux.Vars(req)
+
+	if _, ok := TokenFromContext(req.Context()); ok && policy.KeyOnly {
+		return http.StatusForbidden, fmt.Errorf("tokens are not allowed to %s %s", req.Method, req.URL.Path)
+	}
+
+	switch policy.Identity {
+	case User:
+		if userID, ok := vars["user_id"]; ok && userID != keyID {
+			return http.StatusForbidden, fmt.Errorf("request must be signed by user '%s'", userID)
+		}
+	case Node:
+		if nodeID, ok := vars["node_id"]; ok && nodeID != keyID {
+			return http.StatusForbidden, fmt.Errorf("request must be signed by node '%s'", nodeID)
+		}
+	case Farmer:
+		sfarmID, ok := vars["farm_id"]
+		if !ok {
+			return 0, nil
+		}
+
+		if a.farms == nil {
+			return http.StatusInternalServerError, fmt.Errorf("farm owners are not configured")
+		}
+
+		farmID, err := strconv.ParseInt(sfarmID, 10, 64)
+		if err != nil {
+			return http.StatusBadRequest, errors.Wrap(err, "invalid farm id")
+		}
+
+		owner, err := a.farms(req, schema.ID(farmID))
+		if err != nil {
+			return http.StatusNotFound, errors.Wrapf(err, "farm '%d' not found", farmID)
+		}
+
+		if fmt.Sprint(owner) != keyID {
+			return http.StatusForbidden, fmt.Errorf("request must be signed by the owner of farm '%d'", farmID)
+		}
+	}
+
+	return 0, nil
+}
+
+// Middleware implements mux.Middlware interface
+func (a *Authorizer) Middleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var name string
+		if route := mux.CurrentRoute(req); route != nil {
+			name = route.GetName()
+		}
+
+		policy, ok := a.policies[name]
+		if !ok {
+			log.Error().Str("route", name).Msgf("no authorization policy for %s", req.URL.Path)
+			w.Header().Set("Content-Type", "application/json")
+			writeAuthError(w, http.StatusInternalServerError, errors.Wrap(ErrNoPolicy, name))
+			return
+		}
+
+		if policy.Identity == Anonymous {
+			handler.ServeHTTP(w, req)
+			return
+		}
+
+		if policy.AllowUnsigned && !config.Config.RequireNodeSignatures && len(req.Header.Get("Authorization")) == 0 {
+			log.Warn().Str("route", name).Msgf("deprecated unsigned request to %s", req.URL.Path)
+			handler.ServeHTTP(w, req)
+			return
+		}
+
+		authorized := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if status, err := a.authorize(req, policy); err != nil {
+				log.Error().Err(err).Msgf("forbidden access to %s", req.URL.Path)
+				w.Header().Set("Content-Type", "application/json")
+				writeAuthError(w, status, err)
+				return
+			}
+
+			handler.ServeHTTP(w, req)
+		})
+
+		a.auth[name].Middleware(authorized).ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,139 @@
+package mw_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/tfexplorer/config"
+	generated "github.com/threefoldtech/tfexplorer/models/generated/directory"
+	"github.com/threefoldtech/tfexplorer/mw"
+	"github.com/threefoldtech/tfexplorer/pkg/directory"
+	"github.com/threefoldtech/tfexplorer/pkg/phonebook"
+	"github.com/threefoldtech/tfexplorer/pkg/workloads"
+)
+
+// routeVars are valid values of the variables of the routes
+var routeVars = map[string]string{
+	"farm_id":     "1",
+	"node_id":     "node",
+	"threebot_id": "1",
+	"user_id":     "1",
+	"token_id":    "token",
+	"id":          "1",
+	"owner":       "1",
+	"res_id":      "1",
+	"gwid":        "1-1",
+}
+
+var routeVarRE = regexp.MustCompile(`\{([^}:]+)`)
+
+// unsignedMutations are the anonymous routes changing data, their payload
+// is signed and verified by the handler
+var unsignedMutations = map[string]bool{
+	"user-create-v1":                        true,
+	"user-create":                           true,
+	"user-register-v1":                      true,
+	"user-register":                         true,
+	"user-validate-v1":                      true,
+	"user-validate":                         true,
+	"user-rotate-v1":                        true,
+	"farm-register-v1":                      true,
+	"farm-register":                         true,
+	"versionned-pool-create":                true,
+	"pool-create":                           true,
+	"versionned-reservation-sign-provision": true,
+	"versionned-reservation-sign-delete":    true,
+	"versionned-workloads-bulk-delete":      true,
+	"reservation-sign-provision":            true,
+	"reservation-sign-delete":               true,
+	"workload-sign-provision":               true,
+	"workload-sign-delete":                  true,
+}
+
+// TestRoutePolicies checks every registered route has a policy, and that
+// the routes which are not anonymous refuse unsigned requests
+func TestRoutePolicies(t *testing.T) {
+	limits := config.Config.RateLimits
+	config.Config.RateLimits = config.RateLimits{
+		config.RateLimitDirectory: {Rate: 0, Burst: 1},
+		config.RateLimitPhonebook: {Rate: 0, Burst: 1},
+		config.RateLimitWorkloads: {Rate: 0, Burst: 1},
+	}
+	defer func() { config.Config.RateLimits = limits }()
+
+	// the routes which accepted unsigned node requests before only refuse
+	// them once node signatures are required, see TestAuthorizerAllowUnsigned
+	config.Config.RequireNodeSignatures = true
+	defer func() { config.Config.RequireNodeSignatures = false }()
+
+	router := mux.NewRouter()
+	directory.Routes(router, nil, generated.NodeCloudUnitPrice{})
+	phonebook.Routes(router, nil, "")
+	workloads.Routes(router, nil, "", nil, nil)
+
+	policies := mw.Policies{}
+	for _, p := range []mw.Policies{directory.Policies, phonebook.Policies, workloads.Policies} {
+		for name, policy := range p {
+			require.NotContains(t, policies, name, "route %s has 2 policies", name)
+			policies[name] = policy
+		}
+	}
+
+	registered := map[string]bool{}
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		if route.GetHandler() == nil {
+			return nil
+		}
+
+		tpl, err := route.GetPathTemplate()
+		require.NoError(t, err)
+
+		name := route.GetName()
+		registered[name] = true
+
+		methods, err := route.GetMethods()
+		require.NoError(t, err)
+
+		policy, ok := policies[name]
+		if !assert.True(t, ok, "route %s (%s) has no policy", tpl, name) {
+			return nil
+		}
+
+		if policy.Identity == mw.Anonymous {
+			for _, method := range methods {
+				if method != http.MethodGet {
+					assert.True(t, unsignedMutations[name], "%s %s (%s) changes data without signature", method, tpl, name)
+				}
+			}
+			return nil
+		}
+
+		var pairs []string
+		for _, match := range routeVarRE.FindAllStringSubmatch(tpl, -1) {
+			value, ok := routeVars[match[1]]
+			require.True(t, ok, "no value for variable %s of route %s", match[1], tpl)
+			pairs = append(pairs, match[1], value)
+		}
+
+		u, err := route.URLPath(pairs...)
+		require.NoError(t, err)
+
+		for _, method := range methods {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(method, u.String(), nil))
+			assert.Equal(t, http.StatusUnauthorized, rec.Code, "%s %s (%s) accepts unsigned requests", method, tpl, name)
+		}
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	for name := range policies {
+		assert.True(t, registered[name], "policy of route %s which is not registered", name)
+	}
+}
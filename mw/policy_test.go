@@ -0,0 +1,158 @@
+package mw
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jbenet/go-base58"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/tfexplorer/config"
+	"github.com/threefoldtech/tfexplorer/pkg/phonebook/types"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"github.com/zaibon/httpsig"
+)
+
+func TestAuthorizerMiddleware(t *testing.T) {
+	authorizer := NewAuthorizer(nil, Policies{
+		"public":   {Identity: Anonymous},
+		"node":     {Identity: Node},
+		"any-node": {Identity: Node},
+	}, nil)
+
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	router := mux.NewRouter()
+	router.Use(authorizer.Middleware)
+	router.HandleFunc("/public", ok).Name("public")
+	router.HandleFunc("/nodes", ok).Name("any-node")
+	router.HandleFunc("/nodes/{node_id}", ok).Name("node")
+	router.HandleFunc("/missing", ok).Name("missing")
+
+	newNode := func(t *testing.T) (string, ed25519.PrivateKey) {
+		pk, sk, err := ed25519.GenerateKey(nil)
+		require.NoError(t, err)
+		return base58.Encode(pk), sk
+	}
+
+	serve := func(path string, signer string, sk ed25519.PrivateKey) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if sk != nil {
+			req.Header.Set("threebot-id", signer)
+			require.NoError(t, httpsig.NewSigner(signer, sk, httpsig.Ed25519, requiredHeaders).Sign(req))
+		}
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	node, nodeKey := newNode(t)
+	other, otherKey := newNode(t)
+
+	assert.Equal(t, http.StatusOK, serve("/public", "", nil))
+	assert.Equal(t, http.StatusUnauthorized, serve("/nodes", "", nil))
+	assert.Equal(t, http.StatusOK, serve("/nodes", other, otherKey))
+	assert.Equal(t, http.StatusUnauthorized, serve("/nodes/"+node, "", nil))
+	assert.Equal(t, http.StatusForbidden, serve("/nodes/"+node, other, otherKey))
+	assert.Equal(t, http.StatusOK, serve("/nodes/"+node, node, nodeKey))
+	assert.Equal(t, http.StatusInternalServerError, serve("/missing", "", nil), "routes without policy are refused")
+}
+
+func TestAuthorizerAllowUnsigned(t *testing.T) {
+	authorizer := NewAuthorizer(nil, Policies{
+		"legacy": {Identity: Node, AllowUnsigned: true},
+	}, nil)
+
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	router := mux.NewRouter()
+	router.Use(authorizer.Middleware)
+	router.HandleFunc("/nodes/{node_id}", ok).Name("legacy")
+
+	pk, sk, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	node := base58.Encode(pk)
+	_, otherKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	serve := func(sk ed25519.PrivateKey) int {
+		req := httptest.NewRequest(http.MethodPut, "/nodes/"+node, nil)
+		if sk != nil {
+			// the signature of a request changing data can only be used once
+			req.Header.Set("threebot-id", node)
+			req.Header.Set("nonce", fmt.Sprint(time.Now().UnixNano()))
+			headers := append([]string{"(request-target)", "nonce"}, requiredHeaders...)
+			require.NoError(t, httpsig.NewSigner(node, sk, httpsig.Ed25519, headers).Sign(req))
+		}
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve(nil), "unsigned requests are deprecated but accepted")
+	assert.Equal(t, http.StatusOK, serve(sk))
+	assert.Equal(t, http.StatusUnauthorized, serve(otherKey), "signed requests are still verified")
+
+	config.Config.RequireNodeSignatures = true
+	defer func() { config.Config.RequireNodeSignatures = false }()
+
+	assert.Equal(t, http.StatusUnauthorized, serve(nil))
+	assert.Equal(t, http.StatusOK, serve(sk))
+}
+
+func TestAuthorize(t *testing.T) {
+	farms := func(r *http.Request, farmID schema.ID) (int64, error) {
+		if farmID != 1 {
+			return 0, fmt.Errorf("farm not found")
+		}
+		return 10, nil
+	}
+	authorizer := NewAuthorizer(nil, Policies{}, farms)
+
+	newRequest := func(keyID string, vars map[string]string, token bool) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/test", nil)
+		ctx := httpsig.WithKeyID(context.Background(), keyID)
+		if token {
+			ctx = WithToken(ctx, types.Token{UserID: 10})
+		}
+		return mux.SetURLVars(req.WithContext(ctx), vars)
+	}
+
+	tests := []struct {
+		name   string
+		policy Policy
+		req    *http.Request
+		status int
+	}{
+		{"user", Policy{Identity: User}, newRequest("10", nil, false), 0},
+		{"user of the route", Policy{Identity: User}, newRequest("10", map[string]string{"user_id": "10"}, false), 0},
+		{"other user", Policy{Identity: User}, newRequest("11", map[string]string{"user_id": "10"}, false), http.StatusForbidden},
+		{"token", Policy{Identity: User}, newRequest("10", map[string]string{"user_id": "10"}, true), 0},
+		{"token refused", Policy{Identity: User, KeyOnly: true}, newRequest("10", map[string]string{"user_id": "10"}, true), http.StatusForbidden},
+		{"farmer", Policy{Identity: Farmer}, newRequest("10", map[string]string{"farm_id": "1"}, false), 0},
+		{"not the farmer", Policy{Identity: Farmer}, newRequest("11", map[string]string{"farm_id": "1"}, false), http.StatusForbidden},
+		{"unknown farm", Policy{Identity: Farmer}, newRequest("10", map[string]string{"farm_id": "2"}, false), http.StatusNotFound},
+		{"invalid farm", Policy{Identity: Farmer}, newRequest("10", map[string]string{"farm_id": "farm"}, false), http.StatusBadRequest},
+		{"farmer of the resource", Policy{Identity: Farmer}, newRequest("11", nil, false), 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, err := authorizer.authorize(test.req, test.policy)
+			assert.Equal(t, test.status, status)
+			assert.Equal(t, test.status != 0, err != nil)
+		})
+	}
+}
+
+func TestNewAuthorizerInvalidIdentity(t *testing.T) {
+	assert.Panics(t, func() {
+		NewAuthorizer(nil, Policies{"test": {Identity: "admin"}}, nil)
+	})
+}
@@ -0,0 +1,224 @@
+package mw
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/tfexplorer/config"
+	"github.com/threefoldtech/tfexplorer/pkg/phonebook/types"
+	"github.com/zaibon/httpsig"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// rateLimitIdleTimeout is the time after which the bucket of an identity
+// which made no requests is forgotten
+const rateLimitIdleTimeout = 10 * time.Minute
+
+var throttledRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "explorer",
+	Name:      "ratelimit_throttled_total",
+	Help:      "The total number of requests refused because the client exceeded its rate limit",
+}, []string{"group", "kind"})
+
+func init() {
+	prometheus.MustRegister(throttledRequests)
+}
+
+// bucket is a token bucket, it is refilled at a constant rate up to its burst
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take a token from the bucket. If the bucket is empty, it returns the time
+// to wait before a token is available
+func (b *bucket) take(limit config.RateLimit, now time.Time) (bool, time.Duration) {
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	return false, time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+}
+
+// identityKeyGetter finds the key of the signature of users, tokens and nodes
+type identityKeyGetter struct {
+	users UserKeyGetter
+	nodes NodeKeyGetter
+}
+
+// keyIdentity returns the kind of identity of a key id: the users and their
+// tokens, or the nodes
+func keyIdentity(id string) Identity {
+	if _, ok := types.ParseTokenKeyID(id); ok {
+		return User
+	}
+
+	if _, err := strconv.ParseInt(id, 10, 64); err == nil {
+		return User
+	}
+
+	return Node
+}
+
+// GetKey implements httpsig.KeyGetter
+func (g identityKeyGetter) GetKey(id string) (interface{}, error) {
+	if keyIdentity(id) == User {
+		return g.users.GetKey(id)
+	}
+
+	return g.nodes.GetKey(id)
+}
+
+// RateLimiter throttles the requests made to a group of routes. The requests
+// to the node routes are limited per node, the signed requests per key id, so
+// per user or token, and anonymous requests per client IP. The budget of the
+// group is read from config.Config.RateLimits
+type RateLimiter struct {
+	group    string
+	verifier *httpsig.Verifier
+	// nodeRoutes are the names of the routes limited on their node_id
+	nodeRoutes map[string]struct{}
+
+	m       sync.Mutex
+	buckets map[string]*bucket
+	cleaned time.Time
+}
+
+// NewRateLimiter creates a rate limiter for the group of routes
+func NewRateLimiter(group string, db *mongo.Database) *RateLimiter {
+	verifier := httpsig.NewVerifier(identityKeyGetter{
+		users: NewUserKeyGetter(db),
+		nodes: NewNodeKeyGetter(),
+	})
+	verifier.SetRequiredHeaders(requiredHeaders)
+
+	return &RateLimiter{
+		group:      group,
+		verifier:   verifier,
+		nodeRoutes: make(map[string]struct{}),
+		buckets:    make(map[string]*bucket),
+	}
+}
+
+// ForNodes limits the node routes of the policies per node, on their node_id
+// variable, whether the requests of the node are signed or not
+func (l *RateLimiter) ForNodes(policies Policies) *RateLimiter {
+	for name, policy := range policies {
+		if policy.Identity == Node {
+			l.nodeRoutes[name] = struct{}{}
+		}
+	}
+
+	return l
+}
+
+// allow takes a token from the bucket of the identity
+func (l *RateLimiter) allow(identity string, limit config.RateLimit, now time.Time) (bool, time.Duration) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if now.Sub(l.cleaned) > time.Minute {
+		for id, b := range l.buckets {
+			if now.Sub(b.last) > rateLimitIdleTimeout {
+				delete(l.buckets, id)
+			}
+		}
+		l.cleaned = now
+	}
+
+	b, ok := l.buckets[identity]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		l.buckets[identity] = b
+	}
+
+	return b.take(limit, now)
+}
+
+// identity returns the key the request is limited on, and its kind. The
+// signature is verified with the current key of the signer, it is not
+// consumed: the verified signature is added to the context of the returned
+// request, the auth middleware of the route only protects it against replays
+func (l *RateLimiter) identity(req *http.Request) (string, string, *http.Request) {
+	if route := mux.CurrentRoute(req); route != nil {
+		if _, ok := l.nodeRoutes[route.GetName()]; ok {
+			if nodeID := mux.Vars(req)["node_id"]; len(nodeID) > 0 {
+				return nodeID, "node", req
+			}
+		}
+	}
+
+	if keyID := httpsig.KeyIDFromContext(req.Context()); len(keyID) > 0 {
+		return keyID, "key", req
+	}
+
+	if len(req.Header.Get("Authorization")) > 0 {
+		if keyID, err := l.verifier.Verify(req); err == nil {
+			return keyID, "key", req.WithContext(withVerifiedSignature(req.Context(), req, keyID))
+		}
+	}
+
+	return clientIP(req), "ip", req
+}
+
+// clientIP returns the IP of the client of the request. The proxy headers
+// are only used if the explorer is configured to run behind a proxy
+func clientIP(req *http.Request) string {
+	if config.Config.TrustForwardedFor {
+		if ip := strings.TrimSpace(req.Header.Get("X-Real-Ip")); len(ip) > 0 {
+			return ip
+		}
+
+		// the last entry is the one added by our proxy, the others can be
+		// set by the client
+		if forwarded := req.Header.Get("X-Forwarded-For"); len(forwarded) > 0 {
+			ips := strings.Split(forwarded, ",")
+			return strings.TrimSpace(ips[len(ips)-1])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+
+	return host
+}
+
+// Middleware implements mux.Middlware interface
+func (l *RateLimiter) Middleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		limit := config.Config.RateLimits.Get(l.group)
+		if limit.Rate <= 0 {
+			handler.ServeHTTP(w, req)
+			return
+		}
+
+		identity, kind, req := l.identity(req)
+		ok, wait := l.allow(identity, limit, time.Now())
+		if !ok {
+			throttledRequests.WithLabelValues(l.group, kind).Inc()
+			log.Warn().Str("group", l.group).Str(kind, identity).Msgf("rate limit exceeded on %s", req.URL.Path)
+
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", fmt.Sprint(int64(math.Ceil(wait.Seconds()))))
+			writeAuthError(w, http.StatusTooManyRequests, fmt.Errorf("rate limit exceeded, retry in %s", wait.Round(time.Second)))
+			return
+		}
+
+		handler.ServeHTTP(w, req)
+	})
+}
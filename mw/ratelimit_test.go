@@ -0,0 +1,153 @@
+package mw
+
+import (
+	"crypto/ed25519"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jbenet/go-base58"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/tfexplorer/config"
+	"github.com/zaibon/httpsig"
+)
+
+func TestBucket(t *testing.T) {
+	limit := config.RateLimit{Rate: 2, Burst: 3}
+	now := time.Now()
+	b := bucket{tokens: 3, last: now}
+
+	for i := 0; i < 3; i++ {
+		ok, _ := b.take(limit, now)
+		assert.True(t, ok)
+	}
+
+	ok, wait := b.take(limit, now)
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	ok, _ = b.take(limit, now.Add(500*time.Millisecond))
+	assert.True(t, ok)
+
+	// the bucket never holds more than its burst
+	for i := 0; i < 3; i++ {
+		ok, _ := b.take(limit, now.Add(time.Hour))
+		assert.True(t, ok)
+	}
+	ok, _ = b.take(limit, now.Add(time.Hour))
+	assert.False(t, ok)
+}
+
+func TestRateLimiterMiddleware(t *testing.T) {
+	config.Config.RateLimits = config.RateLimits{config.RateLimitWorkloads: {Rate: 0.001, Burst: 2}}
+	defer func() { config.Config.RateLimits = nil }()
+
+	limiter := &RateLimiter{group: config.RateLimitWorkloads, buckets: make(map[string]*bucket)}
+	handler := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	do := func(addr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/reservations/workloads", nil)
+		req.RemoteAddr = addr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	require.Equal(t, http.StatusOK, do("10.0.0.1:1000").Code)
+	require.Equal(t, http.StatusOK, do("10.0.0.1:1001").Code)
+
+	rec := do("10.0.0.1:1002")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+	// other clients have their own budget
+	require.Equal(t, http.StatusOK, do("10.0.0.2:1000").Code)
+}
+
+func TestRateLimiterNodeRoutes(t *testing.T) {
+	config.Config.RateLimits = config.RateLimits{config.RateLimitWorkloads: {Rate: 0.001, Burst: 1}}
+	defer func() { config.Config.RateLimits = nil }()
+
+	limiter := (&RateLimiter{
+		group:      config.RateLimitWorkloads,
+		nodeRoutes: make(map[string]struct{}),
+		buckets:    make(map[string]*bucket),
+	}).ForNodes(Policies{"poll": {Identity: Node}, "list": {Identity: User}})
+
+	router := mux.NewRouter()
+	router.Use(limiter.Middleware)
+	router.HandleFunc("/nodes/{node_id}/workloads", func(w http.ResponseWriter, r *http.Request) {}).Name("poll")
+
+	do := func(node, addr string) int {
+		req := httptest.NewRequest(http.MethodGet, "/nodes/"+node+"/workloads", nil)
+		req.RemoteAddr = addr
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// the nodes behind the same NAT have their own budget
+	require.Equal(t, http.StatusOK, do("node1", "10.0.0.1:1000"))
+	require.Equal(t, http.StatusOK, do("node2", "10.0.0.1:1000"))
+	assert.Equal(t, http.StatusTooManyRequests, do("node1", "10.0.0.2:1000"), "the budget is per node, not per client")
+}
+
+// countingKeyGetter counts the keys looked up to verify signatures
+type countingKeyGetter struct {
+	key   ed25519.PublicKey
+	count *int
+}
+
+func (g countingKeyGetter) GetKey(id string) (interface{}, error) {
+	*g.count++
+	return g.key, nil
+}
+
+func TestRateLimiterReusesVerification(t *testing.T) {
+	config.Config.RateLimits = config.RateLimits{config.RateLimitWorkloads: {Rate: 100, Burst: 100}}
+	defer func() { config.Config.RateLimits = nil }()
+
+	pk, sk, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	var count int
+	keys := countingKeyGetter{key: pk, count: &count}
+
+	verifier := httpsig.NewVerifier(keys)
+	verifier.SetRequiredHeaders(requiredHeaders)
+	limiter := &RateLimiter{group: config.RateLimitWorkloads, verifier: verifier, buckets: make(map[string]*bucket)}
+	auth := NewAuthMiddleware(httpsig.NewVerifier(keys)).ReuseVerified(User)
+	handler := limiter.Middleware(auth.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	do := func(keyID string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/reservations/workloads", nil)
+		req.Header.Set("threebot-id", keyID)
+		require.NoError(t, httpsig.NewSigner(keyID, sk, httpsig.Ed25519, requiredHeaders).Sign(req))
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, do("1"))
+	assert.Equal(t, 1, count, "the signature verified by the limiter is not verified again")
+
+	count = 0
+	assert.Equal(t, http.StatusOK, do(base58.Encode(pk)))
+	assert.Equal(t, 2, count, "the signature of a node is verified again by the auth of a user route")
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:1000"
+	req.Header.Set("X-Forwarded-For", "1.1.1.1, 2.2.2.2")
+
+	assert.Equal(t, "10.0.0.1", clientIP(req))
+
+	config.Config.TrustForwardedFor = true
+	defer func() { config.Config.TrustForwardedFor = false }()
+	assert.Equal(t, "2.2.2.2", clientIP(req))
+}
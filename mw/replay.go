@@ -0,0 +1,302 @@
+package mw
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/tfexplorer/config"
+	"github.com/zaibon/httpsig"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// UsedSignatureCollection db collection of the signatures used by requests,
+	// they are removed once they expire
+	UsedSignatureCollection = "used-signatures"
+)
+
+var (
+	// ErrSignatureExpired is returned when the creation time of a signed
+	// request is too far from the server time
+	ErrSignatureExpired = errors.New("request signature is expired or created in the future")
+	// ErrSignatureReplayed is returned when a signature is used for more than
+	// one request changing data
+	ErrSignatureReplayed = errors.New("request signature was already used")
+	// ErrBadDigest is returned when the body digest of a request is missing,
+	// not signed or does not match the body
+	ErrBadDigest = errors.New("bad request body digest")
+)
+
+// signatureParamRE scans the parameters of the signature authorization header
+var signatureParamRE = regexp.MustCompile(`(?U)\s*([a-zA-Z][a-zA-Z0-9_]*)\s*=\s*"(.*)"\s*`)
+
+// signatureParams are the parameters of the signature of a request which are
+// needed to prevent replays
+type signatureParams struct {
+	created   int64
+	headers   []string
+	signature string
+}
+
+func parseSignatureParams(req *http.Request) (params signatureParams, err error) {
+	value := strings.TrimPrefix(req.Header.Get("Authorization"), "Signature ")
+	for _, match := range signatureParamRE.FindAllStringSubmatch(value, -1) {
+		switch match[1] {
+		case "created":
+			params.created, err = strconv.ParseInt(match[2], 10, 64)
+			if err != nil {
+				return params, errors.Wrap(err, "invalid signature created parameter")
+			}
+		case "headers":
+			params.headers = strings.Fields(strings.ToLower(match[2]))
+		case "signature":
+			params.signature = match[2]
+		}
+	}
+
+	return params, nil
+}
+
+func (p signatureParams) signs(header string) bool {
+	for _, h := range p.headers {
+		if h == header {
+			return true
+		}
+	}
+
+	return false
+}
+
+// unique checks if the signature can only be made for a single request. It
+// must cover the request target, and either a nonce or the body digest.
+// Other signatures are the same for identical requests sent within the same
+// second, so they are only remembered when used to change data
+func (p signatureParams) unique() bool {
+	return p.signs("(request-target)") && (p.signs("nonce") || p.signs("digest"))
+}
+
+// signatureStore remembers the signatures used until they expire. use records
+// the use of the signature, and reports if it was a replay. Read requests can
+// reuse a signature, since clients can send identical requests within the same
+// second, but a signature used by another request can never be used to change
+// data
+type signatureStore interface {
+	use(ctx context.Context, signature string, safe bool, expiration time.Time) (bool, error)
+}
+
+// usedSignatures stores the used signatures in the database, so they are
+// shared by all the explorer instances
+type usedSignatures struct {
+	db *mongo.Database
+}
+
+func (u usedSignatures) use(ctx context.Context, signature string, safe bool, expiration time.Time) (bool, error) {
+	col := u.db.Collection(UsedSignatureCollection)
+
+	var err error
+	if safe {
+		// a signature only used by read requests matches, another use makes
+		// the upsert fail on the duplicate id
+		_, err = col.UpdateOne(ctx, usedSignatureFilter(signature, safe), usedSignatureUpdate(expiration), options.Update().SetUpsert(true))
+	} else {
+		_, err = col.InsertOne(ctx, usedSignatureDocument(signature, expiration))
+	}
+
+	if merr, ok := err.(mongo.WriteException); ok && len(merr.WriteErrors) != 0 && merr.WriteErrors[0].Code == 11000 {
+		return true, nil
+	}
+
+	return false, err
+}
+
+func usedSignatureFilter(signature string, safe bool) bson.M {
+	return bson.M{"_id": signature, "safe": safe}
+}
+
+func usedSignatureUpdate(expiration time.Time) bson.M {
+	return bson.M{"$setOnInsert": bson.M{"expiration": expiration}}
+}
+
+func usedSignatureDocument(signature string, expiration time.Time) bson.M {
+	return bson.M{"_id": signature, "safe": false, "expiration": expiration}
+}
+
+// SetupUsedSignatures creates the index removing the used signatures once
+// they expire
+func SetupUsedSignatures(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection(UsedSignatureCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"expiration": 1},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+
+	return err
+}
+
+// signatureCache remembers the signatures used until they expire in memory.
+// It is only used when no database is configured on the request
+type signatureCache struct {
+	m       sync.Mutex
+	seen    map[string]seenSignature
+	cleaned time.Time
+}
+
+type seenSignature struct {
+	expiration time.Time
+	// safe is set if the signature was only used for read requests
+	safe bool
+}
+
+// seenSignatures is shared by all the auth middlewares, so a signature can't
+// be replayed on another endpoint
+var seenSignatures = signatureCache{seen: make(map[string]seenSignature)}
+
+func (c *signatureCache) use(ctx context.Context, signature string, safe bool, expiration time.Time) (bool, error) {
+	return c.seenAt(signature, safe, expiration, time.Now()), nil
+}
+
+func (c *signatureCache) seenAt(signature string, safe bool, expiration, now time.Time) bool {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if now.Sub(c.cleaned) > time.Minute {
+		for sig, s := range c.seen {
+			if now.After(s.expiration) {
+				delete(c.seen, sig)
+			}
+		}
+		c.cleaned = now
+	}
+
+	s, ok := c.seen[signature]
+	if ok && !(safe && s.safe) {
+		return true
+	}
+
+	if !ok || !safe {
+		c.seen[signature] = seenSignature{expiration: expiration, safe: safe}
+	}
+
+	return false
+}
+
+// signatureStoreOf returns where the signatures used by the request are
+// remembered
+func signatureStoreOf(req *http.Request) signatureStore {
+	if db, ok := req.Context().Value(dbMiddlewareKey{}).(*mongo.Database); ok {
+		return usedSignatures{db: db}
+	}
+
+	return &seenSignatures
+}
+
+// verifyDigest checks the Digest header of the request against its body. The
+// header is only trusted if it is part of the signature
+func verifyDigest(req *http.Request, params signatureParams) error {
+	digest := req.Header.Get("Digest")
+	if len(digest) == 0 {
+		if config.Config.AuthRequireDigest && req.ContentLength != 0 && req.Body != nil && req.Body != http.NoBody {
+			return errors.Wrap(ErrBadDigest, "requests with a body must sign a Digest header")
+		}
+		return nil
+	}
+
+	if !params.signs("digest") {
+		return errors.Wrap(ErrBadDigest, "Digest header is not signed")
+	}
+
+	if !strings.HasPrefix(digest, "SHA-256=") {
+		return errors.Wrap(ErrBadDigest, "only SHA-256 digests are supported")
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		if err != nil {
+			return errors.Wrap(err, "failed to read request body")
+		}
+		req.Body.Close()
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	sum := sha256.Sum256(body)
+	if strings.TrimPrefix(digest, "SHA-256=") != base64.StdEncoding.EncodeToString(sum[:]) {
+		return errors.Wrap(ErrBadDigest, "Digest header does not match the body")
+	}
+
+	return nil
+}
+
+// VerifyRequest verifies the http signature of the request with v, and
+// protects against replays: the signature must be created within the maximum
+// clock skew of the server, can only be used once to change data if it is
+// unique to the request, and the signed body digest, if any, must match the
+// body.
+// It returns the key id of the signature.
+func VerifyRequest(v *httpsig.Verifier, req *http.Request) (string, error) {
+	keyID, err := v.Verify(req)
+	if err != nil {
+		return "", err
+	}
+
+	return keyID, checkSignatureUse(req, keyID)
+}
+
+// checkSignatureUse protects the verified signature of the request against
+// replays, see VerifyRequest
+func checkSignatureUse(req *http.Request, keyID string) error {
+	params, err := parseSignatureParams(req)
+	if err != nil {
+		return err
+	}
+
+	if err := verifyDigest(req, params); err != nil {
+		return err
+	}
+
+	skew := config.Config.AuthMaxSkew
+	if skew <= 0 {
+		return nil
+	}
+
+	if !params.signs("(created)") {
+		return errors.Wrap(ErrSignatureExpired, "(created) is not signed")
+	}
+
+	now := time.Now()
+	created := time.Unix(params.created, 0)
+	if created.Before(now.Add(-skew)) || created.After(now.Add(skew)) {
+		return errors.Wrapf(ErrSignatureExpired, "created at %s, the maximum clock skew is %s", created.UTC().Format(time.RFC3339), skew)
+	}
+
+	// a signature which is not unique to the request is the same for
+	// identical requests sent within the same second, its reuse is not a
+	// replay
+	if !params.unique() {
+		return nil
+	}
+
+	safe := req.Method == http.MethodGet || req.Method == http.MethodHead
+
+	replayed, err := signatureStoreOf(req).use(req.Context(), fmt.Sprintf("%s:%s", keyID, params.signature), safe, created.Add(skew))
+	if err != nil {
+		return errors.Wrap(err, "failed to check the signature was not used")
+	} else if replayed {
+		return ErrSignatureReplayed
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package mw
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zaibon/httpsig"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type staticKeyGetter struct {
+	key ed25519.PublicKey
+}
+
+func (s staticKeyGetter) GetKey(id string) (interface{}, error) {
+	return s.key, nil
+}
+
+func TestSignatureCache(t *testing.T) {
+	c := signatureCache{seen: make(map[string]seenSignature)}
+	now := time.Now()
+	exp := now.Add(time.Minute)
+
+	assert.False(t, c.seenAt("a", true, exp, now))
+	assert.False(t, c.seenAt("a", true, exp, now), "read requests can reuse a signature")
+	assert.True(t, c.seenAt("a", false, exp, now), "a read signature can't change data")
+
+	assert.False(t, c.seenAt("b", false, exp, now))
+	assert.True(t, c.seenAt("b", false, exp, now))
+	assert.True(t, c.seenAt("b", true, exp, now))
+
+	// expired signatures are forgotten
+	later := now.Add(2 * time.Minute)
+	assert.False(t, c.seenAt("b", false, later.Add(time.Minute), later))
+}
+
+func TestUsedSignatureDocuments(t *testing.T) {
+	exp := time.Now()
+
+	assert.Equal(t, bson.M{"_id": "1:sig", "safe": true}, usedSignatureFilter("1:sig", true), "a read signature only matches a signature used to read")
+	assert.Equal(t, bson.M{"$setOnInsert": bson.M{"expiration": exp}}, usedSignatureUpdate(exp))
+	assert.Equal(t, bson.M{"_id": "1:sig", "safe": false, "expiration": exp}, usedSignatureDocument("1:sig", exp))
+}
+
+func TestVerifyRequest(t *testing.T) {
+	pk, sk, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	verifier := httpsig.NewVerifier(staticKeyGetter{key: pk})
+
+	newRequest := func(t *testing.T, body []byte, digest string, headers ...string) *http.Request {
+		req, err := http.NewRequest(http.MethodPost, "http://explorer/api/v1/test", bytes.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("threebot-id", "1")
+		if len(digest) != 0 {
+			req.Header.Set("Digest", digest)
+		}
+		for _, h := range headers {
+			if h == "nonce" {
+				req.Header.Set("Nonce", fmt.Sprint(time.Now().UnixNano()))
+			}
+		}
+
+		headers = append([]string{"(created)", "date", "threebot-id"}, headers...)
+		require.NoError(t, httpsig.NewSigner("1", sk, httpsig.Ed25519, headers).Sign(req))
+		return req
+	}
+
+	t.Run("replay", func(t *testing.T) {
+		req := newRequest(t, nil, "", "(request-target)", "nonce")
+		_, err := VerifyRequest(verifier, req)
+		require.NoError(t, err)
+
+		_, err = VerifyRequest(verifier, req)
+		assert.Equal(t, ErrSignatureReplayed, err)
+
+		_, err = VerifyRequest(verifier, newRequest(t, nil, "", "(request-target)", "nonce"))
+		assert.NoError(t, err, "another nonce is another request")
+	})
+
+	t.Run("identical requests", func(t *testing.T) {
+		// without a nonce or a digest, identical requests within the same
+		// second have the same signature, it can be reused
+		req := newRequest(t, nil, "")
+		req.Method = http.MethodGet
+		_, err := VerifyRequest(verifier, req)
+		require.NoError(t, err)
+
+		_, err = VerifyRequest(verifier, req)
+		assert.NoError(t, err)
+
+		req = newRequest(t, nil, "", "(request-target)")
+		_, err = VerifyRequest(verifier, req)
+		require.NoError(t, err)
+
+		_, err = VerifyRequest(verifier, req)
+		assert.NoError(t, err, "a node posting identical results within a second is not a replay")
+	})
+
+	t.Run("digest", func(t *testing.T) {
+		body := []byte(`{"id": 1}`)
+		sum := sha256.Sum256(body)
+		digest := "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+
+		_, err := VerifyRequest(verifier, newRequest(t, body, digest, "digest"))
+		assert.NoError(t, err)
+
+		_, err = VerifyRequest(verifier, newRequest(t, []byte(`{"id": 2}`), digest, "digest"))
+		assert.True(t, errors.Is(err, ErrBadDigest))
+
+		_, err = VerifyRequest(verifier, newRequest(t, body, digest))
+		assert.True(t, errors.Is(err, ErrBadDigest), "digest must be signed")
+	})
+}
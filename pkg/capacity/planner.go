@@ -30,6 +30,13 @@ type (
 		// HasCapacity checks if the workload could be provisioned with its attached
 		// pool as it is right now.
 		HasCapacity(w workloads.Workloader, seconds uint) (bool, error)
+		// HasUpdateCapacity checks if the attached pool could support the workload
+		// replacing the previous version of the workload, for the difference of
+		// capacity between both versions.
+		HasUpdateCapacity(w, previous workloads.Workloader, seconds uint) (bool, error)
+		// HasGroupCapacity checks if the pools of the workloads could support all
+		// the workloads together.
+		HasGroupCapacity(ws []workloads.Workloader, seconds uint) (bool, error)
 		// AddUsedCapacity adds a deployed workload to the pool. If the workload
 		// is already in the pool (based on ID), nothing happens.
 		AddUsedCapacity(w workloads.Workloader) error
@@ -82,7 +89,8 @@ type (
 	}
 
 	hasCapacityJob struct {
-		w            workloads.Workloader
+		ws           []workloads.Workloader
+		previous     workloads.Workloader
 		seconds      uint
 		responseChan chan<- hasCapacityResponse
 	}
@@ -116,6 +124,9 @@ type (
 
 const (
 	maxPoolExpirationDelay = time.Hour //* 24 * 365 * 280
+	// workloadExpirationInterval is the interval at which the workloads
+	// which reached their expiry time are deleted
+	workloadExpirationInterval = time.Minute
 )
 
 var (
@@ -155,11 +166,22 @@ func (p *NaivePlanner) Run(ctx context.Context) {
 		log.Error().Err(err).Msg("failed to expire capacity pools")
 	}
 
+	if err := p.handleWorkloadExpiration(); err != nil {
+		log.Error().Err(err).Msg("failed to expire workloads")
+	}
+
+	expiration := time.NewTicker(workloadExpirationInterval)
+	defer expiration.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
 			log.Info().Msg("context is done, stopping planner")
 			return
+		case <-expiration.C:
+			if err := p.handleWorkloadExpiration(); err != nil {
+				log.Error().Err(err).Msg("failure to expire workloads")
+			}
 		case <-p.timer.C:
 			log.Info().Msg("capacity planner timer fired, pool should be expired")
 			if err := p.handlePoolExpiration(true); err != nil {
@@ -172,7 +194,7 @@ func (p *NaivePlanner) Run(ctx context.Context) {
 			status, err := p.isAllowed(job.w)
 			job.responseChan <- allowedResponse{status: status, err: err}
 		case job := <-p.hasCapacityChan:
-			status, err := p.hasCapacity(job.w, job.seconds)
+			status, err := p.hasCapacity(job.ws, job.previous, job.seconds)
 			job.responseChan <- hasCapacityResponse{status: status, err: err}
 		case job := <-p.listChan:
 			var pools []types.Pool
@@ -233,7 +255,40 @@ func (p *NaivePlanner) HasCapacity(w workloads.Workloader, seconds uint) (bool,
 	defer close(ch)
 
 	p.hasCapacityChan <- hasCapacityJob{
-		w:            w,
+		ws:           []workloads.Workloader{w},
+		seconds:      seconds,
+		responseChan: ch,
+	}
+
+	res := <-ch
+
+	return res.status, res.err
+}
+
+// HasUpdateCapacity implements Planner
+func (p *NaivePlanner) HasUpdateCapacity(w, previous workloads.Workloader, seconds uint) (bool, error) {
+	ch := make(chan hasCapacityResponse)
+	defer close(ch)
+
+	p.hasCapacityChan <- hasCapacityJob{
+		ws:           []workloads.Workloader{w},
+		previous:     previous,
+		seconds:      seconds,
+		responseChan: ch,
+	}
+
+	res := <-ch
+
+	return res.status, res.err
+}
+
+// HasGroupCapacity implements Planner
+func (p *NaivePlanner) HasGroupCapacity(ws []workloads.Workloader, seconds uint) (bool, error) {
+	ch := make(chan hasCapacityResponse)
+	defer close(ch)
+
+	p.hasCapacityChan <- hasCapacityJob{
+		ws:           ws,
 		seconds:      seconds,
 		responseChan: ch,
 	}
@@ -348,6 +403,7 @@ func (p *NaivePlanner) reserve(reservation types.Reservation, currencies []strin
 	} else {
 		// create new pool
 		pool = types.NewPool(reservation.ID, reservation.CustomerTid, reservation.SponsorTid, data.NodeIDs)
+		pool.Labels = reservation.Labels
 		pool, err = types.CapacityPoolCreate(p.ctx, p.db, pool)
 		if err != nil {
 			return pi, errors.Wrap(err, "could not create new capacity pool")
@@ -373,22 +429,50 @@ func (p *NaivePlanner) isAllowed(w workloads.Workloader) (bool, error) {
 	return pool.CustomerTid == w.GetCustomerTid() && pool.AllowedInPool(w.GetNodeID()), nil
 }
 
-// hasCapacity checks if the pool set on the workload has enough capacity to support
-// the workload for the given amount of time
-func (p *NaivePlanner) hasCapacity(w workloads.Workloader, seconds uint) (bool, error) {
-	pool, err := types.GetPool(p.ctx, p.db, schema.ID(w.GetPoolID()))
-	if err != nil {
-		return false, errors.Wrap(err, "could not load pool")
+// hasCapacity checks if the pools set on the workloads have enough capacity to
+// support all the workloads for the given amount of time. If previous is set,
+// the workloads replace it, so the capacity used by previous is released first
+func (p *NaivePlanner) hasCapacity(ws []workloads.Workloader, previous workloads.Workloader, seconds uint) (bool, error) {
+	type units struct{ cu, su, ipu float64 }
+
+	used := make(map[int64]units)
+	for _, w := range ws {
+		rsu, err := w.GetRSU()
+		if err != nil {
+			return false, err
+		}
+		cu, su, ipu := CloudUnitsFromResourceUnits(rsu)
+
+		u := used[w.GetPoolID()]
+		used[w.GetPoolID()] = units{cu: u.cu + cu, su: u.su + su, ipu: u.ipu + ipu}
 	}
 
-	rsu, err := w.GetRSU()
-	if err != nil {
-		return false, err
+	until := time.Now().Add(time.Second * time.Duration(seconds)).Unix()
+	for poolID, u := range used {
+		pool, err := types.GetPool(p.ctx, p.db, schema.ID(poolID))
+		if err != nil {
+			return false, errors.Wrap(err, "could not load pool")
+		}
+
+		if previous != nil && previous.GetPoolID() == poolID {
+			rsu, err := previous.GetRSU()
+			if err != nil {
+				return false, err
+			}
+			cu, su, ipu := CloudUnitsFromResourceUnits(rsu)
+			pool.RemoveWorkload(previous.GetID(), cu, su, ipu)
+		}
+
+		// the pool is not saved, the workloads are only added to know when
+		// the pool would be empty. They are not in the pool yet, so they are
+		// added at once
+		pool.AddWorkload(0, u.cu, u.su, u.ipu)
+		if until >= pool.EmptyAt {
+			return false, nil
+		}
 	}
-	cu, su, ipu := CloudUnitsFromResourceUnits(rsu)
-	pool.AddWorkload(w.GetID(), cu, su, ipu)
 
-	return time.Now().Add(time.Second*time.Duration(seconds)).Unix() < pool.EmptyAt, nil
+	return true, nil
 }
 
 // poolByID returns the pool with the given ID
@@ -587,6 +671,29 @@ func (p *NaivePlanner) handlePoolExpiration(cancelOld bool) error {
 	return nil
 }
 
+// handleWorkloadExpiration deletes the workloads which reached their expiry
+// time, so they free the capacity of their pool
+func (p *NaivePlanner) handleWorkloadExpiration() error {
+	filter := workloadtypes.WorkloadFilter{}.WithExpiredAt(time.Now()).WithActive()
+	workloads, err := filter.Find(p.ctx, p.db)
+	if err != nil {
+		return errors.Wrap(err, "could not load expired workloads")
+	}
+
+	for i := range workloads {
+		log.Debug().Int64("Workload", int64(workloads[i].GetID())).Time("ExpiresAt", workloads[i].GetExpiresAt().Time).Msg("expire workload")
+		workloads[i].SetNextAction(workloadtypes.Delete)
+		if err = workloadtypes.WorkloadSetNextAction(p.ctx, p.db, workloads[i].GetID(), workloadtypes.Delete); err != nil {
+			return errors.Wrap(err, "could not set workload to delete state")
+		}
+		if err = workloadtypes.WorkloadPush(p.ctx, p.db, workloads[i]); err != nil {
+			return errors.Wrap(err, "could not push workload to delete in workload queue")
+		}
+	}
+
+	return nil
+}
+
 func (p *NaivePlanner) syncPools() error {
 	ctx, cancel := context.WithCancel(p.ctx)
 	defer cancel()
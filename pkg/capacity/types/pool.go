@@ -64,6 +64,10 @@ type (
 
 		// ActiveWorkloadIDs for this pool, this list contains only unique entries
 		ActiveWorkloadIDs []schema.ID `bson:"active_workload_ids" json:"active_workload_ids"`
+
+		// Labels are set by the owner to group the pools. They are only
+		// changed with PoolSetLabels, UpdatePool keeps the saved labels
+		Labels schema.Labels `bson:"labels,omitempty" json:"labels,omitempty"`
 	}
 )
 
@@ -279,6 +283,9 @@ func CapacityPoolCreate(ctx context.Context, db *mongo.Database, pool Pool) (Poo
 // UpdatePool updates the pool in the database
 func UpdatePool(ctx context.Context, db *mongo.Database, pool Pool) error {
 	filter := bson.M{"_id": pool.ID}
+	// the labels are omitted from the update, so a pool loaded before a
+	// labels update does not revert the labels
+	pool.Labels = nil
 
 	if _, err := db.Collection(CapacityPoolCollection).UpdateOne(ctx, filter, bson.M{"$set": pool}); err != nil {
 		return errors.Wrap(err, "could not update document")
@@ -287,6 +294,20 @@ func UpdatePool(ctx context.Context, db *mongo.Database, pool Pool) error {
 	return nil
 }
 
+// PoolSetLabels replaces the labels of a pool
+func PoolSetLabels(ctx context.Context, db *mongo.Database, id schema.ID, labels schema.Labels) error {
+	res, err := db.Collection(CapacityPoolCollection).UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"labels": labels}})
+	if err != nil {
+		return errors.Wrap(err, "could not update pool labels")
+	}
+
+	if res.MatchedCount == 0 {
+		return ErrPoolNotFound
+	}
+
+	return nil
+}
+
 // PoolResult wrapper object that holds errors
 type PoolResult struct {
 	Pool
@@ -44,6 +44,9 @@ type (
 		CustomerSignature string          `bson:"customer_signature" json:"customer_signature"`
 		SponsorTid        int64           `bson:"sponsor_tid" json:"sponsor_tid"`
 		SponsorSignature  string          `bson:"sponsor_signature" json:"sponsor_signature"`
+		// Labels of the pool, only used when the reservation creates a new
+		// pool. They are not part of the signed json data
+		Labels schema.Labels `bson:"labels,omitempty" json:"labels,omitempty"`
 	}
 
 	// ReservationData is the actual data sent in a capacity pool reservation. If
@@ -54,6 +57,9 @@ type (
 	// points, we only allow purchasing full units. Since such a unit is actually
 	// very small, this is not a problem for over purchasing, and it simplifies
 	// some stuff on our end.
+	//
+	// PromoCode is optional, and is omitted from the json if not set so the
+	// signature of reservations without a code does not change.
 	ReservationData struct {
 		PoolID     int64    `bson:"pool_id" json:"pool_id"`
 		CUs        uint64   `bson:"cus" json:"cus"`
@@ -61,6 +67,7 @@ type (
 		IPv4Us     uint64   `bson:"ipv4us" json:"ipv4us"`
 		NodeIDs    []string `bson:"node_ids" json:"node_ids"`
 		Currencies []string `bson:"currencies" json:"currencies"`
+		PromoCode  string   `bson:"promo_code,omitempty" json:"promo_code,omitempty"`
 	}
 )
 
@@ -97,6 +104,10 @@ func (pr *Reservation) Validate() error {
 		return fmt.Errorf("json data does not match the reservation data")
 	}
 
+	if err := pr.Labels.Validate(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
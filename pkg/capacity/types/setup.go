@@ -19,6 +19,9 @@ func Setup(ctx context.Context, db *mongo.Database) error {
 		{
 			Keys: bson.M{"empty_at": 1},
 		},
+		{
+			Keys: bson.M{"labels": 1},
+		},
 	}
 
 	if _, err := col.Indexes().CreateMany(ctx, indexes); err != nil {
@@ -91,6 +91,14 @@ func (f *FarmAPI) listFarm(r *http.Request) (interface{}, mw.Response) {
 	var findOpts []*options.FindOptions
 
 	pager := models.PageFromRequest(r)
+	cursor, err := models.CursorFromRequest(r)
+	if err != nil {
+		return nil, mw.BadRequest(err)
+	}
+	if cursor != nil {
+		filter = append(filter, cursor.Filter())
+		pager = cursor.Pager()
+	}
 	findOpts = append(findOpts, pager)
 	// hide the email of the farm for any non authenticated user
 	if !f.isAuthenticated(r) {
@@ -104,8 +112,12 @@ func (f *FarmAPI) listFarm(r *http.Request) (interface{}, mw.Response) {
 		return nil, mw.Error(err)
 	}
 
-	pages := fmt.Sprintf("%d", models.Pages(pager, total))
-	return farms, mw.Ok().WithHeader("Pages", pages)
+	var next string
+	if len(farms) > 0 {
+		next = models.NextCursor(pager, len(farms), farms[len(farms)-1].ID)
+	}
+
+	return farms, mw.Page(pager, cursor, total, next)
 }
 
 func (f *FarmAPI) getFarm(r *http.Request) (interface{}, mw.Response) {
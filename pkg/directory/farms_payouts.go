@@ -0,0 +1,273 @@
+package directory
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+
+	"github.com/threefoldtech/tfexplorer/models"
+	"github.com/threefoldtech/tfexplorer/mw"
+	escrow "github.com/threefoldtech/tfexplorer/pkg/escrow/types"
+)
+
+const (
+	// PayoutReportStatements exports every payout statement
+	PayoutReportStatements = "statements"
+	// PayoutReportMonthly exports the monthly totals of the payout statements
+	PayoutReportMonthly = "monthly"
+)
+
+var (
+	statementsHeader = []string{
+		"reservation_id", "pool_id", "month", "cus", "sus", "ipv4us", "asset",
+		"gross", "foundation_cut", "net", "paid", "transaction",
+	}
+	monthlyHeader = []string{
+		"month", "asset", "reservations", "cus", "sus", "ipv4us",
+		"gross", "foundation_cut", "net",
+	}
+)
+
+// payoutStatementFilter builds the statement filter of the farm loaded by the
+// LoadFarmMiddleware, limited to the months range of the request
+func payoutStatementFilter(r *http.Request) (escrow.FarmerPayoutStatementFilter, error) {
+	from := r.URL.Query().Get("from")
+	to := r.URL.Query().Get("to")
+	for _, month := range []string{from, to} {
+		if len(month) == 0 {
+			continue
+		}
+		if _, err := time.Parse(escrow.StatementMonthFormat, month); err != nil {
+			return nil, fmt.Errorf("invalid month '%s', expected format is YYYY-MM", month)
+		}
+	}
+
+	var filter escrow.FarmerPayoutStatementFilter
+	filter = filter.WithFarmID(getFarmID(r.Context())).WithMonths(from, to)
+
+	return filter, nil
+}
+
+func (f *FarmAPI) listFarmPayouts(r *http.Request) (interface{}, mw.Response) {
+	filter, err := payoutStatementFilter(r)
+	if err != nil {
+		return nil, mw.BadRequest(err)
+	}
+
+	db := mw.Database(r)
+	pager := models.PageFromRequest(r)
+	statements, err := filter.List(r.Context(), db, pager)
+	if err != nil {
+		return nil, mw.Error(err)
+	}
+
+	total, err := filter.Count(r.Context(), db)
+	if err != nil {
+		return nil, mw.Error(err)
+	}
+
+	pages := fmt.Sprintf("%d", models.Pages(pager, total))
+	return statements, mw.Ok().WithHeader("Pages", pages)
+}
+
+func (f *FarmAPI) getFarmPayoutTotals(r *http.Request) (interface{}, mw.Response) {
+	filter, err := payoutStatementFilter(r)
+	if err != nil {
+		return nil, mw.BadRequest(err)
+	}
+
+	totals, err := filter.WithPaid(true).MonthlyTotals(r.Context(), mw.Database(r))
+	if err != nil {
+		return nil, mw.Error(err)
+	}
+
+	return totals, nil
+}
+
+// exportFarmPayouts writes the payout statements, or the monthly totals, of a
+// farm as a CSV or PDF document
+func (f *FarmAPI) exportFarmPayouts(w http.ResponseWriter, r *http.Request) {
+	fail := func(res mw.Response) {
+		w.WriteHeader(res.Status())
+		w.Write(res.ErrorAsBytes())
+	}
+
+	filter, err := payoutStatementFilter(r)
+	if err != nil {
+		fail(mw.BadRequest(err))
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if len(format) == 0 {
+		format = "csv"
+	}
+	report := r.URL.Query().Get("report")
+	if len(report) == 0 {
+		report = PayoutReportStatements
+	}
+
+	if format != "csv" && format != "pdf" {
+		fail(mw.BadRequest(fmt.Errorf("unsupported format '%s', supported formats are csv and pdf", format)))
+		return
+	}
+	if report != PayoutReportStatements && report != PayoutReportMonthly {
+		fail(mw.BadRequest(fmt.Errorf("unknown report '%s'", report)))
+		return
+	}
+
+	var (
+		db         = mw.Database(r)
+		statements []escrow.FarmerPayoutStatement
+		totals     []escrow.FarmerPayoutTotal
+	)
+
+	if report == PayoutReportMonthly {
+		totals, err = filter.WithPaid(true).MonthlyTotals(r.Context(), db)
+	} else {
+		statements, err = filter.List(r.Context(), db)
+	}
+	if err != nil {
+		fail(mw.Error(err))
+		return
+	}
+
+	farmID := getFarmID(r.Context())
+	name := fmt.Sprintf("farm-%d-payouts.%s", farmID, format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(http.StatusOK)
+		if report == PayoutReportMonthly {
+			err = writeMonthlyCSV(w, totals)
+		} else {
+			err = writeStatementsCSV(w, statements)
+		}
+	} else {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.WriteHeader(http.StatusOK)
+		err = writeTextPDF(w, payoutReportLines(int64(farmID), report, statements, totals))
+	}
+
+	if err != nil {
+		log.Error().Err(err).Int64("farm", int64(farmID)).Msg("failed to write payouts export")
+	}
+}
+
+func writeStatementsCSV(w io.Writer, statements []escrow.FarmerPayoutStatement) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(statementsHeader); err != nil {
+		return err
+	}
+
+	for _, s := range statements {
+		record := []string{
+			fmt.Sprint(s.ReservationID),
+			fmt.Sprint(s.PoolID),
+			s.Month,
+			strconv.FormatUint(s.CUs, 10),
+			strconv.FormatUint(s.SUs, 10),
+			strconv.FormatUint(s.IPv4Us, 10),
+			s.Asset.Code(),
+			escrow.FormatAmount(s.Gross),
+			escrow.FormatAmount(s.FoundationCut),
+			escrow.FormatAmount(s.Net),
+			strconv.FormatBool(s.Paid),
+			s.Transaction,
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return errors.Wrap(cw.Error(), "failed to write statements")
+}
+
+func writeMonthlyCSV(w io.Writer, totals []escrow.FarmerPayoutTotal) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(monthlyHeader); err != nil {
+		return err
+	}
+
+	for _, t := range totals {
+		record := []string{
+			t.Month,
+			t.Asset.Code(),
+			fmt.Sprint(t.Reservations),
+			strconv.FormatUint(t.CUs, 10),
+			strconv.FormatUint(t.SUs, 10),
+			strconv.FormatUint(t.IPv4Us, 10),
+			escrow.FormatAmount(t.Gross),
+			escrow.FormatAmount(t.FoundationCut),
+			escrow.FormatAmount(t.Net),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return errors.Wrap(cw.Error(), "failed to write monthly totals")
+}
+
+// payoutReportLines formats the report, the statements or the monthly totals,
+// as the text lines of the PDF statement
+func payoutReportLines(farmID int64, report string, statements []escrow.FarmerPayoutStatement, totals []escrow.FarmerPayoutTotal) []string {
+	const (
+		statementFmt = "%-12s %-10s %-8s %6s %6s %6s %-5s %18s %18s %18s  %s"
+		totalFmt     = "%-8s %-5s %12s %8s %8s %8s %18s %18s %18s"
+	)
+
+	lines := []string{
+		fmt.Sprintf("Payout statement of farm %d", farmID),
+		fmt.Sprintf("Generated on %s", time.Now().UTC().Format(time.RFC1123)),
+		"",
+	}
+
+	if report == PayoutReportMonthly {
+		lines = append(lines,
+			"Monthly totals (paid out only)",
+			"",
+			fmt.Sprintf(totalFmt, "MONTH", "ASSET", "RESERVATIONS", "CU", "SU", "IPV4U", "GROSS", "FOUNDATION CUT", "NET"),
+		)
+
+		for _, t := range totals {
+			lines = append(lines, fmt.Sprintf(totalFmt,
+				t.Month, t.Asset.Code(), fmt.Sprint(t.Reservations),
+				strconv.FormatUint(t.CUs, 10), strconv.FormatUint(t.SUs, 10), strconv.FormatUint(t.IPv4Us, 10),
+				escrow.FormatAmount(t.Gross), escrow.FormatAmount(t.FoundationCut), escrow.FormatAmount(t.Net),
+			))
+		}
+
+		return lines
+	}
+
+	lines = append(lines,
+		"Capacity reservations",
+		"",
+		fmt.Sprintf(statementFmt, "RESERVATION", "POOL", "MONTH", "CU", "SU", "IPV4U", "ASSET", "GROSS", "FOUNDATION CUT", "NET", "TRANSACTION"),
+	)
+
+	for _, s := range statements {
+		transaction := s.Transaction
+		if !s.Paid {
+			transaction = "pending"
+		}
+		lines = append(lines, fmt.Sprintf(statementFmt,
+			fmt.Sprint(s.ReservationID), fmt.Sprint(s.PoolID), s.Month,
+			strconv.FormatUint(s.CUs, 10), strconv.FormatUint(s.SUs, 10), strconv.FormatUint(s.IPv4Us, 10),
+			s.Asset.Code(), escrow.FormatAmount(s.Gross), escrow.FormatAmount(s.FoundationCut), escrow.FormatAmount(s.Net),
+			transaction,
+		))
+	}
+
+	return lines
+}
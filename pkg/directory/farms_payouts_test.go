@@ -0,0 +1,109 @@
+package directory
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	escrow "github.com/threefoldtech/tfexplorer/pkg/escrow/types"
+	"github.com/threefoldtech/tfexplorer/pkg/stellar"
+)
+
+var (
+	testStatements = []escrow.FarmerPayoutStatement{
+		{
+			ReservationID: 12,
+			PoolID:        3,
+			Month:         "2020-11",
+			CUs:           100,
+			SUs:           200,
+			Asset:         stellar.TFTMainnet,
+			Gross:         100000000,
+			FoundationCut: 10000000,
+			Net:           90000000,
+			Paid:          true,
+			Transaction:   "abcdef",
+		},
+		{
+			ReservationID: 13,
+			PoolID:        3,
+			Month:         "2020-11",
+			Asset:         stellar.TFTMainnet,
+			Gross:         5,
+			Net:           5,
+		},
+	}
+
+	testTotals = []escrow.FarmerPayoutTotal{
+		{
+			Month:         "2020-11",
+			Asset:         stellar.TFTMainnet,
+			Reservations:  1,
+			CUs:           100,
+			SUs:           200,
+			Gross:         100000000,
+			FoundationCut: 10000000,
+			Net:           90000000,
+		},
+	}
+)
+
+func readCSV(t *testing.T, data []byte) [][]string {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	require.NoError(t, err)
+	return records
+}
+
+func TestWriteStatementsCSV(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, writeStatementsCSV(&buf, testStatements))
+
+	records := readCSV(t, buf.Bytes())
+	require.Len(t, records, 3)
+	assert.Equal(t, statementsHeader, records[0])
+	assert.Equal(t, []string{"12", "3", "2020-11", "100", "200", "0", "TFT", "10.0000000", "1.0000000", "9.0000000", "true", "abcdef"}, records[1])
+	assert.Equal(t, "false", records[2][10])
+	assert.Empty(t, records[2][11])
+}
+
+func TestWriteMonthlyCSV(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, writeMonthlyCSV(&buf, testTotals))
+
+	records := readCSV(t, buf.Bytes())
+	require.Len(t, records, 2)
+	assert.Equal(t, monthlyHeader, records[0])
+	assert.Equal(t, []string{"2020-11", "TFT", "1", "100", "200", "0", "10.0000000", "1.0000000", "9.0000000"}, records[1])
+}
+
+func TestPayoutReportLines(t *testing.T) {
+	statements := strings.Join(payoutReportLines(1, PayoutReportStatements, testStatements, nil), "\n")
+	assert.Contains(t, statements, "Payout statement of farm 1")
+	assert.Contains(t, statements, "Capacity reservations")
+	assert.Contains(t, statements, "abcdef")
+	assert.Contains(t, statements, "pending", "the unpaid statements have no transaction")
+	assert.NotContains(t, statements, "Monthly totals")
+
+	monthly := strings.Join(payoutReportLines(1, PayoutReportMonthly, nil, testTotals), "\n")
+	assert.Contains(t, monthly, "Monthly totals")
+	assert.Contains(t, monthly, "9.0000000")
+	assert.NotContains(t, monthly, "Capacity reservations")
+}
+
+func TestWriteTextPDF(t *testing.T) {
+	lines := make([]string, 2*pdfLinesPerPage+1)
+	for i := range lines {
+		lines[i] = "line (with parenthesis) and \\ backslash"
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeTextPDF(&buf, lines))
+
+	pdf := buf.String()
+	assert.True(t, strings.HasPrefix(pdf, "%PDF-1.4\n"))
+	assert.True(t, strings.HasSuffix(strings.TrimSpace(pdf), "%%EOF"))
+	assert.Contains(t, pdf, "/Count 3", "the lines are split on 3 pages")
+}
@@ -21,7 +21,6 @@ type FarmAPI struct {
 }
 
 // List farms
-// TODO: add paging arguments
 func (s *FarmAPI) List(ctx context.Context, db *mongo.Database, filter directory.FarmFilter, opts ...*options.FindOptions) ([]directory.Farm, int64, error) {
 
 	cur, err := filter.Find(ctx, db, opts...)
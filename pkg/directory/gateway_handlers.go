@@ -116,15 +116,28 @@ func (s *GatewayAPI) listGateways(r *http.Request) (interface{}, mw.Response) {
 		return nil, err
 	}
 
-	db := mw.Database(r)
 	pager := models.PageFromRequest(r)
+	cursor, err := models.CursorFromRequest(r)
+	if err != nil {
+		return nil, mw.BadRequest(err)
+	}
+	if cursor != nil {
+		q.Cursor = cursor
+		pager = cursor.Pager()
+	}
+
+	db := mw.Database(r)
 	nodes, total, err := s.List(r.Context(), db, q, pager)
 	if err != nil {
 		return nil, mw.Error(err)
 	}
 
-	pages := fmt.Sprintf("%d", models.Pages(pager, total))
-	return nodes, mw.Ok().WithHeader("Pages", pages)
+	var next string
+	if len(nodes) > 0 {
+		next = models.NextCursor(pager, len(nodes), nodes[len(nodes)-1].ID)
+	}
+
+	return nodes, mw.Page(pager, cursor, total, next)
 }
 
 func (s *GatewayAPI) updateUptimeHandler(r *http.Request) (interface{}, mw.Response) {
@@ -8,6 +8,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
+	"github.com/threefoldtech/tfexplorer/models"
 	generated "github.com/threefoldtech/tfexplorer/models/generated/directory"
 	"github.com/threefoldtech/tfexplorer/mw"
 	directory "github.com/threefoldtech/tfexplorer/pkg/directory/types"
@@ -23,6 +24,8 @@ type gatewayQuery struct {
 	Country string
 	City    string
 	FarmID  int
+	// Cursor is set if the gateways are paginated by cursor
+	Cursor *models.Cursor
 }
 
 func (n *gatewayQuery) Parse(r *http.Request) mw.Response {
@@ -41,6 +44,9 @@ func (s *GatewayAPI) List(ctx context.Context, db *mongo.Database, q gatewayQuer
 	var filter directory.GatewayFilter
 	filter = filter.WithLocation(q.Country, q.City)
 	filter = filter.WithFarmID(q.FarmID)
+	if q.Cursor != nil {
+		filter = append(filter, q.Cursor.Filter())
+	}
 
 	cur, err := filter.Find(ctx, db, opts...)
 	if err != nil {
@@ -90,15 +90,32 @@ func (s *NodeAPI) listNodes(r *http.Request) (interface{}, mw.Response) {
 		return nil, err
 	}
 
-	db := mw.Database(r)
 	pager := models.PageFromRequest(r)
+	cursor, err := models.CursorFromRequest(r)
+	if err != nil {
+		return nil, mw.BadRequest(err)
+	}
+	if cursor != nil {
+		if len(q.SortPrice) != 0 {
+			return nil, mw.BadRequest(fmt.Errorf("nodes sorted by price can only be paginated by page"))
+		}
+		q.Cursor = cursor
+		pager = cursor.Pager()
+	}
+
+	db := mw.Database(r)
 	nodes, total, err := s.List(r.Context(), db, q, pager)
 	if err != nil {
 		return nil, mw.Error(err)
 	}
 
-	pages := fmt.Sprintf("%d", models.Pages(pager, total))
-	return nodes, mw.Ok().WithHeader("Pages", pages)
+	// the cursor is the last id, it can't be used for nodes sorted by price
+	var next string
+	if len(q.SortPrice) == 0 && len(nodes) > 0 {
+		next = models.NextCursor(pager, len(nodes), nodes[len(nodes)-1].ID)
+	}
+
+	return nodes, mw.Page(pager, cursor, total, next)
 }
 
 func (s *NodeAPI) registerCapacity(r *http.Request) (interface{}, mw.Response) {
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/http"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -24,7 +25,10 @@ import (
 )
 
 // NodeAPI holds api for nodes
-type NodeAPI struct{}
+type NodeAPI struct {
+	// defaultPrice is the price of the farms without custom pricing
+	defaultPrice generated.NodeCloudUnitPrice
+}
 
 type nodeQuery struct {
 	FarmID  int64
@@ -36,6 +40,12 @@ type nodeQuery struct {
 	HRU     int64
 	Proofs  bool
 	Deleted bool
+	// SortPrice is the unit (cu, su or ipv4u) to sort the nodes on by
+	// the price of their farm
+	SortPrice string
+	SortDesc  bool
+	// Cursor is set if the nodes are paginated by cursor
+	Cursor *models.Cursor
 }
 
 func (n *nodeQuery) Parse(r *http.Request) mw.Response {
@@ -65,11 +75,23 @@ func (n *nodeQuery) Parse(r *http.Request) mw.Response {
 	n.Proofs = r.URL.Query().Get("proofs") == "true"
 	n.Deleted = r.URL.Query().Get("deleted") == "true"
 
+	if sort := r.URL.Query().Get("sort"); len(sort) != 0 {
+		if strings.HasPrefix(sort, "-") {
+			n.SortDesc = true
+			sort = sort[1:]
+		}
+		switch sort {
+		case "cu_price", "su_price", "ipv4u_price":
+			n.SortPrice = strings.TrimSuffix(sort, "_price")
+		default:
+			return mw.BadRequest(fmt.Errorf("invalid sort '%s', nodes can be sorted on cu_price, su_price or ipv4u_price", sort))
+		}
+	}
+
 	return nil
 }
 
-// List farms
-// TODO: add paging arguments
+// List nodes
 func (s *NodeAPI) List(ctx context.Context, db *mongo.Database, q nodeQuery, opts ...*options.FindOptions) ([]directory.Node, int64, error) {
 	// Initialize the filter since we don't want a default, we might want a fully
 	// empty one
@@ -82,6 +104,9 @@ func (s *NodeAPI) List(ctx context.Context, db *mongo.Database, q nodeQuery, opt
 	if !q.Deleted {
 		filter = filter.ExcludeDeleted()
 	}
+	if q.Cursor != nil {
+		filter = append(filter, q.Cursor.Filter())
+	}
 
 	if !q.Proofs {
 		projection := bson.D{
@@ -90,7 +115,15 @@ func (s *NodeAPI) List(ctx context.Context, db *mongo.Database, q nodeQuery, opt
 		opts = append(opts, options.Find().SetProjection(projection))
 	}
 
-	cur, err := filter.Find(ctx, db, opts...)
+	var (
+		cur *mongo.Cursor
+		err error
+	)
+	if len(q.SortPrice) != 0 {
+		cur, err = filter.FindSortedByPrice(ctx, db, q.SortPrice, q.SortDesc, s.unitPrice(q.SortPrice), opts...)
+	} else {
+		cur, err = filter.Find(ctx, db, opts...)
+	}
 	if err != nil {
 		return nil, 0, errors.Wrap(err, "failed to list nodes")
 	}
@@ -109,6 +142,20 @@ func (s *NodeAPI) List(ctx context.Context, db *mongo.Database, q nodeQuery, opt
 	return out, count, nil
 }
 
+// unitPrice returns the default price of a unit
+func (s *NodeAPI) unitPrice(unit string) float64 {
+	switch unit {
+	case "cu":
+		return s.defaultPrice.CU
+	case "su":
+		return s.defaultPrice.SU
+	case "ipv4u":
+		return s.defaultPrice.IPv4U
+	}
+
+	return 0
+}
+
 // Get a single node
 func (s *NodeAPI) Get(ctx context.Context, db *mongo.Database, nodeID string, includeProofs bool) (directory.Node, error) {
 	var filter directory.NodeFilter
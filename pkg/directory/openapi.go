@@ -0,0 +1,118 @@
+package directory
+
+import (
+	"net/http"
+
+	generated "github.com/threefoldtech/tfexplorer/models/generated/directory"
+	directory "github.com/threefoldtech/tfexplorer/pkg/directory/types"
+	escrow "github.com/threefoldtech/tfexplorer/pkg/escrow/types"
+	"github.com/threefoldtech/tfexplorer/pkg/openapi"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"github.com/threefoldtech/zos/pkg/capacity"
+	"github.com/threefoldtech/zos/pkg/capacity/dmi"
+)
+
+var (
+	nodeQueryParams = []string{"farm", "country", "city", "cru", "mru", "sru", "hru", "proofs", "deleted", "sort"}
+
+	resourcesUpdate = struct {
+		generated.ResourceAmount
+		generated.WorkloadAmount
+	}{}
+	uptimeUpdate = struct {
+		Uptime uint64 `json:"uptime"`
+	}{}
+)
+
+// Operations documents the versioned directory routes
+var Operations = openapi.Operations{
+	"farm-register-v1": {
+		Summary: "Register a farm",
+		Request: directory.Farm{},
+		Response: struct {
+			ID schema.ID `json:"id"`
+		}{},
+		Status: http.StatusCreated,
+	},
+	"farm-list-v1": {
+		Summary:   "List farms",
+		Response:  []directory.Farm{},
+		Query:     []string{"owner", "name"},
+		Paginated: true,
+	},
+	"farm-get-v1":                     {Summary: "Get a farm", Response: directory.Farm{}},
+	"farm-get-prices-v1":              {Summary: "List the custom prices of a farm", Response: []directory.FarmThreebotPrice{}},
+	"farm-get-prices-for-threebot-v1": {Summary: "Get the custom price of a farm for a threebot", Response: directory.FarmThreebotPrice{}},
+	"farm-add-ip-v1": {
+		Summary: "Add public IPs to a farm",
+		Request: []struct {
+			IP schema.IPCidr `json:"address"`
+			GW schema.IP     `json:"gateway"`
+		}{},
+	},
+	"farm-delete-ip-v1":     {Summary: "Remove public IPs from a farm", Request: []schema.IPCidr{}},
+	"farm-update-v1":        {Summary: "Update a farm", Request: directory.Farm{}},
+	"farm-node-delete-v1":   {Summary: "Remove a node from a farm"},
+	"farm-update-prices-v1": {Summary: "Set the custom price of a farm for a threebot", Request: directory.FarmThreebotPrice{}},
+	"farm-delete-prices-v1": {Summary: "Delete the custom price of a farm for a threebot"},
+	"farm-payouts-list-v1": {
+		Summary:   "List the payout statements of a farm",
+		Response:  []escrow.FarmerPayoutStatement{},
+		Query:     []string{"from", "to"},
+		Paginated: true,
+	},
+	"farm-payouts-monthly-v1": {
+		Summary:  "Get the monthly payout totals of a farm",
+		Response: []escrow.FarmerPayoutTotal{},
+		Query:    []string{"from", "to"},
+	},
+	"farm-payouts-export-v1": {
+		Summary: "Export the payout statements or totals of a farm as a file",
+		Query:   []string{"from", "to", "format", "report"},
+	},
+
+	"node-register-v1": {Summary: "Register a node", Request: directory.Node{}, Status: http.StatusCreated},
+	"nodes-list-v1": {
+		Summary:   "List nodes",
+		Response:  []directory.Node{},
+		Query:     nodeQueryParams,
+		Paginated: true,
+	},
+	"node-get-v1":        {Summary: "Get a node", Response: directory.Node{}, Query: []string{"proofs"}},
+	"node-interfaces-v1": {Summary: "Set the network interfaces of a node", Request: []generated.Iface{}, Status: http.StatusCreated},
+	"node-set-ports-v1": {
+		Summary: "Set the wireguard ports used on a node",
+		Request: struct {
+			Ports []uint `json:"ports"`
+		}{},
+	},
+	"node-configure-public-v1": {Summary: "Configure the public interface of a node", Request: generated.PublicIface{}, Status: http.StatusCreated},
+	"node-configure-free-v1": {
+		Summary: "Set if a node is free to use",
+		Request: struct {
+			FreeToUse bool `json:"free_to_use"`
+		}{},
+	},
+	"node-capacity-v1": {
+		Summary: "Register the total capacity of a node",
+		Request: struct {
+			Capacity   generated.ResourceAmount `json:"capacity,omitempty"`
+			DMI        dmi.DMI                  `json:"dmi,omitempty"`
+			Disks      capacity.Disks           `json:"disks,omitempty"`
+			Hypervisor []string                 `json:"hypervisor,omitempty"`
+		}{},
+	},
+	"node-uptime-v1":             {Summary: "Report the uptime of a node", Request: uptimeUpdate},
+	"node-reserved-resources-v1": {Summary: "Report the resources used on a node", Request: resourcesUpdate},
+
+	"gateway-register-v1": {Summary: "Register a gateway", Request: directory.Gateway{}, Status: http.StatusCreated},
+	"gateway-list-v1": {
+		Summary:   "List gateways",
+		Response:  []directory.Gateway{},
+		Query:     []string{"country", "city", "farm_id"},
+		Paginated: true,
+	},
+	"gateway-get-v1":                {Summary: "Get a gateway", Response: directory.Gateway{}},
+	"gateway-uptime-v1":             {Summary: "Report the uptime of a gateway", Request: uptimeUpdate},
+	"gateway-reserved-resources-v1": {Summary: "Report the resources used on a gateway", Request: resourcesUpdate},
+}
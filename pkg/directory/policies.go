@@ -0,0 +1,89 @@
+package directory
+
+import (
+	"net/http"
+
+	"github.com/threefoldtech/tfexplorer/mw"
+	directory "github.com/threefoldtech/tfexplorer/pkg/directory/types"
+	"github.com/threefoldtech/tfexplorer/schema"
+)
+
+var (
+	anonymous = mw.Policy{Identity: mw.Anonymous}
+	node      = mw.Policy{Identity: mw.Node}
+	// legacyNode routes were called without a signature before, unsigned
+	// requests are accepted until the explorer requires node signatures
+	legacyNode = mw.Policy{Identity: mw.Node, AllowUnsigned: true}
+	farmer     = mw.Policy{Identity: mw.Farmer}
+)
+
+// Policies declares who can call the directory routes
+var Policies = mw.Policies{
+	"farm-register-v1":                anonymous,
+	"farm-list-v1":                    anonymous,
+	"farm-get-v1":                     anonymous,
+	"farm-get-prices-v1":              anonymous,
+	"farm-get-prices-for-threebot-v1": anonymous,
+	"farm-add-ip-v1":                  farmer,
+	"farm-delete-ip-v1":               farmer,
+	"farm-update-v1":                  farmer,
+	"farm-node-delete-v1":             farmer,
+	"farm-update-prices-v1":           farmer,
+	"farm-delete-prices-v1":           farmer,
+	"farm-payouts-list-v1":            farmer,
+	"farm-payouts-monthly-v1":         farmer,
+	"farm-payouts-export-v1":          farmer,
+
+	"node-register-v1":           node,
+	"nodes-list-v1":              anonymous,
+	"node-get-v1":                anonymous,
+	"node-interfaces-v1":         node,
+	"node-set-ports-v1":          node,
+	"node-configure-public-v1":   farmer,
+	"node-configure-free-v1":     farmer,
+	"node-capacity-v1":           node,
+	"node-uptime-v1":             node,
+	"node-reserved-resources-v1": node,
+
+	"gateway-register-v1":           node,
+	"gateway-list-v1":               anonymous,
+	"gateway-get-v1":                anonymous,
+	"gateway-uptime-v1":             node,
+	"gateway-reserved-resources-v1": node,
+
+	// legacy endpoints
+	"farm-register":    anonymous,
+	"farm-list":        anonymous,
+	"farm-get":         anonymous,
+	"farm-update":      farmer,
+	"farm-node-delete": farmer,
+
+	"node-register":           node,
+	"nodes-list":              anonymous,
+	"node-get":                anonymous,
+	"node-interfaces":         node,
+	"node-set-ports":          node,
+	"node-configure-public":   farmer,
+	"node-configure-free":     farmer,
+	"node-capacity":           node,
+	"node-uptime":             node,
+	"node-reserved-resources": node,
+
+	"gateway-register":           legacyNode,
+	"gateway-list":               anonymous,
+	"gateway-get":                anonymous,
+	"gateway-uptime":             node,
+	"gateway-reserved-resources": node,
+}
+
+// farmOwnerGetter returns the owner of a farm, the authorizer uses it to
+// check the signer of the requests to the routes of a farm owns the farm
+func farmOwnerGetter(r *http.Request, farmID schema.ID) (int64, error) {
+	var filter directory.FarmFilter
+	farm, err := filter.WithID(farmID).Get(r.Context(), mw.Database(r))
+	if err != nil {
+		return 0, err
+	}
+
+	return farm.ThreebotID, nil
+}
@@ -34,6 +34,25 @@ type Farm generated.Farm
 
 // Validate validates farm object
 func (f *Farm) Validate() error {
+	return f.validate(nil)
+}
+
+// ValidateUpdate validates the update of the farm. The default prices are
+// only checked against the bounds if they change, so farms which prices fall
+// outside of bounds set later can still be updated
+func (f *Farm) ValidateUpdate(previous Farm) error {
+	return f.validate(&previous)
+}
+
+// pricesChanged checks if the default prices of the farm differ from the
+// ones of the previous version of the farm
+func (f *Farm) pricesChanged(previous *Farm) bool {
+	return previous == nil ||
+		previous.EnableCustomPricing != f.EnableCustomPricing ||
+		previous.FarmCloudUnitsPrice != f.FarmCloudUnitsPrice
+}
+
+func (f *Farm) validate(previous *Farm) error {
 	if !farmNamePattern.MatchString(f.Name) {
 		return fmt.Errorf("invalid farm name. name can only contain alphanumeric characters dash (-) or underscore (_)")
 	}
@@ -70,9 +89,37 @@ func (f *Farm) Validate() error {
 		}
 	}
 
+	if f.EnableCustomPricing && f.pricesChanged(previous) {
+		if err := ValidateFarmPrice(f.FarmCloudUnitsPrice); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// ValidateFarmPrice checks that the default prices of a farm are within
+// the bounds set by the foundation
+func ValidateFarmPrice(price generated.NodeCloudUnitPrice) error {
+	bounds := config.Config.FarmPriceBounds
+
+	check := func(unit string, price float64, bound config.PriceBound) error {
+		if !bound.Contains(price) {
+			return fmt.Errorf("invalid %s price %v, the price must be between %v and %v", unit, price, bound.Min, bound.Max)
+		}
+		return nil
+	}
+
+	if err := check("cu", price.CU, bounds.CU); err != nil {
+		return err
+	}
+	if err := check("su", price.SU, bounds.SU); err != nil {
+		return err
+	}
+
+	return check("ipv4u", price.IPv4U, bounds.IPv4U)
+}
+
 // FarmQuery helper to parse query string
 type FarmQuery struct {
 	FarmName string
@@ -201,7 +248,12 @@ func FarmCreate(ctx context.Context, db *mongo.Database, farm Farm) (schema.ID,
 func FarmUpdate(ctx context.Context, db *mongo.Database, id schema.ID, farm Farm) error {
 	farm.ID = id
 
-	if err := farm.Validate(); err != nil {
+	previous, err := FarmFilter{}.WithID(id).Get(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	if err := farm.ValidateUpdate(previous); err != nil {
 		return err
 	}
 
@@ -234,7 +286,7 @@ func FarmUpdate(ctx context.Context, db *mongo.Database, id schema.ID, farm Farm
 
 	col := db.Collection(FarmCollection)
 	f := FarmFilter{}.WithID(id)
-	_, err := col.UpdateOne(ctx, f, bson.M{"$set": update})
+	_, err = col.UpdateOne(ctx, f, bson.M{"$set": update})
 	if err != nil {
 		return err
 	}
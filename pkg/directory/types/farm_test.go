@@ -0,0 +1,56 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	generated "github.com/threefoldtech/tfexplorer/models/generated/directory"
+	"github.com/threefoldtech/tfexplorer/models/generated/phonebook"
+)
+
+func TestFarmValidatePrices(t *testing.T) {
+	farm := func(cu float64) Farm {
+		return Farm{
+			Name:                "farm",
+			ThreebotID:          1,
+			WalletAddresses:     []phonebook.WalletAddress{{Asset: "TFT", Address: "address"}},
+			EnableCustomPricing: true,
+			FarmCloudUnitsPrice: generated.NodeCloudUnitPrice{CU: cu, SU: 8, IPv4U: 6},
+		}
+	}
+
+	t.Run("create", func(t *testing.T) {
+		valid := farm(10)
+		assert.NoError(t, valid.Validate())
+
+		invalid := farm(30)
+		assert.Error(t, invalid.Validate())
+
+		disabled := farm(30)
+		disabled.EnableCustomPricing = false
+		assert.NoError(t, disabled.Validate(), "prices are not used without custom pricing")
+	})
+
+	t.Run("unchanged out of bounds prices", func(t *testing.T) {
+		previous := farm(30)
+		update := farm(30)
+		update.Name = "renamed"
+		assert.NoError(t, update.ValidateUpdate(previous))
+	})
+
+	t.Run("changed out of bounds prices", func(t *testing.T) {
+		previous := farm(30)
+		update := farm(25)
+		assert.Error(t, update.ValidateUpdate(previous))
+
+		update = farm(10)
+		assert.NoError(t, update.ValidateUpdate(previous))
+	})
+
+	t.Run("enable custom pricing", func(t *testing.T) {
+		previous := farm(30)
+		previous.EnableCustomPricing = false
+		update := farm(30)
+		assert.Error(t, update.ValidateUpdate(previous))
+	})
+}
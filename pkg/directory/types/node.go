@@ -145,6 +145,63 @@ func (f NodeFilter) Find(ctx context.Context, db *mongo.Database, opts ...*optio
 	return col.Find(ctx, f, opts...)
 }
 
+// FindSortedByPrice runs the filter and returns the nodes sorted by the default
+// price of their farm for the given unit (cu, su or ipv4u). Farms without custom
+// pricing are sorted using the defaultPrice. Only the projection, skip and limit
+// of the options are used
+func (f NodeFilter) FindSortedByPrice(ctx context.Context, db *mongo.Database, unit string, desc bool, defaultPrice float64, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	pipeline := f.sortedByPricePipeline(unit, desc, defaultPrice, options.MergeFindOptions(opts...))
+	return db.Collection(NodeCollection).Aggregate(ctx, pipeline)
+}
+
+// sortedByPricePipeline builds the aggregation pipeline of FindSortedByPrice
+func (f NodeFilter) sortedByPricePipeline(unit string, desc bool, defaultPrice float64, opt *options.FindOptions) mongo.Pipeline {
+	f = ensureFilter(f)
+
+	order := 1
+	if desc {
+		order = -1
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: f}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         FarmCollection,
+			"localField":   "farm_id",
+			"foreignField": "_id",
+			"as":           "_farm",
+		}}},
+		{{Key: "$addFields", Value: bson.M{
+			"_farm": bson.M{"$arrayElemAt": bson.A{"$_farm", 0}},
+		}}},
+		{{Key: "$addFields", Value: bson.M{
+			"_price": bson.M{
+				"$cond": bson.A{
+					bson.M{"$eq": bson.A{"$_farm.enable_custom_pricing", true}},
+					"$_farm.farm_cloudunits_price." + unit,
+					defaultPrice,
+				},
+			},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_price", Value: order}, {Key: "_id", Value: 1}}}},
+	}
+
+	if opt.Skip != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$skip", Value: *opt.Skip}})
+	}
+	if opt.Limit != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: *opt.Limit}})
+	}
+
+	projection := bson.M{"_farm": 0, "_price": 0}
+	if p, ok := opt.Projection.(bson.D); ok {
+		for _, e := range p {
+			projection[e.Key] = e.Value
+		}
+	}
+	return append(pipeline, bson.D{{Key: "$project", Value: projection}})
+}
+
 // Get one farm that matches the filter
 func (f NodeFilter) Get(ctx context.Context, db *mongo.Database, includeproofs bool) (node Node, err error) {
 	f = ensureFilter(f)
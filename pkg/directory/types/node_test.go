@@ -0,0 +1,47 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestNodeSortedByPricePipeline(t *testing.T) {
+	stage := func(pipeline []bson.D, name string) interface{} {
+		for _, s := range pipeline {
+			if s[0].Key == name {
+				return s[0].Value
+			}
+		}
+		return nil
+	}
+
+	filter := NodeFilter{}.WithFarmID(1)
+	opt := options.Find().SetSkip(10).SetLimit(5).SetProjection(bson.D{{Key: "proofs", Value: 0}})
+
+	pipeline := filter.sortedByPricePipeline("su", false, 8, opt)
+	require.Len(t, pipeline, 8)
+	assert.Equal(t, filter, stage(pipeline, "$match"))
+	assert.Equal(t, bson.D{{Key: "_price", Value: 1}, {Key: "_id", Value: 1}}, stage(pipeline, "$sort"))
+	assert.Equal(t, int64(10), stage(pipeline, "$skip"))
+	assert.Equal(t, int64(5), stage(pipeline, "$limit"))
+	assert.Equal(t, bson.M{"_farm": 0, "_price": 0, "proofs": 0}, stage(pipeline, "$project"))
+
+	// the price of the farms without custom pricing is the default price
+	price := pipeline[3][0].Value.(bson.M)["_price"].(bson.M)["$cond"].(bson.A)
+	assert.Equal(t, "$_farm.farm_cloudunits_price.su", price[1])
+	assert.Equal(t, 8.0, price[2])
+
+	// skip and limit come after the sort, so a page holds the cheapest nodes
+	assert.Equal(t, "$sort", pipeline[4][0].Key)
+	assert.Equal(t, "$skip", pipeline[5][0].Key)
+	assert.Equal(t, "$limit", pipeline[6][0].Key)
+
+	pipeline = NodeFilter(nil).sortedByPricePipeline("cu", true, 10, options.Find())
+	require.Len(t, pipeline, 6)
+	assert.Equal(t, NodeFilter{}.ExcludeDeleted(), stage(pipeline, "$match"))
+	assert.Equal(t, bson.D{{Key: "_price", Value: -1}, {Key: "_id", Value: 1}}, stage(pipeline, "$sort"))
+}
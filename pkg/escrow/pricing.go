@@ -0,0 +1,258 @@
+package escrow
+
+import (
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stellar/go/xdr"
+	capacitytypes "github.com/threefoldtech/tfexplorer/pkg/capacity/types"
+	"github.com/threefoldtech/tfexplorer/pkg/escrow/types"
+	"github.com/threefoldtech/tfexplorer/schema"
+)
+
+var (
+	// ErrInvalidPromoCode indicates the promotion code of a capacity reservation
+	// does not exist, is expired or can't be used on the farm of the reservation
+	ErrInvalidPromoCode = errors.New("invalid promotion code")
+)
+
+// pricingRequest is the information of a capacity reservation used to select
+// the pricing rules which apply to it
+type pricingRequest struct {
+	farmID    schema.ID
+	units     uint64
+	promoCode string
+	// firstTime is set if the customer never paid a capacity reservation
+	firstTime bool
+	now       time.Time
+}
+
+// applyPricingRules lowers the cost of a capacity reservation with the rules
+// which apply to it, and returns the new cost together with the applied rules.
+// Only the tier rule with the highest reached threshold is applied, followed by
+// the promotion rule of the request code and the first time credits. The
+// credit function converts a credit in dollar to an amount in the reservation
+// currency. The cost never goes below 0.
+func applyPricingRules(cost xdr.Int64, rules []types.PricingRule, req pricingRequest, credit func(dollars float64) xdr.Int64) (xdr.Int64, []types.AppliedPricingRule, error) {
+	var (
+		tier    *types.PricingRule
+		promo   *types.PricingRule
+		credits []types.PricingRule
+	)
+
+	promoCode := types.NormalizePromoCode(req.promoCode)
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.IsActive(req.now) || !rule.AppliesTo(req.farmID) {
+			continue
+		}
+
+		switch rule.Type {
+		case types.PricingRuleTier:
+			if req.units >= rule.MinUnits && (tier == nil || rule.MinUnits > tier.MinUnits) {
+				tier = rule
+			}
+		case types.PricingRulePromo:
+			if len(promoCode) != 0 && rule.Code == promoCode {
+				promo = rule
+			}
+		case types.PricingRuleFirstTime:
+			if req.firstTime {
+				credits = append(credits, *rule)
+			}
+		}
+	}
+
+	if len(promoCode) != 0 && promo == nil {
+		return cost, nil, ErrInvalidPromoCode
+	}
+
+	applied := []types.AppliedPricingRule{}
+	deduct := func(rule *types.PricingRule, amount xdr.Int64) {
+		if amount > cost {
+			amount = cost
+		}
+		if amount <= 0 {
+			return
+		}
+
+		cost -= amount
+		applied = append(applied, types.AppliedPricingRule{
+			RuleID:      rule.ID,
+			Type:        rule.Type,
+			Description: rule.Description,
+			Amount:      amount,
+		})
+	}
+
+	discount := func(rule *types.PricingRule) xdr.Int64 {
+		return xdr.Int64(math.Round(float64(cost) * rule.Discount))
+	}
+
+	if tier != nil {
+		deduct(tier, discount(tier))
+	}
+
+	if promo != nil {
+		deduct(promo, discount(promo))
+	}
+
+	for i := range credits {
+		deduct(&credits[i], credit(credits[i].Credit))
+	}
+
+	return cost, applied, nil
+}
+
+// applyCapacityPricingRules lowers the cost of a capacity reservation on a farm
+// with the enabled pricing rules. The uses of the rules are not counted, see
+// reservePricingRules.
+func (e *Stellar) applyCapacityPricingRules(reservation capacitytypes.Reservation, farmID schema.ID, cost xdr.Int64) (xdr.Int64, []types.AppliedPricingRule, error) {
+	var filter types.PricingRuleFilter
+	rules, err := filter.WithEnabled(true).WithFarmOrGlobal(farmID).List(e.ctx, e.db)
+	if err != nil {
+		return cost, nil, err
+	}
+
+	// customers are offered the first time credits until they have a receipt
+	// of a paid capacity reservation
+	firstTime, err := e.firstTimeCustomer(reservation.CustomerTid)
+	if err != nil {
+		return cost, nil, err
+	}
+
+	data := reservation.DataReservation
+	req := pricingRequest{
+		farmID:    farmID,
+		units:     data.CUs + data.SUs + data.IPv4Us,
+		promoCode: data.PromoCode,
+		firstTime: firstTime,
+		now:       time.Now(),
+	}
+
+	return applyPricingRules(cost, rules, req, e.creditCost)
+}
+
+// firstTimeCustomer checks if the customer never paid a capacity reservation
+func (e *Stellar) firstTimeCustomer(customerTid int64) (bool, error) {
+	var receipts types.CapacityReceiptFilter
+	paid, err := receipts.WithCustomer(customerTid).Count(e.ctx, e.db)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to count paid capacity reservations")
+	}
+
+	return paid == 0, nil
+}
+
+// reservePricingRules counts the uses of the pricing rules applied to a capacity
+// reservation when its escrow is created, so the customer is asked the final
+// amount. The rules which can't be used anymore, because the promotion code is
+// exhausted or the customer already got the first time credits on another
+// reservation, are dropped. It returns the kept rules and the amount the
+// dropped rules deducted, which the customer still has to pay.
+func (e *Stellar) reservePricingRules(customerTid int64, reservationID schema.ID, applied []types.AppliedPricingRule) ([]types.AppliedPricingRule, xdr.Int64, error) {
+	return dropPricingRules(applied, func(a types.AppliedPricingRule) (bool, error) {
+		switch a.Type {
+		case types.PricingRulePromo:
+			var filter types.PricingRuleFilter
+			rule, err := filter.WithID(a.RuleID).Get(e.ctx, e.db)
+			if errors.Is(err, types.ErrPricingRuleNotFound) {
+				// the rule got deleted since the price was computed
+				return false, nil
+			} else if err != nil {
+				return false, err
+			}
+
+			err = types.PricingRuleUse(e.ctx, e.db, rule)
+			if errors.Is(err, types.ErrPromoCodeExhausted) {
+				return false, nil
+			}
+			return err == nil, errors.Wrap(err, "failed to use promotion code")
+		case types.PricingRuleFirstTime:
+			err := types.FirstTimeCreditUse(e.ctx, e.db, customerTid, reservationID)
+			if errors.Is(err, types.ErrFirstTimeCreditUsed) {
+				return false, nil
+			}
+			return err == nil, errors.Wrap(err, "failed to use first time credit")
+		}
+
+		return true, nil
+	})
+}
+
+// releasePricingRules gives back the uses of the pricing rules reserved for a
+// capacity reservation which was never paid
+func (e *Stellar) releasePricingRules(customerTid int64, reservationID schema.ID, applied []types.AppliedPricingRule) error {
+	var firstTime bool
+	for _, a := range applied {
+		switch a.Type {
+		case types.PricingRulePromo:
+			if err := types.PricingRuleRelease(e.ctx, e.db, a.RuleID); err != nil {
+				return errors.Wrap(err, "failed to release promotion code")
+			}
+		case types.PricingRuleFirstTime:
+			firstTime = true
+		}
+	}
+
+	if !firstTime {
+		return nil
+	}
+
+	return errors.Wrap(
+		types.FirstTimeCreditRelease(e.ctx, e.db, customerTid, reservationID),
+		"failed to release first time credit",
+	)
+}
+
+// dropPricingRules removes the applied rules which are not usable, and returns
+// the kept rules together with the amount deducted by the dropped ones. The
+// first time credits are only checked once, as they are used together.
+func dropPricingRules(applied []types.AppliedPricingRule, usable func(types.AppliedPricingRule) (bool, error)) ([]types.AppliedPricingRule, xdr.Int64, error) {
+	var (
+		kept      = []types.AppliedPricingRule{}
+		dropped   xdr.Int64
+		firstTime *bool
+	)
+
+	for _, a := range applied {
+		var (
+			ok  bool
+			err error
+		)
+		if a.Type == types.PricingRuleFirstTime && firstTime != nil {
+			ok = *firstTime
+		} else if ok, err = usable(a); err != nil {
+			return nil, 0, err
+		}
+
+		if a.Type == types.PricingRuleFirstTime {
+			firstTime = &ok
+		}
+
+		if ok {
+			kept = append(kept, a)
+		} else {
+			dropped += a.Amount
+		}
+	}
+
+	return kept, dropped, nil
+}
+
+// creditCost converts a credit in dollar to an amount of TFT stropes, in the
+// same way the price of capacity is converted
+func (e Stellar) creditCost(dollars float64) xdr.Int64 {
+	stropes := int64(dollars * 10_000_000_000 / TftPriceMill)
+	return xdr.Int64(stropes / e.getNetworkDivisor())
+}
+
+// discountFraction is the fraction of the cost which was deducted to get the amount
+func discountFraction(cost, amount xdr.Int64) float64 {
+	if cost == 0 {
+		return 0
+	}
+
+	return float64(cost-amount) / float64(cost)
+}
@@ -0,0 +1,132 @@
+package escrow
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/tfexplorer/pkg/escrow/types"
+	"github.com/threefoldtech/tfexplorer/pkg/gridnetworks"
+	"github.com/threefoldtech/tfexplorer/schema"
+)
+
+func TestApplyPricingRules(t *testing.T) {
+	e := Stellar{gridNetwork: gridnetworks.GridNetworkMainnet}
+	now := time.Now()
+
+	const (
+		cus = 3600 * 24 * 30
+		sus = 3600 * 24 * 30 * 2
+	)
+
+	cost, err := e.calculateCapacityReservationCost(cus, sus, 0)
+	require.NoError(t, err)
+
+	rules := []types.PricingRule{
+		{ID: 1, Type: types.PricingRuleTier, MinUnits: cus, Discount: 0.1, Enabled: true},
+		{ID: 2, Type: types.PricingRuleTier, MinUnits: cus + sus, Discount: 0.2, Enabled: true},
+		{ID: 3, Type: types.PricingRuleTier, MinUnits: 10 * (cus + sus), Discount: 0.5, Enabled: true},
+		{ID: 4, Type: types.PricingRulePromo, Code: "SPRING", Discount: 0.5, Enabled: true},
+		{ID: 5, Type: types.PricingRulePromo, Code: "OTHERFARM", Discount: 0.5, FarmID: 2, Enabled: true},
+		{ID: 6, Type: types.PricingRulePromo, Code: "EXPIRED", Discount: 0.5, Enabled: true, NotAfter: schema.Date{Time: now.Add(-time.Hour)}},
+		{ID: 7, Type: types.PricingRuleFirstTime, Credit: 1, Enabled: true},
+		{ID: 8, Type: types.PricingRuleTier, MinUnits: 1, Discount: 0.9, Enabled: false},
+	}
+
+	req := pricingRequest{
+		farmID: 1,
+		units:  cus + sus,
+		now:    now,
+	}
+
+	t.Run("tier", func(t *testing.T) {
+		amount, applied, err := applyPricingRules(cost, rules, req, e.creditCost)
+		require.NoError(t, err)
+		require.Len(t, applied, 1)
+		assert.Equal(t, schema.ID(2), applied[0].RuleID)
+		assert.Equal(t, cost-applied[0].Amount, amount)
+		assert.InDelta(t, 0.2, discountFraction(cost, amount), 0.0001)
+	})
+
+	t.Run("promo and first time", func(t *testing.T) {
+		req := req
+		req.promoCode = " spring"
+		req.firstTime = true
+
+		amount, applied, err := applyPricingRules(cost, rules, req, e.creditCost)
+		require.NoError(t, err)
+		require.Len(t, applied, 3)
+
+		assert.Equal(t, types.PricingRuleTier, applied[0].Type)
+		assert.Equal(t, types.PricingRulePromo, applied[1].Type)
+		assert.Equal(t, types.PricingRuleFirstTime, applied[2].Type)
+		assert.Equal(t, e.creditCost(1), applied[2].Amount)
+
+		expected := xdr.Int64(float64(cost)*0.8*0.5) - e.creditCost(1)
+		assert.InDelta(t, int64(expected), int64(amount), 1)
+	})
+
+	t.Run("invalid promo", func(t *testing.T) {
+		for _, code := range []string{"UNKNOWN", "OTHERFARM", "EXPIRED"} {
+			req := req
+			req.promoCode = code
+			_, _, err := applyPricingRules(cost, rules, req, e.creditCost)
+			assert.Equal(t, ErrInvalidPromoCode, err, code)
+		}
+	})
+
+	t.Run("credit larger than cost", func(t *testing.T) {
+		req := req
+		req.units = 1
+		req.firstTime = true
+
+		small, err := e.calculateCapacityReservationCost(1, 0, 0)
+		require.NoError(t, err)
+
+		amount, applied, err := applyPricingRules(small, rules, req, e.creditCost)
+		require.NoError(t, err)
+		assert.Equal(t, xdr.Int64(0), amount)
+		require.Len(t, applied, 1)
+		assert.Equal(t, small, applied[0].Amount)
+	})
+}
+
+func TestDropPricingRules(t *testing.T) {
+	applied := []types.AppliedPricingRule{
+		{RuleID: 1, Type: types.PricingRuleTier, Amount: 100},
+		{RuleID: 2, Type: types.PricingRulePromo, Amount: 50},
+		{RuleID: 3, Type: types.PricingRuleFirstTime, Amount: 20},
+		{RuleID: 4, Type: types.PricingRuleFirstTime, Amount: 10},
+	}
+
+	t.Run("all usable", func(t *testing.T) {
+		kept, dropped, err := dropPricingRules(applied, func(types.AppliedPricingRule) (bool, error) {
+			return true, nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, applied, kept)
+		assert.Equal(t, xdr.Int64(0), dropped)
+	})
+
+	t.Run("exhausted code and used credits", func(t *testing.T) {
+		var checked []schema.ID
+		kept, dropped, err := dropPricingRules(applied, func(a types.AppliedPricingRule) (bool, error) {
+			checked = append(checked, a.RuleID)
+			return a.Type == types.PricingRuleTier, nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, applied[:1], kept)
+		assert.Equal(t, xdr.Int64(80), dropped)
+		assert.Equal(t, []schema.ID{1, 2, 3}, checked, "the first time credits are used once")
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, _, err := dropPricingRules(applied, func(types.AppliedPricingRule) (bool, error) {
+			return false, errors.New("failure")
+		})
+		assert.Error(t, err)
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"os"
 	"sort"
 	"time"
 
@@ -37,7 +38,11 @@ type (
 
 		paidCapacityInfoChannel chan schema.ID
 
-		paymentsChannel chan stellar.PayoutJob
+		// payoutQueue stores the payout jobs until they are processed by
+		// the payments loop of any explorer instance
+		payoutQueue *types.PayoutQueue
+		// workerID identifies this instance when claiming payout jobs
+		workerID string
 
 		nodeAPI    NodeAPI
 		gatewayAPI GatewayAPI
@@ -89,6 +94,10 @@ const (
 
 	// MaxOperationsPerTx maximum number of operations in a transaction
 	MaxOperationsPerTx = 100
+
+	// payoutLease is the time a payments loop owns a claimed payout job
+	// before another loop is allowed to claim it again
+	payoutLease = time.Minute * 10
 )
 
 const (
@@ -176,7 +185,8 @@ func NewStellar(wallet stellar.Wallet, db *mongo.Database, foundationAddress str
 		nodeAPI:           &directory.NodeAPI{},
 		gatewayAPI:        &directory.GatewayAPI{},
 		farmAPI:           &directory.FarmAPI{},
-		paymentsChannel:   make(chan stellar.PayoutJob, 100),
+		payoutQueue:       types.NewPayoutQueue(context.Background(), db),
+		workerID:          payoutWorkerID(),
 		// paidCapacityInfoChannel is buffered since it is used to communicate
 		// with other workers, which might also try to communicate with this
 		// worker
@@ -311,11 +321,13 @@ func (e *Stellar) processFailedPayments(herr *horizonclient.Error, jobs []stella
 			continue
 		}
 		if !badJob[i] {
-			e.paymentsChannel <- j
+			e.releasePayoutJob(j, j.Retries)
 		} else if j.Retries > 1 {
-			j.Retries--
-			e.paymentsChannel <- j
+			e.releasePayoutJob(j, j.Retries-1)
 		} else {
+			if err := types.PayoutJobFinish(e.ctx, e.db, e.workerID, types.PayoutJobKey(j), types.PayoutJobFailed); err != nil {
+				log.Error().Err(err).Msg("failed to mark the payout job as failed")
+			}
 			// refund failed payments and store failed refunds in the db
 			if !j.Refund {
 				e.refundCapacityEscrow(escrowInfo, "farmer_payout")
@@ -345,12 +357,129 @@ func (e *Stellar) processFailedPayments(herr *horizonclient.Error, jobs []stella
 	return nil
 }
 
+// releasePayoutJob gives a claimed job back to the payout queue, so it is
+// picked up again by the next batch
+func (e *Stellar) releasePayoutJob(job stellar.PayoutJob, retries int) {
+	if err := types.PayoutJobRelease(e.ctx, e.db, e.workerID, types.PayoutJobKey(job), retries); err != nil {
+		log.Error().Err(err).Int64("id", int64(job.ID)).Msg("failed to release payout job")
+	}
+}
+
+// requeuePayoutJob gives a claimed job which may have been paid back to the
+// payout queue, it is only paid again if its payments did not settle
+func (e *Stellar) requeuePayoutJob(job stellar.PayoutJob) {
+	if err := types.PayoutJobRequeue(e.ctx, e.db, e.workerID, types.PayoutJobKey(job)); err != nil {
+		log.Error().Err(err).Int64("id", int64(job.ID)).Msg("failed to requeue payout job")
+	}
+}
+
+// claimPayoutJob claims the next job from the payout queue. Jobs which were
+// already submitted by a previous owner, but never marked as done (e.g. because
+// the explorer crashed), are only returned if their payments did not go through.
+func (e *Stellar) claimPayoutJob(ctx context.Context) (stellar.PayoutJob, error) {
+	for {
+		info, err := types.PayoutJobClaim(ctx, e.db, e.workerID, payoutLease)
+		if err != nil {
+			return stellar.PayoutJob{}, err
+		}
+
+		job := info.Job()
+		if !info.Submitted {
+			return job, nil
+		}
+
+		settled, err := e.isPayoutSettled(ctx, job)
+		if err != nil {
+			return stellar.PayoutJob{}, errors.Wrap(err, "failed to check if payout job was already paid")
+		}
+
+		if !settled {
+			return job, nil
+		}
+
+		transaction, err := e.payoutTransaction(ctx, job)
+		if err != nil {
+			// the job is paid, the statement is marked paid without the hash
+			log.Error().Err(err).Int64("id", int64(job.ID)).Msg("failed to find the transaction which paid the payout job")
+		}
+
+		log.Info().Int64("id", int64(job.ID)).Str("memo", job.Memo).Str("transaction", transaction).Msg("payout job was already paid, marking as done")
+		e.payoutJobDone(ctx, job, transaction)
+	}
+}
+
+// isPayoutSettled checks if the escrow account has no balance left for the job
+// memo, which means the job was paid out already
+func (e *Stellar) isPayoutSettled(ctx context.Context, job stellar.PayoutJob) (bool, error) {
+	if len(job.Payments) == 0 {
+		return true, nil
+	}
+
+	batchTx, err := getBatchMemoTransactions(ctx, e.db, job.Memo)
+	if err != nil {
+		return false, err
+	}
+
+	address := job.Payments[0].SourceAccount.GetAccountID()
+	balance, _, err := e.wallet.GetBalance(address, job.Memo, job.Asset, &batchTx)
+	if err != nil {
+		return false, err
+	}
+
+	return balance <= 0, nil
+}
+
+// payoutTransaction returns the hash of the transaction which paid a job
+// submitted by a previous owner of the job
+func (e *Stellar) payoutTransaction(ctx context.Context, job stellar.PayoutJob) (string, error) {
+	if len(job.Payments) == 0 {
+		return "", nil
+	}
+
+	batchTx, err := getBatchMemoTransactions(ctx, e.db, job.Memo)
+	if err != nil {
+		return "", err
+	}
+
+	address := job.Payments[0].SourceAccount.GetAccountID()
+	return e.wallet.PayoutTransaction(address, job.Memo, &batchTx)
+}
+
+// payoutJobDone updates the escrow information of a successfully paid job,
+// and removes the job from the queue. The transaction is the hash of the stellar
+// transaction which paid the job, if known
+func (e *Stellar) payoutJobDone(ctx context.Context, job stellar.PayoutJob, transaction string) {
+	rpi, err := types.CapacityReservationPaymentInfoGet(ctx, e.db, job.ID)
+	if err != nil {
+		log.Error().Msgf("failed to get payment info by id: %s", err)
+	}
+	if !job.Refund {
+		rpi.Released = true
+		e.paidCapacityInfoChannel <- rpi.ReservationID
+		err = types.FarmerPayoutStatementPaid(ctx, e.db, job.ID, transaction)
+		if err != nil && !errors.Is(err, types.ErrStatementNotFound) {
+			log.Error().Err(err).Msgf("could not mark payout statement for %d as paid", job.ID)
+		}
+	} else {
+		rpi.CancellationPending = false
+		rpi.Canceled = true
+
+	}
+	if err = types.CapacityReservationPaymentInfoUpdate(ctx, e.db, rpi); err != nil {
+		log.Error().Err(err).Msgf("could not mark escrows for %d as released", rpi.ReservationID)
+	}
+	if err = types.PayoutJobFinish(ctx, e.db, e.workerID, types.PayoutJobKey(job), types.PayoutJobDone); err != nil {
+		log.Error().Err(err).Msgf("could not mark payout job for %d as done", rpi.ReservationID)
+	}
+}
+
 // PaymentsLoop the payment loop the context is done
 func (e *Stellar) PaymentsLoop(ctx context.Context) error {
 	for {
 		var secrets []string
 		var payments []txnbuild.Payment
 		var jobs []stellar.PayoutJob
+		var keys []string
 		jobMap := make(map[int]int)
 		memoMap := make(map[string][]int)
 		ready := false
@@ -359,33 +488,42 @@ func (e *Stellar) PaymentsLoop(ctx context.Context) error {
 			case <-ctx.Done():
 				log.Info().Msg("escrow context done, exiting")
 				return nil
-
-			case job := <-e.paymentsChannel:
-				jobs = append(jobs, job)
-				memoMap[job.Memo] = make([]int, 0)
-				if !stringInSlice(job.SecretKey, secrets) {
-					secrets = append(secrets, job.SecretKey)
-				}
-				for _, payment := range job.Payments {
-					log.Debug().
-						Str("amount", payment.Amount).
-						Str("source", payment.SourceAccount.GetAccountID()).
-						Str("destination", payment.Destination).
-						Str("asset", job.Asset.String()).
-						Msg("another payment received")
-					memoMap[job.Memo] = append(memoMap[job.Memo], len(payments))
-					jobMap[len(payments)] = len(jobs) - 1
-					payments = append(payments, payment)
-				}
-				if len(payments) >= MaxOperationsPerTx*.8 || len(secrets) >= MaxSignaturesPerTx-1 {
-					ready = true
-				}
 			default:
+			}
+
+			job, err := e.claimPayoutJob(ctx)
+			if errors.Is(err, types.ErrNoPayoutJob) {
 				if len(secrets) > 0 {
 					ready = true
 				} else {
 					time.Sleep(1 * time.Second)
 				}
+				continue
+			} else if err != nil {
+				log.Error().Err(err).Msg("failed to claim payout job")
+				time.Sleep(1 * time.Second)
+				continue
+			}
+
+			jobs = append(jobs, job)
+			keys = append(keys, types.PayoutJobKey(job))
+			memoMap[job.Memo] = make([]int, 0)
+			if !stringInSlice(job.SecretKey, secrets) {
+				secrets = append(secrets, job.SecretKey)
+			}
+			for _, payment := range job.Payments {
+				log.Debug().
+					Str("amount", payment.Amount).
+					Str("source", payment.SourceAccount.GetAccountID()).
+					Str("destination", payment.Destination).
+					Str("asset", job.Asset.String()).
+					Msg("another payment received")
+				memoMap[job.Memo] = append(memoMap[job.Memo], len(payments))
+				jobMap[len(payments)] = len(jobs) - 1
+				payments = append(payments, payment)
+			}
+			if len(payments) >= MaxOperationsPerTx*.8 || len(secrets) >= MaxSignaturesPerTx-1 {
+				ready = true
 			}
 		}
 		sequenctNumber, err := e.wallet.GetNextSequenceNumber()
@@ -399,32 +537,34 @@ func (e *Stellar) PaymentsLoop(ctx context.Context) error {
 				TxSequence:   sequenctNumber,
 			})
 		}
-		err = e.wallet.ProcessPayoutBatches(payments, secrets)
+		if err := types.PayoutJobSubmitted(ctx, e.db, e.workerID, keys...); err != nil {
+			// a batch which is not marked could be paid twice if the
+			// explorer stops before it is done, it is retried later
+			log.Error().Err(err).Msg("failed to mark payout jobs as submitted")
+			for _, job := range jobs {
+				e.releasePayoutJob(job, job.Retries)
+			}
+			continue
+		}
+		transaction, err := e.wallet.ProcessPayoutBatches(payments, secrets)
 		totalStellarTransactions.Inc()
 		if err != nil {
 			if err2, ok := err.(*horizonclient.Error); ok {
 				err = e.processFailedPayments(err2, jobs, payments, jobMap)
+			} else {
+				// the batch may have been submitted before the error (e.g.
+				// a timeout), the jobs are only paid again if it did not
+				// settle
+				for _, job := range jobs {
+					e.requeuePayoutJob(job)
+				}
 			}
 			log.Error().Msgf("failed to submit all payouts: %s", err)
 			continue
 		}
 		log.Debug().Msg("End submitting batches")
-		for id, job := range jobs {
-			rpi, err := types.CapacityReservationPaymentInfoGet(ctx, e.db, jobs[id].ID)
-			if err != nil {
-				log.Error().Msgf("failed to get payment info by id: %s", err)
-			}
-			if !job.Refund {
-				rpi.Released = true
-				e.paidCapacityInfoChannel <- rpi.ReservationID
-			} else {
-				rpi.CancellationPending = false
-				rpi.Canceled = true
-
-			}
-			if err = types.CapacityReservationPaymentInfoUpdate(e.ctx, e.db, rpi); err != nil {
-				log.Error().Err(err).Msgf("could not mark escrows for %d as released", rpi.ReservationID)
-			}
+		for _, job := range jobs {
+			e.payoutJobDone(ctx, job, transaction)
 		}
 	}
 }
@@ -443,6 +583,21 @@ func (e *Stellar) refundExpiredCapacityReservations() error {
 			continue
 		}
 
+		// the reservation was never paid, so the uses of its pricing rules
+		// are given back
+		if len(escrowInfo.Rules) == 0 || escrowInfo.Paid {
+			continue
+		}
+
+		reservation, err := capacitytypes.CapacityReservationGet(e.ctx, e.db, escrowInfo.ReservationID)
+		if err != nil {
+			log.Error().Err(err).Msgf("failed to load capacity reservation")
+			continue
+		}
+
+		if err := e.releasePricingRules(reservation.CustomerTid, escrowInfo.ReservationID, escrowInfo.Rules); err != nil {
+			log.Error().Err(err).Msgf("failed to release pricing rules")
+		}
 	}
 	return nil
 }
@@ -484,7 +639,7 @@ func (e *Stellar) checkCapacityReservationPaid(escrowInfo types.CapacityReservat
 
 	// calculate total amount needed for reservation
 	requiredValue := escrowInfo.Amount
-	memo := capacityReservationMemo(escrowInfo.ReservationID)
+	memo := types.CapacityReservationMemo(escrowInfo.ReservationID)
 	batchTx, err := getBatchMemoTransactions(e.ctx, e.db, memo)
 	if err != nil {
 		log.Error().Err(err).Str("memo", memo).Msg("failed to get batch memo transactions")
@@ -506,6 +661,11 @@ func (e *Stellar) checkCapacityReservationPaid(escrowInfo types.CapacityReservat
 		return errors.Wrap(err, "failed to mark reservation escrow info as paid")
 	}
 
+	if err = e.issueReceipt(escrowInfo); err != nil {
+		// the customer paid, so we don't want to block the reservation
+		slog.Error().Err(err).Msg("failed to issue receipt")
+	}
+
 	if err = e.payoutFarmersCap(escrowInfo); err != nil {
 		slog.Debug().Msgf("farmer payout for capacity reservation %d failed, refund client", escrowInfo.ReservationID)
 		if err2 := e.refundCapacityEscrow(escrowInfo, err.Error()); err2 != nil {
@@ -519,6 +679,53 @@ func (e *Stellar) checkCapacityReservationPaid(escrowInfo types.CapacityReservat
 	return nil
 }
 
+// issueReceipt creates the signed receipt of a paid capacity reservation
+func (e *Stellar) issueReceipt(escrowInfo types.CapacityReservationPaymentInformation) error {
+	reservation, err := capacitytypes.CapacityReservationGet(e.ctx, e.db, escrowInfo.ReservationID)
+	if err != nil {
+		return errors.Wrap(err, "failed to load capacity reservation")
+	}
+
+	poolID := reservation.ID
+	if reservation.DataReservation.PoolID != 0 {
+		poolID = schema.ID(reservation.DataReservation.PoolID)
+	}
+
+	memo := types.CapacityReservationMemo(escrowInfo.ReservationID)
+	transactions, err := e.wallet.FundingTransactions(escrowInfo.Address, memo)
+	if err != nil {
+		return errors.Wrap(err, "failed to get the transactions of the payment")
+	}
+
+	receipt := types.CapacityReceipt{
+		ReservationID: escrowInfo.ReservationID,
+		CustomerTid:   reservation.CustomerTid,
+		PoolID:        poolID,
+		FarmID:        escrowInfo.FarmerID,
+		CUs:           reservation.DataReservation.CUs,
+		SUs:           reservation.DataReservation.SUs,
+		IPv4Us:        reservation.DataReservation.IPv4Us,
+		Price:         escrowInfo.Price,
+		Discount:      escrowInfo.Discount,
+		Amount:        escrowInfo.Amount,
+		Asset:         escrowInfo.Asset,
+		Transactions:  transactions,
+		Issued:        schema.Date{Time: time.Now()},
+		Signer:        e.wallet.PublicAddress(),
+	}
+
+	if err := receipt.Sign(e.wallet.Sign); err != nil {
+		return err
+	}
+
+	err = types.CapacityReceiptCreate(e.ctx, e.db, receipt)
+	if errors.Is(err, types.ErrReceiptExists) {
+		return nil
+	}
+
+	return err
+}
+
 // processCapacityReservation processes a single reservation
 // calculates resources and their costs
 func (e *Stellar) processCapacityReservation(reservation capacitytypes.Reservation, offeredCurrencyCodes []string) (types.CustomerCapacityEscrowInformation, error) {
@@ -605,6 +812,11 @@ func (e *Stellar) processCapacityReservation(reservation capacitytypes.Reservati
 		whichThreebotID = pool.SponsorTid
 	}
 
+	unitPrice := types.CapacityPrice{
+		CU:    CuPriceDollarMonth,
+		SU:    SuPriceDollarMonth,
+		IPv4U: IP4uPriceDollarMonth,
+	}
 	price, err := e.farmAPI.GetFarmCustomPriceForThreebot(e.ctx, e.db, farmIDs[0], whichThreebotID)
 	// safe to ignore the error here, we already have a farm
 	if err != nil {
@@ -620,14 +832,36 @@ func (e *Stellar) processCapacityReservation(reservation capacitytypes.Reservati
 		if err != nil {
 			return customerInfo, errors.Wrap(err, "failed to calculate capacity reservation cost")
 		}
+		unitPrice = types.CapacityPrice{
+			CU:    cuDollarPerMonth,
+			SU:    suDollarPerMonth,
+			IPv4U: ip4uDollarPerMonth,
+		}
+	}
+
+	cost := amount
+	amount, rules, err := e.applyCapacityPricingRules(reservation, schema.ID(farmIDs[0]), cost)
+	if err != nil {
+		return customerInfo, err
 	}
 
+	// the uses of the rules are reserved now, so the customer is asked the
+	// amount which is actually required
+	rules, dropped, err := e.reservePricingRules(reservation.CustomerTid, reservation.ID, rules)
+	if err != nil {
+		return customerInfo, errors.Wrap(err, "failed to reserve the pricing rules")
+	}
+	amount += dropped
+
 	reservationPaymentInfo := types.CapacityReservationPaymentInformation{
 		ReservationID:       reservation.ID,
 		Address:             address,
 		Expiration:          schema.Date{Time: time.Now().Add(capacityReservationTimeout)},
 		Asset:               asset,
 		Amount:              amount,
+		Price:               unitPrice,
+		Discount:            discountFraction(cost, amount),
+		Rules:               rules,
 		Paid:                false,
 		Released:            false,
 		Canceled:            false,
@@ -643,6 +877,9 @@ func (e *Stellar) processCapacityReservation(reservation capacitytypes.Reservati
 	}
 	err = types.CapacityReservationPaymentInfoCreate(e.ctx, e.db, reservationPaymentInfo)
 	if err != nil {
+		if err := e.releasePricingRules(reservation.CustomerTid, reservation.ID, rules); err != nil {
+			log.Error().Err(err).Int64("id", int64(reservation.ID)).Msg("failed to release pricing rules")
+		}
 		return customerInfo, errors.Wrap(err, "failed to create reservation payment information")
 	}
 
@@ -655,6 +892,7 @@ func (e *Stellar) processCapacityReservation(reservation capacitytypes.Reservati
 	customerInfo.Address = address
 	customerInfo.Asset = asset
 	customerInfo.Amount = amount
+	customerInfo.Rules = rules
 	return customerInfo, nil
 }
 
@@ -788,18 +1026,64 @@ func (e *Stellar) payoutFarmersCap(rpi types.CapacityReservationPaymentInformati
 		)
 	}
 
+	if err := e.createPayoutStatement(rpi, farm, amounts, payouts); err != nil {
+		// the statement is informative only, this should not block the payout
+		log.Error().Err(err).Msgf("failed to create payout statement for reservation %d", rpi.ReservationID)
+	}
+
 	addressInfo, err := types.CustomerAddressByAddress(e.ctx, e.db, rpi.Address)
 	if err != nil {
 		log.Error().Msgf("failed to load escrow address info: %s", err)
 		return errors.Wrap(err, "could not load escrow address info")
 	}
-	if err = e.wallet.QueuePayout(addressInfo.Secret, paymentInfo, capacityReservationMemo(rpi.ReservationID), rpi.Asset, rpi.ReservationID, e.paymentsChannel); err != nil {
+	if err = e.wallet.QueuePayout(addressInfo.Secret, paymentInfo, types.CapacityReservationMemo(rpi.ReservationID), rpi.Asset, rpi.ReservationID, e.payoutQueue); err != nil {
 		log.Error().Msgf("failed to pay farmer: %s for reservation %d", err, rpi.ReservationID)
 		return errors.Wrap(err, "could not pay farmer")
 	}
 	return nil
 }
 
+// createPayoutStatement records what the farmer earns with the payout of a
+// capacity reservation
+func (e *Stellar) createPayoutStatement(rpi types.CapacityReservationPaymentInformation, farm directorytypes.Farm, amounts []int64, payouts []Payout) error {
+	reservation, err := capacitytypes.CapacityReservationGet(e.ctx, e.db, rpi.ReservationID)
+	if err != nil {
+		return errors.Wrap(err, "failed to load capacity reservation")
+	}
+
+	poolID := reservation.ID
+	if reservation.DataReservation.PoolID != 0 {
+		poolID = schema.ID(reservation.DataReservation.PoolID)
+	}
+
+	farmerAddress, err := addressByAsset(farm.WalletAddresses, rpi.Asset)
+	if err != nil {
+		return err
+	}
+
+	statement := types.FarmerPayoutStatement{
+		ReservationID: rpi.ReservationID,
+		FarmID:        farm.ID,
+		PoolID:        poolID,
+		CUs:           reservation.DataReservation.CUs,
+		SUs:           reservation.DataReservation.SUs,
+		IPv4Us:        reservation.DataReservation.IPv4Us,
+		Asset:         rpi.Asset,
+		Gross:         rpi.Amount,
+	}
+
+	for i, amount := range amounts {
+		switch payouts[i].Address {
+		case farmerAddress:
+			statement.Net += xdr.Int64(amount)
+		case e.foundationAddress:
+			statement.FoundationCut += xdr.Int64(amount)
+		}
+	}
+
+	return types.FarmerPayoutStatementCreate(e.ctx, e.db, statement)
+}
+
 func (e *Stellar) refundCapacityEscrow(escrowInfo types.CapacityReservationPaymentInformation, cause string) error {
 	slog := log.With().
 		Str("address", escrowInfo.Address).
@@ -812,11 +1096,11 @@ func (e *Stellar) refundCapacityEscrow(escrowInfo types.CapacityReservationPayme
 	if err != nil {
 		return errors.Wrap(err, "failed to load escrow info")
 	}
-	batchTxs, err := getBatchMemoTransactions(e.ctx, e.db, capacityReservationMemo(escrowInfo.ReservationID))
+	batchTxs, err := getBatchMemoTransactions(e.ctx, e.db, types.CapacityReservationMemo(escrowInfo.ReservationID))
 	if err != nil {
 		return errors.Wrap(err, "failed to get memo transactions")
 	}
-	if err = e.wallet.Refund(addressInfo.Secret, capacityReservationMemo(escrowInfo.ReservationID), escrowInfo.Asset, &batchTxs, e.paymentsChannel, escrowInfo.ReservationID); err != nil {
+	if err = e.wallet.Refund(addressInfo.Secret, types.CapacityReservationMemo(escrowInfo.ReservationID), escrowInfo.Asset, &batchTxs, e.payoutQueue, escrowInfo.ReservationID); err != nil {
 		return errors.Wrap(err, "failed to refund clients")
 	}
 	escrowInfo.CancellationPending = true
@@ -968,6 +1252,13 @@ func addressByAsset(addrs []gdirectory.WalletAddress, asset stellar.Asset) (stri
 	return "", fmt.Errorf("not address found for asset %s", asset)
 }
 
-func capacityReservationMemo(id schema.ID) string {
-	return fmt.Sprintf("p-%d", id)
+// payoutWorkerID returns a unique identifier of this explorer instance, used
+// as owner of the claimed payout jobs
+func payoutWorkerID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "explorer"
+	}
+
+	return fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), time.Now().UnixNano())
 }
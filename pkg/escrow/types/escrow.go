@@ -62,6 +62,12 @@ type (
 		Expiration    schema.Date   `json:"expiration" bson:"expiration"`
 		Asset         stellar.Asset `json:"asset" bson:"asset"`
 		Amount        xdr.Int64     `json:"amount" bson:"amount"`
+		// Price of the capacity used to compute the amount
+		Price CapacityPrice `json:"price" bson:"price"`
+		// Discount is the fraction of the price deducted from the amount
+		Discount float64 `json:"discount" bson:"discount"`
+		// Rules are the pricing rules which were applied to the price
+		Rules []AppliedPricingRule `json:"rules" bson:"rules"`
 		// Paid indicates the capacity reservation escrows have been fully funded,
 		// resulting in the new funds being allocated into the pool (creating
 		// the pool in case it did not exist yet)
@@ -98,6 +104,8 @@ type (
 		Address string        `json:"address"`
 		Asset   stellar.Asset `json:"asset"`
 		Amount  xdr.Int64     `json:"amount"`
+		// Rules are the pricing rules which lowered the amount
+		Rules []AppliedPricingRule `json:"rules,omitempty"`
 	}
 
 	// CapacityReservationInfo is information to manipulate a capacity pool once
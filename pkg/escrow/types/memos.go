@@ -2,8 +2,10 @@ package types
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/pkg/errors"
+	"github.com/threefoldtech/tfexplorer/schema"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -26,6 +28,11 @@ type (
 	}
 )
 
+// CapacityReservationMemo is the memo text of the payments of a capacity reservation
+func CapacityReservationMemo(id schema.ID) string {
+	return fmt.Sprintf("p-%d", id)
+}
+
 // CapacityMemoTextInfoCreate creates the capacity memo text info document
 func CapacityMemoTextInfoCreate(ctx context.Context, db *mongo.Database, info CapacityMemoTextInfo) error {
 	col := db.Collection(CapacityMemoTextCollection)
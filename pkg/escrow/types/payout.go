@@ -0,0 +1,305 @@
+package types
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stellar/go/txnbuild"
+	"github.com/threefoldtech/tfexplorer/pkg/stellar"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// PayoutJobCollection db collection for queued farmer payouts and client refunds
+	PayoutJobCollection = "capacity-payout-jobs"
+)
+
+// PayoutJobState is the processing state of a queued payout job
+type PayoutJobState string
+
+const (
+	// PayoutJobPending is a job waiting to be claimed by a payments loop
+	PayoutJobPending PayoutJobState = "pending"
+	// PayoutJobClaimed is a job currently leased by a payments loop
+	PayoutJobClaimed PayoutJobState = "claimed"
+	// PayoutJobDone is a job which has been paid out
+	PayoutJobDone PayoutJobState = "done"
+	// PayoutJobFailed is a job which ran out of retries
+	PayoutJobFailed PayoutJobState = "failed"
+)
+
+var (
+	// ErrNoPayoutJob is returned when there is no payout job to claim
+	ErrNoPayoutJob = errors.New("no payout job available")
+)
+
+type (
+	// PayoutPayment is a single payment operation of a payout job
+	PayoutPayment struct {
+		Source      string `bson:"source" json:"source"`
+		Destination string `bson:"destination" json:"destination"`
+		Amount      string `bson:"amount" json:"amount"`
+	}
+
+	// PayoutJobInfo is the persisted form of a stellar.PayoutJob. Jobs are
+	// claimed by a payments loop for a limited amount of time (lease), so
+	// multiple explorer instances can process the queue at the same time.
+	// A job which lease expires before it was completed can be claimed again.
+	PayoutJobInfo struct {
+		// Key is the idempotency key of the job, see PayoutJobKey
+		Key           string          `bson:"_id" json:"key"`
+		ReservationID schema.ID       `bson:"reservation_id" json:"reservation_id"`
+		Memo          string          `bson:"memo" json:"memo"`
+		Asset         stellar.Asset   `bson:"asset" json:"asset"`
+		Secret        string          `bson:"secret" json:"-"`
+		Refund        bool            `bson:"refund" json:"refund"`
+		Retries       int             `bson:"retries" json:"retries"`
+		Payments      []PayoutPayment `bson:"payments" json:"payments"`
+		State         PayoutJobState  `bson:"state" json:"state"`
+		// Submitted is set right before the job is submitted to the network.
+		// If a claimed job has this flag set, the previous owner might have
+		// paid it out before it could mark the job as done.
+		Submitted  bool        `bson:"submitted" json:"submitted"`
+		Attempts   int         `bson:"attempts" json:"attempts"`
+		LeaseOwner string      `bson:"lease_owner" json:"lease_owner"`
+		LeaseUntil schema.Date `bson:"lease_until" json:"lease_until"`
+		Created    schema.Date `bson:"created" json:"created"`
+		Updated    schema.Date `bson:"updated" json:"updated"`
+	}
+)
+
+// PayoutJobKey returns the idempotency key of a job. A payout and a refund for
+// the same reservation have a different key
+func PayoutJobKey(job stellar.PayoutJob) string {
+	kind := "payout"
+	if job.Refund {
+		kind = "refund"
+	}
+
+	return fmt.Sprintf("%s:%d:%s", job.Memo, job.ID, kind)
+}
+
+// NewPayoutJobInfo creates the persisted form of a payout job
+func NewPayoutJobInfo(job stellar.PayoutJob) PayoutJobInfo {
+	now := schema.Date{Time: time.Now()}
+	info := PayoutJobInfo{
+		Key:           PayoutJobKey(job),
+		ReservationID: job.ID,
+		Memo:          job.Memo,
+		Asset:         job.Asset,
+		Secret:        job.SecretKey,
+		Refund:        job.Refund,
+		Retries:       job.Retries,
+		Payments:      make([]PayoutPayment, 0, len(job.Payments)),
+		State:         PayoutJobPending,
+		Created:       now,
+		Updated:       now,
+	}
+
+	for _, payment := range job.Payments {
+		var source string
+		if payment.SourceAccount != nil {
+			source = payment.SourceAccount.GetAccountID()
+		}
+		info.Payments = append(info.Payments, PayoutPayment{
+			Source:      source,
+			Destination: payment.Destination,
+			Amount:      payment.Amount,
+		})
+	}
+
+	return info
+}
+
+// Job rebuilds the stellar.PayoutJob from the persisted information
+func (p PayoutJobInfo) Job() stellar.PayoutJob {
+	job := stellar.PayoutJob{
+		ID:        p.ReservationID,
+		Memo:      p.Memo,
+		Asset:     p.Asset,
+		SecretKey: p.Secret,
+		Refund:    p.Refund,
+		Retries:   p.Retries,
+		Payments:  make([]txnbuild.Payment, 0, len(p.Payments)),
+	}
+
+	for _, payment := range p.Payments {
+		// the sequence number of the source account of an operation is
+		// not used, the transaction is funded by the explorer wallet
+		source := txnbuild.NewSimpleAccount(payment.Source, 0)
+		job.Payments = append(job.Payments, txnbuild.Payment{
+			Destination: payment.Destination,
+			Amount:      payment.Amount,
+			Asset: txnbuild.CreditAsset{
+				Code:   p.Asset.Code(),
+				Issuer: p.Asset.Issuer(),
+			},
+			SourceAccount: &source,
+		})
+	}
+
+	return job
+}
+
+// PayoutJobPush stores a new payout job. Pushing a job with the same key as
+// an existing job is a no-op, this makes it safe to push the same payout more
+// than once
+func PayoutJobPush(ctx context.Context, db *mongo.Database, job stellar.PayoutJob) error {
+	col := db.Collection(PayoutJobCollection)
+	_, err := col.InsertOne(ctx, NewPayoutJobInfo(job))
+	if err != nil {
+		if merr, ok := err.(mongo.WriteException); ok {
+			errCode := merr.WriteErrors[0].Code
+			if errCode == 11000 {
+				return nil
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// payoutJobClaimable filters the jobs which can be claimed at the time: the
+// pending jobs, and the claimed jobs which lease expired
+func payoutJobClaimable(now time.Time) bson.M {
+	return bson.M{
+		"$or": bson.A{
+			bson.M{"state": PayoutJobPending},
+			bson.M{"state": PayoutJobClaimed, "lease_until.time": bson.M{"$lt": now}},
+		},
+	}
+}
+
+// payoutJobClaim leases a job to owner. The submitted flag is kept, so the
+// new owner knows the previous owner might have paid the job already
+func payoutJobClaim(owner string, now time.Time, lease time.Duration) bson.M {
+	return bson.M{
+		"$set": bson.M{
+			"state":       PayoutJobClaimed,
+			"lease_owner": owner,
+			"lease_until": schema.Date{Time: now.Add(lease)},
+			"updated":     schema.Date{Time: now},
+		},
+		"$inc": bson.M{"attempts": 1},
+	}
+}
+
+// payoutJobOwned filters the jobs with the keys currently leased by owner, a
+// job which lease was taken over by another owner is left alone
+func payoutJobOwned(owner string, keys ...string) bson.M {
+	return bson.M{"_id": bson.M{"$in": keys}, "lease_owner": owner, "state": PayoutJobClaimed}
+}
+
+// payoutJobSubmit marks a job as submitted to the network
+func payoutJobSubmit(now time.Time) bson.M {
+	return bson.M{
+		"$set": bson.M{
+			"submitted": true,
+			"updated":   schema.Date{Time: now},
+		},
+	}
+}
+
+// payoutJobRelease gives a job back to the queue. The job was not paid, so
+// the submitted flag is cleared
+func payoutJobRelease(retries int, now time.Time) bson.M {
+	return bson.M{
+		"$set": bson.M{
+			"state":       PayoutJobPending,
+			"retries":     retries,
+			"submitted":   false,
+			"lease_owner": "",
+			"updated":     schema.Date{Time: now},
+		},
+	}
+}
+
+// payoutJobRequeue gives a submitted job back to the queue. The job may be
+// paid, so the submitted flag is kept and the next owner checks the payments
+// settled before paying again
+func payoutJobRequeue(now time.Time) bson.M {
+	return bson.M{
+		"$set": bson.M{
+			"state":       PayoutJobPending,
+			"lease_owner": "",
+			"updated":     schema.Date{Time: now},
+		},
+	}
+}
+
+// payoutJobFinish sets the final state of a job
+func payoutJobFinish(state PayoutJobState, now time.Time) bson.M {
+	return bson.M{
+		"$set": bson.M{
+			"state":   state,
+			"updated": schema.Date{Time: now},
+		},
+	}
+}
+
+// PayoutJobClaim leases the oldest available job to owner for the given duration.
+// Jobs are available if they are pending, or if their lease expired. ErrNoPayoutJob
+// is returned if there is nothing to claim
+func PayoutJobClaim(ctx context.Context, db *mongo.Database, owner string, lease time.Duration) (PayoutJobInfo, error) {
+	now := time.Now()
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.M{"created.time": 1}).
+		SetReturnDocument(options.After)
+
+	var info PayoutJobInfo
+	res := db.Collection(PayoutJobCollection).FindOneAndUpdate(ctx, payoutJobClaimable(now), payoutJobClaim(owner, now, lease), opts)
+	if err := res.Err(); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return info, ErrNoPayoutJob
+		}
+		return info, err
+	}
+
+	err := res.Decode(&info)
+	return info, err
+}
+
+// PayoutJobSubmitted marks the jobs claimed by owner as submitted to the network
+func PayoutJobSubmitted(ctx context.Context, db *mongo.Database, owner string, keys ...string) error {
+	_, err := db.Collection(PayoutJobCollection).UpdateMany(ctx, payoutJobOwned(owner, keys...), payoutJobSubmit(time.Now()))
+	return err
+}
+
+// PayoutJobRelease gives a claimed job back to the queue so it can be retried
+func PayoutJobRelease(ctx context.Context, db *mongo.Database, owner string, key string, retries int) error {
+	_, err := db.Collection(PayoutJobCollection).UpdateOne(ctx, payoutJobOwned(owner, key), payoutJobRelease(retries, time.Now()))
+	return err
+}
+
+// PayoutJobRequeue gives a claimed job which may be paid back to the queue
+func PayoutJobRequeue(ctx context.Context, db *mongo.Database, owner string, key string) error {
+	_, err := db.Collection(PayoutJobCollection).UpdateOne(ctx, payoutJobOwned(owner, key), payoutJobRequeue(time.Now()))
+	return err
+}
+
+// PayoutJobFinish sets the final state (done or failed) of a claimed job
+func PayoutJobFinish(ctx context.Context, db *mongo.Database, owner string, key string, state PayoutJobState) error {
+	_, err := db.Collection(PayoutJobCollection).UpdateOne(ctx, payoutJobOwned(owner, key), payoutJobFinish(state, time.Now()))
+	return err
+}
+
+// PayoutQueue is a stellar.PayoutQueue which stores the jobs in the database
+type PayoutQueue struct {
+	ctx context.Context
+	db  *mongo.Database
+}
+
+// NewPayoutQueue creates a database backed payout queue
+func NewPayoutQueue(ctx context.Context, db *mongo.Database) *PayoutQueue {
+	return &PayoutQueue{ctx: ctx, db: db}
+}
+
+// Push implements stellar.PayoutQueue
+func (q *PayoutQueue) Push(job stellar.PayoutJob) error {
+	return PayoutJobPush(q.ctx, q.db, job)
+}
@@ -0,0 +1,110 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/tfexplorer/pkg/stellar"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestPayoutJobClaimable(t *testing.T) {
+	now := time.Now()
+
+	expected := bson.M{
+		"$or": bson.A{
+			bson.M{"state": PayoutJobPending},
+			bson.M{"state": PayoutJobClaimed, "lease_until.time": bson.M{"$lt": now}},
+		},
+	}
+
+	assert.Equal(t, expected, payoutJobClaimable(now), "pending jobs and claimed jobs which lease expired are claimable")
+}
+
+func TestPayoutJobClaimUpdate(t *testing.T) {
+	now := time.Now()
+
+	update := payoutJobClaim("worker1", now, time.Minute)
+
+	set := update["$set"].(bson.M)
+	assert.Equal(t, PayoutJobClaimed, set["state"])
+	assert.Equal(t, "worker1", set["lease_owner"])
+	assert.Equal(t, schema.Date{Time: now.Add(time.Minute)}, set["lease_until"])
+	assert.NotContains(t, set, "submitted", "a claim keeps the submitted flag")
+	assert.Equal(t, bson.M{"attempts": 1}, update["$inc"])
+}
+
+func TestPayoutJobOwned(t *testing.T) {
+	expected := bson.M{
+		"_id":         bson.M{"$in": []string{"a", "b"}},
+		"lease_owner": "worker1",
+		"state":       PayoutJobClaimed,
+	}
+
+	assert.Equal(t, expected, payoutJobOwned("worker1", "a", "b"), "only the jobs still leased by the owner are updated")
+}
+
+func TestPayoutJobReleaseUpdate(t *testing.T) {
+	now := time.Now()
+
+	set := payoutJobRelease(4, now)["$set"].(bson.M)
+	assert.Equal(t, PayoutJobPending, set["state"])
+	assert.Equal(t, 4, set["retries"])
+	assert.Equal(t, "", set["lease_owner"])
+	assert.Equal(t, false, set["submitted"], "a released job was not paid")
+}
+
+func TestPayoutJobSubmitUpdate(t *testing.T) {
+	now := time.Now()
+
+	set := payoutJobSubmit(now)["$set"].(bson.M)
+	assert.Equal(t, true, set["submitted"])
+	assert.NotContains(t, set, "state", "a submitted job stays claimed")
+}
+
+func TestPayoutJobFinishUpdate(t *testing.T) {
+	now := time.Now()
+
+	set := payoutJobFinish(PayoutJobDone, now)["$set"].(bson.M)
+	assert.Equal(t, PayoutJobDone, set["state"])
+	assert.Equal(t, schema.Date{Time: now}, set["updated"])
+}
+
+func TestPayoutJobInfoRoundTrip(t *testing.T) {
+	job := stellar.PayoutJob{
+		ID:      12,
+		Memo:    "memo",
+		Asset:   stellar.TFTMainnet,
+		Retries: 5,
+	}
+
+	info := NewPayoutJobInfo(job)
+	assert.Equal(t, PayoutJobPending, info.State)
+	assert.Equal(t, PayoutJobKey(job), info.Key)
+
+	back := info.Job()
+	assert.Equal(t, job.ID, back.ID)
+	assert.Equal(t, job.Memo, back.Memo)
+	assert.Equal(t, job.Retries, back.Retries)
+}
+
+func TestPayoutJobKey(t *testing.T) {
+	payout := stellar.PayoutJob{ID: 12, Memo: "memo"}
+	refund := payout
+	refund.Refund = true
+
+	assert.Equal(t, PayoutJobKey(payout), PayoutJobKey(payout))
+	assert.NotEqual(t, PayoutJobKey(payout), PayoutJobKey(refund))
+}
+
+func TestPayoutJobRequeueUpdate(t *testing.T) {
+	now := time.Now()
+
+	set := payoutJobRequeue(now)["$set"].(bson.M)
+	assert.Equal(t, PayoutJobPending, set["state"])
+	assert.Equal(t, "", set["lease_owner"])
+	assert.NotContains(t, set, "submitted", "a requeued job may be paid, the next owner checks it settled")
+	assert.NotContains(t, set, "retries")
+}
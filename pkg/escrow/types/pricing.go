@@ -0,0 +1,370 @@
+package types
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stellar/go/xdr"
+	"github.com/threefoldtech/tfexplorer/models"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// PricingRuleCollection db collection for the capacity pricing rules
+	PricingRuleCollection = "pricing-rules"
+	// FirstTimeCreditCollection db collection for the first time credits used
+	// by the customers
+	FirstTimeCreditCollection = "first-time-credits"
+)
+
+var (
+	// ErrPricingRuleNotFound is returned if a pricing rule is not found
+	ErrPricingRuleNotFound = errors.New("pricing rule not found")
+	// ErrPromoCodeExists is returned when creating a promotion with a code
+	// which is already used
+	ErrPromoCodeExists = errors.New("promotion code already exists")
+	// ErrFirstTimeCreditUsed is returned when a customer already used a first
+	// time credit on another capacity reservation
+	ErrFirstTimeCreditUsed = errors.New("first time credit already used")
+	// ErrPromoCodeExhausted is returned when a promotion code has been used
+	// the maximum amount of times
+	ErrPromoCodeExhausted = errors.New("promotion code has been used the maximum amount of times")
+)
+
+// PricingRuleType is the kind of a pricing rule
+type PricingRuleType string
+
+const (
+	// PricingRuleTier gives a discount when a minimum amount of units is bought
+	PricingRuleTier PricingRuleType = "tier"
+	// PricingRulePromo gives a discount to reservations using the promotion code
+	PricingRulePromo PricingRuleType = "promo"
+	// PricingRuleFirstTime gives a credit to customers on their first paid
+	// capacity reservation
+	PricingRuleFirstTime PricingRuleType = "first_time"
+)
+
+type (
+	// PricingRule is a rule which lowers the price of a capacity reservation.
+	// Rules with a FarmID only apply to reservations on that farm, rules
+	// without one apply to all farms
+	PricingRule struct {
+		ID          schema.ID       `bson:"_id" json:"id"`
+		Type        PricingRuleType `bson:"type" json:"type"`
+		Description string          `bson:"description" json:"description"`
+		FarmID      schema.ID       `bson:"farm_id" json:"farm_id"`
+		// MinUnits is the minimum amount of unit seconds (cus + sus + ipv4us)
+		// reserved for a tier rule to apply
+		MinUnits uint64 `bson:"min_units" json:"min_units"`
+		// Code of a promotion rule
+		Code string `bson:"code,omitempty" json:"code,omitempty"`
+		// Discount is the fraction of the price deducted by a tier or promotion rule
+		Discount float64 `bson:"discount" json:"discount"`
+		// Credit is the amount in dollar deducted by a first time rule
+		Credit float64 `bson:"credit" json:"credit"`
+		// MaxUses is the maximum amount of reservations which can use a
+		// promotion code, 0 means unlimited
+		MaxUses int64 `bson:"max_uses" json:"max_uses"`
+		Uses    int64 `bson:"uses" json:"uses"`
+		// NotBefore and NotAfter limit the time the rule is active, zero
+		// values are not checked
+		NotBefore schema.Date `bson:"not_before" json:"not_before"`
+		NotAfter  schema.Date `bson:"not_after" json:"not_after"`
+		Enabled   bool        `bson:"enabled" json:"enabled"`
+		CreatedBy int64       `bson:"created_by" json:"created_by"`
+		Created   schema.Date `bson:"created" json:"created"`
+	}
+
+	// AppliedPricingRule is a pricing rule applied to a capacity reservation
+	AppliedPricingRule struct {
+		RuleID      schema.ID       `bson:"rule_id" json:"rule_id"`
+		Type        PricingRuleType `bson:"type" json:"type"`
+		Description string          `bson:"description" json:"description"`
+		// Amount deducted from the price of the reservation by the rule
+		Amount xdr.Int64 `bson:"amount" json:"amount"`
+	}
+)
+
+// Validate the pricing rule
+func (r *PricingRule) Validate() error {
+	switch r.Type {
+	case PricingRuleTier:
+		if r.MinUnits == 0 {
+			return fmt.Errorf("min_units is required for a tier rule")
+		}
+	case PricingRulePromo:
+		if len(strings.TrimSpace(r.Code)) == 0 {
+			return fmt.Errorf("code is required for a promo rule")
+		}
+	case PricingRuleFirstTime:
+		if r.Credit <= 0 {
+			return fmt.Errorf("credit must be positive for a first_time rule")
+		}
+	default:
+		return fmt.Errorf("invalid rule type '%s', supported types are tier, promo and first_time", r.Type)
+	}
+
+	if r.Type != PricingRuleFirstTime && (r.Discount <= 0 || r.Discount >= 1) {
+		return fmt.Errorf("discount must be between 0 and 1")
+	}
+
+	if r.MaxUses < 0 {
+		return fmt.Errorf("max_uses can't be negative")
+	}
+
+	if !r.NotBefore.IsZero() && !r.NotAfter.IsZero() && r.NotAfter.Before(r.NotBefore.Time) {
+		return fmt.Errorf("not_after must be after not_before")
+	}
+
+	return nil
+}
+
+// IsActive checks if the rule can be applied at the given time
+func (r *PricingRule) IsActive(now time.Time) bool {
+	if !r.Enabled {
+		return false
+	}
+
+	if !r.NotBefore.IsZero() && now.Before(r.NotBefore.Time) {
+		return false
+	}
+
+	if !r.NotAfter.IsZero() && now.After(r.NotAfter.Time) {
+		return false
+	}
+
+	return r.MaxUses == 0 || r.Uses < r.MaxUses
+}
+
+// AppliesTo checks if the rule can be used for a reservation on the farm
+func (r *PricingRule) AppliesTo(farmID schema.ID) bool {
+	return r.FarmID == 0 || r.FarmID == farmID
+}
+
+// NormalizePromoCode is the form in which promotion codes are stored and compared
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// PricingRuleFilter type
+type PricingRuleFilter bson.D
+
+// WithID filter rule with ID
+func (f PricingRuleFilter) WithID(id schema.ID) PricingRuleFilter {
+	return append(f, bson.E{Key: "_id", Value: id})
+}
+
+// WithType filter rules of a type
+func (f PricingRuleFilter) WithType(t PricingRuleType) PricingRuleFilter {
+	return append(f, bson.E{Key: "type", Value: t})
+}
+
+// WithFarmID filter rules created for a farm
+func (f PricingRuleFilter) WithFarmID(id schema.ID) PricingRuleFilter {
+	return append(f, bson.E{Key: "farm_id", Value: id})
+}
+
+// WithFarmOrGlobal filter rules which apply to reservations on the farm
+func (f PricingRuleFilter) WithFarmOrGlobal(id schema.ID) PricingRuleFilter {
+	return append(f, bson.E{Key: "farm_id", Value: bson.M{"$in": bson.A{0, id}}})
+}
+
+// WithCode filter promotion rule with code
+func (f PricingRuleFilter) WithCode(code string) PricingRuleFilter {
+	return append(f, bson.E{Key: "code", Value: NormalizePromoCode(code)})
+}
+
+// WithEnabled filter enabled or disabled rules
+func (f PricingRuleFilter) WithEnabled(enabled bool) PricingRuleFilter {
+	return append(f, bson.E{Key: "enabled", Value: enabled})
+}
+
+// Find run the filter and return a cursor result
+func (f PricingRuleFilter) Find(ctx context.Context, db *mongo.Database, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	col := db.Collection(PricingRuleCollection)
+	if f == nil {
+		f = PricingRuleFilter{}
+	}
+
+	return col.Find(ctx, f, opts...)
+}
+
+// Count number of documents matching
+func (f PricingRuleFilter) Count(ctx context.Context, db *mongo.Database) (int64, error) {
+	col := db.Collection(PricingRuleCollection)
+	if f == nil {
+		f = PricingRuleFilter{}
+	}
+
+	return col.CountDocuments(ctx, f)
+}
+
+// List all the rules matching the filter
+func (f PricingRuleFilter) List(ctx context.Context, db *mongo.Database) ([]PricingRule, error) {
+	cur, err := f.Find(ctx, db, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list pricing rules")
+	}
+	defer cur.Close(ctx)
+
+	rules := []PricingRule{}
+	if err := cur.All(ctx, &rules); err != nil {
+		return nil, errors.Wrap(err, "failed to decode pricing rules")
+	}
+
+	return rules, nil
+}
+
+// Get one rule that matches the filter
+func (f PricingRuleFilter) Get(ctx context.Context, db *mongo.Database) (rule PricingRule, err error) {
+	if f == nil {
+		f = PricingRuleFilter{}
+	}
+
+	result := db.Collection(PricingRuleCollection).FindOne(ctx, f)
+	if err = result.Err(); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return rule, ErrPricingRuleNotFound
+		}
+		return rule, err
+	}
+
+	err = result.Decode(&rule)
+	return
+}
+
+// Delete the rules matching the filter
+func (f PricingRuleFilter) Delete(ctx context.Context, db *mongo.Database) error {
+	if f == nil {
+		f = PricingRuleFilter{}
+	}
+
+	result, err := db.Collection(PricingRuleCollection).DeleteOne(ctx, f)
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return ErrPricingRuleNotFound
+	}
+
+	return nil
+}
+
+// PricingRuleCreate validates and saves a new pricing rule
+func PricingRuleCreate(ctx context.Context, db *mongo.Database, rule PricingRule) (PricingRule, error) {
+	if rule.Type == PricingRulePromo {
+		rule.Code = NormalizePromoCode(rule.Code)
+	}
+
+	if err := rule.Validate(); err != nil {
+		return rule, err
+	}
+
+	id, err := models.NextID(ctx, db, PricingRuleCollection)
+	if err != nil {
+		return rule, err
+	}
+
+	rule.ID = id
+	rule.Uses = 0
+	rule.Created = schema.Date{Time: time.Now()}
+
+	if _, err := db.Collection(PricingRuleCollection).InsertOne(ctx, rule); err != nil {
+		if merr, ok := err.(mongo.WriteException); ok {
+			errCode := merr.WriteErrors[0].Code
+			if errCode == 11000 {
+				return rule, ErrPromoCodeExists
+			}
+		}
+		return rule, err
+	}
+
+	return rule, nil
+}
+
+// PricingRuleSetEnabled enables or disables a pricing rule
+func PricingRuleSetEnabled(ctx context.Context, db *mongo.Database, id schema.ID, enabled bool) error {
+	result, err := db.Collection(PricingRuleCollection).UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"enabled": enabled}})
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrPricingRuleNotFound
+	}
+
+	return nil
+}
+
+// PricingRuleUse counts a use of the rule. It fails with ErrPromoCodeExhausted
+// if the rule is already used the maximum amount of times
+func PricingRuleUse(ctx context.Context, db *mongo.Database, rule PricingRule) error {
+	filter := bson.M{"_id": rule.ID}
+	if rule.MaxUses != 0 {
+		filter["uses"] = bson.M{"$lt": rule.MaxUses}
+	}
+
+	result, err := db.Collection(PricingRuleCollection).UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"uses": 1}})
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrPromoCodeExhausted
+	}
+
+	return nil
+}
+
+// FirstTimeCreditUse records the use of the first time credits of a customer by
+// a capacity reservation. It fails with ErrFirstTimeCreditUsed if the customer
+// used them for another reservation
+func FirstTimeCreditUse(ctx context.Context, db *mongo.Database, customerTid int64, reservationID schema.ID) error {
+	col := db.Collection(FirstTimeCreditCollection)
+	_, err := col.InsertOne(ctx, bson.M{
+		"_id":            customerTid,
+		"reservation_id": reservationID,
+		"used":           schema.Date{Time: time.Now()},
+	})
+	if err == nil {
+		return nil
+	}
+
+	merr, ok := err.(mongo.WriteException)
+	if !ok || len(merr.WriteErrors) == 0 || merr.WriteErrors[0].Code != 11000 {
+		return err
+	}
+
+	// the use is already recorded for this reservation
+	count, err := col.CountDocuments(ctx, bson.M{"_id": customerTid, "reservation_id": reservationID})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrFirstTimeCreditUsed
+	}
+
+	return nil
+}
+
+// PricingRuleRelease gives back a use of the rule reserved by a capacity
+// reservation which was never paid
+func PricingRuleRelease(ctx context.Context, db *mongo.Database, id schema.ID) error {
+	filter := bson.M{"_id": id, "uses": bson.M{"$gt": 0}}
+	_, err := db.Collection(PricingRuleCollection).UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"uses": -1}})
+	return err
+}
+
+// FirstTimeCreditRelease removes the use of the first time credits of a customer
+// by a capacity reservation which was never paid, so they can be used again
+func FirstTimeCreditRelease(ctx context.Context, db *mongo.Database, customerTid int64, reservationID schema.ID) error {
+	_, err := db.Collection(FirstTimeCreditCollection).DeleteOne(ctx, bson.M{"_id": customerTid, "reservation_id": reservationID})
+	return err
+}
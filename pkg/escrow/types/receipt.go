@@ -0,0 +1,212 @@
+package types
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/xdr"
+	"github.com/threefoldtech/tfexplorer/pkg/stellar"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// CapacityReceiptCollection db collection for the receipts of paid capacity reservations
+	CapacityReceiptCollection = "capacity-receipts"
+)
+
+var (
+	// ErrReceiptNotFound is returned if a receipt is not found
+	ErrReceiptNotFound = errors.New("receipt not found")
+	// ErrReceiptExists is returned when trying to save a receipt for a
+	// reservation that already has one
+	ErrReceiptExists = errors.New("receipt for reservation already exists")
+)
+
+type (
+	// CapacityPrice is the price in dollar of 1 unit of capacity for a month
+	CapacityPrice struct {
+		CU    float64 `bson:"cu" json:"cu"`
+		SU    float64 `bson:"su" json:"su"`
+		IPv4U float64 `bson:"ipv4u" json:"ipv4u"`
+	}
+
+	// CapacityReceipt is the proof of payment of a capacity reservation. It is
+	// signed by the explorer, and can be verified with the explorer address
+	CapacityReceipt struct {
+		ReservationID schema.ID `bson:"_id" json:"reservation_id"`
+		CustomerTid   int64     `bson:"customer_tid" json:"customer_tid"`
+		PoolID        schema.ID `bson:"pool_id" json:"pool_id"`
+		FarmID        schema.ID `bson:"farm_id" json:"farm_id"`
+		CUs           uint64    `bson:"cus" json:"cus"`
+		SUs           uint64    `bson:"sus" json:"sus"`
+		IPv4Us        uint64    `bson:"ipv4us" json:"ipv4us"`
+		// Price of the units at the time of the reservation
+		Price CapacityPrice `bson:"price" json:"price"`
+		// Discount is the fraction of the price which was deducted from the amount
+		Discount float64       `bson:"discount" json:"discount"`
+		Amount   xdr.Int64     `bson:"amount" json:"amount"`
+		Asset    stellar.Asset `bson:"asset" json:"asset"`
+		// Transactions are the hashes of the stellar transactions which paid the reservation
+		Transactions []string    `bson:"transactions" json:"transactions"`
+		Issued       schema.Date `bson:"issued" json:"issued"`
+		// Signer is the stellar address of the explorer which issued the receipt
+		Signer string `bson:"signer" json:"signer"`
+		// Signature of the receipt challenge, hex encoded
+		Signature string `bson:"signature" json:"signature"`
+	}
+)
+
+// SignatureChallenge is the message signed by the explorer
+func (r *CapacityReceipt) SignatureChallenge() ([]byte, error) {
+	b := &bytes.Buffer{}
+
+	fields := []string{
+		fmt.Sprint(r.ReservationID),
+		fmt.Sprint(r.CustomerTid),
+		fmt.Sprint(r.PoolID),
+		fmt.Sprint(r.FarmID),
+		fmt.Sprint(r.CUs),
+		fmt.Sprint(r.SUs),
+		fmt.Sprint(r.IPv4Us),
+		strconv.FormatFloat(r.Price.CU, 'f', -1, 64),
+		strconv.FormatFloat(r.Price.SU, 'f', -1, 64),
+		strconv.FormatFloat(r.Price.IPv4U, 'f', -1, 64),
+		strconv.FormatFloat(r.Discount, 'f', -1, 64),
+		fmt.Sprint(int64(r.Amount)),
+		r.Asset.String(),
+		strings.Join(r.Transactions, ","),
+		fmt.Sprint(r.Issued.Unix()),
+		r.Signer,
+	}
+
+	// fields are separated so the values of 2 consecutive fields can't be
+	// shifted without changing the challenge
+	for _, field := range fields {
+		if _, err := fmt.Fprintf(b, "%s\n", field); err != nil {
+			return nil, err
+		}
+	}
+
+	return b.Bytes(), nil
+}
+
+// Sign the receipt with the given sign function, which must sign with the
+// key of the Signer address
+func (r *CapacityReceipt) Sign(sign func(message []byte) ([]byte, error)) error {
+	challenge, err := r.SignatureChallenge()
+	if err != nil {
+		return err
+	}
+
+	signature, err := sign(challenge)
+	if err != nil {
+		return errors.Wrap(err, "failed to sign receipt")
+	}
+
+	r.Signature = hex.EncodeToString(signature)
+	return nil
+}
+
+// Verify the signature of the receipt against the given explorer address
+func (r *CapacityReceipt) Verify(address string) error {
+	if address != r.Signer {
+		return fmt.Errorf("receipt is signed by '%s' and not by '%s'", r.Signer, address)
+	}
+
+	kp, err := keypair.ParseAddress(address)
+	if err != nil {
+		return errors.Wrap(err, "invalid explorer address")
+	}
+
+	signature, err := hex.DecodeString(r.Signature)
+	if err != nil {
+		return errors.Wrap(err, "invalid signature format, expecting hex encoded string")
+	}
+
+	challenge, err := r.SignatureChallenge()
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrap(kp.Verify(challenge, signature), "receipt signature verification failed")
+}
+
+// CapacityReceiptFilter type
+type CapacityReceiptFilter bson.D
+
+// WithID filter receipt with reservation ID
+func (f CapacityReceiptFilter) WithID(id schema.ID) CapacityReceiptFilter {
+	return append(f, bson.E{Key: "_id", Value: id})
+}
+
+// WithCustomer filter receipts of a customer
+func (f CapacityReceiptFilter) WithCustomer(tid int64) CapacityReceiptFilter {
+	return append(f, bson.E{Key: "customer_tid", Value: tid})
+}
+
+// WithPoolID filter receipts of a pool
+func (f CapacityReceiptFilter) WithPoolID(id schema.ID) CapacityReceiptFilter {
+	return append(f, bson.E{Key: "pool_id", Value: id})
+}
+
+// Find run the filter and return a cursor result
+func (f CapacityReceiptFilter) Find(ctx context.Context, db *mongo.Database, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	col := db.Collection(CapacityReceiptCollection)
+	if f == nil {
+		f = CapacityReceiptFilter{}
+	}
+
+	return col.Find(ctx, f, opts...)
+}
+
+// Count number of documents matching
+func (f CapacityReceiptFilter) Count(ctx context.Context, db *mongo.Database) (int64, error) {
+	col := db.Collection(CapacityReceiptCollection)
+	if f == nil {
+		f = CapacityReceiptFilter{}
+	}
+
+	return col.CountDocuments(ctx, f)
+}
+
+// Get one receipt that matches the filter
+func (f CapacityReceiptFilter) Get(ctx context.Context, db *mongo.Database) (receipt CapacityReceipt, err error) {
+	if f == nil {
+		f = CapacityReceiptFilter{}
+	}
+
+	result := db.Collection(CapacityReceiptCollection).FindOne(ctx, f)
+	if err = result.Err(); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return receipt, ErrReceiptNotFound
+		}
+		return receipt, err
+	}
+
+	err = result.Decode(&receipt)
+	return
+}
+
+// CapacityReceiptCreate saves a signed receipt
+func CapacityReceiptCreate(ctx context.Context, db *mongo.Database, receipt CapacityReceipt) error {
+	_, err := db.Collection(CapacityReceiptCollection).InsertOne(ctx, receipt)
+	if err != nil {
+		if merr, ok := err.(mongo.WriteException); ok {
+			errCode := merr.WriteErrors[0].Code
+			if errCode == 11000 {
+				return ErrReceiptExists
+			}
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/tfexplorer/pkg/stellar"
+	"github.com/threefoldtech/tfexplorer/schema"
+)
+
+func TestCapacityReceiptSignature(t *testing.T) {
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+
+	receipt := CapacityReceipt{
+		ReservationID: 12,
+		CustomerTid:   3,
+		PoolID:        10,
+		FarmID:        1,
+		CUs:           100,
+		SUs:           200,
+		IPv4Us:        1,
+		Price:         CapacityPrice{CU: 10, SU: 8, IPv4U: 6},
+		Amount:        123456789,
+		Asset:         stellar.TFTMainnet,
+		Transactions:  []string{"abcdef"},
+		Issued:        schema.Date{Time: time.Now()},
+		Signer:        kp.Address(),
+	}
+
+	require.NoError(t, receipt.Sign(kp.Sign))
+	require.NoError(t, receipt.Verify(kp.Address()))
+
+	other, err := keypair.Random()
+	require.NoError(t, err)
+	require.Error(t, receipt.Verify(other.Address()))
+
+	tampered := receipt
+	tampered.Amount = 1
+	require.Error(t, tampered.Verify(kp.Address()))
+
+	// shifting a digit from a field to the next changes the challenge
+	shifted := receipt
+	shifted.CUs = 1002
+	shifted.SUs = 0
+	require.Error(t, shifted.Verify(kp.Address()))
+}
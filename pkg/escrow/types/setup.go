@@ -52,5 +52,52 @@ func Setup(ctx context.Context, db *mongo.Database) error {
 		log.Error().Err(err).Msg("failed to initialize failed payment index")
 	}
 
+	jobs := db.Collection(PayoutJobCollection)
+	_, err = jobs.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "state", Value: 1}, {Key: "lease_until.time", Value: 1}},
+		},
+		{
+			Keys: bson.M{"created.time": 1},
+		},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to initialize payout job index")
+	}
+
+	statements := db.Collection(FarmerPayoutStatementCollection)
+	_, err = statements.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "farm_id", Value: 1}, {Key: "month", Value: 1}},
+		},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to initialize payout statement index")
+	}
+
+	receipts := db.Collection(CapacityReceiptCollection)
+	_, err = receipts.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.M{"customer_tid": 1},
+		},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to initialize receipt index")
+	}
+
+	rules := db.Collection(PricingRuleCollection)
+	_, err = rules.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.M{"code": 1},
+			Options: options.Index().SetUnique(true).SetSparse(true),
+		},
+		{
+			Keys: bson.M{"farm_id": 1},
+		},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to initialize pricing rule index")
+	}
+
 	return err
 }
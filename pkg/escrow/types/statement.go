@@ -0,0 +1,241 @@
+package types
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stellar/go/xdr"
+	"github.com/threefoldtech/tfexplorer/pkg/stellar"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// FarmerPayoutStatementCollection db collection for the payout statements of farmers
+	FarmerPayoutStatementCollection = "farmer-payout-statements"
+
+	// StatementMonthFormat is the format of the month of a statement
+	StatementMonthFormat = "2006-01"
+)
+
+var (
+	// ErrStatementNotFound is returned if a payout statement is not found
+	ErrStatementNotFound = errors.New("payout statement not found")
+)
+
+type (
+	// FarmerPayoutStatement is the record of a capacity reservation paid out
+	// to a farmer
+	FarmerPayoutStatement struct {
+		ReservationID schema.ID     `bson:"_id" json:"reservation_id"`
+		FarmID        schema.ID     `bson:"farm_id" json:"farm_id"`
+		PoolID        schema.ID     `bson:"pool_id" json:"pool_id"`
+		CUs           uint64        `bson:"cus" json:"cus"`
+		SUs           uint64        `bson:"sus" json:"sus"`
+		IPv4Us        uint64        `bson:"ipv4us" json:"ipv4us"`
+		Asset         stellar.Asset `bson:"asset" json:"asset"`
+		// Gross is the amount paid by the customer for the reservation
+		Gross xdr.Int64 `bson:"gross" json:"gross"`
+		// FoundationCut is the part of the gross amount which went to the foundation
+		FoundationCut xdr.Int64 `bson:"foundation_cut" json:"foundation_cut"`
+		// Net is the part of the gross amount which went to the farmer
+		Net xdr.Int64 `bson:"net" json:"net"`
+		// Transaction is the hash of the stellar transaction of the payout,
+		// it is only set once the payout has been done
+		Transaction string `bson:"transaction" json:"transaction"`
+		Paid        bool   `bson:"paid" json:"paid"`
+		// Month the statement is booked on, in the StatementMonthFormat
+		Month   string      `bson:"month" json:"month"`
+		Created schema.Date `bson:"created" json:"created"`
+		PaidAt  schema.Date `bson:"paid_at" json:"paid_at"`
+	}
+
+	// FarmerPayoutTotal is the sum of all the payout statements of a farm
+	// in a single month
+	FarmerPayoutTotal struct {
+		Month         string        `bson:"_id" json:"month"`
+		Asset         stellar.Asset `bson:"asset" json:"asset"`
+		Reservations  int64         `bson:"reservations" json:"reservations"`
+		CUs           uint64        `bson:"cus" json:"cus"`
+		SUs           uint64        `bson:"sus" json:"sus"`
+		IPv4Us        uint64        `bson:"ipv4us" json:"ipv4us"`
+		Gross         xdr.Int64     `bson:"gross" json:"gross"`
+		FoundationCut xdr.Int64     `bson:"foundation_cut" json:"foundation_cut"`
+		Net           xdr.Int64     `bson:"net" json:"net"`
+	}
+)
+
+// StatementMonth returns the month a statement made at time t is booked on
+func StatementMonth(t time.Time) string {
+	return t.UTC().Format(StatementMonthFormat)
+}
+
+// FarmerPayoutStatementFilter type
+type FarmerPayoutStatementFilter bson.D
+
+// WithFarmID filter statements of a farm
+func (f FarmerPayoutStatementFilter) WithFarmID(id schema.ID) FarmerPayoutStatementFilter {
+	return append(f, bson.E{Key: "farm_id", Value: id})
+}
+
+// WithPaid filter statements which are paid out
+func (f FarmerPayoutStatementFilter) WithPaid(paid bool) FarmerPayoutStatementFilter {
+	return append(f, bson.E{Key: "paid", Value: paid})
+}
+
+// WithMonths filter statements booked between the from and to months
+// (both included). Empty values are ignored
+func (f FarmerPayoutStatementFilter) WithMonths(from, to string) FarmerPayoutStatementFilter {
+	months := bson.M{}
+	if len(from) != 0 {
+		months["$gte"] = from
+	}
+	if len(to) != 0 {
+		months["$lte"] = to
+	}
+	if len(months) == 0 {
+		return f
+	}
+
+	return append(f, bson.E{Key: "month", Value: months})
+}
+
+// Find run the filter and return a cursor result
+func (f FarmerPayoutStatementFilter) Find(ctx context.Context, db *mongo.Database, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	col := db.Collection(FarmerPayoutStatementCollection)
+	if f == nil {
+		f = FarmerPayoutStatementFilter{}
+	}
+
+	return col.Find(ctx, f, opts...)
+}
+
+// Count number of documents matching
+func (f FarmerPayoutStatementFilter) Count(ctx context.Context, db *mongo.Database) (int64, error) {
+	col := db.Collection(FarmerPayoutStatementCollection)
+	if f == nil {
+		f = FarmerPayoutStatementFilter{}
+	}
+
+	return col.CountDocuments(ctx, f)
+}
+
+// List the statements that matches the filter, sorted by reservation
+func (f FarmerPayoutStatementFilter) List(ctx context.Context, db *mongo.Database, opts ...*options.FindOptions) ([]FarmerPayoutStatement, error) {
+	opts = append(opts, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	cur, err := f.Find(ctx, db, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list payout statements")
+	}
+	defer cur.Close(ctx)
+
+	statements := []FarmerPayoutStatement{}
+	if err := cur.All(ctx, &statements); err != nil {
+		return nil, errors.Wrap(err, "failed to load payout statements")
+	}
+
+	return statements, nil
+}
+
+// MonthlyTotals sums the paid statements that matches the filter per month
+// and asset, sorted by month
+func (f FarmerPayoutStatementFilter) MonthlyTotals(ctx context.Context, db *mongo.Database) ([]FarmerPayoutTotal, error) {
+	if f == nil {
+		f = FarmerPayoutStatementFilter{}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: f}},
+		{{Key: "$group", Value: bson.M{
+			"_id":            bson.M{"month": "$month", "asset": "$asset"},
+			"reservations":   bson.M{"$sum": 1},
+			"cus":            bson.M{"$sum": "$cus"},
+			"sus":            bson.M{"$sum": "$sus"},
+			"ipv4us":         bson.M{"$sum": "$ipv4us"},
+			"gross":          bson.M{"$sum": "$gross"},
+			"foundation_cut": bson.M{"$sum": "$foundation_cut"},
+			"net":            bson.M{"$sum": "$net"},
+		}}},
+		{{Key: "$addFields", Value: bson.M{
+			"asset": "$_id.asset",
+			"_id":   "$_id.month",
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}, {Key: "asset", Value: 1}}}},
+	}
+
+	cur, err := db.Collection(FarmerPayoutStatementCollection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to compute monthly payout totals")
+	}
+	defer cur.Close(ctx)
+
+	totals := []FarmerPayoutTotal{}
+	if err := cur.All(ctx, &totals); err != nil {
+		return nil, errors.Wrap(err, "failed to load monthly payout totals")
+	}
+
+	return totals, nil
+}
+
+// FarmerPayoutStatementCreate saves the statement of a payout. If a statement
+// already exists for the reservation it is replaced, as long as it is not paid
+func FarmerPayoutStatementCreate(ctx context.Context, db *mongo.Database, statement FarmerPayoutStatement) error {
+	statement.Created = schema.Date{Time: time.Now()}
+	statement.Month = StatementMonth(statement.Created.Time)
+
+	col := db.Collection(FarmerPayoutStatementCollection)
+	_, err := col.ReplaceOne(
+		ctx,
+		bson.M{"_id": statement.ReservationID, "paid": false},
+		statement,
+		options.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		if merr, ok := err.(mongo.WriteException); ok && merr.WriteErrors[0].Code == 11000 {
+			// the statement is already paid
+			return nil
+		}
+		return errors.Wrapf(err, "failed to save payout statement for reservation %d", statement.ReservationID)
+	}
+
+	return nil
+}
+
+// FarmerPayoutStatementPaid marks the statement of a reservation as paid by the
+// given transaction. The statement is booked on the month of the payout
+func FarmerPayoutStatementPaid(ctx context.Context, db *mongo.Database, id schema.ID, transaction string) error {
+	now := time.Now()
+	col := db.Collection(FarmerPayoutStatementCollection)
+	res, err := col.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set": bson.M{
+			"paid":        true,
+			"transaction": transaction,
+			"paid_at":     schema.Date{Time: now},
+			"month":       StatementMonth(now),
+		},
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to mark payout statement for reservation %d as paid", id)
+	}
+	if res.MatchedCount == 0 {
+		return ErrStatementNotFound
+	}
+
+	return nil
+}
+
+// FormatAmount formats an amount of a statement, expressed in stroops, with
+// the precision of the stellar network
+func FormatAmount(amount xdr.Int64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	return fmt.Sprintf("%s%d.%07d", sign, amount/1e7, amount%1e7)
+}
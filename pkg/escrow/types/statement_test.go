@@ -0,0 +1,21 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatAmount(t *testing.T) {
+	assert.Equal(t, "0.0000000", FormatAmount(0))
+	assert.Equal(t, "1.0000000", FormatAmount(10000000))
+	assert.Equal(t, "12.3456789", FormatAmount(123456789))
+	assert.Equal(t, "-0.0000005", FormatAmount(-5))
+}
+
+func TestStatementMonth(t *testing.T) {
+	// the month is the one of the UTC time
+	at := time.Date(2020, 12, 1, 1, 0, 0, 0, time.FixedZone("east", 3*3600))
+	assert.Equal(t, "2020-11", StatementMonth(at))
+}
@@ -0,0 +1,230 @@
+// Package openapi generates the OpenAPI 3 document of the explorer API from
+// the routes registered on the router, so the document can't drift from the
+// handlers. Every route must be documented by the package registering it
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+)
+
+// Version of the OpenAPI specification of the generated documents
+const Version = "3.0.3"
+
+// ErrUndocumented is returned when the registered routes and the documented
+// operations don't match
+var ErrUndocumented = errors.New("routes and documented operations don't match")
+
+// Operation documents a route
+type Operation struct {
+	Summary string
+	// Request is a value of the type of the request body, nil if the
+	// route has no body
+	Request interface{}
+	// Response is a value of the type of the response body, nil if the
+	// route returns no data
+	Response interface{}
+	// Status is the status of a successful response, 200 if not set
+	Status int
+	// Query are the optional query parameters
+	Query []string
+	// Paginated is set for lists which can be paginated by page or by cursor
+	Paginated bool
+	// Headers are the headers of a successful response, by header name
+	// with their description
+	Headers map[string]string
+}
+
+// Operations documents the routes by route name
+type Operations map[string]Operation
+
+// pathParamRE matches the variables of a mux path template, with their
+// optional pattern
+var pathParamRE = regexp.MustCompile(`\{([^}:]+)(?::([^}]+))?\}`)
+
+// Generate returns the document of the routes of the router which path
+// starts with prefix. It fails if a route is not documented, or if an
+// operation documents a route which is not registered
+func Generate(router *mux.Router, info Info, prefix string, operations ...Operations) (*Document, error) {
+	ops := Operations{}
+	for _, o := range operations {
+		for name, op := range o {
+			if _, ok := ops[name]; ok {
+				return nil, fmt.Errorf("operation %s is documented twice", name)
+			}
+			ops[name] = op
+		}
+	}
+
+	gen := newGenerator()
+	doc := Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]PathItem{},
+	}
+
+	var missing []string
+	seen := map[string]bool{}
+	err := router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		if route.GetHandler() == nil {
+			return nil
+		}
+
+		tpl, err := route.GetPathTemplate()
+		if err != nil || !strings.HasPrefix(tpl, prefix+"/") {
+			return nil
+		}
+
+		name := route.GetName()
+		op, ok := ops[name]
+		if len(name) == 0 || !ok {
+			missing = append(missing, fmt.Sprintf("%s (%s)", tpl, name))
+			return nil
+		}
+		seen[name] = true
+
+		methods, err := route.GetMethods()
+		if err != nil {
+			return errors.Wrapf(err, "route %s has no methods", name)
+		}
+
+		queries, _ := route.GetQueriesTemplates()
+
+		path, params := pathParameters(tpl)
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = PathItem{}
+			doc.Paths[path] = item
+		}
+
+		for _, method := range methods {
+			operation := gen.operation(op, tag(strings.TrimPrefix(tpl, prefix)), params, queries)
+			operation.OperationID = name
+			if len(methods) > 1 {
+				operation.OperationID = fmt.Sprintf("%s-%s", name, strings.ToLower(method))
+			}
+			item[strings.ToLower(method)] = operation
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var stale []string
+	for name := range ops {
+		if !seen[name] {
+			stale = append(stale, name)
+		}
+	}
+
+	if len(missing) > 0 || len(stale) > 0 {
+		sort.Strings(missing)
+		sort.Strings(stale)
+		return nil, errors.Wrapf(ErrUndocumented, "routes missing from the spec: [%s], documented routes which are not registered: [%s]",
+			strings.Join(missing, ", "), strings.Join(stale, ", "))
+	}
+
+	doc.Components.Schemas = gen.components
+	return &doc, nil
+}
+
+// pathParameters converts a mux path template to an OpenAPI path, and
+// returns its parameters
+func pathParameters(tpl string) (string, []Parameter) {
+	var params []Parameter
+	for _, match := range pathParamRE.FindAllStringSubmatch(tpl, -1) {
+		schema := &Schema{Type: "string"}
+		if len(match[2]) != 0 {
+			schema.Pattern = fmt.Sprintf("^%s$", match[2])
+		}
+		params = append(params, Parameter{Name: match[1], In: "path", Required: true, Schema: schema})
+	}
+
+	return pathParamRE.ReplaceAllString(tpl, "{$1}"), params
+}
+
+// tag returns the first segment of the path, used to group the operations
+func tag(path string) string {
+	return strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)[0]
+}
+
+func (g *generator) operation(op Operation, tag string, params []Parameter, queries []string) *OperationObject {
+	operation := OperationObject{
+		Summary:    op.Summary,
+		Tags:       []string{tag},
+		Parameters: append([]Parameter{}, params...),
+		Responses:  map[string]Response{},
+	}
+
+	// queries required by the route, in the format name={name:pattern}
+	for _, query := range queries {
+		kv := strings.SplitN(query, "=", 2)
+		_, qp := pathParameters(kv[len(kv)-1])
+		schema := &Schema{Type: "string"}
+		if len(qp) > 0 {
+			schema = qp[0].Schema
+		}
+		operation.Parameters = append(operation.Parameters, Parameter{Name: kv[0], In: "query", Required: true, Schema: schema})
+	}
+
+	for _, query := range op.Query {
+		operation.Parameters = append(operation.Parameters, Parameter{Name: query, In: "query", Schema: &Schema{Type: "string"}})
+	}
+
+	if op.Request != nil {
+		operation.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{"application/json": {Schema: g.schemaOf(op.Request)}},
+		}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	response := Response{Description: http.StatusText(status)}
+	if op.Response != nil {
+		response.Content = map[string]MediaType{"application/json": {Schema: g.schemaOf(op.Response)}}
+	}
+
+	if op.Paginated {
+		operation.Parameters = append(operation.Parameters,
+			Parameter{Name: "page", In: "query", Description: "page number, starting at 1", Schema: &Schema{Type: "integer"}},
+			Parameter{Name: "size", In: "query", Description: "number of items per page", Schema: &Schema{Type: "integer"}},
+			Parameter{Name: "cursor", In: "query", Description: "list the items after the cursor instead of by page number, empty to start at the first item", Schema: &Schema{Type: "string"}},
+		)
+		response.Headers = map[string]Header{
+			"Pages":       {Description: "number of pages, only set for requests paginated by page number", Schema: &Schema{Type: "integer"}},
+			"Next-Cursor": {Description: "cursor of the next page, not set on the last page", Schema: &Schema{Type: "string"}},
+		}
+	}
+
+	for name, description := range op.Headers {
+		if response.Headers == nil {
+			response.Headers = map[string]Header{}
+		}
+		response.Headers[name] = Header{Description: description, Schema: &Schema{Type: "string"}}
+	}
+
+	operation.Responses[fmt.Sprint(status)] = response
+	operation.Responses["default"] = Response{
+		Description: "error",
+		Content:     map[string]MediaType{"application/json": {Schema: g.schemaOf(errorResponse{})}},
+	}
+
+	return &operation
+}
+
+// errorResponse is the body of all error responses
+type errorResponse struct {
+	Error string `json:"error"`
+}
@@ -0,0 +1,129 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	generated "github.com/threefoldtech/tfexplorer/models/generated/directory"
+	"github.com/threefoldtech/tfexplorer/pkg/directory"
+	"github.com/threefoldtech/tfexplorer/pkg/openapi"
+	"github.com/threefoldtech/tfexplorer/pkg/phonebook"
+	"github.com/threefoldtech/tfexplorer/pkg/workloads"
+)
+
+func explorerRouter() *mux.Router {
+	router := mux.NewRouter()
+	directory.Routes(router, nil, generated.NodeCloudUnitPrice{})
+	phonebook.Routes(router, nil, "")
+	workloads.Routes(router, nil, "", nil, nil)
+	return router
+}
+
+var info = openapi.Info{Title: "TF Explorer", Version: "test"}
+
+// TestAllRoutesDocumented fails when a route is added without being
+// documented in the Operations of its package
+func TestAllRoutesDocumented(t *testing.T) {
+	doc, err := openapi.Generate(explorerRouter(), info, "/api/v1", directory.Operations, phonebook.Operations, workloads.Operations)
+	require.NoError(t, err)
+
+	assert.Equal(t, openapi.Version, doc.OpenAPI)
+
+	nodes, ok := doc.Paths["/api/v1/nodes/{node_id}"]
+	require.True(t, ok)
+	assert.Contains(t, nodes, "get")
+
+	poll, ok := doc.Paths["/api/v1/reservations/nodes/{node_id}/workloads"]
+	require.True(t, ok)
+	require.Contains(t, poll, "get")
+	var from *openapi.Parameter
+	for i, p := range poll["get"].Parameters {
+		if p.Name == "from" {
+			from = &poll["get"].Parameters[i]
+		}
+	}
+	require.NotNil(t, from)
+	assert.Equal(t, "query", from.In)
+	assert.True(t, from.Required)
+
+	users, ok := doc.Paths["/api/v1/users"]
+	require.True(t, ok)
+	require.Contains(t, users, "get")
+	assert.Contains(t, users["get"].Responses["200"].Headers, "Next-Cursor")
+
+	_, err = json.Marshal(doc)
+	assert.NoError(t, err)
+}
+
+func TestUndocumentedRoute(t *testing.T) {
+	router := explorerRouter()
+	router.HandleFunc("/api/v1/undocumented", func(http.ResponseWriter, *http.Request) {}).Methods(http.MethodGet).Name("undocumented")
+
+	_, err := openapi.Generate(router, info, "/api/v1", directory.Operations, phonebook.Operations, workloads.Operations)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, openapi.ErrUndocumented))
+	assert.Contains(t, err.Error(), "/api/v1/undocumented")
+}
+
+func TestStaleOperation(t *testing.T) {
+	_, err := openapi.Generate(explorerRouter(), info, "/api/v1", directory.Operations, phonebook.Operations, workloads.Operations, openapi.Operations{
+		"removed": {Summary: "a route which is not registered anymore"},
+	})
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, openapi.ErrUndocumented))
+	assert.Contains(t, err.Error(), "removed")
+}
+
+func TestSchemas(t *testing.T) {
+	type inner struct {
+		Value string `json:"value"`
+	}
+	type embedded struct {
+		Embedded int64 `json:"embedded"`
+	}
+	type body struct {
+		embedded
+		Name     string            `json:"name"`
+		Tags     []string          `json:"tags"`
+		Labels   map[string]string `json:"labels"`
+		Inner    inner             `json:"inner"`
+		Ignored  string            `json:"-"`
+		internal string
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/test", func(http.ResponseWriter, *http.Request) {}).Methods(http.MethodPost).Name("test")
+
+	doc, err := openapi.Generate(router, info, "/api/v1", openapi.Operations{
+		"test": {Summary: "test", Request: body{}, Status: http.StatusCreated},
+	})
+	require.NoError(t, err)
+
+	op := doc.Paths["/api/v1/test"]["post"]
+	require.NotNil(t, op)
+	require.Contains(t, op.Responses, "201")
+
+	ref := op.RequestBody.Content["application/json"].Schema.Ref
+	require.NotEmpty(t, ref)
+
+	var schema *openapi.Schema
+	for name, s := range doc.Components.Schemas {
+		if "#/components/schemas/"+name == ref {
+			schema = s
+		}
+	}
+	require.NotNil(t, schema)
+	assert.Equal(t, "object", schema.Type)
+	assert.Contains(t, schema.Properties, "embedded")
+	assert.Contains(t, schema.Properties, "name")
+	assert.Equal(t, "array", schema.Properties["tags"].Type)
+	assert.Equal(t, "object", schema.Properties["labels"].Type)
+	assert.NotContains(t, schema.Properties, "Ignored")
+	assert.NotContains(t, schema.Properties, "internal")
+	assert.Len(t, schema.Properties, 5)
+}
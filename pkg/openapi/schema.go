@@ -0,0 +1,154 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/threefoldtech/tfexplorer/schema"
+)
+
+const modulePath = "github.com/threefoldtech/tfexplorer/"
+
+var (
+	marshalerType     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+	definedM sync.RWMutex
+	defined  = map[reflect.Type]Schema{
+		reflect.TypeOf(schema.Date{}): {Type: "integer", Format: "int64", Description: "unix timestamp, 0 if not set"},
+		reflect.TypeOf(time.Time{}):   {Type: "string", Format: "date-time"},
+	}
+)
+
+// Define sets the schema of the type of v. It is needed for the types which
+// have a custom json encoding which isn't a string
+func Define(v interface{}, s Schema) {
+	definedM.Lock()
+	defer definedM.Unlock()
+
+	defined[reflect.TypeOf(v)] = s
+}
+
+// generator builds the schemas of go types, named structs are added to the
+// components of the document and referenced
+type generator struct {
+	components map[string]*Schema
+}
+
+func newGenerator() *generator {
+	return &generator{components: map[string]*Schema{}}
+}
+
+// componentName is the name of a type in the components, the package path is
+// used since several packages have types with the same name
+func componentName(t reflect.Type) string {
+	path := strings.TrimPrefix(t.PkgPath(), modulePath)
+	path = strings.TrimPrefix(path, "github.com/")
+	return strings.ReplaceAll(path, "/", ".") + "." + t.Name()
+}
+
+func (g *generator) schemaOf(v interface{}) *Schema {
+	return g.schema(reflect.TypeOf(v))
+}
+
+func (g *generator) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	definedM.RLock()
+	s, ok := defined[t]
+	definedM.RUnlock()
+	if ok {
+		return &s
+	}
+
+	if t.Implements(marshalerType) || t.Implements(textMarshalerType) ||
+		reflect.PtrTo(t).Implements(marshalerType) || reflect.PtrTo(t).Implements(textMarshalerType) {
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if len(t.Name()) == 0 {
+			return g.object(t)
+		}
+
+		name := componentName(t)
+		if _, ok := g.components[name]; !ok {
+			// registered before the properties are generated, for the
+			// recursive types
+			g.components[name] = &Schema{}
+			*g.components[name] = *g.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+
+	// interfaces can hold any value
+	return &Schema{}
+}
+
+// object returns the schema of the json encoding of a struct
+func (g *generator) object(t reflect.Type) *Schema {
+	s := Schema{Type: "object", Properties: map[string]*Schema{}}
+	g.properties(t, s.Properties)
+	return &s
+}
+
+func (g *generator) properties(t reflect.Type, properties map[string]*Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name := strings.Split(tag, ",")[0]
+		ft := field.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		// embedded structs without a json name are flattened like
+		// encoding/json does
+		if field.Anonymous && len(name) == 0 {
+			if ft.Kind() == reflect.Struct {
+				g.properties(ft, properties)
+			}
+			continue
+		}
+
+		if len(field.PkgPath) != 0 {
+			// unexported
+			continue
+		}
+
+		if len(name) == 0 {
+			name = field.Name
+		}
+		properties[name] = g.schema(field.Type)
+	}
+}
@@ -0,0 +1,51 @@
+package phonebook
+
+import (
+	"net/http"
+
+	"github.com/threefoldtech/tfexplorer/pkg/openapi"
+	"github.com/threefoldtech/tfexplorer/pkg/phonebook/types"
+	"github.com/threefoldtech/tfexplorer/schema"
+)
+
+// Operations documents the versioned phonebook routes
+var Operations = openapi.Operations{
+	"user-create-v1": {Summary: "Register a user", Request: types.User{}, Response: types.User{}, Status: http.StatusCreated},
+	"user-list-v1": {
+		Summary:   "List users",
+		Response:  []types.User{},
+		Query:     []string{"name", "email"},
+		Paginated: true,
+	},
+	"user-register-v1": {
+		Summary: "Update a user, signed by the user",
+		Request: struct {
+			types.User
+			Signature string `json:"sender_signature_hex"`
+		}{},
+	},
+	"user-get-v1": {Summary: "Get a user", Response: types.User{}},
+	"user-validate-v1": {
+		Summary: "Validate a signature of a user",
+		Request: struct {
+			Payload   string `json:"payload"`
+			Signature string `json:"signature"`
+			At        int64  `json:"at,omitempty"`
+		}{},
+		Response: struct {
+			IsValid bool `json:"is_valid"`
+		}{},
+	},
+	"user-rotate-v1": {Summary: "Rotate the key of a user, signed by the user or its guardians", Request: types.KeyRotation{}},
+	"user-keys-v1":   {Summary: "List the current and previous keys of a user", Response: []types.UserKey{}},
+	"user-guardians-v1": {
+		Summary: "Set the guardians of a user",
+		Request: struct {
+			Guardians []schema.ID `json:"guardians"`
+			Threshold int64       `json:"threshold"`
+		}{},
+	},
+	"user-tokens-create-v1": {Summary: "Register an API token signed by the user", Request: types.Token{}, Response: types.Token{}, Status: http.StatusCreated},
+	"user-tokens-list-v1":   {Summary: "List the API tokens of a user", Response: []types.Token{}},
+	"user-tokens-revoke-v1": {Summary: "Revoke an API token of a user", Status: http.StatusNoContent},
+}
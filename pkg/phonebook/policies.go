@@ -0,0 +1,33 @@
+package phonebook
+
+import "github.com/threefoldtech/tfexplorer/mw"
+
+var (
+	anonymous = mw.Policy{Identity: mw.Anonymous}
+	// the keys and tokens of a user can only be managed with the user key
+	userKey = mw.Policy{Identity: mw.User, KeyOnly: true}
+)
+
+// Policies declares who can call the phonebook routes. The user updates
+// and key rotations are not signed with http signatures but carry the
+// signature in their payload
+var Policies = mw.Policies{
+	"user-create-v1":        anonymous,
+	"user-list-v1":          anonymous,
+	"user-register-v1":      anonymous,
+	"user-get-v1":           anonymous,
+	"user-validate-v1":      anonymous,
+	"user-rotate-v1":        anonymous,
+	"user-keys-v1":          anonymous,
+	"user-guardians-v1":     userKey,
+	"user-tokens-create-v1": userKey,
+	"user-tokens-list-v1":   userKey,
+	"user-tokens-revoke-v1": userKey,
+
+	// legacy endpoints
+	"user-create":   anonymous,
+	"user-list":     anonymous,
+	"user-register": anonymous,
+	"user-get":      anonymous,
+	"user-validate": anonymous,
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/threefoldtech/tfexplorer/config"
 	"github.com/threefoldtech/tfexplorer/mw"
 	phonebook "github.com/threefoldtech/tfexplorer/pkg/phonebook/types"
 	"github.com/zaibon/httpsig"
@@ -17,6 +18,12 @@ func Setup(parent *mux.Router, db *mongo.Database, threebotConnectURL string) er
 		return err
 	}
 
+	Routes(parent, db, threebotConnectURL)
+	return nil
+}
+
+// Routes registers the phonebook routes on the parent router
+func Routes(parent *mux.Router, db *mongo.Database, threebotConnectURL string) {
 	userVerifier := httpsig.NewVerifier(mw.NewUserKeyGetter(db))
 
 	var userAPI = UserAPI{
@@ -24,8 +31,13 @@ func Setup(parent *mux.Router, db *mongo.Database, threebotConnectURL string) er
 		threebotConnectAPIURL: threebotConnectURL,
 	}
 
+	limiter := mw.NewRateLimiter(config.RateLimitPhonebook, db)
+	authorizer := mw.NewAuthorizer(db, Policies, nil)
+
 	// versionned endpoints
 	api := parent.PathPrefix("/api/v1").Subrouter()
+	api.Use(limiter.Middleware)
+	api.Use(authorizer.Middleware)
 	users := api.PathPrefix("/users").Subrouter()
 
 	users.HandleFunc("", mw.AsHandlerFunc(userAPI.create)).Methods(http.MethodPost).Name("user-create-v1")
@@ -33,15 +45,21 @@ func Setup(parent *mux.Router, db *mongo.Database, threebotConnectURL string) er
 	users.HandleFunc("/{user_id}", mw.AsHandlerFunc(userAPI.register)).Methods(http.MethodPut).Name("user-register-v1")
 	users.HandleFunc("/{user_id}", mw.AsHandlerFunc(userAPI.get)).Methods(http.MethodGet).Name("user-get-v1")
 	users.HandleFunc("/{user_id}/validate", mw.AsHandlerFunc(userAPI.validate)).Methods(http.MethodPost).Name("user-validate-v1")
+	users.HandleFunc("/{user_id}/rotate", mw.AsHandlerFunc(userAPI.rotate)).Methods(http.MethodPost).Name("user-rotate-v1")
+	users.HandleFunc("/{user_id}/keys", mw.AsHandlerFunc(userAPI.keys)).Methods(http.MethodGet).Name("user-keys-v1")
+	users.HandleFunc("/{user_id}/guardians", mw.AsHandlerFunc(userAPI.setGuardians)).Methods(http.MethodPut).Name("user-guardians-v1")
+	users.HandleFunc("/{user_id}/tokens", mw.AsHandlerFunc(userAPI.createToken)).Methods(http.MethodPost).Name("user-tokens-create-v1")
+	users.HandleFunc("/{user_id}/tokens", mw.AsHandlerFunc(userAPI.listTokens)).Methods(http.MethodGet).Name("user-tokens-list-v1")
+	users.HandleFunc("/{user_id}/tokens/{token_id}", mw.AsHandlerFunc(userAPI.revokeToken)).Methods(http.MethodDelete).Name("user-tokens-revoke-v1")
 
 	// legacy endpoints
 	legacyUsers := parent.PathPrefix("/explorer/users").Subrouter()
+	legacyUsers.Use(limiter.Middleware)
+	legacyUsers.Use(authorizer.Middleware)
 
 	legacyUsers.HandleFunc("", mw.AsHandlerFunc(userAPI.create)).Methods(http.MethodPost).Name("user-create")
 	legacyUsers.HandleFunc("", mw.AsHandlerFunc(userAPI.list)).Methods(http.MethodGet).Name(("user-list"))
 	legacyUsers.HandleFunc("/{user_id}", mw.AsHandlerFunc(userAPI.register)).Methods(http.MethodPut).Name("user-register")
 	legacyUsers.HandleFunc("/{user_id}", mw.AsHandlerFunc(userAPI.get)).Methods(http.MethodGet).Name("user-get")
 	legacyUsers.HandleFunc("/{user_id}/validate", mw.AsHandlerFunc(userAPI.validate)).Methods(http.MethodPost).Name("user-validate")
-
-	return nil
 }
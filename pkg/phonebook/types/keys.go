@@ -0,0 +1,432 @@
+package types
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/tfexplorer/config"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"github.com/threefoldtech/zos/pkg/crypto"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// UserKeyCollection db collection name of the keys replaced by a rotation
+	UserKeyCollection = "user-keys"
+
+	// KeyRotationMaxAge is how long a signed key rotation request can be used.
+	// It leaves guardians time to collect their signatures
+	KeyRotationMaxAge = 24 * time.Hour
+	// keyRotationMaxSkew is how far in the future a rotation timestamp can be
+	keyRotationMaxSkew = 5 * time.Minute
+)
+
+var (
+	// ErrBadKeyRotation is returned when a key rotation request is invalid
+	// or not signed correctly
+	ErrBadKeyRotation = errors.New("bad key rotation")
+	// ErrBadGuardians is returned when the guardians of a user are invalid
+	ErrBadGuardians = errors.New("bad guardians")
+)
+
+// KeyRotationMethod is how a key rotation was authorized
+type KeyRotationMethod string
+
+const (
+	// KeyRotationSelf the rotation was signed by the replaced key
+	KeyRotationSelf KeyRotationMethod = "key"
+	// KeyRotationGuardians the rotation was signed by a quorum of the guardians
+	// of the user
+	KeyRotationGuardians KeyRotationMethod = "guardians"
+)
+
+type (
+	// UserKey is a public key of a user and the period it was valid. Keys
+	// replaced by a rotation are kept so signatures made with them can still
+	// be verified
+	UserKey struct {
+		UserID schema.ID `bson:"user_id" json:"user_id"`
+		Pubkey string    `bson:"pubkey" json:"pubkey"`
+		// ValidFrom is zero for the key the user registered with
+		ValidFrom schema.Date `bson:"valid_from" json:"valid_from"`
+		// ValidUntil is zero for the current key of the user
+		ValidUntil schema.Date       `bson:"valid_until" json:"valid_until"`
+		RotatedBy  KeyRotationMethod `bson:"rotated_by" json:"rotated_by,omitempty"`
+	}
+
+	// KeyRotation is a request to replace the public key of a user. It must be
+	// signed either by the current key of the user, or by enough guardians of
+	// the user to reach their threshold
+	KeyRotation struct {
+		// Pubkey is the new public key, hex encoded
+		Pubkey string `json:"pubkey"`
+		// Timestamp is the unix time of the request
+		Timestamp int64 `json:"timestamp"`
+		// Signature of the challenge with the current key, hex encoded
+		Signature string `json:"signature,omitempty"`
+		// Guardians signatures of the challenge
+		Guardians []GuardianSignature `json:"guardians,omitempty"`
+	}
+
+	// UserGuardians are the users which can rotate the public key of a user
+	// if the private key is lost. At least Threshold of them need to sign the
+	// rotation. They are stored in the document of the user
+	UserGuardians struct {
+		Guardians []schema.ID `bson:"guardians" json:"guardians"`
+		Threshold int64       `bson:"guardians_threshold" json:"threshold"`
+	}
+
+	// GuardianSignature is the signature of a key rotation challenge by a guardian
+	GuardianSignature struct {
+		ID schema.ID `json:"id"`
+		// Signature of the challenge with the guardian key, hex encoded
+		Signature string `json:"signature"`
+	}
+)
+
+// Challenge is the message which must be signed to rotate the key of the user.
+// Since it contains the current key, a signed rotation can't be used anymore
+// once the key changed
+func (k *KeyRotation) Challenge(userID schema.ID, current string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprint(int64(userID)))
+	buf.WriteString(current)
+	buf.WriteString(k.Pubkey)
+	buf.WriteString(fmt.Sprint(k.Timestamp))
+
+	return buf.Bytes()
+}
+
+// ValidateGuardians checks the guardians a user wants to set
+func (u *User) ValidateGuardians(guardians []schema.ID, threshold int64) error {
+	if len(guardians) == 0 {
+		if threshold != 0 {
+			return errors.Wrap(ErrBadGuardians, "threshold must be 0 without guardians")
+		}
+		return nil
+	}
+
+	seen := make(map[schema.ID]struct{})
+	for _, id := range guardians {
+		if id == u.ID {
+			return errors.Wrap(ErrBadGuardians, "a user can't be its own guardian")
+		}
+		if _, ok := seen[id]; ok {
+			return errors.Wrapf(ErrBadGuardians, "guardian '%d' is listed twice", id)
+		}
+		seen[id] = struct{}{}
+	}
+
+	if threshold < 1 || threshold > int64(len(guardians)) {
+		return errors.Wrapf(ErrBadGuardians, "threshold must be between 1 and %d", len(guardians))
+	}
+
+	return nil
+}
+
+// IsGuardian checks if user id is one of the guardians
+func (g *UserGuardians) IsGuardian(id schema.ID) bool {
+	for _, guardian := range g.Guardians {
+		if guardian == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// UserGuardiansGet loads the guardians of the user
+func UserGuardiansGet(ctx context.Context, db *mongo.Database, id schema.ID) (UserGuardians, error) {
+	var guardians UserGuardians
+	projection := bson.M{"guardians": 1, "guardians_threshold": 1}
+	result := db.Collection(UserCollection).FindOne(ctx, bson.M{"_id": id}, options.FindOne().SetProjection(projection))
+	if err := result.Err(); errors.Is(err, mongo.ErrNoDocuments) {
+		return guardians, ErrUserNotFound
+	} else if err != nil {
+		return guardians, err
+	}
+
+	err := result.Decode(&guardians)
+	return guardians, err
+}
+
+// UserSetGuardians sets the users which can recover the user account
+func UserSetGuardians(ctx context.Context, db *mongo.Database, id schema.ID, guardians []schema.ID, threshold int64) error {
+	var filter UserFilter
+	user, err := filter.WithID(id).Get(ctx, db)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrUserNotFound
+	} else if err != nil {
+		return err
+	}
+
+	if err := user.ValidateGuardians(guardians, threshold); err != nil {
+		return err
+	}
+
+	for _, g := range guardians {
+		var filter UserFilter
+		if _, err := filter.WithID(g).Get(ctx, db); errors.Is(err, mongo.ErrNoDocuments) {
+			return errors.Wrapf(ErrBadGuardians, "guardian '%d' not found", g)
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if guardians == nil {
+		guardians = []schema.ID{}
+	}
+
+	_, err = db.Collection(UserCollection).UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set": bson.M{
+			"guardians":           guardians,
+			"guardians_threshold": threshold,
+		},
+	})
+
+	return err
+}
+
+// verifyHex verifies the hex encoded signature of the message with the hex
+// encoded public key
+func verifyHex(pubkey string, message []byte, signature string) error {
+	key, err := crypto.KeyFromHex(pubkey)
+	if err != nil {
+		return err
+	}
+
+	sig, err := hex.DecodeString(signature)
+	if err != nil {
+		return errors.Wrap(err, "invalid signature hex")
+	}
+
+	return crypto.Verify(key, message, sig)
+}
+
+// authorizeKeyRotation checks the signatures of the rotation, and returns how
+// it is authorized
+func authorizeKeyRotation(ctx context.Context, db *mongo.Database, user User, rotation KeyRotation) (KeyRotationMethod, error) {
+	challenge := rotation.Challenge(user.ID, user.Pubkey)
+
+	if len(rotation.Signature) != 0 {
+		if err := verifyHex(user.Pubkey, challenge, rotation.Signature); err != nil {
+			return "", errors.Wrap(ErrBadKeyRotation, "signature verification failed")
+		}
+		return KeyRotationSelf, nil
+	}
+
+	guardians, err := UserGuardiansGet(ctx, db, user.ID)
+	if err != nil {
+		return "", err
+	}
+
+	if len(guardians.Guardians) == 0 {
+		return "", errors.Wrap(ErrBadKeyRotation, "signature is required, the user has no guardians")
+	}
+
+	signed := make(map[schema.ID]struct{})
+	for _, s := range rotation.Guardians {
+		if !guardians.IsGuardian(s.ID) {
+			return "", errors.Wrapf(ErrBadKeyRotation, "user '%d' is not a guardian", s.ID)
+		}
+
+		var filter UserFilter
+		guardian, err := filter.WithID(s.ID).Get(ctx, db)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return "", errors.Wrapf(ErrBadKeyRotation, "guardian '%d' not found", s.ID)
+		} else if err != nil {
+			return "", err
+		}
+
+		if err := verifyHex(guardian.Pubkey, challenge, s.Signature); err != nil {
+			return "", errors.Wrapf(ErrBadKeyRotation, "signature verification of guardian '%d' failed", s.ID)
+		}
+
+		signed[s.ID] = struct{}{}
+	}
+
+	if int64(len(signed)) < guardians.Threshold {
+		return "", errors.Wrapf(ErrBadKeyRotation, "%d guardians signed, %d are required", len(signed), guardians.Threshold)
+	}
+
+	return KeyRotationGuardians, nil
+}
+
+// UserKeyRotate replaces the public key of the user. The replaced key is kept
+// in the key history of the user and the tokens of the user are revoked
+func UserKeyRotate(ctx context.Context, db *mongo.Database, id schema.ID, rotation KeyRotation) error {
+	var filter UserFilter
+	user, err := filter.WithID(id).Get(ctx, db)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrUserNotFound
+	} else if err != nil {
+		return err
+	}
+
+	if _, err := crypto.KeyFromHex(rotation.Pubkey); err != nil {
+		return errors.Wrapf(ErrBadKeyRotation, "invalid public key %s", rotation.Pubkey)
+	}
+
+	if rotation.Pubkey == user.Pubkey {
+		return errors.Wrap(ErrBadKeyRotation, "new public key is the current one")
+	}
+
+	now := time.Now()
+	at := time.Unix(rotation.Timestamp, 0)
+	if at.Before(now.Add(-KeyRotationMaxAge)) || at.After(now.Add(keyRotationMaxSkew)) {
+		return errors.Wrap(ErrBadKeyRotation, "timestamp is expired or in the future")
+	}
+
+	method, err := authorizeKeyRotation(ctx, db, user, rotation)
+	if err != nil {
+		return err
+	}
+
+	keys, err := UserKeyFilter{}.WithUserID(id).List(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	var validFrom schema.Date
+	if len(keys) != 0 {
+		validFrom = keys[len(keys)-1].ValidUntil
+	}
+
+	// only replace the key if nobody else rotated it in the meantime
+	result, err := db.Collection(UserCollection).UpdateOne(ctx,
+		bson.M{"_id": id, "pubkey": user.Pubkey},
+		bson.M{"$set": bson.M{"pubkey": rotation.Pubkey}},
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return errors.Wrap(ErrBadKeyRotation, "public key was rotated concurrently")
+	}
+
+	_, err = db.Collection(UserKeyCollection).InsertOne(ctx, UserKey{
+		UserID:     id,
+		Pubkey:     user.Pubkey,
+		ValidFrom:  validFrom,
+		ValidUntil: schema.Date{Time: now},
+		RotatedBy:  method,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to save key history")
+	}
+
+	// tokens were minted with the replaced key, which might be compromised
+	_, err = db.Collection(TokenCollection).UpdateMany(ctx,
+		bson.M{"user_id": id, "revoked": schema.Date{}},
+		bson.M{"$set": bson.M{"revoked": schema.Date{Time: now}}},
+	)
+
+	return errors.Wrap(err, "failed to revoke tokens")
+}
+
+// UserKeys returns all the keys of the user, oldest first. The last key is the
+// current key of the user
+func UserKeys(ctx context.Context, db *mongo.Database, user User) ([]UserKey, error) {
+	keys, err := UserKeyFilter{}.WithUserID(user.ID).List(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	current := UserKey{
+		UserID: user.ID,
+		Pubkey: user.Pubkey,
+	}
+	if len(keys) != 0 {
+		current.ValidFrom = keys[len(keys)-1].ValidUntil
+	}
+
+	return append(keys, current), nil
+}
+
+// UserKeyAt returns the public key the user had at time t
+func UserKeyAt(ctx context.Context, db *mongo.Database, user User, t time.Time) (string, error) {
+	filter := UserKeyFilter{}.WithUserID(user.ID).WithValidAt(t)
+
+	var key UserKey
+	result := db.Collection(UserKeyCollection).FindOne(ctx, filter, options.FindOne().SetSort(bson.M{"valid_until.time": 1}))
+	if err := result.Err(); errors.Is(err, mongo.ErrNoDocuments) {
+		// no key was replaced since t
+		return user.Pubkey, nil
+	} else if err != nil {
+		return "", err
+	}
+
+	if err := result.Decode(&key); err != nil {
+		return "", err
+	}
+
+	return key.Pubkey, nil
+}
+
+// UserKeySignedAt returns the public key to verify a signature the user made
+// at time t. A replaced key is only used for signatures made within the
+// maximum clock skew of the server, so it can't be used to backdate new
+// signatures once it was replaced
+func UserKeySignedAt(ctx context.Context, db *mongo.Database, user User, t time.Time) (string, error) {
+	if SignedAt(t) != t {
+		return user.Pubkey, nil
+	}
+
+	return UserKeyAt(ctx, db, user, t)
+}
+
+// SignedAt returns the time at which the keys of a signature claimed to be
+// made at time t are checked. A time which is not within the maximum clock
+// skew of the server is not trusted, and the signature is checked now
+func SignedAt(t time.Time) time.Time {
+	skew := config.Config.AuthMaxSkew
+	if skew <= 0 || time.Since(t) > skew || time.Until(t) > skew {
+		return time.Now()
+	}
+
+	return t
+}
+
+// UserKeyFilter type
+type UserKeyFilter bson.D
+
+// WithUserID filters keys of a user
+func (f UserKeyFilter) WithUserID(id schema.ID) UserKeyFilter {
+	return append(f, bson.E{Key: "user_id", Value: id})
+}
+
+// WithValidAt filters keys that were still valid at time t
+func (f UserKeyFilter) WithValidAt(t time.Time) UserKeyFilter {
+	return append(f, bson.E{Key: "valid_until.time", Value: bson.M{"$gt": t}})
+}
+
+// Find all keys that matches filter
+func (f UserKeyFilter) Find(ctx context.Context, db *mongo.Database, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	if f == nil {
+		f = UserKeyFilter{}
+	}
+	return db.Collection(UserKeyCollection).Find(ctx, f, opts...)
+}
+
+// List all keys that matches filter, oldest first
+func (f UserKeyFilter) List(ctx context.Context, db *mongo.Database) ([]UserKey, error) {
+	cur, err := f.Find(ctx, db, options.Find().SetSort(bson.M{"valid_until.time": 1}))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list user keys")
+	}
+	defer cur.Close(ctx)
+
+	keys := []UserKey{}
+	if err := cur.All(ctx, &keys); err != nil {
+		return nil, errors.Wrap(err, "failed to decode user keys")
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,77 @@
+package types
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/threefoldtech/tfexplorer/config"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"gotest.tools/assert"
+)
+
+func TestUser_ValidateGuardians(t *testing.T) {
+	u := User{ID: 1}
+
+	tests := []struct {
+		name      string
+		guardians []schema.ID
+		threshold int64
+		err       string
+	}{
+		{name: "none", guardians: nil, threshold: 0},
+		{name: "quorum", guardians: []schema.ID{2, 3, 4}, threshold: 2},
+		{name: "none with threshold", guardians: nil, threshold: 1, err: "threshold must be 0 without guardians: bad guardians"},
+		{name: "self", guardians: []schema.ID{1, 2}, threshold: 1, err: "a user can't be its own guardian: bad guardians"},
+		{name: "twice", guardians: []schema.ID{2, 2}, threshold: 1, err: "guardian '2' is listed twice: bad guardians"},
+		{name: "threshold too high", guardians: []schema.ID{2, 3}, threshold: 3, err: "threshold must be between 1 and 2: bad guardians"},
+		{name: "threshold zero", guardians: []schema.ID{2, 3}, threshold: 0, err: "threshold must be between 1 and 2: bad guardians"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := u.ValidateGuardians(tt.guardians, tt.threshold)
+			if tt.err != "" {
+				assert.Error(t, err, tt.err)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
+
+func TestKeyRotation_Challenge(t *testing.T) {
+	rotation := KeyRotation{Pubkey: "new", Timestamp: 1600000000}
+
+	assert.Equal(t, string(rotation.Challenge(12, "old")), "12oldnew1600000000")
+	// a signed rotation is bound to the key it replaces
+	assert.Assert(t, string(rotation.Challenge(12, "new")) != string(rotation.Challenge(12, "old")))
+}
+
+func TestUserKeySignedAt(t *testing.T) {
+	user := User{ID: 1, Pubkey: "current"}
+
+	// the key history is never used to verify old signatures, so the db is
+	// not needed
+	for _, at := range []time.Time{time.Now().Add(-time.Hour), time.Now().Add(time.Hour)} {
+		key, err := UserKeySignedAt(context.Background(), nil, user, at)
+		assert.NilError(t, err)
+		assert.Equal(t, "current", key)
+	}
+}
+
+func TestSignedAt(t *testing.T) {
+	skew := config.Config.AuthMaxSkew
+	defer func() { config.Config.AuthMaxSkew = skew }()
+	config.Config.AuthMaxSkew = 5 * time.Minute
+
+	recent := time.Now().Add(-time.Minute)
+	assert.Equal(t, SignedAt(recent), recent)
+
+	// a time before the skew window is not trusted, so a replaced key can't
+	// be used to backdate a signature
+	old := time.Now().Add(-time.Hour)
+	assert.Assert(t, SignedAt(old).After(old.Add(30*time.Minute)))
+
+	config.Config.AuthMaxSkew = 0
+	assert.Assert(t, SignedAt(recent) != recent)
+}
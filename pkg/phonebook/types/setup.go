@@ -25,6 +25,28 @@ func Setup(ctx context.Context, db *mongo.Database) error {
 	})
 	if err != nil {
 		log.Error().Err(err).Msg("failed to initialize user index")
+		return err
+	}
+
+	keys := db.Collection(UserKeyCollection)
+	_, err = keys.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "valid_until.time", Value: 1}},
+		},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to initialize user keys index")
+		return err
+	}
+
+	tokens := db.Collection(TokenCollection)
+	_, err = tokens.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.M{"user_id": 1},
+		},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to initialize user tokens index")
 	}
 
 	return err
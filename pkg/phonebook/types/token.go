@@ -0,0 +1,329 @@
+package types
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"github.com/threefoldtech/zos/pkg/crypto"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// TokenCollection db collection name of the user API tokens
+	TokenCollection = "user-tokens"
+
+	// TokenMaxLifetime is the longest time a token can be valid
+	TokenMaxLifetime = 365 * 24 * time.Hour
+
+	// tokenKeyIDPrefix is the prefix of the http signature key id of
+	// requests signed with a token key
+	tokenKeyIDPrefix = "token:"
+)
+
+var (
+	// ErrTokenNotFound is returned if a token is not found
+	ErrTokenNotFound = errors.New("token not found")
+	// ErrTokenExists is returned when registering a token with an id which
+	// is already used
+	ErrTokenExists = errors.New("token already exists")
+	// ErrBadToken is returned when a token is invalid or not signed correctly
+	ErrBadToken = errors.New("bad token")
+)
+
+// Scope is an operation a token is allowed to do. All tokens can read
+type Scope string
+
+const (
+	// ScopeRead only allows read requests
+	ScopeRead Scope = "read"
+	// ScopePool allows creating and topping up capacity pools
+	ScopePool Scope = "pool"
+	// ScopeDeploy allows deploying workloads
+	ScopeDeploy Scope = "deploy"
+)
+
+// Valid checks if the scope is known
+func (s Scope) Valid() bool {
+	switch s {
+	case ScopeRead, ScopePool, ScopeDeploy:
+		return true
+	}
+
+	return false
+}
+
+// Token is a scoped and expiring credential of a user. The token has its own
+// key pair, the public key and the scopes are signed by the user key. Requests
+// and payloads signed with the token key are accepted as if they were signed
+// by the user, within the scopes of the token
+type Token struct {
+	// ID is chosen by the user when minting the token, it must be unique
+	ID     string    `bson:"_id" json:"id"`
+	UserID schema.ID `bson:"user_id" json:"user_id"`
+	// Pubkey of the token key pair, hex encoded
+	Pubkey string  `bson:"pubkey" json:"pubkey"`
+	Scopes []Scope `bson:"scopes" json:"scopes"`
+	// Pools limits the pools the token can use, empty means any pool
+	Pools      []schema.ID `bson:"pools" json:"pools"`
+	Expiration schema.Date `bson:"expiration" json:"expiration"`
+	// Signature of the token with the user key, hex encoded
+	Signature string      `bson:"signature" json:"signature"`
+	Created   schema.Date `bson:"created" json:"created"`
+	// Revoked is the time the token was revoked, zero if it is not
+	Revoked schema.Date `bson:"revoked" json:"revoked"`
+}
+
+// Encode token data for signing
+func (t *Token) Encode() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(t.ID)
+	buf.WriteString(fmt.Sprint(int64(t.UserID)))
+	buf.WriteString(t.Pubkey)
+	// lists are joined with a separator, so different lists can't have the
+	// same encoding
+	for i, s := range t.Scopes {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString(string(s))
+	}
+	buf.WriteString(";")
+	for i, p := range t.Pools {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString(fmt.Sprint(int64(p)))
+	}
+	buf.WriteString(";")
+	buf.WriteString(fmt.Sprint(t.Expiration.Unix()))
+
+	return buf.Bytes()
+}
+
+// Validate makes the sanity check of a new token
+func (t *Token) Validate(now time.Time) error {
+	if len(t.ID) < 16 || strings.Contains(t.ID, ":") {
+		return errors.Wrap(ErrBadToken, "id must be at least 16 characters and can't contain ':'")
+	}
+
+	if _, err := crypto.KeyFromHex(t.Pubkey); err != nil {
+		return errors.Wrapf(ErrBadToken, "invalid public key %s", t.Pubkey)
+	}
+
+	if len(t.Scopes) == 0 {
+		return errors.Wrap(ErrBadToken, "at least one scope is required")
+	}
+
+	for _, s := range t.Scopes {
+		if !s.Valid() {
+			return errors.Wrapf(ErrBadToken, "invalid scope '%s', supported scopes are read, pool and deploy", s)
+		}
+	}
+
+	if !t.Expiration.After(now) || t.Expiration.After(now.Add(TokenMaxLifetime)) {
+		return errors.Wrapf(ErrBadToken, "expiration must be in the future and within %s", TokenMaxLifetime)
+	}
+
+	return nil
+}
+
+// Verify the signature of the token with the user public key
+func (t *Token) Verify(pubkey string) error {
+	return verifyHex(pubkey, t.Encode(), t.Signature)
+}
+
+// HasScope checks if the token was given the scope
+func (t *Token) HasScope(scope Scope) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowsPool checks if the token can use the pool
+func (t *Token) AllowsPool(id schema.ID) bool {
+	if len(t.Pools) == 0 {
+		return true
+	}
+
+	for _, p := range t.Pools {
+		if p == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidAt checks if the token could be used at time t
+func (t *Token) ValidAt(at time.Time) bool {
+	if at.Before(t.Created.Time) || !at.Before(t.Expiration.Time) {
+		return false
+	}
+
+	return t.Revoked.IsZero() || at.Before(t.Revoked.Time)
+}
+
+// TrustedAt checks if a signature the token made at time t can be trusted.
+// The time of a signature is chosen by the signer, and the key of a revoked
+// token might be compromised and used to backdate signatures, so signatures of
+// revoked tokens are never trusted
+func (t *Token) TrustedAt(at time.Time) bool {
+	return t.Revoked.IsZero() && t.ValidAt(at)
+}
+
+// TokenKeyID is the http signature key id of requests signed with the token key
+func TokenKeyID(id string) string {
+	return tokenKeyIDPrefix + id
+}
+
+// ParseTokenKeyID returns the token id of a http signature key id, and false
+// if the key id is not the one of a token
+func ParseTokenKeyID(keyID string) (string, bool) {
+	if !strings.HasPrefix(keyID, tokenKeyIDPrefix) {
+		return "", false
+	}
+
+	return strings.TrimPrefix(keyID, tokenKeyIDPrefix), true
+}
+
+// TokenFilter type
+type TokenFilter bson.D
+
+// WithID filters token with id
+func (f TokenFilter) WithID(id string) TokenFilter {
+	return append(f, bson.E{Key: "_id", Value: id})
+}
+
+// WithUserID filters tokens of a user
+func (f TokenFilter) WithUserID(id schema.ID) TokenFilter {
+	return append(f, bson.E{Key: "user_id", Value: id})
+}
+
+// WithScope filters tokens with a scope
+func (f TokenFilter) WithScope(scope Scope) TokenFilter {
+	return append(f, bson.E{Key: "scopes", Value: scope})
+}
+
+// Find all tokens that matches filter
+func (f TokenFilter) Find(ctx context.Context, db *mongo.Database, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	if f == nil {
+		f = TokenFilter{}
+	}
+	return db.Collection(TokenCollection).Find(ctx, f, opts...)
+}
+
+// List all tokens that matches filter, oldest first
+func (f TokenFilter) List(ctx context.Context, db *mongo.Database) ([]Token, error) {
+	cur, err := f.Find(ctx, db, options.Find().SetSort(bson.M{"created": 1}))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list tokens")
+	}
+	defer cur.Close(ctx)
+
+	tokens := []Token{}
+	if err := cur.All(ctx, &tokens); err != nil {
+		return nil, errors.Wrap(err, "failed to decode tokens")
+	}
+
+	return tokens, nil
+}
+
+// Get single token
+func (f TokenFilter) Get(ctx context.Context, db *mongo.Database) (token Token, err error) {
+	if f == nil {
+		f = TokenFilter{}
+	}
+
+	result := db.Collection(TokenCollection).FindOne(ctx, f)
+	if err = result.Err(); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return token, ErrTokenNotFound
+		}
+		return token, err
+	}
+
+	err = result.Decode(&token)
+	return
+}
+
+// TokenCreate validates and registers a token signed by the user
+func TokenCreate(ctx context.Context, db *mongo.Database, token Token) (Token, error) {
+	var filter UserFilter
+	user, err := filter.WithID(token.UserID).Get(ctx, db)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return token, ErrUserNotFound
+	} else if err != nil {
+		return token, err
+	}
+
+	now := time.Now()
+	if err := token.Validate(now); err != nil {
+		return token, err
+	}
+
+	if err := token.Verify(user.Pubkey); err != nil {
+		return token, errors.Wrap(ErrBadToken, "signature verification failed")
+	}
+
+	token.Created = schema.Date{Time: now}
+	token.Revoked = schema.Date{}
+
+	if _, err := db.Collection(TokenCollection).InsertOne(ctx, token); err != nil {
+		if merr, ok := err.(mongo.WriteException); ok {
+			errCode := merr.WriteErrors[0].Code
+			if errCode == 11000 {
+				return token, ErrTokenExists
+			}
+		}
+		return token, err
+	}
+
+	return token, nil
+}
+
+// TokenRevoke revokes a token of the user. Signatures made with the token
+// are not trusted anymore, even the ones made before it was revoked
+func TokenRevoke(ctx context.Context, db *mongo.Database, userID schema.ID, id string) error {
+	result, err := db.Collection(TokenCollection).UpdateOne(ctx,
+		bson.M{"_id": id, "user_id": userID, "revoked": schema.Date{}},
+		bson.M{"$set": bson.M{"revoked": schema.Date{Time: time.Now()}}},
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrTokenNotFound
+	}
+
+	return nil
+}
+
+// UserTokenKeys returns the public keys of the tokens of the user with the
+// scope which signatures made at time t can be trusted
+func UserTokenKeys(ctx context.Context, db *mongo.Database, userID schema.ID, scope Scope, t time.Time) ([]string, error) {
+	tokens, err := TokenFilter{}.WithUserID(userID).WithScope(scope).List(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, token := range tokens {
+		if token.TrustedAt(t) {
+			keys = append(keys, token.Pubkey)
+		}
+	}
+
+	return keys, nil
+}
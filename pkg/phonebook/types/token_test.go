@@ -0,0 +1,96 @@
+package types
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/threefoldtech/tfexplorer/schema"
+	"gotest.tools/assert"
+)
+
+func TestToken_Validate(t *testing.T) {
+	pk, _, err := ed25519.GenerateKey(nil)
+	assert.NilError(t, err)
+
+	now := time.Now()
+	valid := Token{
+		ID:         "0123456789abcdef",
+		UserID:     1,
+		Pubkey:     hex.EncodeToString(pk),
+		Scopes:     []Scope{ScopeDeploy},
+		Expiration: schema.Date{Time: now.Add(time.Hour)},
+	}
+	assert.NilError(t, valid.Validate(now))
+
+	tests := []struct {
+		name   string
+		update func(t *Token)
+		err    string
+	}{
+		{"short id", func(t *Token) { t.ID = "abc" }, "id must be at least 16 characters and can't contain ':': bad token"},
+		{"no scope", func(t *Token) { t.Scopes = nil }, "at least one scope is required: bad token"},
+		{"unknown scope", func(t *Token) { t.Scopes = []Scope{"admin"} }, "invalid scope 'admin', supported scopes are read, pool and deploy: bad token"},
+		{"expired", func(t *Token) { t.Expiration = schema.Date{Time: now.Add(-time.Hour)} }, "expiration must be in the future and within 8760h0m0s: bad token"},
+		{"too long", func(t *Token) { t.Expiration = schema.Date{Time: now.Add(2 * TokenMaxLifetime)} }, "expiration must be in the future and within 8760h0m0s: bad token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := valid
+			tt.update(&token)
+			assert.Error(t, token.Validate(now), tt.err)
+		})
+	}
+}
+
+func TestToken_Verify(t *testing.T) {
+	pk, sk, err := ed25519.GenerateKey(nil)
+	assert.NilError(t, err)
+
+	token := Token{
+		ID:         "0123456789abcdef",
+		UserID:     1,
+		Scopes:     []Scope{ScopePool},
+		Pools:      []schema.ID{1, 23},
+		Expiration: schema.Date{Time: time.Unix(1600000000, 0)},
+	}
+	token.Signature = hex.EncodeToString(ed25519.Sign(sk, token.Encode()))
+	assert.NilError(t, token.Verify(hex.EncodeToString(pk)))
+
+	// the pools are part of the signed data
+	token.Pools = []schema.ID{12, 3}
+	assert.Assert(t, token.Verify(hex.EncodeToString(pk)) != nil)
+}
+
+func TestToken_ValidAt(t *testing.T) {
+	now := time.Now()
+	token := Token{
+		Created:    schema.Date{Time: now},
+		Expiration: schema.Date{Time: now.Add(2 * time.Hour)},
+		Pools:      []schema.ID{5},
+	}
+
+	assert.Assert(t, !token.ValidAt(now.Add(-time.Minute)))
+	assert.Assert(t, token.ValidAt(now.Add(time.Hour)))
+	assert.Assert(t, !token.ValidAt(now.Add(3*time.Hour)))
+
+	assert.Assert(t, token.TrustedAt(now.Add(10*time.Minute)))
+
+	token.Revoked = schema.Date{Time: now.Add(30 * time.Minute)}
+	assert.Assert(t, token.ValidAt(now.Add(10*time.Minute)))
+	assert.Assert(t, !token.ValidAt(now.Add(time.Hour)))
+	assert.Assert(t, !token.TrustedAt(now.Add(10*time.Minute)), "signatures of revoked tokens can be backdated")
+
+	assert.Assert(t, token.AllowsPool(5))
+	assert.Assert(t, !token.AllowsPool(6))
+}
+
+func TestParseTokenKeyID(t *testing.T) {
+	id, ok := ParseTokenKeyID(TokenKeyID("abc"))
+	assert.Assert(t, ok)
+	assert.Equal(t, id, "abc")
+
+	_, ok = ParseTokenKeyID("12")
+	assert.Assert(t, !ok)
+}
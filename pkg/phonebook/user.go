@@ -6,9 +6,9 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"math"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
@@ -122,6 +122,14 @@ func (u *UserAPI) list(r *http.Request) (interface{}, mw.Response) {
 
 	findOpts := make([]*options.FindOptions, 0, 2)
 	pager := models.PageFromRequest(r)
+	cursor, err := models.CursorFromRequest(r)
+	if err != nil {
+		return nil, mw.BadRequest(err)
+	}
+	if cursor != nil {
+		filter = append(filter, cursor.Filter())
+		pager = cursor.Pager()
+	}
 	findOpts = append(findOpts, pager)
 
 	// hide the email of the user for any non authenticated user
@@ -146,10 +154,12 @@ func (u *UserAPI) list(r *http.Request) (interface{}, mw.Response) {
 		return nil, mw.Error(err, http.StatusInternalServerError)
 	}
 
-	nrPages := math.Ceil(float64(total) / float64(*pager.Limit))
-	pages := fmt.Sprintf("%d", int64(nrPages))
+	var next string
+	if len(users) > 0 {
+		next = models.NextCursor(pager, len(users), users[len(users)-1].ID)
+	}
 
-	return users, mw.Ok().WithHeader("Pages", pages)
+	return users, mw.Page(pager, cursor, total, next)
 }
 
 func (u *UserAPI) parseID(id string) (schema.ID, error) {
@@ -188,6 +198,10 @@ func (u *UserAPI) validate(r *http.Request) (interface{}, mw.Response) {
 	var payload struct {
 		Payload   string `json:"payload"`
 		Signature string `json:"signature"`
+		// At is an optional unix timestamp, if set the signature is validated
+		// with the key the user had at that time. At is not signed, so it is
+		// only used within the maximum clock skew of the server
+		At int64 `json:"at,omitempty"`
 	}
 
 	userID, err := u.parseID(mux.Vars(r)["user_id"])
@@ -220,7 +234,17 @@ func (u *UserAPI) validate(r *http.Request) (interface{}, mw.Response) {
 		return nil, mw.NotFound(err)
 	}
 
-	key, err := crypto.KeyFromHex(user.Pubkey)
+	at := time.Now()
+	if payload.At != 0 {
+		at = types.SignedAt(time.Unix(payload.At, 0))
+	}
+
+	pubkey, err := types.UserKeySignedAt(r.Context(), db, user, at)
+	if err != nil {
+		return nil, mw.Error(err)
+	}
+
+	key, err := crypto.KeyFromHex(pubkey)
 	if err != nil {
 		return nil, mw.Error(err)
 	}
@@ -229,13 +253,173 @@ func (u *UserAPI) validate(r *http.Request) (interface{}, mw.Response) {
 		return nil, mw.Error(fmt.Errorf("public key has the wrong size"))
 	}
 
+	valid := ed25519.Verify(key, data, signature)
+	if !valid {
+		// workloads can also be signed by the tokens of the user which
+		// are allowed to deploy
+		keys, err := types.UserTokenKeys(r.Context(), db, user.ID, types.ScopeDeploy, at)
+		if err != nil {
+			return nil, mw.Error(err)
+		}
+
+		for _, k := range keys {
+			if key, err := crypto.KeyFromHex(k); err == nil && ed25519.Verify(key, data, signature) {
+				valid = true
+				break
+			}
+		}
+	}
+
 	return struct {
 		IsValid bool `json:"is_valid"`
 	}{
-		IsValid: ed25519.Verify(key, data, signature),
+		IsValid: valid,
 	}, nil
 }
 
+// createToken registers a token minted by the user
+func (u *UserAPI) createToken(r *http.Request) (interface{}, mw.Response) {
+	userID, err := u.parseID(mux.Vars(r)["user_id"])
+	if err != nil {
+		return nil, mw.BadRequest(err)
+	}
+
+	defer r.Body.Close()
+
+	var token types.Token
+	if err := json.NewDecoder(r.Body).Decode(&token); err != nil {
+		return nil, mw.BadRequest(err)
+	}
+
+	if token.UserID != userID {
+		return nil, mw.BadRequest(fmt.Errorf("token user_id does not match the user"))
+	}
+
+	token, err = types.TokenCreate(r.Context(), mw.Database(r), token)
+	if errors.Is(err, types.ErrBadToken) {
+		return nil, mw.BadRequest(err)
+	} else if errors.Is(err, types.ErrTokenExists) {
+		return nil, mw.Conflict(err)
+	} else if err != nil {
+		return nil, mw.Error(err)
+	}
+
+	return token, mw.Created()
+}
+
+// listTokens lists the tokens of the user, including the revoked and
+// expired ones
+func (u *UserAPI) listTokens(r *http.Request) (interface{}, mw.Response) {
+	userID, err := u.parseID(mux.Vars(r)["user_id"])
+	if err != nil {
+		return nil, mw.BadRequest(err)
+	}
+
+	tokens, err := types.TokenFilter{}.WithUserID(userID).List(r.Context(), mw.Database(r))
+	if err != nil {
+		return nil, mw.Error(err)
+	}
+
+	return tokens, nil
+}
+
+// revokeToken revokes a token of the user
+func (u *UserAPI) revokeToken(r *http.Request) (interface{}, mw.Response) {
+	userID, err := u.parseID(mux.Vars(r)["user_id"])
+	if err != nil {
+		return nil, mw.BadRequest(err)
+	}
+
+	err = types.TokenRevoke(r.Context(), mw.Database(r), userID, mux.Vars(r)["token_id"])
+	if errors.Is(err, types.ErrTokenNotFound) {
+		return nil, mw.NotFound(err)
+	} else if err != nil {
+		return nil, mw.Error(err)
+	}
+
+	return nil, mw.NoContent()
+}
+
+// rotate replaces the public key of the user. The request is not signed with
+// http signatures since the current private key might be lost, instead the
+// payload is signed by the current key or by the guardians of the user
+func (u *UserAPI) rotate(r *http.Request) (interface{}, mw.Response) {
+	userID, err := u.parseID(mux.Vars(r)["user_id"])
+	if err != nil {
+		return nil, mw.BadRequest(err)
+	}
+
+	defer r.Body.Close()
+
+	var rotation types.KeyRotation
+	if err := json.NewDecoder(r.Body).Decode(&rotation); err != nil {
+		return nil, mw.BadRequest(err)
+	}
+
+	if err := types.UserKeyRotate(r.Context(), mw.Database(r), userID, rotation); err != nil {
+		if errors.Is(err, types.ErrUserNotFound) {
+			return nil, mw.NotFound(err)
+		} else if errors.Is(err, types.ErrBadKeyRotation) {
+			return nil, mw.BadRequest(err)
+		}
+		return nil, mw.Error(err)
+	}
+
+	return nil, nil
+}
+
+// keys lists the current and previous public keys of the user
+func (u *UserAPI) keys(r *http.Request) (interface{}, mw.Response) {
+	userID, err := u.parseID(mux.Vars(r)["user_id"])
+	if err != nil {
+		return nil, mw.BadRequest(err)
+	}
+
+	db := mw.Database(r)
+
+	var filter types.UserFilter
+	user, err := filter.WithID(userID).Get(r.Context(), db)
+	if err != nil {
+		return nil, mw.NotFound(err)
+	}
+
+	keys, err := types.UserKeys(r.Context(), db, user)
+	if err != nil {
+		return nil, mw.Error(err)
+	}
+
+	return keys, nil
+}
+
+// setGuardians sets the users which can rotate the key of the user
+func (u *UserAPI) setGuardians(r *http.Request) (interface{}, mw.Response) {
+	userID, err := u.parseID(mux.Vars(r)["user_id"])
+	if err != nil {
+		return nil, mw.BadRequest(err)
+	}
+
+	defer r.Body.Close()
+
+	var payload struct {
+		Guardians []schema.ID `json:"guardians"`
+		Threshold int64       `json:"threshold"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		return nil, mw.BadRequest(err)
+	}
+
+	err = types.UserSetGuardians(r.Context(), mw.Database(r), userID, payload.Guardians, payload.Threshold)
+	if errors.Is(err, types.ErrUserNotFound) {
+		return nil, mw.NotFound(err)
+	} else if errors.Is(err, types.ErrBadGuardians) {
+		return nil, mw.BadRequest(err)
+	} else if err != nil {
+		return nil, mw.Error(err)
+	}
+
+	return nil, nil
+}
+
 type errWrongPubKey struct {
 	name   string
 	pubkey string
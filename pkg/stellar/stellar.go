@@ -43,6 +43,12 @@ type (
 		Retries   int
 	}
 
+	// PayoutQueue accepts payout jobs for later submission. Implementations
+	// must make sure a job which is pushed more than once is only paid out once.
+	PayoutQueue interface {
+		Push(job PayoutJob) error
+	}
+
 	// stellarWallet is the foundation wallet
 	// Payments will be funded and fees will be taken with this wallet
 	stellarWallet struct {
@@ -65,14 +71,17 @@ type (
 		PublicAddress() string
 		CreateAccount() (encSeed string, address string, err error)
 		GetBalance(address string, memo string, asset Asset, batchTxs *BatchTransactionsInfo) (xdr.Int64, []string, error)
-		Refund(encryptedSeed string, memo string, asset Asset, batchTxs *BatchTransactionsInfo, pn chan PayoutJob, ReservationID schema.ID) error
+		Refund(encryptedSeed string, memo string, asset Asset, batchTxs *BatchTransactionsInfo, queue PayoutQueue, ReservationID schema.ID) error
 		PayoutFarmers(encryptedSeed string, destinations []PayoutInfo, memo string, asset Asset) error
 		GetAccountDetails(address string) (account hProtocol.Account, err error)
 		GetNextSequenceNumber() (string, error)
 		GetHorizonClient() (*horizonclient.Client, error)
 		GetNetworkPassPhrase() string
-		QueuePayout(encryptedSeed string, destinations []PayoutInfo, memo string, asset Asset, ID schema.ID, pn chan PayoutJob) error
-		ProcessPayoutBatches(payouts []txnbuild.Payment, secets []string) error
+		QueuePayout(encryptedSeed string, destinations []PayoutInfo, memo string, asset Asset, ID schema.ID, queue PayoutQueue) error
+		ProcessPayoutBatches(payouts []txnbuild.Payment, secets []string) (string, error)
+		FundingTransactions(address string, memo string) ([]string, error)
+		PayoutTransaction(address string, memo string, batchTxs *BatchTransactionsInfo) (string, error)
+		Sign(message []byte) ([]byte, error)
 	}
 )
 
@@ -158,6 +167,15 @@ func (w *stellarWallet) PublicAddress() string {
 	return w.keypair.Address()
 }
 
+// Sign a message with the key of this wallet. The signature can be verified
+// with the public address of the wallet
+func (w *stellarWallet) Sign(message []byte) ([]byte, error) {
+	if w.keypair == nil {
+		return nil, fmt.Errorf("wallet has no key to sign with")
+	}
+	return w.keypair.Sign(message)
+}
+
 // CreateAccount and activate it, so that it is ready to be used
 // The encrypted seed of the wallet is returned, together with the public address
 func (w *stellarWallet) CreateAccount() (string, string, error) {
@@ -500,10 +518,89 @@ func (w *stellarWallet) GetBalance(address string, memo string, asset Asset, bat
 	return total, donorList, nil
 }
 
+// FundingTransactions returns the hashes of the successful transactions with the
+// given memo, which were sent to the address by another account
+func (w *stellarWallet) FundingTransactions(address string, memo string) ([]string, error) {
+	horizonClient, err := w.GetHorizonClient()
+	if err != nil {
+		return nil, err
+	}
+
+	txReq := horizonclient.TransactionRequest{
+		ForAccount: address,
+		Limit:      stellarPageLimit,
+	}
+
+	var hashes []string
+	for {
+		txes, err := horizonClient.Transactions(txReq)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not get transactions")
+		}
+
+		for _, tx := range txes.Embedded.Records {
+			if tx.Memo == memo && tx.Successful && tx.Account != address {
+				hashes = append(hashes, tx.Hash)
+			}
+			txReq.Cursor = tx.PagingToken()
+		}
+
+		if len(txes.Embedded.Records) < stellarPageLimit {
+			break
+		}
+	}
+
+	return hashes, nil
+}
+
+// PayoutTransaction returns the hash of the last successful transaction which
+// paid out the funds of the memo from the address, either sent by the address
+// itself or as part of a payout batch. An empty hash is returned if there is no
+// such transaction
+func (w *stellarWallet) PayoutTransaction(address string, memo string, batchTxs *BatchTransactionsInfo) (string, error) {
+	horizonClient, err := w.GetHorizonClient()
+	if err != nil {
+		return "", err
+	}
+
+	if batchTxs == nil {
+		batchTxs = &BatchTransactionsInfo{
+			Ops: make(map[string][]int),
+		}
+	}
+
+	txReq := horizonclient.TransactionRequest{
+		ForAccount: address,
+		Limit:      stellarPageLimit,
+	}
+
+	var hash string
+	for {
+		txes, err := horizonClient.Transactions(txReq)
+		if err != nil {
+			return "", errors.Wrap(err, "could not get transactions")
+		}
+
+		for _, tx := range txes.Embedded.Records {
+			sent := tx.Memo == memo && tx.Account == address
+			if tx.Successful && (sent || batchTxs.isTransactionInMemo(tx.AccountSequence)) {
+				hash = tx.Hash
+			}
+			txReq.Cursor = tx.PagingToken()
+		}
+
+		if len(txes.Embedded.Records) < stellarPageLimit {
+			break
+		}
+	}
+
+	return hash, nil
+}
+
 // Refund an escrow address for a reservation. This will transfer all funds
 // for this reservation that are currently on the address (if any), to (some of)
 // the addresses which these funds came from.
-func (w *stellarWallet) Refund(encryptedSeed string, memo string, asset Asset, batchTxs *BatchTransactionsInfo, pn chan PayoutJob, ReservationID schema.ID) error {
+func (w *stellarWallet) Refund(encryptedSeed string, memo string, asset Asset, batchTxs *BatchTransactionsInfo, queue PayoutQueue, ReservationID schema.ID) error {
 	keypair, err := w.keypairFromEncryptedSeed(encryptedSeed)
 	if err != nil {
 		return errors.Wrap(err, "could not get keypair from encrypted seed")
@@ -543,8 +640,8 @@ func (w *stellarWallet) Refund(encryptedSeed string, memo string, asset Asset, b
 		ID:        ReservationID,
 		Retries:   ClientRefundsMaxRetries,
 	}
-	pn <- job
-	return nil
+
+	return errors.Wrap(queue.Push(job), "failed to queue refund")
 }
 
 // PayoutFarmers pays a group of farmers, from an escrow account. The escrow
@@ -594,7 +691,7 @@ func (w *stellarWallet) PayoutFarmers(encryptedSeed string, destinations []Payou
 
 // QueuePayout enqueues a group of farmers payment, from an escrow account. The escrow
 // account must be provided as the encrypted string of the seed.
-func (w *stellarWallet) QueuePayout(encryptedSeed string, destinations []PayoutInfo, memo string, asset Asset, ID schema.ID, pn chan PayoutJob) error {
+func (w *stellarWallet) QueuePayout(encryptedSeed string, destinations []PayoutInfo, memo string, asset Asset, ID schema.ID, queue PayoutQueue) error {
 	keypair, err := w.keypairFromEncryptedSeed(encryptedSeed)
 	if err != nil {
 		return errors.Wrap(err, "could not get keypair from encrypted seed")
@@ -646,15 +743,15 @@ func (w *stellarWallet) QueuePayout(encryptedSeed string, destinations []PayoutI
 				SourceAccount: &sourceAccount,
 			})
 	}
-	pn <- job
-	return nil
+
+	return errors.Wrap(queue.Push(job), "failed to queue payout")
 }
 
-func (w *stellarWallet) ProcessPayoutBatches(payouts []txnbuild.Payment, secrets []string) error {
+func (w *stellarWallet) ProcessPayoutBatches(payouts []txnbuild.Payment, secrets []string) (string, error) {
 	client, err := w.GetHorizonClient()
 
 	if err != nil {
-		return errors.Wrap(err, "failed to get horizon client")
+		return "", errors.Wrap(err, "failed to get horizon client")
 	}
 
 	paymentOps := make([]txnbuild.Operation, 0, len(payouts)+1)
@@ -673,20 +770,20 @@ func (w *stellarWallet) ProcessPayoutBatches(payouts []txnbuild.Payment, secrets
 	}
 	fundedTx, err := w.fundTransaction(&tx)
 	if err != nil {
-		return errors.Wrap(err, "failed to fund transaction")
+		return "", errors.Wrap(err, "failed to fund transaction")
 	}
 	for _, secret := range secrets {
 		keyPair, err := w.keypairFromEncryptedSeed(secret)
 		if err != nil {
-			return errors.Wrap(err, "could not get keypair from encrypted seed")
+			return "", errors.Wrap(err, "could not get keypair from encrypted seed")
 		}
 		fundedTx, err = fundedTx.Sign(w.GetNetworkPassPhrase(), &keyPair)
 		if err != nil {
-			return errors.Wrap(err, "failed to sign transaction with keypair")
+			return "", errors.Wrap(err, "failed to sign transaction with keypair")
 		}
 	}
 	log.Info().Msg("submitting transaction to the stellar network")
-	_, err = client.SubmitTransaction(fundedTx)
+	res, err := client.SubmitTransaction(fundedTx)
 
 	if err != nil {
 		if err2, ok := err.(*horizonclient.Error); ok {
@@ -695,9 +792,9 @@ func (w *stellarWallet) ProcessPayoutBatches(payouts []txnbuild.Payment, secrets
 			fmt.Println(err2.ResultString())
 			fmt.Println(err2.Problem)
 		}
-		return err
+		return "", err
 	}
-	return nil
+	return res.Hash, nil
 }
 
 func (w *stellarWallet) GetNextSequenceNumber() (string, error) {
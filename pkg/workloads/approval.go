@@ -0,0 +1,224 @@
+package workloads
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	generated "github.com/threefoldtech/tfexplorer/models/generated/workloads"
+	"github.com/threefoldtech/tfexplorer/mw"
+	phonebook "github.com/threefoldtech/tfexplorer/pkg/phonebook/types"
+	"github.com/threefoldtech/tfexplorer/pkg/workloads/types"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// listApprovals lists the signing requests of the workloads awaiting the
+// signature of the request user
+func (a *API) listApprovals(r *http.Request) (interface{}, mw.Response) {
+	tid, resp := requestUser(r)
+	if resp != nil {
+		return nil, resp
+	}
+
+	kinds := []types.ApprovalKind{types.ApprovalProvision, types.ApprovalDelete}
+	if kind := r.URL.Query().Get("kind"); kind != "" {
+		if err := types.ApprovalKind(kind).Valid(); err != nil {
+			return nil, mw.BadRequest(err)
+		}
+		kinds = []types.ApprovalKind{types.ApprovalKind(kind)}
+	}
+
+	db := mw.Database(r)
+	approvals := []types.Approval{}
+	for _, kind := range kinds {
+		rejected, err := types.ApprovalRejected(r.Context(), db, tid, kind)
+		if err != nil {
+			return nil, mw.Error(err)
+		}
+
+		workloads, err := types.WorkloadFilter{}.WithAwaitingSignature(kind, tid).Find(r.Context(), db)
+		if err != nil {
+			return nil, mw.Error(err)
+		}
+
+		for _, workload := range workloads {
+			if rejected[workload.GetID()] {
+				continue
+			}
+
+			decisions, err := types.ApprovalDecisions(r.Context(), db, workload.GetID(), kind)
+			if err != nil {
+				return nil, mw.Error(err)
+			}
+
+			approvals = append(approvals, types.NewApproval(kind, workload, decisions))
+		}
+	}
+
+	return approvals, nil
+}
+
+// approvalRequest loads the workload and checks the decision of the request
+// user. The signature of an approval is verified
+func (a *API) approvalRequest(r *http.Request, approve bool) (types.WorkloaderType, types.ApprovalRequest, types.ApprovalDecision, mw.Response) {
+	var decision types.ApprovalDecision
+	var request types.ApprovalRequest
+
+	tid, resp := requestUser(r)
+	if resp != nil {
+		return types.WorkloaderType{}, request, decision, resp
+	}
+
+	id, err := a.parseID(mux.Vars(r)["res_id"])
+	if err != nil {
+		return types.WorkloaderType{}, request, decision, mw.BadRequest(fmt.Errorf("invalid reservation id"))
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return types.WorkloaderType{}, request, decision, mw.BadRequest(err)
+	}
+
+	if err := request.Validate(approve); err != nil {
+		return types.WorkloaderType{}, request, decision, mw.BadRequest(err)
+	}
+
+	db := mw.Database(r)
+	workload, err := a.workloadpipeline(types.WorkloadFilter{}.WithID(id).Get(r.Context(), db))
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return workload, request, decision, mw.NotFound(err)
+		}
+		return workload, request, decision, mw.Error(err)
+	}
+
+	awaiting := workload.IsAny(types.Sign)
+	if request.Kind == types.ApprovalDelete {
+		awaiting = !workload.IsAny(types.Delete, types.Deleted, types.Invalid)
+	}
+
+	if !awaiting {
+		return workload, request, decision, mw.Conflict(fmt.Errorf("workload '%d' in state '%s' is not expecting %s signatures", id, workload.GetNextAction(), request.Kind))
+	}
+
+	signingRequest, signatures := request.Kind.Request(workload)
+	if resp := userCanSign(tid, signingRequest, signatures); resp != nil {
+		return workload, request, decision, resp
+	}
+
+	rejected, err := types.ApprovalRejected(r.Context(), db, tid, request.Kind)
+	if err != nil {
+		return workload, request, decision, mw.Error(err)
+	}
+
+	if rejected[id] {
+		return workload, request, decision, mw.Conflict(fmt.Errorf("user %d already rejected the %s of workload '%d'", tid, request.Kind, id))
+	}
+
+	decision = types.ApprovalDecision{
+		WorkloadID: id,
+		Kind:       request.Kind,
+		Tid:        tid,
+		Approved:   approve,
+		Comment:    request.Comment,
+		Epoch:      schema.Date{Time: time.Now()},
+	}
+
+	if !approve {
+		return workload, request, decision, nil
+	}
+
+	user, err := phonebook.UserFilter{}.WithID(schema.ID(tid)).Get(r.Context(), db)
+	if err != nil {
+		return workload, request, decision, mw.NotFound(errors.Wrap(err, "signer id not found"))
+	}
+
+	signature := generated.SigningSignature{Tid: tid, Signature: request.Signature}
+	verify := workload.SignatureProvisionRequestVerify
+	if request.Kind == types.ApprovalDelete {
+		verify = workload.SignatureDeleteRequestVerify
+	}
+
+	if err := verify(user.Pubkey, signature); err != nil {
+		return workload, request, decision, mw.UnAuthorized(errors.Wrap(err, "failed to verify signature"))
+	}
+
+	return workload, request, decision, nil
+}
+
+// approval returns the approval of the workload once the decision is taken
+func (a *API) approval(r *http.Request, decision types.ApprovalDecision) (interface{}, mw.Response) {
+	db := mw.Database(r)
+	workload, err := a.workloadpipeline(types.WorkloadFilter{}.WithID(decision.WorkloadID).Get(r.Context(), db))
+	if err != nil {
+		return nil, mw.Error(err)
+	}
+
+	decisions, err := types.ApprovalDecisions(r.Context(), db, decision.WorkloadID, decision.Kind)
+	if err != nil {
+		return nil, mw.Error(err)
+	}
+
+	return types.NewApproval(decision.Kind, workload, decisions), nil
+}
+
+// approveWorkload signs the signing request of a workload with a comment
+func (a *API) approveWorkload(r *http.Request) (interface{}, mw.Response) {
+	defer r.Body.Close()
+
+	_, request, decision, resp := a.approvalRequest(r, true)
+	if resp != nil {
+		return nil, resp
+	}
+
+	push := a.pushProvisionSignature
+	if decision.Kind == types.ApprovalDelete {
+		push = a.pushDeleteSignature
+	}
+
+	db := mw.Database(r)
+	signature := generated.SigningSignature{Tid: decision.Tid, Signature: request.Signature}
+	if _, err := push(r.Context(), db, decision.WorkloadID, signature); err != nil {
+		return nil, mw.Error(err)
+	}
+
+	if err := types.ApprovalDecisionCreate(r.Context(), db, decision); err != nil {
+		return nil, mw.Error(err)
+	}
+
+	return a.approval(r, decision)
+}
+
+// rejectWorkload rejects the signing request of a workload with a comment.
+// A workload which can't reach its provision quorum anymore is invalidated
+func (a *API) rejectWorkload(r *http.Request) (interface{}, mw.Response) {
+	defer r.Body.Close()
+
+	workload, _, decision, resp := a.approvalRequest(r, false)
+	if resp != nil {
+		return nil, resp
+	}
+
+	db := mw.Database(r)
+	if err := types.ApprovalDecisionCreate(r.Context(), db, decision); err != nil {
+		return nil, mw.Error(err)
+	}
+
+	if decision.Kind == types.ApprovalProvision {
+		decisions, err := types.ApprovalDecisions(r.Context(), db, decision.WorkloadID, decision.Kind)
+		if err != nil {
+			return nil, mw.Error(err)
+		}
+
+		if types.QuorumUnreachable(workload.GetSigningRequestProvision(), decisions) {
+			if err := types.WorkloadSetNextAction(r.Context(), db, decision.WorkloadID, types.Invalid); err != nil {
+				return nil, mw.Error(err)
+			}
+		}
+	}
+
+	return a.approval(r, decision)
+}
@@ -0,0 +1,143 @@
+package workloads
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/tfexplorer/config"
+	generated "github.com/threefoldtech/tfexplorer/models/generated/workloads"
+	"github.com/threefoldtech/tfexplorer/mw"
+	phonebook "github.com/threefoldtech/tfexplorer/pkg/phonebook/types"
+	"github.com/threefoldtech/tfexplorer/pkg/workloads/types"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BulkDeleteResponse wraps the outcome of a bulk delete for each workload
+type BulkDeleteResponse struct {
+	Results []types.BulkDeleteResult `json:"results"`
+}
+
+// bulkDeleteIDs returns the sorted ids of the workloads of a bulk delete
+func bulkDeleteIDs(ctx context.Context, db *mongo.Database, request types.BulkDelete) ([]schema.ID, mw.Response) {
+	ids := make([]schema.ID, len(request.IDs))
+	copy(ids, request.IDs)
+
+	if request.HasSelector() {
+		workloads, err := request.Filter().Find(ctx, db, options.Find().SetLimit(types.MaxBulkDelete+1))
+		if err != nil {
+			return nil, mw.Error(err)
+		}
+
+		if len(workloads) > types.MaxBulkDelete {
+			return nil, mw.BadRequest(fmt.Errorf("the selector matches more than %d workloads", types.MaxBulkDelete))
+		}
+
+		for _, workload := range workloads {
+			ids = append(ids, workload.GetID())
+		}
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+// bulkDelete signs the deletion of many workloads with a single signature.
+// The signature is checked against the delete signing request of each
+// workload, the outcome is returned for each workload
+func (a *API) bulkDelete(r *http.Request) (interface{}, mw.Response) {
+	defer r.Body.Close()
+
+	var request types.BulkDelete
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return nil, mw.BadRequest(err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return nil, mw.BadRequest(err)
+	}
+
+	if skew := config.Config.AuthMaxSkew; skew > 0 {
+		if age := time.Since(request.Epoch.Time); age > skew || age < -skew {
+			return nil, mw.UnAuthorized(fmt.Errorf("bulk delete is too old or in the future"))
+		}
+	}
+
+	db := mw.Database(r)
+	user, err := phonebook.UserFilter{}.WithID(schema.ID(request.Tid)).Get(r.Context(), db)
+	if err != nil {
+		return nil, mw.NotFound(errors.Wrap(err, "signer id not found"))
+	}
+
+	ids, resp := bulkDeleteIDs(r.Context(), db, request)
+	if resp != nil {
+		return nil, resp
+	}
+
+	pubkey, err := phonebook.UserKeySignedAt(r.Context(), db, user, request.Epoch.Time)
+	if err != nil {
+		return nil, mw.Error(err)
+	}
+
+	if err := request.Verify(ids, pubkey); err != nil {
+		return nil, mw.UnAuthorized(errors.Wrap(err, "failed to verify the signature of the sorted workload ids"))
+	}
+
+	signature := generated.SigningSignature{
+		Tid:       request.Tid,
+		Signature: request.Signature,
+	}
+
+	results := make([]types.BulkDeleteResult, 0, len(ids))
+	for _, id := range ids {
+		results = append(results, a.bulkDeleteWorkload(r.Context(), db, id, signature))
+	}
+
+	return BulkDeleteResponse{Results: results}, nil
+}
+
+// bulkDeleteWorkload records the bulk delete signature of a workload
+func (a *API) bulkDeleteWorkload(ctx context.Context, db *mongo.Database, id schema.ID, signature generated.SigningSignature) types.BulkDeleteResult {
+	result := types.BulkDeleteResult{ID: id, State: types.BulkDeleteFailed}
+
+	workload, err := a.workloadpipeline(types.WorkloadFilter{}.WithID(id).Get(ctx, db))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		result.Error = "workload not found"
+		return result
+	} else if err != nil {
+		log.Error().Err(err).Int64("id", int64(id)).Msg("failed to load workload to delete")
+		result.Error = "could not load workload"
+		return result
+	}
+
+	if workload.IsAny(types.Delete, types.Deleted, types.Invalid) {
+		result.Error = fmt.Sprintf("workload is in state '%s'", workload.GetNextAction())
+		return result
+	}
+
+	if resp := userCanSign(signature.Tid, workload.GetSigningRequestDelete(), workload.GetSignaturesDelete()); resp != nil {
+		result.Error = resp.Err().Error()
+		return result
+	}
+
+	deleting, err := a.pushDeleteSignature(ctx, db, id, signature)
+	if err != nil {
+		log.Error().Err(err).Int64("id", int64(id)).Msg("failed to push delete signature")
+		result.Error = "could not record the delete signature"
+		return result
+	}
+
+	result.State = types.BulkDeleteSigned
+	if deleting {
+		result.State = types.BulkDeleteDeleting
+	}
+
+	return result
+}
@@ -182,6 +182,9 @@ func (a *API) postConversionList(r *http.Request) (interface{}, mw.Response) {
 		expectedWls[i].SetSignatureFarmer(workloads.SigningSignature{})
 		workloaders[i].SetSignatureFarmer(workloads.SigningSignature{})
 
+		// converted workloads have no expiry time
+		resetExpiresAt(workloaders[i])
+
 		// truncate time to account for the lost nanosecond precision during json marshalling
 		workloaders[i].SetEpoch(schema.Date{Time: workloaders[i].GetEpoch().Time.Truncate(time.Second)})
 		expectedWls[i].SetEpoch(schema.Date{Time: expectedWls[i].GetEpoch().Time.Truncate(time.Second)})
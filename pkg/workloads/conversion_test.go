@@ -0,0 +1,185 @@
+package workloads
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/threefoldtech/tfexplorer/config"
+	"github.com/threefoldtech/tfexplorer/models/generated/workloads"
+	"github.com/threefoldtech/tfexplorer/pkg/workloads/types"
+	"github.com/threefoldtech/tfexplorer/schema"
+)
+
+func legacyReservation() types.Reservation {
+	ok := func(gwid, nodeID string) workloads.Result {
+		return workloads.Result{
+			WorkloadId: gwid,
+			NodeId:     nodeID,
+			State:      workloads.ResultStateOK,
+			Epoch:      schema.Date{Time: time.Now()},
+		}
+	}
+
+	reservation := types.Reservation{
+		ID:          10,
+		CustomerTid: 1,
+		NextAction:  workloads.NextActionDeploy,
+		Epoch:       schema.Date{Time: time.Now()},
+		Metadata:    "metadata",
+		Results: []workloads.Result{
+			ok("10-1", "node1"),
+			ok("10-2", "node1"),
+			ok("10-3", "node2"),
+			ok("10-4", "node1"),
+		},
+	}
+
+	data := &reservation.DataReservation
+	data.Description = "legacy"
+	data.SigningRequestDelete = workloads.SigningRequest{Signers: []int64{1}, QuorumMin: 1}
+	data.Containers = []workloads.Container{{
+		ReservationInfo: workloads.ReservationInfo{WorkloadId: 1, NodeId: "node1"},
+		Flist:           "https://hub.grid.tf/tf-official-apps/base:latest.flist",
+	}}
+	data.Volumes = []workloads.Volume{
+		{ReservationInfo: workloads.ReservationInfo{WorkloadId: 2, NodeId: "node1"}, Size: 10},
+		{ReservationInfo: workloads.ReservationInfo{WorkloadId: 3, NodeId: "node2"}, Size: 10},
+	}
+	data.Networks = []workloads.Network{{
+		Name:       "network",
+		WorkloadId: 4,
+		NetworkResources: []workloads.NetworkNetResource{
+			{NodeId: "node1", WireguardListenPort: 1000},
+			{NodeId: "node2", WireguardListenPort: 1001},
+		},
+	}}
+
+	return reservation
+}
+
+// convert returns the workloads the migration creates out of the legacy
+// reservations, once sent over by the customer
+func convert(t *testing.T, reservations ...types.Reservation) []types.WorkloaderType {
+	workloaders, err := loadWorkloaders(reservations)
+	require.NoError(t, err)
+
+	networks, err := loadNetworks(reservations)
+	require.NoError(t, err)
+
+	data, err := json.Marshal(append(workloaders, networks...))
+	require.NoError(t, err)
+
+	var converted []types.WorkloaderType
+	require.NoError(t, json.Unmarshal(data, &converted))
+
+	for _, workload := range converted {
+		resetExpiresAt(workload)
+	}
+
+	return converted
+}
+
+func TestConversionKeepsNodeWorkloads(t *testing.T) {
+	var api API
+	reservation := legacyReservation()
+	converted := convert(t, reservation)
+
+	for _, nodeID := range []string{"node1", "node2"} {
+		served := make(map[string]types.WorkloaderType)
+		for _, workload := range converted {
+			if workload.GetNodeID() != nodeID {
+				continue
+			}
+
+			// the converted workloads are served by the workloads pipeline
+			workload, err := api.workloadpipeline(workload, nil)
+			require.NoError(t, err)
+			assert.Equal(t, types.Deploy, workload.GetNextAction(), "workload %s is not served anymore", workload.GetReference())
+
+			served[workload.GetReference()] = workload
+		}
+
+		for _, legacy := range reservation.Workloads(nodeID) {
+			if legacy.GetWorkloadType() == workloads.WorkloadTypeNetwork {
+				// networks are converted to their network resources
+				workload, ok := served[legacy.UniqueWorkloadID()]
+				require.True(t, ok, "network of node %s is not converted", nodeID)
+				assert.Equal(t, workloads.WorkloadTypeNetworkResource, workload.GetWorkloadType())
+				continue
+			}
+
+			workload, ok := served[legacy.UniqueWorkloadID()]
+			require.True(t, ok, "workload %s of node %s is not converted", legacy.UniqueWorkloadID(), nodeID)
+			assert.Equal(t, legacy.GetWorkloadType(), workload.GetWorkloadType())
+			assert.Equal(t, legacy.GetCustomerTid(), workload.GetCustomerTid())
+			assert.Equal(t, legacy.GetResult().State, workload.GetResult().State)
+			assert.Equal(t, legacy.GetSigningRequestDelete(), workload.GetSigningRequestDelete())
+		}
+	}
+
+	var container *workloads.Container
+	for _, workload := range converted {
+		if c, ok := workload.Workloader.(*workloads.Container); ok {
+			container = c
+		}
+	}
+	require.NotNil(t, container)
+	assert.Equal(t, reservation.DataReservation.Containers[0].Flist, container.Flist)
+}
+
+func TestLegacyDisabled(t *testing.T) {
+	config.Config.DisableLegacy = true
+	defer func() { config.Config.DisableLegacy = false }()
+
+	var api API
+
+	// the legacy reservations are never found so the handlers use the workloads
+	_, err := api.reservation(context.Background(), nil, 1)
+	assert.True(t, errors.Is(err, mongo.ErrNoDocuments))
+
+	served, lastID, err := api.legacyWorkloads(context.Background(), nil, "node1", 10, 200)
+	require.NoError(t, err)
+	assert.Empty(t, served)
+	assert.Equal(t, schema.ID(10), lastID)
+
+	router := mux.NewRouter()
+	Routes(router, nil, "", nil, nil)
+
+	names := make(map[string]bool)
+	require.NoError(t, router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		names[route.GetName()] = true
+		return nil
+	}))
+
+	for _, name := range []string{
+		"reservation-list",
+		"reservation-get",
+		"reservation-sign-provision",
+		"reservation-sign-delete",
+		"conversion-list",
+		"conversion-post",
+		"versionned-conversion-list",
+		"versionned-conversion-post",
+	} {
+		assert.False(t, names[name], "legacy route %s is registered", name)
+	}
+
+	for _, name := range []string{
+		"nodes-workloads-poll",
+		"nodes-workload-get",
+		"nodes-workloads-results",
+		"nodes-workloads-deleted",
+		"versionned-workloads-poll",
+		"workload-sign-delete",
+	} {
+		assert.True(t, names[name], "route %s is not registered", name)
+	}
+}
@@ -0,0 +1,185 @@
+package workloads
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/tfexplorer/config"
+	"github.com/threefoldtech/tfexplorer/pkg/workloads/types"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// deliveryCheckInterval is the interval between the checks of the delivery
+	// of the workloads to the nodes
+	deliveryCheckInterval = time.Minute
+	// pollRecordInterval is the interval between the records of the polls of
+	// a node which keeps polling from the same id
+	pollRecordInterval = deliveryCheckInterval / 2
+	// fetchRetention is how long a fetch is remembered, a workload fetched
+	// again after that is only recorded again if the timeline misses it
+	fetchRetention = time.Hour
+)
+
+var (
+	nodePollLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "explorer",
+		Name:      "node_poll_lag",
+		Help:      "The newest workload id minus the id the node last polled its workloads from",
+	}, []string{"node_id"})
+
+	nodeUnfetchedWorkloads = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "explorer",
+		Name:      "node_unfetched_workloads",
+		Help:      "The number of workloads queued for the node which it did not fetch within the fetch timeout",
+	}, []string{"node_id"})
+)
+
+func init() {
+	prometheus.MustRegister(nodePollLag)
+	prometheus.MustRegister(nodeUnfetchedWorkloads)
+}
+
+type (
+	// deliveryTracker remembers the polls and the fetches recorded lately, so
+	// the nodes which poll their workloads over and over only write what
+	// changed
+	deliveryTracker struct {
+		m       sync.Mutex
+		polls   map[string]trackedPoll
+		fetched map[trackedFetch]time.Time
+		pruned  time.Time
+	}
+
+	trackedPoll struct {
+		from     schema.ID
+		recorded time.Time
+	}
+
+	trackedFetch struct {
+		id     schema.ID
+		nodeID string
+	}
+)
+
+func newDeliveryTracker() *deliveryTracker {
+	return &deliveryTracker{
+		polls:   make(map[string]trackedPoll),
+		fetched: make(map[trackedFetch]time.Time),
+		pruned:  time.Now(),
+	}
+}
+
+// pollChanged checks if the poll of the node must be recorded, the poll is
+// recorded when the node polls from another id, or to keep it active
+func (d *deliveryTracker) pollChanged(nodeID string, from schema.ID, now time.Time) bool {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	last, ok := d.polls[nodeID]
+	if ok && last.from == from && now.Sub(last.recorded) < pollRecordInterval {
+		return false
+	}
+
+	d.polls[nodeID] = trackedPoll{from: from, recorded: now}
+	return true
+}
+
+// firstFetch checks if the workload is sent to the node for the first time
+// since it is remembered
+func (d *deliveryTracker) firstFetch(id schema.ID, nodeID string, now time.Time) bool {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	if now.Sub(d.pruned) > fetchRetention {
+		for key, fetched := range d.fetched {
+			if now.Sub(fetched) > fetchRetention {
+				delete(d.fetched, key)
+			}
+		}
+		d.pruned = now
+	}
+
+	key := trackedFetch{id: id, nodeID: nodeID}
+	if _, ok := d.fetched[key]; ok {
+		return false
+	}
+
+	d.fetched[key] = now
+	return true
+}
+
+// recordPoll records the poll of the workloads by the node if it changed
+func (a *API) recordPoll(ctx context.Context, db *mongo.Database, nodeID string, from schema.ID) {
+	if a.deliveries.pollChanged(nodeID, from, time.Now()) {
+		types.NodePollSet(ctx, db, nodeID, from)
+	}
+}
+
+// recordFetch records the first fetch of the workload by the node
+func (a *API) recordFetch(ctx context.Context, db *mongo.Database, id schema.ID, nodeID string) {
+	if a.deliveries.firstFetch(id, nodeID, time.Now()) {
+		types.WorkloadFetchedPush(ctx, db, id, nodeID)
+	}
+}
+
+// WatchDeliveries periodically checks the nodes fetch the workloads queued
+// for them, until the context is done
+func WatchDeliveries(ctx context.Context, db *mongo.Database) {
+	ticker := time.NewTicker(deliveryCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := checkDeliveries(ctx, db, config.Config.FetchTimeout); err != nil {
+				log.Error().Err(err).Msg("failed to check the delivery of the workloads")
+			}
+		}
+	}
+}
+
+// checkDeliveries updates the lag of the nodes, and flags the workloads
+// which the active nodes did not fetch within the timeout
+func checkDeliveries(ctx context.Context, db *mongo.Database, timeout time.Duration) error {
+	lastID, err := types.WorkloadsLastID(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	polls, err := types.NodePolls(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	since := time.Now().Add(-timeout)
+	for _, poll := range polls {
+		nodePollLag.WithLabelValues(poll.NodeID).Set(float64(poll.Lag(lastID)))
+
+		// a node which stopped polling is down, its lag keeps growing
+		if timeout == 0 || !poll.Active(since) {
+			nodeUnfetchedWorkloads.DeleteLabelValues(poll.NodeID)
+			continue
+		}
+
+		ids, err := types.WorkloadsNotFetched(ctx, db, poll.NodeID, since)
+		if err != nil {
+			return err
+		}
+
+		nodeUnfetchedWorkloads.WithLabelValues(poll.NodeID).Set(float64(len(ids)))
+		for _, id := range ids {
+			if types.WorkloadNotFetchedPush(ctx, db, id, poll.NodeID) {
+				log.Warn().Int64("id", int64(id)).Str("node", poll.NodeID).Msg("workload not fetched by the node in time")
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package workloads
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeliveryTrackerPollChanged(t *testing.T) {
+	tracker := newDeliveryTracker()
+	now := time.Now()
+
+	assert.True(t, tracker.pollChanged("node1", 10, now), "the first poll is recorded")
+	assert.False(t, tracker.pollChanged("node1", 10, now.Add(time.Second)), "a poll from the same id is not recorded again")
+	assert.True(t, tracker.pollChanged("node2", 10, now), "the polls are tracked per node")
+	assert.True(t, tracker.pollChanged("node1", 11, now.Add(time.Second)), "a poll from another id is recorded")
+	assert.True(t, tracker.pollChanged("node1", 11, now.Add(pollRecordInterval+time.Second)), "the poll is recorded again to keep the node active")
+}
+
+func TestDeliveryTrackerFirstFetch(t *testing.T) {
+	tracker := newDeliveryTracker()
+	now := time.Now()
+
+	assert.True(t, tracker.firstFetch(1, "node1", now))
+	assert.False(t, tracker.firstFetch(1, "node1", now.Add(time.Second)), "a workload sent again is not recorded again")
+	assert.True(t, tracker.firstFetch(1, "node2", now))
+	assert.True(t, tracker.firstFetch(2, "node1", now))
+
+	later := now.Add(fetchRetention + time.Minute)
+	assert.True(t, tracker.firstFetch(1, "node1", later), "the fetches are forgotten after the retention")
+	assert.Len(t, tracker.fetched, 1)
+}
@@ -0,0 +1,152 @@
+package workloads
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	generated "github.com/threefoldtech/tfexplorer/models/generated/workloads"
+	"github.com/threefoldtech/tfexplorer/mw"
+	"github.com/threefoldtech/tfexplorer/pkg/workloads/types"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// resolveDependencies sets the workloads the workload depends on, the
+// references to unknown, foreign, failed or deleted workloads are refused
+func resolveDependencies(ctx context.Context, db *mongo.Database, workload types.WorkloaderType) mw.Response {
+	deps, err := types.Dependencies(ctx, db, workload)
+	if err != nil {
+		if errors.Is(err, types.ErrInvalidDependency) {
+			return mw.BadRequest(err)
+		}
+		return mw.Error(err)
+	}
+
+	workload.SetDependsOn(deps)
+
+	state, failed, err := types.WorkloadDependencyState(ctx, db, workload)
+	if err != nil {
+		return mw.Error(err)
+	}
+
+	if state == types.DependenciesFailed {
+		if failed != nil {
+			return mw.BadRequest(fmt.Errorf("dependency '%d' of the workload failed or is deleted", failed.GetID()))
+		}
+		return mw.BadRequest(fmt.Errorf("a dependency of the workload is deleted"))
+	}
+
+	return nil
+}
+
+// heldBack checks if a workload must wait for its dependencies before it is
+// sent to the node. It only reads the dependencies: a workload which
+// dependency failed or is deleted is failed by the handler which processes
+// the failure or the deletion, and is held back until then. Workloads already
+// deployed by the node are never held back
+func (a *API) heldBack(ctx context.Context, db *mongo.Database, workload types.WorkloaderType) (bool, error) {
+	// the queued workloads keep the action they were pushed with, only the
+	// deletions are never held back
+	if len(workload.GetDependsOn()) == 0 || workload.IsAny(types.Delete, types.Deleted, types.Invalid) {
+		return false, nil
+	}
+
+	if len(workload.GetResult().WorkloadId) != 0 {
+		return false, nil
+	}
+
+	state, _, err := types.WorkloadDependencyState(ctx, db, workload)
+	if err != nil {
+		return true, err
+	}
+
+	return state != types.DependenciesReady, nil
+}
+
+// workloadFailed releases the capacity and the public ip of a workload which
+// failed to deploy, and fails the workloads which depend on it
+func (a *API) workloadFailed(ctx context.Context, db *mongo.Database, workload types.WorkloaderType) error {
+	// remove capacity from pool
+	if err := a.capacityPlanner.RemoveUsedCapacity(workload); err != nil {
+		return errors.Wrap(err, "failed to decrease used capacity in pool")
+	}
+
+	if err := types.WorkloadSetNextAction(ctx, db, workload.GetID(), generated.NextActionDelete); err != nil {
+		return err
+	}
+
+	if workload.GetWorkloadType() == generated.WorkloadTypePublicIP {
+		if err := a.setFarmIPFree(ctx, db, workload); err != nil {
+			return err
+		}
+	}
+
+	if err := a.rollbackGroup(ctx, db, workload); err != nil {
+		return errors.Wrap(err, "failed to rollback the deployment group of the workload")
+	}
+
+	return a.failDependents(ctx, db, workload, fmt.Sprintf("dependency '%d' of the workload failed", workload.GetID()))
+}
+
+// failDependents fails the workloads which depend on the workload and which
+// are not deployed yet, they can't be deployed anymore. The dependents of
+// any version of the workload depend on its latest version
+func (a *API) failDependents(ctx context.Context, db *mongo.Database, workload types.WorkloaderType, message string) error {
+	versions, err := types.WorkloadFilter{}.WithVersionsOf(original(workload)).Find(ctx, db)
+	if err != nil {
+		return errors.Wrap(err, "failed to load the versions of the workload")
+	}
+
+	ids := []schema.ID{workload.GetID()}
+	for _, version := range versions {
+		if version.GetID() != workload.GetID() {
+			ids = append(ids, version.GetID())
+		}
+	}
+
+	dependents, err := types.WorkloadFilter{}.WithDependencyIn(ids).WithActive().Find(ctx, db)
+	if err != nil {
+		return errors.Wrap(err, "failed to load the workloads which depend on the workload")
+	}
+
+	for _, dependent := range dependents {
+		if len(dependent.GetResult().WorkloadId) != 0 {
+			// already deployed
+			continue
+		}
+
+		if err := a.dependencyFailed(ctx, db, dependent, message); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dependencyFailed sets an error result on a workload which can't be
+// deployed because of one of its dependencies
+func (a *API) dependencyFailed(ctx context.Context, db *mongo.Database, workload types.WorkloaderType, message string) error {
+	log.Debug().Int64("id", int64(workload.GetID())).Msg(message)
+
+	result := types.Result{
+		Category:   workload.GetWorkloadType(),
+		WorkloadId: workload.Workload().WorkloadId,
+		State:      generated.ResultStateError,
+		Message:    message,
+		Epoch:      schema.Date{Time: time.Now()},
+		NodeId:     workload.GetNodeID(),
+	}
+
+	if err := types.WorkloadResultPush(ctx, db, workload.GetID(), result); err != nil {
+		return err
+	}
+
+	if err := types.WorkloadPop(ctx, db, workload.GetID()); err != nil {
+		return err
+	}
+
+	return a.workloadFailed(ctx, db, workload)
+}
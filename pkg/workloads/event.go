@@ -0,0 +1,36 @@
+package workloads
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/tfexplorer/mw"
+	"github.com/threefoldtech/tfexplorer/pkg/workloads/types"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// events lists the timeline of a workload, from its creation to its deletion
+func (a *API) events(r *http.Request) (interface{}, mw.Response) {
+	id, err := a.parseID(mux.Vars(r)["res_id"])
+	if err != nil {
+		return nil, mw.BadRequest(fmt.Errorf("invalid reservation id"))
+	}
+
+	db := mw.Database(r)
+	_, err = types.WorkloadFilter{}.WithID(id).Get(r.Context(), db)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, mw.NotFound(err)
+		}
+		return nil, mw.Error(err)
+	}
+
+	events, err := types.WorkloadEvents(r.Context(), db, id)
+	if err != nil {
+		return nil, mw.Error(err)
+	}
+
+	return events, nil
+}
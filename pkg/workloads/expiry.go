@@ -0,0 +1,78 @@
+package workloads
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/tfexplorer/config"
+	"github.com/threefoldtech/tfexplorer/mw"
+	"github.com/threefoldtech/tfexplorer/pkg/workloads/types"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// workloadExpiry extends the expiry time of a workload
+func (a *API) workloadExpiry(r *http.Request) (interface{}, mw.Response) {
+	defer r.Body.Close()
+
+	requestUserID, resp := requestUser(r)
+	if resp != nil {
+		return nil, resp
+	}
+
+	id, err := a.parseID(mux.Vars(r)["res_id"])
+	if err != nil {
+		return nil, mw.BadRequest(fmt.Errorf("invalid reservation id"))
+	}
+
+	var extension types.ExpiryExtension
+	if err := json.NewDecoder(r.Body).Decode(&extension); err != nil {
+		return nil, mw.BadRequest(err)
+	}
+
+	if skew := config.Config.AuthMaxSkew; skew > 0 {
+		if age := time.Since(extension.Epoch.Time); age > skew || age < -skew {
+			return nil, mw.UnAuthorized(fmt.Errorf("expiry extension is too old or in the future"))
+		}
+	}
+
+	db := mw.Database(r)
+	workload, err := a.workloadpipeline(types.WorkloadFilter{}.WithID(id).Get(r.Context(), db))
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, mw.NotFound(err)
+		}
+		return nil, mw.Error(err)
+	}
+
+	if workload.GetCustomerTid() != requestUserID {
+		return nil, mw.UnAuthorized(fmt.Errorf("request user identity does not match the workload customer-tid"))
+	}
+
+	if workload.IsAny(types.Delete, types.Deleted, types.Invalid) {
+		return nil, mw.Conflict(fmt.Errorf("workload '%d' is in state '%s'", id, workload.GetNextAction()))
+	}
+
+	if err := extension.Validate(workload); err != nil {
+		return nil, mw.BadRequest(err)
+	}
+
+	pubkey, resp := customerKey(r, db, workload.GetCustomerTid(), extension.Epoch.Time, workload.GetPoolID())
+	if resp != nil {
+		return nil, resp
+	}
+
+	if err := extension.Verify(id, pubkey); err != nil {
+		return nil, mw.BadRequest(errors.Wrap(err, "failed to verify the expiry extension signature"))
+	}
+
+	if err := types.WorkloadSetExpiresAt(r.Context(), db, id, extension.ExpiresAt); err != nil {
+		return nil, mw.Error(err)
+	}
+
+	workload.SetExpiresAt(extension.ExpiresAt)
+	return workload, nil
+}
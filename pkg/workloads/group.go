@@ -0,0 +1,305 @@
+package workloads
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	generated "github.com/threefoldtech/tfexplorer/models/generated/workloads"
+	"github.com/threefoldtech/tfexplorer/mw"
+	capacitytypes "github.com/threefoldtech/tfexplorer/pkg/capacity/types"
+	"github.com/threefoldtech/tfexplorer/pkg/workloads/types"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"github.com/zaibon/httpsig"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DeploymentGroupCreateResponse wraps deployment group create response
+type DeploymentGroupCreateResponse struct {
+	ID schema.ID `json:"group_id"`
+	// WorkloadIDs are the ids of the created workloads, in the order of
+	// the workloads of the request
+	WorkloadIDs []schema.ID `json:"workload_ids"`
+}
+
+// validateGroupWorkload runs the checks of a new workload, before any
+// workload of the group is saved
+func (a *API) validateGroupWorkload(r *http.Request, db *mongo.Database, workload types.WorkloaderType) (types.WorkloaderType, mw.Response) {
+	resetWorkload(workload)
+
+	if err := workload.Validate(); err != nil {
+		return workload, mw.BadRequest(err)
+	}
+
+	if workload.GetOriginal() != 0 {
+		return workload, mw.BadRequest(fmt.Errorf("a deployment group can't update workloads"))
+	}
+
+	workload, err := a.workloadpipeline(workload, nil)
+	if err != nil {
+		return workload, mw.BadRequest(err)
+	}
+
+	// force next action to create.
+	workload.SetNextAction(generated.NextActionCreate)
+
+	if err := verifyCustomerSignature(r, db, workload); err != nil {
+		return workload, err
+	}
+
+	allowed, err := a.capacityPlanner.IsAllowed(workload)
+	if err != nil {
+		if errors.Is(err, capacitytypes.ErrPoolNotFound) {
+			return workload, mw.NotFound(fmt.Errorf("pool '%d' does not exist", workload.GetPoolID()))
+		}
+		log.Error().Err(err).Msg("failed to load workload capacity pool")
+		return workload, mw.Error(errors.New("could not load the required capacity pool"))
+	}
+
+	if !allowed {
+		return workload, mw.Forbidden(fmt.Errorf("not allowed to deploy workload on pool '%d'", workload.GetPoolID()))
+	}
+
+	if workload.GetWorkloadType() == generated.WorkloadTypeKubernetes {
+		if err := a.handleKubernetesSize(r.Context(), db, workload); err != nil {
+			return workload, err
+		}
+	}
+
+	if ip := publicIP(workload); ip != 0 {
+		if err := checkPublicIPAvailablity(r.Context(), db, ip, workload.GetCustomerTid()); err != nil {
+			return workload, err
+		}
+	}
+
+	return workload, nil
+}
+
+// createGroup creates all the workloads of a deployment group, or none of
+// them if one of the workloads is refused
+func (a *API) createGroup(r *http.Request) (interface{}, mw.Response) {
+	defer r.Body.Close()
+
+	requestUserID, err := strconv.ParseInt(httpsig.KeyIDFromContext(r.Context()), 10, 64)
+	if err != nil {
+		return nil, mw.BadRequest(errors.Wrap(err, "failed to parse request user id"))
+	}
+
+	var group types.DeploymentGroup
+	if err := json.NewDecoder(r.Body).Decode(&group); err != nil {
+		return nil, mw.BadRequest(err)
+	}
+
+	if group.CustomerTid != requestUserID {
+		return nil, mw.UnAuthorized(fmt.Errorf("request user identity does not match the group customer-tid"))
+	}
+
+	if err := group.Validate(); err != nil {
+		return nil, mw.BadRequest(err)
+	}
+
+	db := mw.Database(r)
+
+	pools := make([]int64, 0, len(group.Workloads))
+	for _, workload := range group.Workloads {
+		pools = append(pools, workload.GetPoolID())
+	}
+
+	// the epoch of the group is not signed, so the group is verified with the
+	// current key
+	pubkey, resp := customerKey(r, db, group.CustomerTid, time.Now(), pools...)
+	if resp != nil {
+		return nil, resp
+	}
+
+	if err := group.Verify(pubkey); err != nil {
+		return nil, mw.BadRequest(errors.Wrap(err, "failed to verify the group signature"))
+	}
+
+	ips := make(map[schema.ID]bool)
+	capacity := make([]generated.Workloader, 0, len(group.Workloads))
+	for i := range group.Workloads {
+		workload, resp := a.validateGroupWorkload(r, db, group.Workloads[i])
+		if resp != nil {
+			log.Debug().Int("index", i).Msg("workload of the group refused")
+			return nil, resp
+		}
+
+		if ip := publicIP(workload); ip != 0 {
+			if ips[ip] {
+				return nil, mw.Conflict(fmt.Errorf("workload %d of the group uses public ip '%d' which is used by another workload of the group", i, ip))
+			}
+			ips[ip] = true
+		}
+
+		group.Workloads[i] = workload
+		capacity = append(capacity, workload.Workloader)
+	}
+
+	allowed, err := a.capacityPlanner.HasGroupCapacity(capacity, minCapacitySeconds)
+	if err != nil {
+		if errors.Is(err, capacitytypes.ErrPoolNotFound) {
+			return nil, mw.NotFound(errors.New("pool does not exist"))
+		}
+		log.Error().Err(err).Msg("failed to load workload capacity pool")
+		return nil, mw.Error(errors.New("could not load the required capacity pool"))
+	}
+
+	if !allowed {
+		return nil, mw.PaymentRequired(errors.New("pools need additional capacity to support the workloads of the group"))
+	}
+
+	now := schema.Date{Time: time.Now()}
+	group.Epoch = now
+	group.WorkloadIDs = make([]schema.ID, 0, len(group.Workloads))
+	// saved and reserved are the workloads and the public ips to roll back if
+	// the group can't be created
+	var saved, reserved []types.WorkloaderType
+	for _, workload := range group.Workloads {
+		workload.SetEpoch(now)
+
+		// the workloads of the group can depend on the workloads created
+		// before them in the group
+		if resp := resolveDependencies(r.Context(), db, workload); resp != nil {
+			a.invalidateGroup(r.Context(), db, saved, reserved)
+			return nil, resp
+		}
+
+		id, err := types.WorkloadCreate(r.Context(), db, workload)
+		if err != nil {
+			log.Error().Err(err).Msg("could not create workload")
+			a.invalidateGroup(r.Context(), db, saved, reserved)
+			return nil, mw.Error(err)
+		}
+		group.WorkloadIDs = append(group.WorkloadIDs, id)
+		saved = append(saved, workload)
+	}
+
+	for _, workload := range group.Workloads {
+		if workload.GetWorkloadType() != generated.WorkloadTypePublicIP {
+			continue
+		}
+
+		if err := a.handlePublicIPReservation(r.Context(), db, workload); err != nil {
+			a.invalidateGroup(r.Context(), db, saved, reserved)
+			return nil, err
+		}
+		reserved = append(reserved, workload)
+	}
+
+	id, err := types.GroupCreate(r.Context(), db, group)
+	if err != nil {
+		log.Error().Err(err).Msg("could not create deployment group")
+		a.invalidateGroup(r.Context(), db, saved, reserved)
+		return nil, mw.Error(err)
+	}
+
+	for _, workload := range group.Workloads {
+		if err := types.WorkloadToDeploy(r.Context(), db, workload); err != nil {
+			log.Error().Err(err).Msg("failed to schedule the reservation to deploy")
+			a.invalidateGroup(r.Context(), db, saved, reserved)
+			return nil, mw.Error(errors.New("could not schedule reservation to deploy"))
+		}
+	}
+
+	return DeploymentGroupCreateResponse{ID: id, WorkloadIDs: group.WorkloadIDs}, mw.Created()
+}
+
+// invalidateGroup rolls back the workloads already saved of a group which
+// could not be created. They are marked as invalid and removed from the queue
+// of their node, so they are never deployed, and the public ips reserved for
+// them are released
+func (a *API) invalidateGroup(ctx context.Context, db *mongo.Database, saved, reserved []types.WorkloaderType) {
+	for _, workload := range saved {
+		id := workload.GetID()
+		if err := types.WorkloadPop(ctx, db, id); err != nil {
+			log.Error().Err(err).Int64("id", int64(id)).Msg("failed to remove workload of the group from the queue")
+		}
+
+		if err := types.WorkloadSetNextAction(ctx, db, id, types.Invalid); err != nil {
+			log.Error().Err(err).Int64("id", int64(id)).Msg("failed to mark workload of the group as invalid")
+		}
+	}
+
+	for _, workload := range reserved {
+		if err := a.setFarmIPFree(ctx, db, workload); err != nil {
+			log.Error().Err(err).Int64("id", int64(workload.GetID())).Msg("failed to release public ip of the group")
+		}
+	}
+}
+
+func (a *API) getGroup(r *http.Request) (interface{}, mw.Response) {
+	id, err := a.parseID(mux.Vars(r)["id"])
+	if err != nil {
+		return nil, mw.BadRequest(fmt.Errorf("invalid group id"))
+	}
+
+	db := mw.Database(r)
+	group, err := types.GroupFilter{}.WithID(id).Get(r.Context(), db)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, mw.NotFound(err)
+		}
+		return nil, mw.Error(err)
+	}
+
+	workloads, err := types.WorkloadFilter{}.WithIDs(group.WorkloadIDs).Find(r.Context(), db)
+	if err != nil {
+		return nil, mw.Error(err)
+	}
+
+	group.Workloads = make([]types.WorkloaderType, 0, len(workloads))
+	for _, workload := range workloads {
+		workload, err := a.workloadpipeline(workload, nil)
+		if err != nil {
+			return nil, mw.Error(err)
+		}
+		group.Workloads = append(group.Workloads, workload)
+	}
+	group.State = types.GroupStateOf(group.Workloads)
+
+	return group, nil
+}
+
+// rollbackGroup deletes the other workloads of the group of a failed
+// workload, if the group has the rollback policy
+func (a *API) rollbackGroup(ctx context.Context, db *mongo.Database, failed types.WorkloaderType) error {
+	group, err := types.GroupFilter{}.WithWorkloadID(failed.GetID()).Get(ctx, db)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	} else if err != nil {
+		return errors.Wrap(err, "failed to load the group of the workload")
+	}
+
+	if !group.Rollback {
+		return nil
+	}
+
+	siblings, err := types.WorkloadFilter{}.WithIDs(group.WorkloadIDs).Find(ctx, db)
+	if err != nil {
+		return errors.Wrap(err, "failed to load the workloads of the group")
+	}
+
+	for _, sibling := range siblings {
+		if sibling.GetID() == failed.GetID() || sibling.IsAny(types.Delete, types.Deleted, types.Invalid) {
+			continue
+		}
+
+		log.Debug().Int64("group", int64(group.ID)).Int64("workload", int64(sibling.GetID())).Msg("rollback workload of the group")
+		sibling.SetNextAction(types.Delete)
+		if err := types.WorkloadSetNextAction(ctx, db, sibling.GetID(), types.Delete); err != nil {
+			return errors.Wrap(err, "could not set workload to delete state")
+		}
+		if err := types.WorkloadPush(ctx, db, sibling); err != nil {
+			return errors.Wrap(err, "could not push workload to delete in workload queue")
+		}
+	}
+
+	return nil
+}
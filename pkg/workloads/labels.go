@@ -0,0 +1,147 @@
+package workloads
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/tfexplorer/config"
+	"github.com/threefoldtech/tfexplorer/mw"
+	capacitytypes "github.com/threefoldtech/tfexplorer/pkg/capacity/types"
+	"github.com/threefoldtech/tfexplorer/pkg/workloads/types"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"github.com/zaibon/httpsig"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// decodeLabelsUpdate decodes and validates the labels update of the request
+func decodeLabelsUpdate(r *http.Request) (types.LabelsUpdate, mw.Response) {
+	var update types.LabelsUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		return update, mw.BadRequest(err)
+	}
+
+	if err := update.Labels.Validate(); err != nil {
+		return update, mw.BadRequest(err)
+	}
+
+	if skew := config.Config.AuthMaxSkew; skew > 0 {
+		if age := time.Since(update.Epoch.Time); age > skew || age < -skew {
+			return update, mw.UnAuthorized(fmt.Errorf("labels update is too old or in the future"))
+		}
+	}
+
+	return update, nil
+}
+
+// requestUser returns the id of the user who signed the request
+func requestUser(r *http.Request) (int64, mw.Response) {
+	id, err := strconv.ParseInt(httpsig.KeyIDFromContext(r.Context()), 10, 64)
+	if err != nil {
+		return 0, mw.BadRequest(errors.Wrap(err, "failed to parse request user id"))
+	}
+
+	return id, nil
+}
+
+// workloadLabels replaces the labels of a workload
+func (a *API) workloadLabels(r *http.Request) (interface{}, mw.Response) {
+	defer r.Body.Close()
+
+	requestUserID, resp := requestUser(r)
+	if resp != nil {
+		return nil, resp
+	}
+
+	id, err := a.parseID(mux.Vars(r)["res_id"])
+	if err != nil {
+		return nil, mw.BadRequest(fmt.Errorf("invalid reservation id"))
+	}
+
+	update, resp := decodeLabelsUpdate(r)
+	if resp != nil {
+		return nil, resp
+	}
+
+	db := mw.Database(r)
+	workload, err := types.WorkloadFilter{}.WithID(id).Get(r.Context(), db)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, mw.NotFound(err)
+		}
+		return nil, mw.Error(err)
+	}
+
+	if workload.GetCustomerTid() != requestUserID {
+		return nil, mw.UnAuthorized(fmt.Errorf("request user identity does not match the workload customer-tid"))
+	}
+
+	pubkey, resp := customerKey(r, db, workload.GetCustomerTid(), update.Epoch.Time, workload.GetPoolID())
+	if resp != nil {
+		return nil, resp
+	}
+
+	if err := update.Verify(types.LabelResourceWorkload, id, pubkey); err != nil {
+		return nil, mw.BadRequest(errors.Wrap(err, "failed to verify the labels update signature"))
+	}
+
+	if err := types.WorkloadSetLabels(r.Context(), db, id, update.Labels); err != nil {
+		return nil, mw.Error(err)
+	}
+
+	workload.SetLabels(update.Labels)
+	return workload, nil
+}
+
+// poolLabels replaces the labels of a capacity pool
+func (a *API) poolLabels(r *http.Request) (interface{}, mw.Response) {
+	defer r.Body.Close()
+
+	requestUserID, resp := requestUser(r)
+	if resp != nil {
+		return nil, resp
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return nil, mw.BadRequest(errors.New("id must be an integer"))
+	}
+
+	update, resp := decodeLabelsUpdate(r)
+	if resp != nil {
+		return nil, resp
+	}
+
+	pool, err := a.capacityPlanner.PoolByID(id)
+	if err != nil {
+		if errors.Is(err, capacitytypes.ErrPoolNotFound) {
+			return nil, mw.NotFound(errors.New("capacity pool not found"))
+		}
+		return nil, mw.Error(err)
+	}
+
+	if pool.CustomerTid != requestUserID {
+		return nil, mw.UnAuthorized(fmt.Errorf("request user identity does not match the pool owner"))
+	}
+
+	db := mw.Database(r)
+	pubkey, resp := customerKey(r, db, pool.CustomerTid, update.Epoch.Time, id)
+	if resp != nil {
+		return nil, resp
+	}
+
+	if err := update.Verify(types.LabelResourcePool, schema.ID(id), pubkey); err != nil {
+		return nil, mw.BadRequest(errors.Wrap(err, "failed to verify the labels update signature"))
+	}
+
+	if err := capacitytypes.PoolSetLabels(r.Context(), db, schema.ID(id), update.Labels); err != nil {
+		return nil, mw.Error(err)
+	}
+
+	pool.Labels = update.Labels
+	return pool, nil
+}
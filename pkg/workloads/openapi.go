@@ -0,0 +1,100 @@
+package workloads
+
+import (
+	"net/http"
+
+	generated "github.com/threefoldtech/tfexplorer/models/generated/workloads"
+	capacitytypes "github.com/threefoldtech/tfexplorer/pkg/capacity/types"
+	escrowtypes "github.com/threefoldtech/tfexplorer/pkg/escrow/types"
+	"github.com/threefoldtech/tfexplorer/pkg/openapi"
+	"github.com/threefoldtech/tfexplorer/pkg/workloads/types"
+)
+
+func init() {
+	// the workloads are encoded by their own MarshalJSON, the fields depend
+	// on the workload_type
+	openapi.Define(types.WorkloaderType{}, openapi.Schema{
+		Type:        "object",
+		Description: "a workload, the fields depend on its workload_type",
+	})
+}
+
+var workloadListQuery = []string{
+	"customer_tid", "next_action", "workload_type", "pool_id", "node_id", "farm_id",
+	"from_epoch", "to_epoch", "result_state", "metadata", "labels", "active", "sort",
+}
+
+var workloadListHeaders = map[string]string{
+	statesHeader: "number of workloads matching the search in each result state, as state=count pairs separated by commas",
+}
+
+// Operations documents the versioned workloads routes
+var Operations = openapi.Operations{
+	"prices-get": {Summary: "Get the prices of the capacity of a farm", Response: priceList{}, Query: []string{"farm"}},
+
+	"pricing-rules-list":   {Summary: "List the pricing rules", Response: []escrowtypes.PricingRule{}, Query: []string{"farm", "type"}},
+	"pricing-rules-create": {Summary: "Create a pricing rule", Request: escrowtypes.PricingRule{}, Response: escrowtypes.PricingRule{}, Status: http.StatusCreated},
+	"pricing-rules-get":    {Summary: "Get a pricing rule", Response: escrowtypes.PricingRule{}},
+	"pricing-rules-update": {
+		Summary: "Enable or disable a pricing rule",
+		Request: struct {
+			Enabled bool `json:"enabled"`
+		}{},
+		Response: escrowtypes.PricingRule{},
+	},
+	"pricing-rules-delete": {Summary: "Delete a pricing rule", Status: http.StatusNoContent},
+
+	"versionned-pool-create": {
+		Summary:  "Reserve capacity in a pool",
+		Request:  capacitytypes.Reservation{},
+		Response: CapacityPoolCreateResponse{},
+		Status:   http.StatusCreated,
+	},
+	"versionned-pool-get":              {Summary: "Get a capacity pool", Response: capacitytypes.Pool{}},
+	"versionned-pool-get-by-owner":     {Summary: "List the capacity pools of a user", Response: []capacitytypes.Pool{}, Query: []string{"labels"}, Paginated: true},
+	"versionned-pool-labels":           {Summary: "Replace the labels of a capacity pool", Request: types.LabelsUpdate{}, Response: capacitytypes.Pool{}},
+	"versionned-pool-get-payment-info": {Summary: "Get the payment information of a capacity reservation", Response: escrowtypes.CapacityReservationPaymentInformation{}},
+	"versionned-pool-receipts-list": {
+		Summary:   "List the receipts of the capacity pools of the user",
+		Response:  []escrowtypes.CapacityReceipt{},
+		Query:     []string{"pool"},
+		Paginated: true,
+	},
+	"versionned-pool-receipt-get": {Summary: "Get a capacity receipt", Response: escrowtypes.CapacityReceipt{}},
+
+	"versionned-workloads-create": {
+		Summary:  "Create a workload",
+		Request:  types.WorkloaderType{},
+		Response: ReservationCreateResponse{},
+		Status:   http.StatusCreated,
+	},
+	"versionned-workloadreservation-list": {
+		Summary:   "Search workloads",
+		Response:  []types.WorkloaderType{},
+		Query:     workloadListQuery,
+		Paginated: true,
+		Headers:   workloadListHeaders,
+	},
+	"versionned-workloadreservation-get":    {Summary: "Get a workload", Response: types.WorkloaderType{}},
+	"versionned-reservation-sign-provision": {Summary: "Sign the provisioning of a workload", Request: generated.SigningSignature{}, Status: http.StatusCreated},
+	"versionned-reservation-sign-delete":    {Summary: "Sign the deletion of a workload", Request: generated.SigningSignature{}, Status: http.StatusCreated},
+	"versionned-workloads-bulk-delete":      {Summary: "Sign the deletion of many workloads, selected by id or by pool and labels", Request: types.BulkDelete{}, Response: BulkDeleteResponse{}},
+	"versionned-workload-update":            {Summary: "Create a new version of a deployed workload, replacing it in place", Request: types.WorkloaderType{}, Response: ReservationCreateResponse{}, Status: http.StatusCreated},
+	"versionned-workload-labels":            {Summary: "Replace the labels of a workload", Request: types.LabelsUpdate{}, Response: types.WorkloaderType{}},
+	"versionned-workload-expiry":            {Summary: "Extend the expiry time of a workload", Request: types.ExpiryExtension{}, Response: types.WorkloaderType{}},
+	"versionned-workload-versions":          {Summary: "List all the versions of a workload, ordered by revision", Response: []types.WorkloaderType{}},
+	"versionned-workload-events":            {Summary: "List the timeline of a workload, from its creation to its deletion", Response: []types.WorkloadEvent{}},
+	"versionned-group-create":               {Summary: "Create a group of workloads validated and deployed together", Request: types.DeploymentGroup{}, Response: DeploymentGroupCreateResponse{}, Status: http.StatusCreated},
+	"versionned-group-get":                  {Summary: "Get a deployment group with the aggregated state of its workloads", Response: types.DeploymentGroup{}},
+	"versionned-approvals-list":             {Summary: "List the workloads awaiting the signature of the user, with the progress of the quorum", Response: []types.Approval{}, Query: []string{"kind"}},
+	"versionned-approval-approve":           {Summary: "Sign the signing request of a workload with a comment", Request: types.ApprovalRequest{}, Response: types.Approval{}},
+	"versionned-approval-reject":            {Summary: "Reject the signing request of a workload with a comment", Request: types.ApprovalRequest{}, Response: types.Approval{}},
+
+	"versionned-conversion-list": {Summary: "List the legacy reservations of the user converted to workloads", Response: []types.WorkloaderType{}},
+	"versionned-conversion-post": {Summary: "Save the signed conversion of the legacy reservations of the user", Request: []types.WorkloaderType{}},
+
+	"versionned-workloads-poll":    {Summary: "Poll the workloads of a node", Response: []types.WorkloaderType{}},
+	"versionned-workload-get":      {Summary: "Get a workload by its global id", Response: types.WorkloaderType{}},
+	"versionned-workloads-results": {Summary: "Report the result of the deployment of a workload", Request: types.Result{}, Status: http.StatusCreated},
+	"versionned-workloads-deleted": {Summary: "Report the deletion of a workload by a node", Request: types.DeletedReport{}},
+}
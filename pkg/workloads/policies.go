@@ -0,0 +1,94 @@
+package workloads
+
+import (
+	"github.com/threefoldtech/tfexplorer/mw"
+	phonebook "github.com/threefoldtech/tfexplorer/pkg/phonebook/types"
+)
+
+var (
+	anonymous = mw.Policy{Identity: mw.Anonymous}
+	user      = mw.Policy{Identity: mw.User}
+	// the user identity of the request must be the one of the signed
+	// workload, deploy tokens can create workloads for the user
+	deployer = mw.Policy{Identity: mw.User, Scopes: []phonebook.Scope{phonebook.ScopeDeploy}}
+	// pool tokens can manage the pools of the user
+	poolManager = mw.Policy{Identity: mw.User, Scopes: []phonebook.Scope{phonebook.ScopePool}}
+	node        = mw.Policy{Identity: mw.Node}
+	// legacyNode routes were called without a signature before, unsigned
+	// requests are accepted until the explorer requires node signatures
+	legacyNode = mw.Policy{Identity: mw.Node, AllowUnsigned: true}
+	// the pricing rules are managed by the admins and the farmers, the
+	// handlers check the farm of the rule
+	farmer = mw.Policy{Identity: mw.Farmer}
+)
+
+// Policies declares who can call the workloads routes. The pools, the
+// workload signatures and the node results carry the signature in their
+// payload, the handlers verify it
+var Policies = mw.Policies{
+	"prices-get": anonymous,
+
+	"pricing-rules-list":   farmer,
+	"pricing-rules-create": farmer,
+	"pricing-rules-get":    farmer,
+	"pricing-rules-update": farmer,
+	"pricing-rules-delete": farmer,
+
+	"versionned-pool-create":           anonymous,
+	"versionned-pool-get":              anonymous,
+	"versionned-pool-get-by-owner":     anonymous,
+	"versionned-pool-get-payment-info": anonymous,
+	"versionned-pool-receipts-list":    user,
+	"versionned-pool-receipt-get":      user,
+	"versionned-pool-labels":           poolManager,
+
+	"versionned-workloads-create":           deployer,
+	"versionned-workloadreservation-list":   anonymous,
+	"versionned-workloadreservation-get":    anonymous,
+	"versionned-reservation-sign-provision": anonymous,
+	"versionned-reservation-sign-delete":    anonymous,
+	"versionned-workloads-bulk-delete":      anonymous,
+	"versionned-workload-update":            deployer,
+	"versionned-workload-versions":          anonymous,
+	"versionned-workload-events":            anonymous,
+	"versionned-workload-labels":            deployer,
+	"versionned-workload-expiry":            deployer,
+	"versionned-group-create":               deployer,
+	"versionned-group-get":                  anonymous,
+	"versionned-approvals-list":             user,
+	"versionned-approval-approve":           user,
+	"versionned-approval-reject":            user,
+
+	"versionned-conversion-list": user,
+	"versionned-conversion-post": user,
+
+	"versionned-workloads-poll":    anonymous,
+	"versionned-workload-get":      anonymous,
+	"versionned-workloads-results": legacyNode,
+	"versionned-workloads-deleted": legacyNode,
+
+	// legacy endpoints
+	"reservation-create":         deployer,
+	"reservation-list":           anonymous,
+	"reservation-get":            anonymous,
+	"reservation-sign-provision": anonymous,
+	"reservation-sign-delete":    anonymous,
+
+	"workload-create":         deployer,
+	"workload-list":           anonymous,
+	"workload-get":            anonymous,
+	"workload-sign-provision": anonymous,
+	"workload-sign-delete":    anonymous,
+
+	"pool-create":       anonymous,
+	"pool-get":          anonymous,
+	"pool-get-by-owner": anonymous,
+
+	"conversion-list": user,
+	"conversion-post": user,
+
+	"nodes-workloads-poll":    anonymous,
+	"nodes-workload-get":      anonymous,
+	"nodes-workloads-results": legacyNode,
+	"nodes-workloads-deleted": legacyNode,
+}
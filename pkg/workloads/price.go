@@ -4,30 +4,41 @@ import (
 	"net/http"
 	"sync"
 
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/tfexplorer/models"
 	"github.com/threefoldtech/tfexplorer/mw"
+	directory "github.com/threefoldtech/tfexplorer/pkg/directory/types"
 	"github.com/threefoldtech/tfexplorer/pkg/escrow"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
+type priceList struct {
+	CuPriceDollarMonth   float64
+	SuPriceDollarMonth   float64
+	TftPriceMill         float64
+	IP4uPriceDollarMonth float64
+}
+
 var (
 	pricesOnce sync.Once
-	prices     struct {
-		CuPriceDollarMonth   float64
-		SuPriceDollarMonth   float64
-		TftPriceMill         float64
-		IP4uPriceDollarMonth float64
-	}
+	prices     priceList
 )
 
+func (a *API) divisor() float64 {
+	div, err := a.network.Divisor()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get network divisor")
+		div = 1
+	}
+
+	return float64(div)
+}
+
 func (a *API) getPrices(r *http.Request) (interface{}, mw.Response) {
 	pricesOnce.Do(func() {
-		div, err := a.network.Divisor()
-		if err != nil {
-			log.Error().Err(err).Msg("failed to get network divisor")
-			div = 1
-		}
-
-		divisor := float64(div)
+		divisor := a.divisor()
 
 		prices.CuPriceDollarMonth = float64(escrow.CuPriceDollarMonth) / divisor
 		prices.SuPriceDollarMonth = float64(escrow.SuPriceDollarMonth) / divisor
@@ -35,5 +46,37 @@ func (a *API) getPrices(r *http.Request) (interface{}, mw.Response) {
 		prices.IP4uPriceDollarMonth = float64(escrow.IP4uPriceDollarMonth) / divisor
 	})
 
-	return prices, nil
+	farmID, err := models.QueryInt(r, "farm")
+	if err != nil {
+		return nil, mw.BadRequest(errors.Wrap(err, "farm should be an integer"))
+	}
+
+	if farmID == 0 {
+		return prices, nil
+	}
+
+	var filter directory.FarmFilter
+	farm, err := filter.WithID(schema.ID(farmID)).Get(r.Context(), mw.Database(r))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, mw.NotFound(errors.New("farm not found"))
+	} else if err != nil {
+		return nil, mw.Error(err)
+	}
+
+	// farms without their own prices use the grid wide prices
+	if !farm.EnableCustomPricing {
+		return prices, nil
+	}
+
+	return farmPrices(farm, a.divisor()), nil
+}
+
+// farmPrices returns the prices of a farm with custom pricing
+func farmPrices(farm directory.Farm, divisor float64) priceList {
+	return priceList{
+		CuPriceDollarMonth:   farm.FarmCloudUnitsPrice.CU / divisor,
+		SuPriceDollarMonth:   farm.FarmCloudUnitsPrice.SU / divisor,
+		TftPriceMill:         escrow.TftPriceMill,
+		IP4uPriceDollarMonth: farm.FarmCloudUnitsPrice.IPv4U / divisor,
+	}
 }
@@ -0,0 +1,24 @@
+package workloads
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	generated "github.com/threefoldtech/tfexplorer/models/generated/directory"
+	directory "github.com/threefoldtech/tfexplorer/pkg/directory/types"
+	"github.com/threefoldtech/tfexplorer/pkg/escrow"
+)
+
+func TestFarmPrices(t *testing.T) {
+	farm := directory.Farm{
+		EnableCustomPricing: true,
+		FarmCloudUnitsPrice: generated.NodeCloudUnitPrice{CU: 20, SU: 10, IPv4U: 6},
+	}
+
+	assert.Equal(t, priceList{
+		CuPriceDollarMonth:   10,
+		SuPriceDollarMonth:   5,
+		TftPriceMill:         escrow.TftPriceMill,
+		IP4uPriceDollarMonth: 3,
+	}, farmPrices(farm, 2))
+}
@@ -0,0 +1,172 @@
+package workloads
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/tfexplorer/config"
+	"github.com/threefoldtech/tfexplorer/models"
+	"github.com/threefoldtech/tfexplorer/mw"
+	directory "github.com/threefoldtech/tfexplorer/pkg/directory/types"
+	escrowtypes "github.com/threefoldtech/tfexplorer/pkg/escrow/types"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"github.com/zaibon/httpsig"
+)
+
+// authorizePricingRules checks that the user making the request can manage the
+// pricing rules of a farm. Rules for all farms (farm 0) can only be managed by
+// the admins, rules of a farm can also be managed by the farmer
+func (a *API) authorizePricingRules(r *http.Request, farmID schema.ID) (int64, mw.Response) {
+	userTid, err := strconv.ParseInt(httpsig.KeyIDFromContext(r.Context()), 10, 64)
+	if err != nil {
+		return 0, mw.BadRequest(errors.Wrap(err, "failed to parse request user id"))
+	}
+
+	if config.IsAdmin(userTid) {
+		return userTid, nil
+	}
+
+	if farmID == 0 {
+		return 0, mw.Forbidden(fmt.Errorf("only admins can manage the pricing rules of all farms"))
+	}
+
+	var filter directory.FarmFilter
+	farm, err := filter.WithID(farmID).Get(r.Context(), mw.Database(r))
+	if err != nil {
+		return 0, mw.NotFound(errors.Wrap(err, "farm not found"))
+	}
+
+	if farm.ThreebotID != userTid {
+		return 0, mw.Forbidden(fmt.Errorf("only the farmer can manage the pricing rules of farm '%d'", farmID))
+	}
+
+	return userTid, nil
+}
+
+func (a *API) listPricingRules(r *http.Request) (interface{}, mw.Response) {
+	farmID, err := models.QueryInt(r, "farm")
+	if err != nil {
+		return nil, mw.BadRequest(errors.Wrap(err, "farm should be an integer"))
+	}
+
+	if _, resp := a.authorizePricingRules(r, schema.ID(farmID)); resp != nil {
+		return nil, resp
+	}
+
+	var filter escrowtypes.PricingRuleFilter
+	if farmID != 0 {
+		filter = filter.WithFarmID(schema.ID(farmID))
+	}
+
+	if t := r.URL.Query().Get("type"); len(t) != 0 {
+		filter = filter.WithType(escrowtypes.PricingRuleType(t))
+	}
+
+	rules, err := filter.List(r.Context(), mw.Database(r))
+	if err != nil {
+		return nil, mw.Error(err)
+	}
+
+	return rules, nil
+}
+
+func (a *API) createPricingRule(r *http.Request) (interface{}, mw.Response) {
+	defer r.Body.Close()
+
+	var rule escrowtypes.PricingRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		return nil, mw.BadRequest(err)
+	}
+
+	userTid, resp := a.authorizePricingRules(r, rule.FarmID)
+	if resp != nil {
+		return nil, resp
+	}
+
+	if err := rule.Validate(); err != nil {
+		return nil, mw.BadRequest(err)
+	}
+
+	rule.CreatedBy = userTid
+	rule, err := escrowtypes.PricingRuleCreate(r.Context(), mw.Database(r), rule)
+	if errors.Is(err, escrowtypes.ErrPromoCodeExists) {
+		return nil, mw.Conflict(err)
+	} else if err != nil {
+		return nil, mw.Error(err)
+	}
+
+	return rule, mw.Created()
+}
+
+// loadPricingRule loads the rule of the request, and checks that the user
+// making the request can manage it
+func (a *API) loadPricingRule(r *http.Request) (escrowtypes.PricingRule, mw.Response) {
+	id, err := a.parseID(mux.Vars(r)["id"])
+	if err != nil {
+		return escrowtypes.PricingRule{}, mw.BadRequest(err)
+	}
+
+	var filter escrowtypes.PricingRuleFilter
+	rule, err := filter.WithID(id).Get(r.Context(), mw.Database(r))
+	if errors.Is(err, escrowtypes.ErrPricingRuleNotFound) {
+		return rule, mw.NotFound(err)
+	} else if err != nil {
+		return rule, mw.Error(err)
+	}
+
+	if _, resp := a.authorizePricingRules(r, rule.FarmID); resp != nil {
+		return rule, resp
+	}
+
+	return rule, nil
+}
+
+func (a *API) getPricingRule(r *http.Request) (interface{}, mw.Response) {
+	rule, resp := a.loadPricingRule(r)
+	if resp != nil {
+		return nil, resp
+	}
+
+	return rule, nil
+}
+
+func (a *API) updatePricingRule(r *http.Request) (interface{}, mw.Response) {
+	defer r.Body.Close()
+
+	rule, resp := a.loadPricingRule(r)
+	if resp != nil {
+		return nil, resp
+	}
+
+	var update struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		return nil, mw.BadRequest(err)
+	}
+
+	if err := escrowtypes.PricingRuleSetEnabled(r.Context(), mw.Database(r), rule.ID, update.Enabled); err != nil {
+		return nil, mw.Error(err)
+	}
+
+	rule.Enabled = update.Enabled
+	return rule, nil
+}
+
+func (a *API) deletePricingRule(r *http.Request) (interface{}, mw.Response) {
+	rule, resp := a.loadPricingRule(r)
+	if resp != nil {
+		return nil, resp
+	}
+
+	var filter escrowtypes.PricingRuleFilter
+	if err := filter.WithID(rule.ID).Delete(r.Context(), mw.Database(r)); err != nil {
+		return nil, mw.Error(err)
+	}
+
+	return nil, mw.NoContent()
+}
@@ -0,0 +1,94 @@
+package workloads
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/tfexplorer/models"
+	"github.com/threefoldtech/tfexplorer/mw"
+	escrowtypes "github.com/threefoldtech/tfexplorer/pkg/escrow/types"
+	"github.com/zaibon/httpsig"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// listReceipts lists the receipts of the capacity reservations paid by
+// the user making the request
+func (a *API) listReceipts(r *http.Request) (interface{}, mw.Response) {
+	userTid, err := strconv.ParseInt(httpsig.KeyIDFromContext(r.Context()), 10, 64)
+	if err != nil {
+		return nil, mw.BadRequest(errors.Wrap(err, "failed to parse request user id"))
+	}
+
+	var filter escrowtypes.CapacityReceiptFilter
+	filter = filter.WithCustomer(userTid)
+
+	if pool := r.URL.Query().Get("pool"); len(pool) != 0 {
+		poolID, err := a.parseID(pool)
+		if err != nil {
+			return nil, mw.BadRequest(err)
+		}
+		filter = filter.WithPoolID(poolID)
+	}
+
+	db := mw.Database(r)
+	pager := models.PageFromRequest(r)
+	cursor, err := models.CursorFromRequest(r)
+	if err != nil {
+		return nil, mw.BadRequest(err)
+	}
+	if cursor != nil {
+		filter = append(filter, cursor.Filter())
+		pager = cursor.Pager()
+	}
+	cur, err := filter.Find(r.Context(), db, pager, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, mw.Error(err)
+	}
+	defer cur.Close(r.Context())
+
+	receipts := []escrowtypes.CapacityReceipt{}
+	if err := cur.All(r.Context(), &receipts); err != nil {
+		return nil, mw.Error(err)
+	}
+
+	total, err := filter.Count(r.Context(), db)
+	if err != nil {
+		return nil, mw.Error(err)
+	}
+
+	var next string
+	if len(receipts) > 0 {
+		next = models.NextCursor(pager, len(receipts), receipts[len(receipts)-1].ReservationID)
+	}
+
+	return receipts, mw.Page(pager, cursor, total, next)
+}
+
+// getReceipt gets the receipt of a capacity reservation paid by the user
+// making the request
+func (a *API) getReceipt(r *http.Request) (interface{}, mw.Response) {
+	userTid, err := strconv.ParseInt(httpsig.KeyIDFromContext(r.Context()), 10, 64)
+	if err != nil {
+		return nil, mw.BadRequest(errors.Wrap(err, "failed to parse request user id"))
+	}
+
+	id, err := a.parseID(mux.Vars(r)["id"])
+	if err != nil {
+		return nil, mw.BadRequest(err)
+	}
+
+	var filter escrowtypes.CapacityReceiptFilter
+	filter = filter.WithID(id).WithCustomer(userTid)
+
+	receipt, err := filter.Get(r.Context(), mw.Database(r))
+	if errors.Is(err, escrowtypes.ErrReceiptNotFound) {
+		return nil, mw.NotFound(err)
+	} else if err != nil {
+		return nil, mw.Error(err)
+	}
+
+	return receipt, nil
+}
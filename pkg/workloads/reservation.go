@@ -6,8 +6,10 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -15,6 +17,7 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/tfexplorer/config"
 	"github.com/threefoldtech/tfexplorer/models"
 	generateddirectory "github.com/threefoldtech/tfexplorer/models/generated/directory"
 	"github.com/threefoldtech/tfexplorer/models/generated/workloads"
@@ -40,6 +43,7 @@ type (
 		escrow          escrow.Escrow
 		capacityPlanner capacity.Planner
 		network         gridnetworks.GridNetwork
+		deliveries      *deliveryTracker
 	}
 
 	// ReservationCreateResponse wraps reservation create response
@@ -66,6 +70,10 @@ const minCapacitySeconds = 120 // 2 min
 // 			 with version 1, secret are encrypted with nacl.SecretBox using a share secret derived from the node public key and the user private key
 const lastestWorkloadVersion = 2
 
+// statesHeader is the header of the workloads list holding the number of
+// workloads matching the search in each result state
+const statesHeader = "States"
+
 func (a *API) create(r *http.Request) (interface{}, mw.Response) {
 	defer r.Body.Close()
 
@@ -74,29 +82,19 @@ func (a *API) create(r *http.Request) (interface{}, mw.Response) {
 		return nil, mw.BadRequest(errors.Wrap(err, "failed to parse request user id"))
 	}
 
-	bodyBuf := bytes.NewBuffer(nil)
-	bodyBuf.ReadFrom(r.Body)
-	w, err := workloads.UnmarshalJSON(bodyBuf.Bytes())
+	workload, err := decodeWorkload(r)
 	if err != nil {
 		return nil, mw.BadRequest(err)
 	}
 
-	workload := types.WorkloaderType{Workloader: w}
-
-	// we make sure those arrays are initialized correctly
-	// this will make updating the document in place much easier
-	// in later stages
-	workload.SetSignaturesProvision(make([]generated.SigningSignature, 0))
-	workload.SetSignaturesDelete(make([]generated.SigningSignature, 0))
-	workload.SetSignatureFarmer(generated.SigningSignature{})
-	workload.SetResult(generated.Result{})
-	workload.SetID(schema.ID(0))
-	workload.SetVersion(lastestWorkloadVersion)
-
 	if err := workload.Validate(); err != nil {
 		return nil, mw.BadRequest(err)
 	}
 
+	if workload.GetOriginal() != 0 {
+		return nil, mw.BadRequest(fmt.Errorf("new versions of a workload must be created with the update endpoint"))
+	}
+
 	if workload.GetCustomerTid() != requestUserID {
 		return nil, mw.UnAuthorized(fmt.Errorf("request user identity does not match the reservation customer-tid"))
 	}
@@ -117,20 +115,8 @@ func (a *API) create(r *http.Request) (interface{}, mw.Response) {
 
 	db := mw.Database(r)
 
-	var filter phonebook.UserFilter
-	filter = filter.WithID(schema.ID(workload.GetCustomerTid()))
-	user, err := filter.Get(r.Context(), db)
-	if err != nil {
-		return nil, mw.BadRequest(errors.Wrapf(err, "cannot find user with id '%d'", workload.GetCustomerTid()))
-	}
-
-	signature, err := hex.DecodeString(workload.GetCustomerSignature())
-	if err != nil {
-		return nil, mw.BadRequest(errors.Wrap(err, "invalid signature format, expecting hex encoded string"))
-	}
-
-	if err := workload.Verify(user.Pubkey, signature); err != nil {
-		return nil, mw.BadRequest(errors.Wrap(err, "failed to verify customer signature"))
+	if err := verifyCustomerSignature(r, db, workload); err != nil {
+		return nil, err
 	}
 
 	workload.SetEpoch(schema.Date{Time: time.Now()})
@@ -167,6 +153,10 @@ func (a *API) create(r *http.Request) (interface{}, mw.Response) {
 
 	}
 
+	if err := resolveDependencies(r.Context(), db, workload); err != nil {
+		return nil, err
+	}
+
 	id, err := types.WorkloadCreate(r.Context(), db, workload)
 	if err != nil {
 		log.Error().Err(err).Msg("could not create workload")
@@ -212,6 +202,124 @@ func (a *API) create(r *http.Request) (interface{}, mw.Response) {
 	return ReservationCreateResponse{ID: id}, mw.Created()
 }
 
+// decodeWorkload decodes the workload of the request body, and resets the
+// fields managed by the explorer
+func decodeWorkload(r *http.Request) (types.WorkloaderType, error) {
+	bodyBuf := bytes.NewBuffer(nil)
+	bodyBuf.ReadFrom(r.Body)
+	w, err := workloads.UnmarshalJSON(bodyBuf.Bytes())
+	if err != nil {
+		return types.WorkloaderType{}, err
+	}
+
+	workload := types.WorkloaderType{Workloader: w}
+	resetWorkload(workload)
+
+	return workload, nil
+}
+
+// resetWorkload resets the fields of a new workload managed by the explorer
+func resetWorkload(workload types.WorkloaderType) {
+	// we make sure those arrays are initialized correctly
+	// this will make updating the document in place much easier
+	// in later stages
+	workload.SetSignaturesProvision(make([]generated.SigningSignature, 0))
+	workload.SetSignaturesDelete(make([]generated.SigningSignature, 0))
+	workload.SetSignatureFarmer(generated.SigningSignature{})
+	workload.SetResult(generated.Result{})
+	workload.SetID(schema.ID(0))
+	workload.SetVersion(lastestWorkloadVersion)
+	workload.SetDependsOn(nil)
+	resetExpiresAt(workload)
+}
+
+// resetExpiresAt clears the expiry time of a workload sent without one, it is
+// sent as the unix epoch
+func resetExpiresAt(workload types.WorkloaderType) {
+	if workload.GetExpiresAt().Unix() <= 0 {
+		workload.SetExpiresAt(schema.Date{})
+	}
+}
+
+// customerKey returns the key the customer signs the workloads with. It is
+// the key the user had when the data was signed, or the key of the token which
+// signed the request if the token can deploy on all the pools
+func customerKey(r *http.Request, db *mongo.Database, customer int64, signed time.Time, pools ...int64) (string, mw.Response) {
+	var filter phonebook.UserFilter
+	filter = filter.WithID(schema.ID(customer))
+	user, err := filter.Get(r.Context(), db)
+	if err != nil {
+		return "", mw.BadRequest(errors.Wrapf(err, "cannot find user with id '%d'", customer))
+	}
+
+	// workloads deployed with a token are signed with the token key
+	token, ok := mw.TokenFromContext(r.Context())
+	if !ok {
+		pubkey, err := phonebook.UserKeySignedAt(r.Context(), db, user, signed)
+		if err != nil {
+			return "", mw.Error(err)
+		}
+		return pubkey, nil
+	}
+
+	for _, pool := range pools {
+		if !token.AllowsPool(schema.ID(pool)) {
+			return "", mw.Forbidden(fmt.Errorf("token is not allowed to deploy on pool '%d'", pool))
+		}
+	}
+
+	return token.Pubkey, nil
+}
+
+// verifyCustomerSignature verifies the customer signature of a workload. The
+// workload is signed with the user key, or with the key of the token which
+// signed the request
+func verifyCustomerSignature(r *http.Request, db *mongo.Database, workload types.WorkloaderType) mw.Response {
+	pubkey, resp := customerKey(r, db, workload.GetCustomerTid(), workload.GetEpoch().Time, workload.GetPoolID())
+	if resp != nil {
+		return resp
+	}
+
+	signature, err := hex.DecodeString(workload.GetCustomerSignature())
+	if err != nil {
+		return mw.BadRequest(errors.Wrap(err, "invalid signature format, expecting hex encoded string"))
+	}
+
+	if err := workload.Verify(pubkey, signature); err != nil {
+		return mw.BadRequest(errors.Wrap(err, "failed to verify customer signature"))
+	}
+
+	return nil
+}
+
+// verifyPoolSignature verifies the customer signature of a capacity reservation.
+// The reservation is signed with the user key, or with a token of the user
+// which can manage the pool
+func verifyPoolSignature(ctx context.Context, db *mongo.Database, user phonebook.User, reservation capacitytypes.Reservation) error {
+	err := reservation.Verify(user.Pubkey)
+	if err == nil {
+		return nil
+	}
+
+	tokens, terr := phonebook.TokenFilter{}.WithUserID(user.ID).WithScope(phonebook.ScopePool).List(ctx, db)
+	if terr != nil {
+		return terr
+	}
+
+	now := time.Now()
+	for _, token := range tokens {
+		if !token.ValidAt(now) || !token.AllowsPool(schema.ID(reservation.DataReservation.PoolID)) {
+			continue
+		}
+
+		if reservation.Verify(token.Pubkey) == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
 func (a *API) setupPool(r *http.Request) (interface{}, mw.Response) {
 	defer r.Body.Close()
 	var reservation capacitytypes.Reservation
@@ -267,7 +375,7 @@ func (a *API) setupPool(r *http.Request) (interface{}, mw.Response) {
 		return nil, mw.BadRequest(errors.Wrapf(err, "cannot find user with id '%d'", reservation.CustomerTid))
 	}
 
-	if err := reservation.Verify(user.Pubkey); err != nil {
+	if err := verifyPoolSignature(r.Context(), db, user, reservation); err != nil {
 		return nil, mw.BadRequest(errors.Wrap(err, "failed to verify customer signature"))
 	}
 	// sponsor filter
@@ -298,7 +406,9 @@ func (a *API) setupPool(r *http.Request) (interface{}, mw.Response) {
 
 	info, err := a.capacityPlanner.Reserve(reservation, currencies)
 	if err != nil {
-		if errors.Is(err, capacity.ErrTransparantCapacityExtension) {
+		if errors.Is(err, capacity.ErrTransparantCapacityExtension) ||
+			errors.Is(err, escrow.ErrInvalidPromoCode) ||
+			errors.Is(err, escrowtypes.ErrPromoCodeExhausted) {
 			return nil, mw.BadRequest(err)
 		}
 		return nil, mw.Error(err)
@@ -355,12 +465,49 @@ func (a *API) listPools(r *http.Request) (interface{}, mw.Response) {
 		return nil, mw.BadRequest(errors.New("owner id must be an integer"))
 	}
 
-	pool, err := a.capacityPlanner.PoolsForOwner(owner)
+	cursor, err := models.CursorFromRequest(r)
+	if err != nil {
+		return nil, mw.BadRequest(err)
+	}
+
+	selector, err := schema.ParseLabels(r.FormValue("labels"))
+	if err != nil {
+		return nil, mw.BadRequest(errors.Wrap(err, "labels should be a list of key=value"))
+	}
+
+	owned, err := a.capacityPlanner.PoolsForOwner(owner)
 	if err != nil {
 		return nil, mw.Error(err)
 	}
 
-	return pool, nil
+	pools := []capacitytypes.Pool{}
+	for _, pool := range owned {
+		if pool.Labels.Match(selector) {
+			pools = append(pools, pool)
+		}
+	}
+
+	// all the pools are returned unless the client asks for a cursor, an
+	// owner only has a few pools so they are paginated in memory
+	if cursor == nil {
+		return pools, nil
+	}
+
+	sort.Slice(pools, func(i, j int) bool { return pools[i].ID < pools[j].ID })
+	page := []capacitytypes.Pool{}
+	for _, pool := range pools {
+		if pool.ID > cursor.After && int64(len(page)) < cursor.Size {
+			page = append(page, pool)
+		}
+	}
+
+	pager := cursor.Pager()
+	var next string
+	if len(page) > 0 {
+		next = models.NextCursor(pager, len(page), page[len(page)-1].ID)
+	}
+
+	return page, mw.Page(pager, cursor, int64(len(pools)), next)
 }
 
 func (a *API) parseID(id string) (schema.ID, error) {
@@ -385,6 +532,17 @@ func (a *API) pipeline(r types.Reservation, err error) (types.Reservation, error
 	return r, nil
 }
 
+// reservation returns the legacy reservation of the id. When the legacy
+// reservations are disabled it is never found, the handlers then use the
+// workloads
+func (a *API) reservation(ctx context.Context, db *mongo.Database, id schema.ID) (types.Reservation, error) {
+	if config.Config.DisableLegacy {
+		return types.Reservation{}, mongo.ErrNoDocuments
+	}
+
+	return a.pipeline(types.ReservationFilter{}.WithID(id).Get(ctx, db))
+}
+
 func (a *API) workloadpipeline(w types.WorkloaderType, err error) (types.WorkloaderType, error) {
 	if err != nil {
 		return w, err
@@ -444,8 +602,17 @@ func (a *API) list(r *http.Request) (interface{}, mw.Response) {
 		return nil, mw.BadRequest(err)
 	}
 
-	db := mw.Database(r)
 	pager := models.PageFromRequest(r)
+	cursor, err := models.CursorFromRequest(r)
+	if err != nil {
+		return nil, mw.BadRequest(err)
+	}
+	if cursor != nil {
+		filter = append(filter, cursor.Filter())
+		pager = cursor.Pager()
+	}
+
+	db := mw.Database(r)
 	cur, err := filter.Find(r.Context(), db, pager)
 	if err != nil {
 		return nil, mw.Error(err)
@@ -460,7 +627,16 @@ func (a *API) list(r *http.Request) (interface{}, mw.Response) {
 
 	reservations := []types.Reservation{}
 
+	// the next cursor is based on the scanned documents, including the
+	// ones which are skipped
+	var (
+		scanned int
+		last    schema.ID
+	)
 	for cur.Next(r.Context()) {
+		scanned++
+		last = schema.ID(cur.Current.Lookup("_id").Int64())
+
 		var reservation types.Reservation
 		if err := cur.Decode(&reservation); err != nil {
 			// skip reservations we can not load
@@ -479,8 +655,8 @@ func (a *API) list(r *http.Request) (interface{}, mw.Response) {
 		reservations = append(reservations, reservation)
 	}
 
-	pages := fmt.Sprintf("%d", models.NrPages(total, *pager.Limit))
-	return reservations, mw.Ok().WithHeader("Pages", pages)
+	next := models.NextCursor(pager, scanned, last)
+	return reservations, mw.Page(pager, cursor, total, next)
 }
 
 func (a *API) listWorkload(r *http.Request) (interface{}, mw.Response) {
@@ -490,8 +666,47 @@ func (a *API) listWorkload(r *http.Request) (interface{}, mw.Response) {
 		return nil, mw.BadRequest(err)
 	}
 
-	db := mw.Database(r)
+	sorting, err := types.SortWorkloadFromRequest(r)
+	if err != nil {
+		return nil, mw.BadRequest(err)
+	}
+
 	pager := models.PageFromRequest(r)
+	cursor, err := models.CursorFromRequest(r)
+	if err != nil {
+		return nil, mw.BadRequest(err)
+	}
+
+	if cursor != nil && sorting != nil {
+		return nil, mw.BadRequest(fmt.Errorf("workloads paginated by cursor can't be sorted"))
+	}
+
+	db := mw.Database(r)
+	farmID, err := models.QueryInt(r, "farm_id")
+	if err != nil {
+		return nil, mw.BadRequest(errors.Wrap(err, "farm_id should be an integer"))
+	}
+	if farmID != 0 {
+		nodes, err := farmNodeIDs(r.Context(), db, farmID)
+		if err != nil {
+			return nil, mw.Error(err)
+		}
+		filter = filter.WithNodeIDs(nodes)
+	}
+
+	states, err := workloadStates(r.Context(), db, filter)
+	if err != nil {
+		return nil, mw.Error(err)
+	}
+
+	if cursor != nil {
+		filter = append(filter, cursor.Filter())
+		pager = cursor.Pager()
+	}
+	if sorting != nil {
+		(*options.FindOptions)(pager).SetSort(sorting)
+	}
+
 	cur, err := filter.FindCursor(r.Context(), db, pager)
 	if err != nil {
 		return nil, mw.Error(err)
@@ -506,7 +721,16 @@ func (a *API) listWorkload(r *http.Request) (interface{}, mw.Response) {
 
 	reservations := []types.WorkloaderType{}
 
+	// the next cursor is based on the scanned documents, including the
+	// ones which are skipped
+	var (
+		scanned int
+		last    schema.ID
+	)
 	for cur.Next(r.Context()) {
+		scanned++
+		last = schema.ID(cur.Current.Lookup("_id").Int64())
+
 		var workload types.WorkloaderType
 		if err := cur.Decode(&workload); err != nil {
 			// skip reservations we can not load
@@ -525,8 +749,26 @@ func (a *API) listWorkload(r *http.Request) (interface{}, mw.Response) {
 		reservations = append(reservations, workload)
 	}
 
-	pages := fmt.Sprintf("%d", models.NrPages(total, *pager.Limit))
-	return reservations, mw.Ok().WithHeader("Pages", pages)
+	next := models.NextCursor(pager, scanned, last)
+	return reservations, mw.Page(pager, cursor, total, next).WithHeader(statesHeader, states)
+}
+
+// workloadStates returns the number of workloads matching the filter in
+// each result state, formatted as state=count pairs separated by commas
+func workloadStates(ctx context.Context, db *mongo.Database, filter types.WorkloadFilter) (string, error) {
+	counts := make([]string, 0, len(types.WorkloadResultStates))
+	for _, state := range types.WorkloadResultStates {
+		// copy the filter so the filters of the states don't share memory
+		count, err := append(types.WorkloadFilter{}, filter...).
+			WithResultState(state).
+			Count(ctx, db)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to count %s workloads", state)
+		}
+		counts = append(counts, fmt.Sprintf("%s=%d", state, count))
+	}
+
+	return strings.Join(counts, ","), nil
 }
 
 func (a *API) queued(ctx context.Context, db *mongo.Database, nodeID string, limit int64) ([]types.WorkloaderType, error) {
@@ -547,45 +789,45 @@ func (a *API) queued(ctx context.Context, db *mongo.Database, nodeID string, lim
 		if err := cur.Decode(&wl); err != nil {
 			return nil, err
 		}
+
+		held, err := a.heldBack(ctx, db, wl)
+		if err != nil {
+			return nil, err
+		}
+
+		if held {
+			continue
+		}
+
 		workloads = append(workloads, wl)
 	}
 
 	return workloads, nil
 }
 
-func (a *API) workloads(r *http.Request) (interface{}, mw.Response) {
-	const (
-		maxPageSize = 200
-	)
-
-	var (
-		nodeID = mux.Vars(r)["node_id"]
-	)
-
-	db := mw.Database(r)
+// legacyWorkloads returns the workloads of the legacy reservations of the node
+// from the id lastID, and the id of the last reservation returned. Nothing is
+// returned when the legacy reservations are disabled
+func (a *API) legacyWorkloads(ctx context.Context, db *mongo.Database, nodeID string, lastID schema.ID, limit int) ([]types.WorkloaderType, schema.ID, error) {
 	var workloads []types.WorkloaderType
-
-	lastID, err := a.parseID(r.FormValue("from"))
-	if err != nil {
-		return nil, mw.BadRequest(err)
+	if config.Config.DisableLegacy {
+		return workloads, lastID, nil
 	}
 
-	//var lastID schema.ID
-
 	rfilter := types.ReservationFilter{}.WithIDGE(lastID)
 	rfilter = rfilter.WithNodeID(nodeID)
 
-	cur, err := rfilter.Find(r.Context(), db)
+	cur, err := rfilter.Find(ctx, db)
 	if err != nil {
-		return nil, mw.Error(err)
+		return nil, lastID, err
 	}
 
-	defer cur.Close(r.Context())
+	defer cur.Close(ctx)
 
-	for cur.Next(r.Context()) {
+	for cur.Next(ctx) {
 		var reservation types.Reservation
 		if err := cur.Decode(&reservation); err != nil {
-			return nil, mw.Error(err)
+			return nil, lastID, err
 		}
 
 		reservation, err = a.pipeline(reservation, nil)
@@ -602,11 +844,37 @@ func (a *API) workloads(r *http.Request) (interface{}, mw.Response) {
 		workloads = append(workloads, reservation.Workloads(nodeID)...)
 
 		lastID = reservation.ID
-		if len(workloads) >= maxPageSize {
+		if len(workloads) >= limit {
 			break
 		}
 	}
 
+	return workloads, lastID, nil
+}
+
+func (a *API) workloads(r *http.Request) (interface{}, mw.Response) {
+	const (
+		maxPageSize = 200
+	)
+
+	var (
+		nodeID = mux.Vars(r)["node_id"]
+	)
+
+	db := mw.Database(r)
+
+	lastID, err := a.parseID(r.FormValue("from"))
+	if err != nil {
+		return nil, mw.BadRequest(err)
+	}
+
+	a.recordPoll(r.Context(), db, nodeID, lastID)
+
+	workloads, lastID, err := a.legacyWorkloads(r.Context(), db, nodeID, lastID, maxPageSize)
+	if err != nil {
+		return nil, mw.Error(err)
+	}
+
 	// if we have sufficient data return
 	if len(workloads) >= maxPageSize {
 		return workloads, mw.Ok().WithHeader("x-last-id", fmt.Sprint(lastID))
@@ -615,7 +883,7 @@ func (a *API) workloads(r *http.Request) (interface{}, mw.Response) {
 	filter := types.WorkloadFilter{}.WithIDGE(lastID)
 	filter = filter.WithNodeID(nodeID)
 
-	cur, err = filter.FindCursor(r.Context(), db)
+	cur, err := filter.FindCursor(r.Context(), db)
 	if err != nil {
 		return nil, mw.Error(err)
 	}
@@ -639,10 +907,21 @@ func (a *API) workloads(r *http.Request) (interface{}, mw.Response) {
 			}
 		}
 
-		if !workloader.IsAny(types.Deploy, types.Delete) {
+		if !workloader.IsAny(types.Deploy, types.Delete, types.Update) {
+			continue
+		}
+
+		// the workload is sent from the queue once its dependencies are deployed
+		held, err := a.heldBack(r.Context(), db, workloader)
+		if err != nil {
+			return nil, mw.Error(err)
+		}
+
+		if held {
 			continue
 		}
 
+		a.recordFetch(r.Context(), db, workloader.GetID(), nodeID)
 		workloads = append(workloads, workloader)
 		lastID = workloader.GetID()
 
@@ -668,6 +947,7 @@ func (a *API) workloads(r *http.Request) (interface{}, mw.Response) {
 
 		log.Debug().Msgf("%d queue", len(queued))
 		for _, workload := range queued {
+			a.recordFetch(r.Context(), db, workload.GetID(), nodeID)
 			workloads = append(workloads, workload)
 			if id := workload.GetID(); id > lastID {
 				lastID = id
@@ -693,11 +973,8 @@ func (a *API) workloadGet(r *http.Request) (interface{}, mw.Response) {
 		return nil, mw.BadRequest(errors.Wrap(err, "invalid reservation id part"))
 	}
 
-	var filter types.ReservationFilter
-	filter = filter.WithID(rid)
-
 	db := mw.Database(r)
-	reservation, err := a.pipeline(filter.Get(r.Context(), db))
+	reservation, err := a.reservation(r.Context(), db, rid)
 	if err != nil {
 		return a.newWorkloadGet(r)
 	}
@@ -793,9 +1070,9 @@ func (a *API) workloadPutResult(r *http.Request) (interface{}, mw.Response) {
 	filter = filter.WithID(rid)
 
 	db := mw.Database(r)
-	reservation, err := a.pipeline(filter.Get(r.Context(), db))
+	reservation, err := a.reservation(r.Context(), db, rid)
 	if err != nil {
-		return a.newStyleWorkloadPutResult(r.Context(), db, gwid, rid, result)
+		return a.newStyleWorkloadPutResult(r.Context(), db, nodeID, gwid, rid, result)
 	}
 
 	workloads := reservation.Workloads(nodeID)
@@ -832,7 +1109,17 @@ func (a *API) workloadPutResult(r *http.Request) (interface{}, mw.Response) {
 	return nil, mw.Created()
 }
 
-func (a *API) newStyleWorkloadPutResult(ctx context.Context, db *mongo.Database, gwid string, globalID schema.ID, result types.Result) (interface{}, mw.Response) {
+// checkWorkloadNode makes sure the node reporting about the workload is the
+// node the workload is deployed on, any node can sign its own reports
+func checkWorkloadNode(workload types.WorkloaderType, nodeID string) mw.Response {
+	if workload.GetNodeID() != nodeID {
+		return mw.Forbidden(fmt.Errorf("workload '%d' is not deployed on node '%s'", workload.GetID(), nodeID))
+	}
+
+	return nil
+}
+
+func (a *API) newStyleWorkloadPutResult(ctx context.Context, db *mongo.Database, nodeID, gwid string, globalID schema.ID, result types.Result) (interface{}, mw.Response) {
 	var filter types.WorkloadFilter
 	filter = filter.WithID(globalID)
 
@@ -850,6 +1137,10 @@ func (a *API) newStyleWorkloadPutResult(ctx context.Context, db *mongo.Database,
 		return nil, mw.NotFound(errors.New("workload id does not exist"))
 	}
 
+	if err := checkWorkloadNode(workload, nodeID); err != nil {
+		return nil, err
+	}
+
 	if err := types.WorkloadResultPush(ctx, db, globalID, result); err != nil {
 		return nil, mw.Error(err)
 	}
@@ -858,22 +1149,15 @@ func (a *API) newStyleWorkloadPutResult(ctx context.Context, db *mongo.Database,
 		return nil, mw.Error(err)
 	}
 
-	if result.State == generated.ResultStateError {
-		// remove capacity from pool
-		if err := a.capacityPlanner.RemoveUsedCapacity(workload); err != nil {
-			log.Error().Err(err).Msg("failed to decrease used capacity in pool")
-			return nil, mw.Error(err)
-		}
+	if workload.GetNextAction() == types.Update {
+		return a.workloadUpdated(ctx, db, workload, result)
+	}
 
-		if err := types.WorkloadSetNextAction(ctx, db, globalID, generated.NextActionDelete); err != nil {
+	if result.State == generated.ResultStateError {
+		if err := a.workloadFailed(ctx, db, workload); err != nil {
+			log.Error().Err(err).Int64("id", int64(globalID)).Msg("failed to process the workload failure")
 			return nil, mw.Error(err)
 		}
-
-		if workload.GetWorkloadType() == generated.WorkloadTypePublicIP {
-			if err := a.setFarmIPFree(ctx, db, workload); err != nil {
-				return nil, mw.Error(err)
-			}
-		}
 	} else if result.State == generated.ResultStateOK {
 		// add capacity to pool
 		if err := a.capacityPlanner.AddUsedCapacity(workload); err != nil {
@@ -884,16 +1168,55 @@ func (a *API) newStyleWorkloadPutResult(ctx context.Context, db *mongo.Database,
 	return nil, mw.Created()
 }
 
+// verifyDeletedReport verifies the report of the node which deleted a
+// workload. Unsigned reports are deprecated, they are refused if the explorer
+// requires signed deletions
+func verifyDeletedReport(r *http.Request, nodeID, gwid string) mw.Response {
+	var report types.DeletedReport
+	if err := json.NewDecoder(r.Body).Decode(&report); errors.Is(err, io.EOF) {
+		if config.Config.RequireNodeSignatures {
+			return mw.UnAuthorized(fmt.Errorf("the deletion of workload '%s' must be signed by the node", gwid))
+		}
+
+		log.Warn().Str("node", nodeID).Str("workload", gwid).Msg("deprecated unsigned deletion report")
+		return nil
+	} else if err != nil {
+		return mw.BadRequest(errors.Wrap(err, "invalid deletion report"))
+	}
+
+	if report.WorkloadId != gwid {
+		return mw.BadRequest(fmt.Errorf("deletion report is for workload '%s', not '%s'", report.WorkloadId, gwid))
+	}
+
+	if skew := config.Config.AuthMaxSkew; skew > 0 {
+		if age := time.Since(report.Epoch.Time); age > skew || age < -skew {
+			return mw.UnAuthorized(fmt.Errorf("deletion report is too old or in the future"))
+		}
+	}
+
+	if err := report.Verify(nodeID); err != nil {
+		return mw.UnAuthorized(errors.Wrap(err, "invalid deletion report signature"))
+	}
+
+	return nil
+}
+
+// verifyWorkloadDeletedReport verifies the report of the deletion of the
+// workload comes from the node the workload is deployed on
+func verifyWorkloadDeletedReport(r *http.Request, workload types.WorkloaderType, nodeID, gwid string) mw.Response {
+	if err := checkWorkloadNode(workload, nodeID); err != nil {
+		return err
+	}
+
+	return verifyDeletedReport(r, nodeID, gwid)
+}
+
 func (a *API) workloadPutDeleted(r *http.Request) (interface{}, mw.Response) {
-	// WARNING: #TODO
-	// This method does not validate the signature of the caller
-	// because there is no payload in a delete call.
-	// may be a simple body that has "reservation id" and "signature"
-	// can be used, we use the reservation id to avoid using the same
-	// request body to delete other reservations
+	defer r.Body.Close()
 
-	// HTTP Delete should not have a body though, so may be this should be
-	// changed to a PUT operation.
+	// the request is signed by the node of node_id if the node signs its
+	// requests, see Policies, and the deletion itself is signed by the node
+	// like the results are
 
 	nodeID := mux.Vars(r)["node_id"]
 	gwid := mux.Vars(r)["gwid"]
@@ -907,11 +1230,13 @@ func (a *API) workloadPutDeleted(r *http.Request) (interface{}, mw.Response) {
 	filter = filter.WithID(rid)
 
 	db := mw.Database(r)
-	reservation, err := a.pipeline(filter.Get(r.Context(), db))
+	reservation, err := a.reservation(r.Context(), db, rid)
 	if err != nil {
-		return a.newStyleWorkloadPutDeleted(r.Context(), db, rid, gwid, nodeID)
+		return a.newStyleWorkloadPutDeleted(r, db, rid, gwid, nodeID)
 	}
 
+	// only the workloads of the node are looked up, so the report is
+	// verified against the node of the workload
 	workloads := reservation.Workloads(nodeID)
 
 	var found bool
@@ -926,6 +1251,10 @@ func (a *API) workloadPutDeleted(r *http.Request) (interface{}, mw.Response) {
 		return nil, mw.NotFound(errors.New("workload not found"))
 	}
 
+	if err := verifyDeletedReport(r, nodeID, gwid); err != nil {
+		return nil, err
+	}
+
 	result := reservation.ResultOf(gwid)
 	if result == nil {
 		// no result for this work load
@@ -963,7 +1292,8 @@ func (a *API) workloadPutDeleted(r *http.Request) (interface{}, mw.Response) {
 	return nil, nil
 }
 
-func (a *API) newStyleWorkloadPutDeleted(ctx context.Context, db *mongo.Database, wid schema.ID, gwid string, nodeID string) (interface{}, mw.Response) {
+func (a *API) newStyleWorkloadPutDeleted(r *http.Request, db *mongo.Database, wid schema.ID, gwid string, nodeID string) (interface{}, mw.Response) {
+	ctx := r.Context()
 	rid, err := a.parseID(strings.Split(gwid, "-")[0])
 	if err != nil {
 		return nil, mw.BadRequest(errors.Wrap(err, "invalid reservation id part"))
@@ -981,6 +1311,10 @@ func (a *API) newStyleWorkloadPutDeleted(ctx context.Context, db *mongo.Database
 		return nil, mw.NotFound(errors.New("workload not found"))
 	}
 
+	if err := verifyWorkloadDeletedReport(r, workload, nodeID, gwid); err != nil {
+		return nil, err
+	}
+
 	result := workload.ResultOf(gwid)
 	if result == nil {
 		// no result for this work load
@@ -1017,6 +1351,11 @@ func (a *API) newStyleWorkloadPutDeleted(ctx context.Context, db *mongo.Database
 		}
 	}
 
+	message := fmt.Sprintf("dependency '%d' of the workload is deleted", workload.GetID())
+	if err := a.failDependents(ctx, db, workload, message); err != nil {
+		return nil, mw.Error(err)
+	}
+
 	return nil, nil
 }
 
@@ -1048,7 +1387,7 @@ func (a *API) signProvision(r *http.Request) (interface{}, mw.Response) {
 	filter = filter.WithID(id)
 
 	db := mw.Database(r)
-	reservation, err := a.pipeline(filter.Get(r.Context(), db))
+	reservation, err := a.reservation(r.Context(), db, id)
 	if err != nil {
 		r.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
 		return a.newSignProvision(r)
@@ -1126,23 +1465,37 @@ func (a *API) newSignProvision(r *http.Request) (interface{}, mw.Response) {
 		return nil, mw.UnAuthorized(errors.Wrap(err, "failed to verify signature"))
 	}
 
-	signature.Epoch = schema.Date{Time: time.Now()}
-	if err := types.WorkloadPushSignature(r.Context(), db, id, types.SignatureProvision, signature); err != nil {
+	if _, err := a.pushProvisionSignature(r.Context(), db, id, signature); err != nil {
 		return nil, mw.Error(err)
 	}
 
-	workload, err = a.workloadpipeline(filter.Get(r.Context(), db))
+	return nil, mw.Created()
+}
+
+// pushProvisionSignature records a verified provision signature of a
+// workload, and sends the workload to the node once the provision quorum is
+// reached. It returns true if the workload is being deployed
+func (a *API) pushProvisionSignature(ctx context.Context, db *mongo.Database, id schema.ID, signature generated.SigningSignature) (bool, error) {
+	signature.Epoch = schema.Date{Time: time.Now()}
+	if err := types.WorkloadPushSignature(ctx, db, id, types.SignatureProvision, signature); err != nil {
+		return false, err
+	}
+
+	workload, err := a.workloadpipeline(types.WorkloadFilter{}.WithID(id).Get(ctx, db))
 	if err != nil {
-		return nil, mw.Error(err)
+		return false, err
 	}
 
-	if workload.GetNextAction() == generated.NextActionDeploy {
-		if err = types.WorkloadPush(r.Context(), db, workload); err != nil {
-			return nil, mw.Error(err)
-		}
+	if workload.GetNextAction() != generated.NextActionDeploy {
+		return false, nil
 	}
 
-	return nil, mw.Created()
+	if err := types.WorkloadPush(ctx, db, workload); err != nil {
+		return false, err
+	}
+
+	types.WorkloadQueuedPush(ctx, db, workload)
+	return true, nil
 }
 
 func (a *API) signDelete(r *http.Request) (interface{}, mw.Response) {
@@ -1246,26 +1599,36 @@ func (a *API) newSignDelete(r *http.Request) (interface{}, mw.Response) {
 		return nil, mw.UnAuthorized(errors.Wrap(err, "failed to verify signature"))
 	}
 
-	signature.Epoch = schema.Date{Time: time.Now()}
-	if err := types.WorkloadPushSignature(r.Context(), db, id, types.SignatureDelete, signature); err != nil {
+	if _, err := a.pushDeleteSignature(r.Context(), db, id, signature); err != nil {
 		return nil, mw.Error(err)
 	}
 
-	workload, err = a.workloadpipeline(filter.Get(r.Context(), db))
+	return nil, mw.Created()
+}
+
+// pushDeleteSignature records a verified delete signature of a workload, and
+// sends the workload to be deleted once the delete quorum is reached. It
+// returns true if the workload is being deleted
+func (a *API) pushDeleteSignature(ctx context.Context, db *mongo.Database, id schema.ID, signature generated.SigningSignature) (bool, error) {
+	signature.Epoch = schema.Date{Time: time.Now()}
+	if err := types.WorkloadPushSignature(ctx, db, id, types.SignatureDelete, signature); err != nil {
+		return false, err
+	}
+
+	workload, err := a.workloadpipeline(types.WorkloadFilter{}.WithID(id).Get(ctx, db))
 	if err != nil {
-		return nil, mw.Error(err)
+		return false, err
 	}
 
 	if workload.GetNextAction() != generated.NextActionDelete {
-		return nil, mw.Created()
+		return false, nil
 	}
 
-	workload, err = a.setWorkloadDelete(r.Context(), db, workload)
-	if err != nil {
-		return nil, mw.Error(err)
+	if _, err := a.setWorkloadDelete(ctx, db, workload); err != nil {
+		return false, err
 	}
 
-	return nil, mw.Created()
+	return true, nil
 }
 
 func (a *API) setWorkloadDelete(ctx context.Context, db *mongo.Database, w types.WorkloaderType) (types.WorkloaderType, error) {
@@ -1275,7 +1638,12 @@ func (a *API) setWorkloadDelete(ctx context.Context, db *mongo.Database, w types
 		return w, errors.Wrap(err, "could not update workload to delete state")
 	}
 
-	return w, errors.Wrap(types.WorkloadPush(ctx, db, w), "could not push workload to delete in queue")
+	if err := types.WorkloadPush(ctx, db, w); err != nil {
+		return w, errors.Wrap(err, "could not push workload to delete in queue")
+	}
+
+	message := fmt.Sprintf("dependency '%d' of the workload is deleted", w.GetID())
+	return w, errors.Wrap(a.failDependents(ctx, db, w, message), "could not fail the workloads which depend on the workload")
 }
 
 func (a *API) handlePublicIPReservation(ctx context.Context, db *mongo.Database, workload types.WorkloaderType) mw.Response {
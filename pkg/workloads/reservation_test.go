@@ -1,11 +1,21 @@
 package workloads
 
 import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg/identity"
 
+	"github.com/threefoldtech/tfexplorer/config"
 	"github.com/threefoldtech/tfexplorer/models/generated/workloads"
+	"github.com/threefoldtech/tfexplorer/pkg/workloads/types"
+	"github.com/threefoldtech/tfexplorer/schema"
 )
 
 func Test_userCanSign(t *testing.T) {
@@ -72,3 +82,119 @@ func Test_userCanSign(t *testing.T) {
 		})
 	}
 }
+
+func Test_verifyDeletedReport(t *testing.T) {
+	kp, err := identity.GenerateKeyPair()
+	require.NoError(t, err)
+	nodeID := kp.Identity()
+
+	request := func(report *types.DeletedReport) *http.Request {
+		var body bytes.Buffer
+		if report != nil {
+			require.NoError(t, json.NewEncoder(&body).Encode(report))
+		}
+		return httptest.NewRequest(http.MethodDelete, "/", &body)
+	}
+
+	signed := func(gwid string, epoch time.Time) *types.DeletedReport {
+		report := types.DeletedReport{WorkloadId: gwid, Epoch: schema.Date{Time: epoch}}
+		require.NoError(t, report.Sign(kp.PrivateKey))
+		return &report
+	}
+
+	t.Run("signed", func(t *testing.T) {
+		assert.Nil(t, verifyDeletedReport(request(signed("1-1", time.Now())), nodeID, "1-1"))
+	})
+
+	t.Run("other_workload", func(t *testing.T) {
+		resp := verifyDeletedReport(request(signed("2-1", time.Now())), nodeID, "1-1")
+		require.NotNil(t, resp)
+		assert.Equal(t, http.StatusBadRequest, resp.Status())
+	})
+
+	t.Run("other_node", func(t *testing.T) {
+		other, err := identity.GenerateKeyPair()
+		require.NoError(t, err)
+
+		resp := verifyDeletedReport(request(signed("1-1", time.Now())), other.Identity(), "1-1")
+		require.NotNil(t, resp)
+		assert.Equal(t, http.StatusUnauthorized, resp.Status())
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		report := signed("1-1", time.Now())
+		report.Epoch = schema.Date{Time: time.Now().Add(time.Second)}
+
+		resp := verifyDeletedReport(request(report), nodeID, "1-1")
+		require.NotNil(t, resp)
+		assert.Equal(t, http.StatusUnauthorized, resp.Status())
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		resp := verifyDeletedReport(request(signed("1-1", time.Now().Add(-time.Hour))), nodeID, "1-1")
+		require.NotNil(t, resp)
+		assert.Equal(t, http.StatusUnauthorized, resp.Status())
+	})
+
+	t.Run("unsigned", func(t *testing.T) {
+		assert.Nil(t, verifyDeletedReport(request(nil), nodeID, "1-1"))
+
+		config.Config.RequireNodeSignatures = true
+		defer func() { config.Config.RequireNodeSignatures = false }()
+
+		resp := verifyDeletedReport(request(nil), nodeID, "1-1")
+		require.NotNil(t, resp)
+		assert.Equal(t, http.StatusUnauthorized, resp.Status())
+	})
+}
+
+func Test_checkWorkloadNode(t *testing.T) {
+	workload := types.WorkloaderType{Workloader: &workloads.Container{
+		ReservationInfo: workloads.ReservationInfo{
+			ID:           1,
+			NodeId:       "node1",
+			WorkloadType: workloads.WorkloadTypeContainer,
+		},
+	}}
+
+	assert.Nil(t, checkWorkloadNode(workload, "node1"))
+
+	resp := checkWorkloadNode(workload, "node2")
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusForbidden, resp.Status())
+}
+
+func Test_verifyWorkloadDeletedReport(t *testing.T) {
+	kp, err := identity.GenerateKeyPair()
+	require.NoError(t, err)
+
+	other, err := identity.GenerateKeyPair()
+	require.NoError(t, err)
+
+	workload := types.WorkloaderType{Workloader: &workloads.Container{
+		ReservationInfo: workloads.ReservationInfo{
+			ID:           1,
+			NodeId:       kp.Identity(),
+			WorkloadType: workloads.WorkloadTypeContainer,
+		},
+	}}
+
+	request := func(signer identity.KeyPair) *http.Request {
+		report := types.DeletedReport{WorkloadId: "1-1", Epoch: schema.Date{Time: time.Now()}}
+		require.NoError(t, report.Sign(signer.PrivateKey))
+
+		var body bytes.Buffer
+		require.NoError(t, json.NewEncoder(&body).Encode(report))
+		return httptest.NewRequest(http.MethodDelete, "/", &body)
+	}
+
+	t.Run("node_of_the_workload", func(t *testing.T) {
+		assert.Nil(t, verifyWorkloadDeletedReport(request(kp), workload, kp.Identity(), "1-1"))
+	})
+
+	t.Run("signed_by_other_node", func(t *testing.T) {
+		resp := verifyWorkloadDeletedReport(request(other), workload, other.Identity(), "1-1")
+		require.NotNil(t, resp)
+		assert.Equal(t, http.StatusForbidden, resp.Status())
+	})
+}
@@ -2,15 +2,16 @@ package workloads
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/threefoldtech/tfexplorer/config"
 	"github.com/threefoldtech/tfexplorer/mw"
 	"github.com/threefoldtech/tfexplorer/pkg/capacity"
 	"github.com/threefoldtech/tfexplorer/pkg/escrow"
 	"github.com/threefoldtech/tfexplorer/pkg/gridnetworks"
 	"github.com/threefoldtech/tfexplorer/pkg/workloads/types"
-	"github.com/zaibon/httpsig"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
@@ -20,40 +21,81 @@ func Setup(parent *mux.Router, db *mongo.Database, network gridnetworks.GridNetw
 		return err
 	}
 
-	userVerifier := httpsig.NewVerifier(mw.NewUserKeyGetter(db))
+	// the legacy reservations can only be converted by their customers, who
+	// sign the converted workloads, so the legacy paths are kept until they
+	// all did
+	if config.Config.DisableLegacy {
+		active, err := types.ReservationFilter{}.WithNextAction(types.Deploy).Count(context.TODO(), db)
+		if err != nil {
+			return err
+		}
+
+		if active > 0 {
+			return fmt.Errorf("can't disable the legacy reservations, %d active reservations are not converted to workloads yet", active)
+		}
+	}
+
+	Routes(parent, db, network, escrow, planner)
+	return nil
+}
 
+// Routes registers the workloads routes on the parent router
+func Routes(parent *mux.Router, db *mongo.Database, network gridnetworks.GridNetwork, escrow escrow.Escrow, planner capacity.Planner) {
 	service := API{
 		escrow:          escrow,
 		capacityPlanner: planner,
 		network:         network,
+		deliveries:      newDeliveryTracker(),
 	}
 
+	limiter := mw.NewRateLimiter(config.RateLimitWorkloads, db).ForNodes(Policies)
+	authorizer := mw.NewAuthorizer(db, Policies, nil)
+
 	// versionned endpoints
 	api := parent.PathPrefix("/api/v1").Subrouter()
+	api.Use(limiter.Middleware)
+	api.Use(authorizer.Middleware)
 	api.HandleFunc("/prices", mw.AsHandlerFunc(service.getPrices)).Methods(http.MethodGet).Name("prices-get")
 
+	// pricing rules of capacity reservations, managed by the admins and the farmers
+	pricingRules := api.PathPrefix("/pricing/rules").Subrouter()
+	pricingRules.HandleFunc("", mw.AsHandlerFunc(service.listPricingRules)).Methods(http.MethodGet).Name("pricing-rules-list")
+	pricingRules.HandleFunc("", mw.AsHandlerFunc(service.createPricingRule)).Methods(http.MethodPost).Name("pricing-rules-create")
+	pricingRules.HandleFunc("/{id:\\d+}", mw.AsHandlerFunc(service.getPricingRule)).Methods(http.MethodGet).Name("pricing-rules-get")
+	pricingRules.HandleFunc("/{id:\\d+}", mw.AsHandlerFunc(service.updatePricingRule)).Methods(http.MethodPut).Name("pricing-rules-update")
+	pricingRules.HandleFunc("/{id:\\d+}", mw.AsHandlerFunc(service.deletePricingRule)).Methods(http.MethodDelete).Name("pricing-rules-delete")
+
 	apiReservation := api.PathPrefix("/reservations").Subrouter()
 
 	apiReservation.HandleFunc("/pools", mw.AsHandlerFunc(service.setupPool)).Methods(http.MethodPost).Name("versionned-pool-create")
 	apiReservation.HandleFunc("/pools/{id:\\d+}", mw.AsHandlerFunc(service.getPool)).Methods(http.MethodGet).Name("versionned-pool-get")
 	apiReservation.HandleFunc("/pools/owner/{owner:\\d+}", mw.AsHandlerFunc(service.listPools)).Methods(http.MethodGet).Name("versionned-pool-get-by-owner")
 	apiReservation.HandleFunc("/pools/payment/{id:\\d+}", mw.AsHandlerFunc(service.getPaymentInfo)).Methods(http.MethodGet).Name("versionned-pool-get-payment-info")
-	// only create reservation call requires authentication to make sure
-	// the user identity associated with the request is the same exact
-	// one associated with the signed reservation object.
-	authenticated := apiReservation.NewRoute().Subrouter()
-	authenticated.Use(mw.NewAuthMiddleware(userVerifier).Middleware)
-	authenticated.HandleFunc("/workloads", mw.AsHandlerFunc(service.create)).Methods(http.MethodPost).Name("versionned-workloads-create")
-	// other calls are public
+	apiReservation.HandleFunc("/pools/receipts", mw.AsHandlerFunc(service.listReceipts)).Methods(http.MethodGet).Name("versionned-pool-receipts-list")
+	apiReservation.HandleFunc("/pools/receipts/{id:\\d+}", mw.AsHandlerFunc(service.getReceipt)).Methods(http.MethodGet).Name("versionned-pool-receipt-get")
+	apiReservation.HandleFunc("/pools/{id:\\d+}/labels", mw.AsHandlerFunc(service.poolLabels)).Methods(http.MethodPut).Name("versionned-pool-labels")
+	apiReservation.HandleFunc("/workloads", mw.AsHandlerFunc(service.create)).Methods(http.MethodPost).Name("versionned-workloads-create")
 	apiReservation.HandleFunc("/workloads", mw.AsHandlerFunc(service.listWorkload)).Methods(http.MethodGet).Name("versionned-workloadreservation-list")
+	apiReservation.HandleFunc("/workloads/delete", mw.AsHandlerFunc(service.bulkDelete)).Methods(http.MethodPost).Name("versionned-workloads-bulk-delete")
 	apiReservation.HandleFunc("/workloads/{res_id:\\d+}", mw.AsHandlerFunc(service.getWorkload)).Methods(http.MethodGet).Name("versionned-workloadreservation-get")
 	apiReservation.HandleFunc("/workloads/{res_id:\\d+}/sign/provision", mw.AsHandlerFunc(service.signProvision)).Methods(http.MethodPost).Name("versionned-reservation-sign-provision")
 	apiReservation.HandleFunc("/workloads/{res_id:\\d+}/sign/delete", mw.AsHandlerFunc(service.newSignDelete)).Methods(http.MethodPost).Name("versionned-reservation-sign-delete")
-
-	conversionAuthenticated := apiReservation.PathPrefix("/convert").Subrouter()
-	conversionAuthenticated.Use(mw.NewAuthMiddleware(userVerifier).Middleware)
-	conversionAuthenticated.HandleFunc("", mw.AsHandlerFunc(service.getConversionList)).Methods(http.MethodGet).Name("versionned-conversion-list")
-	conversionAuthenticated.HandleFunc("", mw.AsHandlerFunc(service.postConversionList)).Methods(http.MethodPost).Name("versionned-conversion-post")
+	apiReservation.HandleFunc("/workloads/{res_id:\\d+}/update", mw.AsHandlerFunc(service.update)).Methods(http.MethodPost).Name("versionned-workload-update")
+	apiReservation.HandleFunc("/workloads/{res_id:\\d+}/labels", mw.AsHandlerFunc(service.workloadLabels)).Methods(http.MethodPut).Name("versionned-workload-labels")
+	apiReservation.HandleFunc("/workloads/{res_id:\\d+}/expiry", mw.AsHandlerFunc(service.workloadExpiry)).Methods(http.MethodPut).Name("versionned-workload-expiry")
+	apiReservation.HandleFunc("/workloads/{res_id:\\d+}/versions", mw.AsHandlerFunc(service.versions)).Methods(http.MethodGet).Name("versionned-workload-versions")
+	apiReservation.HandleFunc("/workloads/{res_id:\\d+}/events", mw.AsHandlerFunc(service.events)).Methods(http.MethodGet).Name("versionned-workload-events")
+	apiReservation.HandleFunc("/groups", mw.AsHandlerFunc(service.createGroup)).Methods(http.MethodPost).Name("versionned-group-create")
+	apiReservation.HandleFunc("/groups/{id:\\d+}", mw.AsHandlerFunc(service.getGroup)).Methods(http.MethodGet).Name("versionned-group-get")
+	apiReservation.HandleFunc("/approvals", mw.AsHandlerFunc(service.listApprovals)).Methods(http.MethodGet).Name("versionned-approvals-list")
+	apiReservation.HandleFunc("/approvals/{res_id:\\d+}/approve", mw.AsHandlerFunc(service.approveWorkload)).Methods(http.MethodPost).Name("versionned-approval-approve")
+	apiReservation.HandleFunc("/approvals/{res_id:\\d+}/reject", mw.AsHandlerFunc(service.rejectWorkload)).Methods(http.MethodPost).Name("versionned-approval-reject")
+
+	if !config.Config.DisableLegacy {
+		conversion := apiReservation.PathPrefix("/convert").Subrouter()
+		conversion.HandleFunc("", mw.AsHandlerFunc(service.getConversionList)).Methods(http.MethodGet).Name("versionned-conversion-list")
+		conversion.HandleFunc("", mw.AsHandlerFunc(service.postConversionList)).Methods(http.MethodPost).Name("versionned-conversion-post")
+	}
 
 	// Nodes oriented endpoints
 	apiReservation.HandleFunc("/nodes/{node_id}/workloads", mw.AsHandlerFunc(service.workloads)).Queries("from", "{from:\\d+}").Methods(http.MethodGet).Name("versionned-workloads-poll")
@@ -63,36 +105,47 @@ func Setup(parent *mux.Router, db *mongo.Database, network gridnetworks.GridNetw
 
 	// legacy endpoints
 	legacyReservations := parent.PathPrefix("/explorer/reservations").Subrouter()
+	legacyReservations.Use(limiter.Middleware)
+	legacyReservations.Use(authorizer.Middleware)
 
 	legacyReservations.HandleFunc("", mw.AsHandlerFunc(service.create)).Methods(http.MethodPost).Name("reservation-create")
-	legacyReservations.HandleFunc("", mw.AsHandlerFunc(service.list)).Methods(http.MethodGet).Name("reservation-list")
-	legacyReservations.HandleFunc("/{res_id:\\d+}", mw.AsHandlerFunc(service.get)).Methods(http.MethodGet).Name("reservation-get")
-	legacyReservations.HandleFunc("/{res_id:\\d+}/sign/provision", mw.AsHandlerFunc(service.signProvision)).Methods(http.MethodPost).Name("reservation-sign-provision")
-	legacyReservations.HandleFunc("/{res_id:\\d+}/sign/delete", mw.AsHandlerFunc(service.signDelete)).Methods(http.MethodPost).Name("reservation-sign-delete")
+
+	// the legacy reservation model, the nodes oriented endpoints below
+	// serve the workloads as well and are kept
+	signDelete := service.newSignDelete
+	if !config.Config.DisableLegacy {
+		signDelete = service.signDelete
+
+		legacyReservations.HandleFunc("", mw.AsHandlerFunc(service.list)).Methods(http.MethodGet).Name("reservation-list")
+		legacyReservations.HandleFunc("/{res_id:\\d+}", mw.AsHandlerFunc(service.get)).Methods(http.MethodGet).Name("reservation-get")
+		legacyReservations.HandleFunc("/{res_id:\\d+}/sign/provision", mw.AsHandlerFunc(service.signProvision)).Methods(http.MethodPost).Name("reservation-sign-provision")
+		legacyReservations.HandleFunc("/{res_id:\\d+}/sign/delete", mw.AsHandlerFunc(service.signDelete)).Methods(http.MethodPost).Name("reservation-sign-delete")
+	}
 
 	// new style workloads
 	workloads := parent.PathPrefix("/explorer/workloads").Subrouter()
+	workloads.Use(limiter.Middleware)
+	workloads.Use(authorizer.Middleware)
 	workloads.HandleFunc("", mw.AsHandlerFunc(service.create)).Methods(http.MethodPost).Name("workload-create")
 	workloads.HandleFunc("", mw.AsHandlerFunc(service.listWorkload)).Methods(http.MethodGet).Name("workload-list")
 	workloads.HandleFunc("/{res_id:\\d+}", mw.AsHandlerFunc(service.getWorkload)).Methods(http.MethodGet).Name("workload-get")
 	workloads.HandleFunc("/{res_id:\\d+}/sign/provision", mw.AsHandlerFunc(service.signProvision)).Methods(http.MethodPost).Name("workload-sign-provision")
-	workloads.HandleFunc("/{res_id:\\d+}/sign/delete", mw.AsHandlerFunc(service.signDelete)).Methods(http.MethodPost).Name("workload-sign-delete")
+	workloads.HandleFunc("/{res_id:\\d+}/sign/delete", mw.AsHandlerFunc(signDelete)).Methods(http.MethodPost).Name("workload-sign-delete")
 
 	legacyReservations.HandleFunc("/pools", mw.AsHandlerFunc(service.setupPool)).Methods(http.MethodPost).Name("pool-create")
 	legacyReservations.HandleFunc("/pools/{id:\\d+}", mw.AsHandlerFunc(service.getPool)).Methods(http.MethodGet).Name("pool-get")
 	legacyReservations.HandleFunc("/pools/owner/{owner:\\d+}", mw.AsHandlerFunc(service.listPools)).Methods(http.MethodGet).Name("pool-get-by-owner")
 
 	// conversion
-	legacyConversionAuthenticated := legacyReservations.PathPrefix("/explorer/convert").Subrouter()
-	legacyConversionAuthenticated.Use(mw.NewAuthMiddleware(userVerifier).Middleware)
-	legacyConversionAuthenticated.HandleFunc("", mw.AsHandlerFunc(service.getConversionList)).Methods(http.MethodGet).Name("conversion-list")
-	legacyConversionAuthenticated.HandleFunc("", mw.AsHandlerFunc(service.postConversionList)).Methods(http.MethodPost).Name("conversion-post")
+	if !config.Config.DisableLegacy {
+		legacyConversion := legacyReservations.PathPrefix("/explorer/convert").Subrouter()
+		legacyConversion.HandleFunc("", mw.AsHandlerFunc(service.getConversionList)).Methods(http.MethodGet).Name("conversion-list")
+		legacyConversion.HandleFunc("", mw.AsHandlerFunc(service.postConversionList)).Methods(http.MethodPost).Name("conversion-post")
+	}
 
 	// node oriented endpoints
 	legacyReservations.HandleFunc("/nodes/{node_id}/workloads", mw.AsHandlerFunc(service.workloads)).Queries("from", "{from:\\d+}").Methods(http.MethodGet).Name("nodes-workloads-poll")
 	legacyReservations.HandleFunc("/workloads/{gwid:\\d+-\\d+}", mw.AsHandlerFunc(service.workloadGet)).Methods(http.MethodGet).Name("nodes-workload-get")
 	legacyReservations.HandleFunc("/nodes/{node_id}/workloads/{gwid:\\d+-\\d+}", mw.AsHandlerFunc(service.workloadPutResult)).Methods(http.MethodPut).Name("nodes-workloads-results")
 	legacyReservations.HandleFunc("/nodes/{node_id}/workloads/{gwid:\\d+-\\d+}", mw.AsHandlerFunc(service.workloadPutDeleted)).Methods(http.MethodDelete).Name("nodes-workloads-deleted")
-
-	return nil
 }
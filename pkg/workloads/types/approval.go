@@ -0,0 +1,241 @@
+package types
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	generated "github.com/threefoldtech/tfexplorer/models/generated/workloads"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"github.com/threefoldtech/zos/pkg/crypto"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// ApprovalCollection db collection name
+	ApprovalCollection = "approval"
+
+	// maxApprovalComment is the maximum size of the comment of a decision
+	maxApprovalComment = 1024
+)
+
+// ApprovalKind is the signing request a signer approves or rejects
+type ApprovalKind string
+
+const (
+	// ApprovalProvision approval of the provisioning of a workload
+	ApprovalProvision ApprovalKind = "provision"
+	// ApprovalDelete approval of the deletion of a workload
+	ApprovalDelete ApprovalKind = "delete"
+)
+
+// Valid checks the kind is known
+func (k ApprovalKind) Valid() error {
+	if k != ApprovalProvision && k != ApprovalDelete {
+		return fmt.Errorf("unknown approval kind '%s'", k)
+	}
+
+	return nil
+}
+
+// Mode returns the signatures of the workload of the kind
+func (k ApprovalKind) Mode() SignatureMode {
+	if k == ApprovalDelete {
+		return SignatureDelete
+	}
+
+	return SignatureProvision
+}
+
+// Request returns the signing request of the workload of the kind
+func (k ApprovalKind) Request(w WorkloaderType) (generated.SigningRequest, []generated.SigningSignature) {
+	if k == ApprovalDelete {
+		return w.GetSigningRequestDelete(), w.GetSignaturesDelete()
+	}
+
+	return w.GetSigningRequestProvision(), w.GetSignaturesProvision()
+}
+
+// ApprovalDecision is the approval or the rejection of a signing request of a
+// workload by one of its signers. The signature itself is recorded with the
+// signatures of the workload, the decision keeps the comment of the signer
+type ApprovalDecision struct {
+	WorkloadID schema.ID    `bson:"workload_id" json:"workload_id"`
+	Kind       ApprovalKind `bson:"kind" json:"kind"`
+	Tid        int64        `bson:"tid" json:"tid"`
+	Approved   bool         `bson:"approved" json:"approved"`
+	Comment    string       `bson:"comment" json:"comment"`
+	Epoch      schema.Date  `bson:"epoch" json:"epoch"`
+}
+
+// ApprovalRequest approves or rejects a signing request of a workload. The
+// signature is required to approve, it is the signature of the signing
+// request of the kind
+type ApprovalRequest struct {
+	Kind      ApprovalKind `json:"kind"`
+	Signature string       `json:"signature,omitempty"`
+	Comment   string       `json:"comment"`
+}
+
+// Validate the approval request
+func (a *ApprovalRequest) Validate(approve bool) error {
+	if err := a.Kind.Valid(); err != nil {
+		return err
+	}
+
+	if approve && len(a.Signature) == 0 {
+		return fmt.Errorf("signature is required to approve")
+	}
+
+	if len(a.Comment) > maxApprovalComment {
+		return fmt.Errorf("comment can not be bigger than %d bytes", maxApprovalComment)
+	}
+
+	return nil
+}
+
+// Approval is a signing request of a workload awaiting the signature of a
+// user, with the progress of the quorum
+type Approval struct {
+	Kind       ApprovalKind       `json:"kind"`
+	Workload   WorkloaderType     `json:"workload"`
+	QuorumMin  int64              `json:"quorum_min"`
+	Signatures int64              `json:"signatures"`
+	Decisions  []ApprovalDecision `json:"decisions"`
+}
+
+// NewApproval returns the approval of the signing request of the workload
+func NewApproval(kind ApprovalKind, w WorkloaderType, decisions []ApprovalDecision) Approval {
+	request, signatures := kind.Request(w)
+	return Approval{
+		Kind:       kind,
+		Workload:   w,
+		QuorumMin:  request.QuorumMin,
+		Signatures: int64(countSignatures(signatures, request)),
+		Decisions:  decisions,
+	}
+}
+
+// QuorumUnreachable checks if the signers which did not reject the signing
+// request can still reach the quorum
+func QuorumUnreachable(request generated.SigningRequest, decisions []ApprovalDecision) bool {
+	rejected := make(map[int64]bool)
+	for _, decision := range decisions {
+		if !decision.Approved {
+			rejected[decision.Tid] = true
+		}
+	}
+
+	var left int64
+	for _, signer := range request.Signers {
+		if !rejected[signer] {
+			left++
+		}
+	}
+
+	return left < request.QuorumMin
+}
+
+// SignatureRequestChallenge returns the data signed by a signer of the
+// signing request of the kind
+func (w *WorkloaderType) SignatureRequestChallenge(kind ApprovalKind, tid int64) ([]byte, error) {
+	b, err := w.SignatureChallenge()
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bytes.NewBuffer(b)
+	if _, err := fmt.Fprintf(buf, "%s%d", kind, tid); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// SignatureRequestSign signs the signing request of the kind with the key of
+// the signer, it returns the hex encoded signature
+func (w *WorkloaderType) SignatureRequestSign(kind ApprovalKind, tid int64, sk ed25519.PrivateKey) (string, error) {
+	challenge, err := w.SignatureRequestChallenge(kind, tid)
+	if err != nil {
+		return "", err
+	}
+
+	msg := sha256.Sum256(challenge)
+	sig, err := crypto.Sign(sk, msg[:])
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(sig), nil
+}
+
+// WithAwaitingSignature filter the workloads which signing request of the kind
+// awaits the signature of the user
+func (f WorkloadFilter) WithAwaitingSignature(kind ApprovalKind, tid int64) WorkloadFilter {
+	if kind == ApprovalDelete {
+		// the deletion is awaited once one of the signers asked for it
+		f = append(f,
+			bson.E{Key: "next_action", Value: Deploy},
+			bson.E{Key: "signing_request_delete.signers", Value: tid},
+			bson.E{Key: "signatures_delete.0", Value: bson.M{"$exists": true}},
+		)
+	} else {
+		f = append(f,
+			bson.E{Key: "next_action", Value: Sign},
+			bson.E{Key: "signing_request_provision.signers", Value: tid},
+		)
+	}
+
+	return append(f, bson.E{Key: string(kind.Mode()) + ".tid", Value: bson.M{"$ne": tid}})
+}
+
+// ApprovalDecisionCreate saves the decision of a signer
+func ApprovalDecisionCreate(ctx context.Context, db *mongo.Database, decision ApprovalDecision) error {
+	col := db.Collection(ApprovalCollection)
+	_, err := col.InsertOne(ctx, decision)
+	return err
+}
+
+// ApprovalDecisions returns the decisions on the signing request of the kind
+// of a workload, in the order they were taken
+func ApprovalDecisions(ctx context.Context, db *mongo.Database, id schema.ID, kind ApprovalKind) ([]ApprovalDecision, error) {
+	col := db.Collection(ApprovalCollection)
+	cur, err := col.Find(ctx, bson.M{"workload_id": id, "kind": kind}, options.Find().SetSort(bson.D{{Key: "epoch.time", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	decisions := []ApprovalDecision{}
+	if err := cur.All(ctx, &decisions); err != nil {
+		return nil, err
+	}
+
+	return decisions, nil
+}
+
+// ApprovalRejected returns the ids of the workloads which signing request of
+// the kind was rejected by the user
+func ApprovalRejected(ctx context.Context, db *mongo.Database, tid int64, kind ApprovalKind) (map[schema.ID]bool, error) {
+	col := db.Collection(ApprovalCollection)
+	cur, err := col.Find(ctx, bson.M{"tid": tid, "kind": kind, "approved": false})
+	if err != nil {
+		return nil, err
+	}
+
+	var decisions []ApprovalDecision
+	if err := cur.All(ctx, &decisions); err != nil {
+		return nil, err
+	}
+
+	rejected := make(map[schema.ID]bool, len(decisions))
+	for _, decision := range decisions {
+		rejected[decision.WorkloadID] = true
+	}
+
+	return rejected, nil
+}
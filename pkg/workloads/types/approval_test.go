@@ -0,0 +1,65 @@
+package types
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	generated "github.com/threefoldtech/tfexplorer/models/generated/workloads"
+	"github.com/threefoldtech/zos/pkg/identity"
+)
+
+func TestSignatureRequestSign(t *testing.T) {
+	kp, err := identity.GenerateKeyPair()
+	require.NoError(t, err)
+	pk := hex.EncodeToString(kp.PublicKey)
+
+	workload := groupWorkload(Sign, 0)
+
+	signature, err := workload.SignatureRequestSign(ApprovalProvision, 2, kp.PrivateKey)
+	require.NoError(t, err)
+	assert.NoError(t, workload.SignatureProvisionRequestVerify(pk, generated.SigningSignature{Tid: 2, Signature: signature}))
+	assert.Error(t, workload.SignatureDeleteRequestVerify(pk, generated.SigningSignature{Tid: 2, Signature: signature}))
+	assert.Error(t, workload.SignatureProvisionRequestVerify(pk, generated.SigningSignature{Tid: 3, Signature: signature}))
+
+	signature, err = workload.SignatureRequestSign(ApprovalDelete, 2, kp.PrivateKey)
+	require.NoError(t, err)
+	assert.NoError(t, workload.SignatureDeleteRequestVerify(pk, generated.SigningSignature{Tid: 2, Signature: signature}))
+}
+
+func TestQuorumUnreachable(t *testing.T) {
+	request := generated.SigningRequest{Signers: []int64{1, 2, 3}, QuorumMin: 2}
+
+	assert.False(t, QuorumUnreachable(request, nil))
+	assert.False(t, QuorumUnreachable(request, []ApprovalDecision{
+		{Tid: 1, Approved: false},
+		{Tid: 2, Approved: true},
+	}))
+	assert.True(t, QuorumUnreachable(request, []ApprovalDecision{
+		{Tid: 1, Approved: false},
+		{Tid: 3, Approved: false},
+	}))
+}
+
+func TestNewApproval(t *testing.T) {
+	workload := groupWorkload(Sign, 0)
+	workload.SetSigningRequestProvision(generated.SigningRequest{Signers: []int64{1, 2, 3}, QuorumMin: 2})
+	workload.SetSignaturesProvision([]generated.SigningSignature{{Tid: 1}, {Tid: 4}})
+
+	approval := NewApproval(ApprovalProvision, workload, nil)
+	assert.Equal(t, int64(2), approval.QuorumMin)
+	assert.Equal(t, int64(1), approval.Signatures, "only the signatures of the signers count")
+}
+
+func TestApprovalRequestValidate(t *testing.T) {
+	request := ApprovalRequest{Kind: ApprovalProvision}
+	assert.NoError(t, request.Validate(false))
+	assert.Error(t, request.Validate(true), "approving requires a signature")
+
+	request.Signature = "signature"
+	assert.NoError(t, request.Validate(true))
+
+	request.Kind = "update"
+	assert.Error(t, request.Validate(true))
+}
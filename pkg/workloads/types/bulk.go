@@ -0,0 +1,158 @@
+package types
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"github.com/threefoldtech/zos/pkg/crypto"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// MaxBulkDelete is the maximum number of workloads deleted by a bulk
+	// delete request
+	MaxBulkDelete = 1000
+)
+
+// BulkDelete signs the deletion of many workloads at once. The workloads are
+// either listed by id, or selected by pool and labels. In both cases the
+// signer signs the sorted list of the ids of the workloads
+type BulkDelete struct {
+	IDs []schema.ID `json:"ids,omitempty"`
+	// PoolID and Labels select the active workloads of a pool, or with
+	// the labels, when IDs is empty
+	PoolID int64         `json:"pool_id,omitempty"`
+	Labels schema.Labels `json:"labels,omitempty"`
+
+	Tid       int64       `json:"tid"`
+	Epoch     schema.Date `json:"epoch"`
+	Signature string      `json:"signature"`
+}
+
+// BulkDeleteState is the outcome of a bulk delete for a workload
+type BulkDeleteState string
+
+const (
+	// BulkDeleteSigned the signature is recorded, but the delete quorum of
+	// the workload is not reached yet
+	BulkDeleteSigned BulkDeleteState = "signed"
+	// BulkDeleteDeleting the delete quorum is reached, the workload is sent
+	// to the node to be deleted
+	BulkDeleteDeleting BulkDeleteState = "deleting"
+	// BulkDeleteFailed the signature could not be recorded for the workload
+	BulkDeleteFailed BulkDeleteState = "failed"
+)
+
+// BulkDeleteResult is the outcome of a bulk delete for a workload
+type BulkDeleteResult struct {
+	ID    schema.ID       `json:"id"`
+	State BulkDeleteState `json:"state"`
+	Error string          `json:"error,omitempty"`
+}
+
+// HasSelector returns true if the workloads are selected by pool or labels
+func (b *BulkDelete) HasSelector() bool {
+	return b.PoolID != 0 || len(b.Labels) != 0
+}
+
+// Validate the bulk delete request
+func (b *BulkDelete) Validate() error {
+	if b.Tid == 0 {
+		return fmt.Errorf("tid is required")
+	}
+
+	if len(b.Signature) == 0 {
+		return fmt.Errorf("signature is required")
+	}
+
+	if len(b.IDs) != 0 && b.HasSelector() {
+		return fmt.Errorf("workloads must be selected by id or by pool and labels, not both")
+	}
+
+	if len(b.IDs) == 0 && !b.HasSelector() {
+		return fmt.Errorf("ids or a pool or labels selector is required")
+	}
+
+	if len(b.IDs) > MaxBulkDelete {
+		return fmt.Errorf("can't delete more than %d workloads at once", MaxBulkDelete)
+	}
+
+	seen := make(map[schema.ID]bool, len(b.IDs))
+	for _, id := range b.IDs {
+		if seen[id] {
+			return fmt.Errorf("workload '%d' is listed twice", id)
+		}
+		seen[id] = true
+	}
+
+	return b.Labels.Validate()
+}
+
+// Filter returns the filter of the active workloads selected by the pool and
+// the labels of the request
+func (b *BulkDelete) Filter() WorkloadFilter {
+	filter := WorkloadFilter{}.WithLabels(b.Labels).WithActive()
+	if b.PoolID != 0 {
+		filter = filter.WithPoolID(b.PoolID)
+	}
+
+	return filter
+}
+
+// SignatureChallenge returns the data signed by the signer for the ids. The
+// ids are sorted, so the signature doesn't depend on the order of the ids
+func (b *BulkDelete) SignatureChallenge(ids []schema.ID) []byte {
+	sorted := make([]schema.ID, len(ids))
+	copy(sorted, ids)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "delete%d%d", b.Tid, b.Epoch.Unix())
+	for _, id := range sorted {
+		fmt.Fprintf(buf, ",%d", id)
+	}
+
+	return buf.Bytes()
+}
+
+// Sign the deletion of the workloads with the key of the signer
+func (b *BulkDelete) Sign(ids []schema.ID, sk ed25519.PrivateKey) error {
+	msg := sha256.Sum256(b.SignatureChallenge(ids))
+	sig, err := crypto.Sign(sk, msg[:])
+	if err != nil {
+		return err
+	}
+
+	b.Signature = hex.EncodeToString(sig)
+	return nil
+}
+
+// Verify the signature of the deletion of the workloads
+// pk is the public key used as verification key in hex encoded format
+func (b *BulkDelete) Verify(ids []schema.ID, pk string) error {
+	key, err := crypto.KeyFromHex(pk)
+	if err != nil {
+		return errors.Wrap(err, "invalid verification key")
+	}
+
+	sig, err := hex.DecodeString(b.Signature)
+	if err != nil {
+		return errors.Wrap(err, "invalid signature format, expecting hex encoded string")
+	}
+
+	msg := sha256.Sum256(b.SignatureChallenge(ids))
+	return crypto.Verify(key, msg[:], sig)
+}
+
+// WithActive filter the workloads which are not deleted, or being deleted
+func (f WorkloadFilter) WithActive() WorkloadFilter {
+	return append(f, bson.E{
+		Key: "next_action", Value: bson.M{"$nin": bson.A{Delete, Deleted, Invalid}},
+	})
+}
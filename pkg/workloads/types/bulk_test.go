@@ -0,0 +1,69 @@
+package types
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg/identity"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/threefoldtech/tfexplorer/schema"
+)
+
+func TestBulkDeleteValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		request BulkDelete
+		valid   bool
+	}{
+		{name: "ids", request: BulkDelete{IDs: []schema.ID{1, 2}, Tid: 1, Signature: "sig"}, valid: true},
+		{name: "pool", request: BulkDelete{PoolID: 1, Tid: 1, Signature: "sig"}, valid: true},
+		{name: "labels", request: BulkDelete{Labels: schema.Labels{"env": "dev"}, Tid: 1, Signature: "sig"}, valid: true},
+		{name: "no_selection", request: BulkDelete{Tid: 1, Signature: "sig"}},
+		{name: "ids_and_selector", request: BulkDelete{IDs: []schema.ID{1}, PoolID: 1, Tid: 1, Signature: "sig"}},
+		{name: "duplicate", request: BulkDelete{IDs: []schema.ID{1, 1}, Tid: 1, Signature: "sig"}},
+		{name: "no_tid", request: BulkDelete{IDs: []schema.ID{1}, Signature: "sig"}},
+		{name: "no_signature", request: BulkDelete{IDs: []schema.ID{1}, Tid: 1}},
+		{name: "too_many", request: BulkDelete{IDs: make([]schema.ID, MaxBulkDelete+1), Tid: 1, Signature: "sig"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.request.Validate()
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestBulkDeleteVerify(t *testing.T) {
+	kp, err := identity.GenerateKeyPair()
+	require.NoError(t, err)
+	pk := hex.EncodeToString(kp.PublicKey)
+
+	request := BulkDelete{PoolID: 1, Tid: 1, Epoch: schema.Date{Time: time.Now()}}
+	require.NoError(t, request.Sign([]schema.ID{3, 1, 2}, kp.PrivateKey))
+
+	// the ids are sorted before they are signed
+	assert.NoError(t, request.Verify([]schema.ID{1, 2, 3}, pk))
+	assert.Error(t, request.Verify([]schema.ID{1, 2}, pk))
+	assert.Error(t, request.Verify([]schema.ID{1, 2, 3, 4}, pk))
+
+	request.Tid = 2
+	assert.Error(t, request.Verify([]schema.ID{1, 2, 3}, pk))
+}
+
+func TestBulkDeleteFilter(t *testing.T) {
+	request := BulkDelete{PoolID: 4, Labels: schema.Labels{"env": "dev"}}
+	assert.Equal(t, WorkloadFilter{
+		{Key: "labels", Value: bson.M{"$all": []string{"env=dev"}}},
+		{Key: "next_action", Value: bson.M{"$nin": bson.A{Delete, Deleted, Invalid}}},
+		{Key: "pool_id", Value: int64(4)},
+	}, request.Filter())
+}
@@ -0,0 +1,159 @@
+package types
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// NodePollCollection db collection name
+	NodePollCollection = "node-poll"
+)
+
+// NodePoll is the last poll of the workloads by a node
+type NodePoll struct {
+	NodeID string `bson:"node_id" json:"node_id"`
+	// From is the id the node polled the workloads from
+	From schema.ID `bson:"from" json:"from"`
+	// Polled is the time of the poll
+	Polled schema.Date `bson:"polled" json:"polled"`
+}
+
+// Active checks if the node polled its workloads since the time
+func (p NodePoll) Active(since time.Time) bool {
+	return !p.Polled.Before(since)
+}
+
+// Lag returns the number of workload ids the node is behind the newest
+// workload id
+func (p NodePoll) Lag(lastID schema.ID) int64 {
+	if lastID < p.From {
+		return 0
+	}
+
+	return int64(lastID - p.From)
+}
+
+// NodePollSet records the poll of the workloads by the node. Tracking the
+// polls is informative, an error is logged and doesn't fail the poll
+func NodePollSet(ctx context.Context, db *mongo.Database, nodeID string, from schema.ID) {
+	col := db.Collection(NodePollCollection)
+	_, err := col.UpdateOne(ctx, bson.M{"node_id": nodeID}, bson.M{
+		"$set": NodePoll{
+			NodeID: nodeID,
+			From:   from,
+			Polled: schema.Date{Time: time.Now()},
+		},
+	}, options.Update().SetUpsert(true))
+
+	if err != nil {
+		log.Error().Err(err).Str("node", nodeID).Msg("failed to record node poll")
+	}
+}
+
+// NodePolls returns the last poll of every node
+func NodePolls(ctx context.Context, db *mongo.Database) ([]NodePoll, error) {
+	cur, err := db.Collection(NodePollCollection).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	polls := []NodePoll{}
+	if err := cur.All(ctx, &polls); err != nil {
+		return nil, err
+	}
+
+	return polls, nil
+}
+
+// notFetched returns the queued workloads which are not fetched
+func notFetched(queued, fetched []schema.ID) []schema.ID {
+	seen := make(map[schema.ID]struct{}, len(fetched))
+	for _, id := range fetched {
+		seen[id] = struct{}{}
+	}
+
+	var ids []schema.ID
+	for _, id := range queued {
+		if _, ok := seen[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+// eventWorkloads returns the ids of the workloads with events matching the
+// filter
+func eventWorkloads(ctx context.Context, db *mongo.Database, filter bson.M) ([]schema.ID, error) {
+	values, err := db.Collection(EventCollection).Distinct(ctx, "workload_id", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]schema.ID, 0, len(values))
+	for _, value := range values {
+		if id, ok := value.(int64); ok {
+			ids = append(ids, schema.ID(id))
+		}
+	}
+
+	return ids, nil
+}
+
+// WorkloadsNotFetched returns the workloads queued for the node before the
+// time which the node never fetched. The workloads waiting for their
+// dependencies are not sent to the node, they are not returned
+func WorkloadsNotFetched(ctx context.Context, db *mongo.Database, nodeID string, before time.Time) ([]schema.ID, error) {
+	pending, err := WorkloadFilter{}.
+		WithNodeID(nodeID).
+		WithNextAction(Deploy).
+		WithResultState(WorkloadResultPending).
+		Find(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]schema.ID, 0, len(pending))
+	for _, workload := range pending {
+		state, _, err := WorkloadDependencyState(ctx, db, workload)
+		if err != nil {
+			return nil, err
+		}
+
+		if state == DependenciesReady {
+			ids = append(ids, workload.GetID())
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	queued, err := eventWorkloads(ctx, db, bson.M{
+		"workload_id": bson.M{"$in": ids},
+		"kind":        EventQueued,
+		"node_id":     nodeID,
+		"epoch.time":  bson.M{"$lt": before},
+	})
+	if err != nil || len(queued) == 0 {
+		return nil, err
+	}
+
+	fetched, err := eventWorkloads(ctx, db, bson.M{
+		"workload_id": bson.M{"$in": queued},
+		"kind":        EventFetched,
+		"node_id":     nodeID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return notFetched(queued, fetched), nil
+}
@@ -0,0 +1,33 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/tfexplorer/schema"
+)
+
+func TestNodePollLag(t *testing.T) {
+	poll := NodePoll{NodeID: "node1", From: 90}
+
+	assert.Equal(t, int64(10), poll.Lag(100))
+	assert.Equal(t, int64(0), poll.Lag(90))
+	// workloads deleted since the poll
+	assert.Equal(t, int64(0), poll.Lag(80))
+}
+
+func TestNodePollActive(t *testing.T) {
+	now := time.Now()
+	poll := NodePoll{NodeID: "node1", Polled: schema.Date{Time: now.Add(-time.Minute)}}
+
+	assert.True(t, poll.Active(now.Add(-10*time.Minute)))
+	assert.False(t, poll.Active(now))
+}
+
+func TestNotFetched(t *testing.T) {
+	assert.Empty(t, notFetched(nil, []schema.ID{1}))
+	assert.Equal(t, []schema.ID{1, 2, 3}, notFetched([]schema.ID{1, 2, 3}, nil))
+	assert.Equal(t, []schema.ID{3}, notFetched([]schema.ID{1, 2, 3}, []schema.ID{2, 1, 4}))
+	assert.Empty(t, notFetched([]schema.ID{1, 2}, []schema.ID{1, 2}))
+}
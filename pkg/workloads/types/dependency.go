@@ -0,0 +1,356 @@
+package types
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+	generated "github.com/threefoldtech/tfexplorer/models/generated/workloads"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrInvalidDependency is returned when a workload references a workload
+// which does not exist, or which is owned by another customer
+var ErrInvalidDependency = errors.New("invalid dependency")
+
+// DependencyState is the progress of the dependencies of a workload
+type DependencyState int
+
+const (
+	// DependenciesReady all the dependencies are deployed
+	DependenciesReady DependencyState = iota
+	// DependenciesPending some dependencies are not deployed yet
+	DependenciesPending
+	// DependenciesFailed a dependency failed to deploy, or is deleted
+	DependenciesFailed
+)
+
+// WithDependency filter the workloads which depend on the workload
+func (f WorkloadFilter) WithDependency(id schema.ID) WorkloadFilter {
+	return append(f, bson.E{Key: "depends_on", Value: id})
+}
+
+// WithDependencyIn filter the workloads which depend on any of the workloads
+func (f WorkloadFilter) WithDependencyIn(ids []schema.ID) WorkloadFilter {
+	return append(f, bson.E{Key: "depends_on", Value: bson.M{"$in": ids}})
+}
+
+// WithNetworkName filter the network resources of the network
+func (f WorkloadFilter) WithNetworkName(name string) WorkloadFilter {
+	return append(f,
+		bson.E{Key: "workload_type", Value: generated.WorkloadTypeNetworkResource},
+		bson.E{Key: "name", Value: name},
+	)
+}
+
+// WithNetworkID filter the workloads connected to the network
+func (f WorkloadFilter) WithNetworkID(name string) WorkloadFilter {
+	return append(f, bson.E{Key: "network_id", Value: name})
+}
+
+// volumeReference returns the id of the volume workload mounted by a container
+func volumeReference(volumeID string) (schema.ID, error) {
+	ss := strings.SplitN(volumeID, "-", 2)
+	if len(ss) != 2 || len(ss[0]) == 0 {
+		return 0, errors.Wrapf(ErrInvalidDependency, "invalid volume id '%s'", volumeID)
+	}
+
+	var id schema.ID
+	if _, err := fmt.Sscanf(ss[0], "%d", &id); err != nil {
+		return 0, errors.Wrapf(ErrInvalidDependency, "invalid volume id '%s'", volumeID)
+	}
+
+	return id, nil
+}
+
+// checkDependency checks the workload can depend on dep
+func checkDependency(w, dep WorkloaderType, depType generated.WorkloadTypeEnum, sameNode bool) error {
+	if dep.GetWorkloadType() != depType {
+		return errors.Wrapf(ErrInvalidDependency, "workload '%d' is not a %s", dep.GetID(), depType)
+	}
+
+	if dep.GetCustomerTid() != w.GetCustomerTid() {
+		return errors.Wrapf(ErrInvalidDependency, "workload '%d' is not owned by the customer", dep.GetID())
+	}
+
+	if dep.IsAny(Delete, Deleted, Invalid) {
+		return errors.Wrapf(ErrInvalidDependency, "workload '%d' is deleted", dep.GetID())
+	}
+
+	if sameNode && dep.GetNodeID() != w.GetNodeID() {
+		return errors.Wrapf(ErrInvalidDependency, "workload '%d' is not on node '%s'", dep.GetID(), w.GetNodeID())
+	}
+
+	return nil
+}
+
+// latestVersion returns the version which superseded the workload, the
+// workload itself is returned if it is not deleted or was not superseded by
+// an active version. The versions which failed to deploy never supersede it
+func latestVersion(w WorkloaderType, versions []WorkloaderType) WorkloaderType {
+	if !w.IsAny(Deleted) {
+		return w
+	}
+
+	latest := w
+	for _, version := range versions {
+		if version.IsAny(Delete, Deleted, Invalid) || version.GetRevision() <= latest.GetRevision() {
+			continue
+		}
+		latest = version
+	}
+
+	return latest
+}
+
+// LatestVersion loads the version which superseded the workload, the
+// dependents of a workload which is updated depend on its latest version
+func LatestVersion(ctx context.Context, db *mongo.Database, w WorkloaderType) (WorkloaderType, error) {
+	if !w.IsAny(Deleted) {
+		return w, nil
+	}
+
+	original := w.GetOriginal()
+	if original == 0 {
+		original = w.GetID()
+	}
+
+	versions, err := WorkloadFilter{}.WithVersionsOf(original).WithActive().Find(ctx, db)
+	if err != nil {
+		return w, err
+	}
+
+	return latestVersion(w, versions), nil
+}
+
+// DependencyStateOf returns the progress of the dependencies of a workload,
+// and the first dependency which failed
+func DependencyStateOf(deps []WorkloaderType) (DependencyState, *WorkloaderType) {
+	state := DependenciesReady
+	for i := range deps {
+		dep := deps[i]
+		result := dep.GetResult()
+		// the result is empty until the node reports it
+		reported := len(result.WorkloadId) != 0
+
+		switch {
+		case dep.IsAny(Delete, Deleted, Invalid) || (reported && result.State == generated.ResultStateError):
+			return DependenciesFailed, &dep
+		case !reported || result.State != generated.ResultStateOK:
+			state = DependenciesPending
+		}
+	}
+
+	return state, nil
+}
+
+// Dependencies resolves the workloads referenced by the workload: the volumes
+// and the network of a container, the network, the public ip and the masters
+// of a kubernetes worker, and the network and the public ip of a virtual
+// machine. A reference to a workload which does not exist or which is owned
+// by another customer is an ErrInvalidDependency
+func Dependencies(ctx context.Context, db *mongo.Database, w WorkloaderType) ([]schema.ID, error) {
+	var deps []schema.ID
+	add := func(id schema.ID) {
+		for _, dep := range deps {
+			if dep == id {
+				return
+			}
+		}
+		deps = append(deps, id)
+	}
+
+	switch workload := w.Workloader.(type) {
+	case *generated.Container:
+		for _, mount := range workload.Volumes {
+			id, err := volumeDependency(ctx, db, w, mount.VolumeId)
+			if err != nil {
+				return nil, err
+			}
+			add(id)
+		}
+
+		for _, connection := range workload.NetworkConnection {
+			id, err := networkDependency(ctx, db, w, connection.NetworkId)
+			if err != nil {
+				return nil, err
+			}
+			add(id)
+		}
+	case *generated.K8S:
+		id, err := networkDependency(ctx, db, w, workload.NetworkId)
+		if err != nil {
+			return nil, err
+		}
+		add(id)
+
+		if workload.PublicIP != 0 {
+			if err := publicIPDependency(ctx, db, w, workload.PublicIP); err != nil {
+				return nil, err
+			}
+			add(workload.PublicIP)
+		}
+
+		masters, err := masterDependencies(ctx, db, w, workload.NetworkId, workload.MasterIps)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range masters {
+			add(id)
+		}
+	case *generated.VirtualMachine:
+		id, err := networkDependency(ctx, db, w, workload.NetworkId)
+		if err != nil {
+			return nil, err
+		}
+		add(id)
+
+		if workload.PublicIP != 0 {
+			if err := publicIPDependency(ctx, db, w, workload.PublicIP); err != nil {
+				return nil, err
+			}
+			add(workload.PublicIP)
+		}
+	}
+
+	return deps, nil
+}
+
+func volumeDependency(ctx context.Context, db *mongo.Database, w WorkloaderType, volumeID string) (schema.ID, error) {
+	id, err := volumeReference(volumeID)
+	if err != nil {
+		return 0, err
+	}
+
+	volume, err := WorkloadFilter{}.WithID(id).Get(ctx, db)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, errors.Wrapf(ErrInvalidDependency, "volume '%s' not found", volumeID)
+	} else if err != nil {
+		return 0, err
+	}
+
+	if volume.Workload().WorkloadId != volumeID {
+		return 0, errors.Wrapf(ErrInvalidDependency, "volume '%s' not found", volumeID)
+	}
+
+	volume, err = LatestVersion(ctx, db, volume)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, checkDependency(w, volume, generated.WorkloadTypeVolume, true)
+}
+
+// networkDependency returns the latest network resource of the network on the
+// node of the workload
+func networkDependency(ctx context.Context, db *mongo.Database, w WorkloaderType, name string) (schema.ID, error) {
+	resources, err := WorkloadFilter{}.
+		WithNetworkName(name).
+		WithNodeID(w.GetNodeID()).
+		WithActive().
+		Find(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+
+	var found *WorkloaderType
+	for i := range resources {
+		if resources[i].GetCustomerTid() != w.GetCustomerTid() {
+			continue
+		}
+		if found == nil || resources[i].GetID() > found.GetID() {
+			found = &resources[i]
+		}
+	}
+
+	if found == nil {
+		return 0, errors.Wrapf(ErrInvalidDependency, "network '%s' not found on node '%s'", name, w.GetNodeID())
+	}
+
+	return found.GetID(), nil
+}
+
+func publicIPDependency(ctx context.Context, db *mongo.Database, w WorkloaderType, id schema.ID) error {
+	ip, err := WorkloadFilter{}.WithID(id).Get(ctx, db)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return errors.Wrapf(ErrInvalidDependency, "public ip workload '%d' not found", id)
+	} else if err != nil {
+		return err
+	}
+
+	ip, err = LatestVersion(ctx, db, ip)
+	if err != nil {
+		return err
+	}
+
+	return checkDependency(w, ip, generated.WorkloadTypePublicIP, false)
+}
+
+// masterDependencies returns the kubernetes masters of the network with the
+// master ips of a worker
+func masterDependencies(ctx context.Context, db *mongo.Database, w WorkloaderType, network string, ips []net.IP) ([]schema.ID, error) {
+	if len(ips) == 0 {
+		return nil, nil
+	}
+
+	masters, err := WorkloadFilter{}.
+		WithWorkloadType(generated.WorkloadTypeKubernetes).
+		WithCustomerID(w.GetCustomerTid()).
+		WithNetworkID(network).
+		WithActive().
+		Find(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]schema.ID, 0, len(ips))
+	for _, ip := range ips {
+		var found bool
+		for _, master := range masters {
+			k8s, ok := master.Workloader.(*generated.K8S)
+			if ok && k8s.Ipaddress.Equal(ip) {
+				ids = append(ids, master.GetID())
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, errors.Wrapf(ErrInvalidDependency, "kubernetes master '%s' not found in network '%s'", ip, network)
+		}
+	}
+
+	return ids, nil
+}
+
+// WorkloadDependencyState loads the dependencies of a workload and returns
+// their progress, and the first dependency which failed. A dependency which
+// was updated is replaced by its latest version
+func WorkloadDependencyState(ctx context.Context, db *mongo.Database, w WorkloaderType) (DependencyState, *WorkloaderType, error) {
+	ids := w.GetDependsOn()
+	if len(ids) == 0 {
+		return DependenciesReady, nil, nil
+	}
+
+	deps, err := WorkloadFilter{}.WithIDs(ids).Find(ctx, db)
+	if err != nil {
+		return DependenciesPending, nil, err
+	}
+
+	if len(deps) != len(ids) {
+		return DependenciesFailed, nil, nil
+	}
+
+	for i := range deps {
+		if deps[i], err = LatestVersion(ctx, db, deps[i]); err != nil {
+			return DependenciesPending, nil, err
+		}
+	}
+
+	state, failed := DependencyStateOf(deps)
+	return state, failed, nil
+}
@@ -0,0 +1,99 @@
+package types
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	generated "github.com/threefoldtech/tfexplorer/models/generated/workloads"
+	"github.com/threefoldtech/tfexplorer/schema"
+)
+
+func TestVolumeReference(t *testing.T) {
+	id, err := volumeReference("12-1")
+	require.NoError(t, err)
+	assert.Equal(t, schema.ID(12), id)
+
+	for _, ref := range []string{"", "12", "-1", "volume-1"} {
+		_, err := volumeReference(ref)
+		assert.True(t, errors.Is(err, ErrInvalidDependency), "reference '%s'", ref)
+	}
+}
+
+func TestCheckDependency(t *testing.T) {
+	container := WorkloaderType{Workloader: &generated.Container{
+		ReservationInfo: generated.ReservationInfo{
+			CustomerTid:  1,
+			NodeId:       "node1",
+			WorkloadType: generated.WorkloadTypeContainer,
+		},
+	}}
+
+	volume := groupWorkload(Deploy, generated.ResultStateOK)
+	volume.Workloader.(*generated.Volume).NodeId = "node1"
+	assert.NoError(t, checkDependency(container, volume, generated.WorkloadTypeVolume, true))
+
+	assert.Error(t, checkDependency(container, volume, generated.WorkloadTypePublicIP, true), "wrong type")
+
+	volume.Workloader.(*generated.Volume).NodeId = "node2"
+	assert.Error(t, checkDependency(container, volume, generated.WorkloadTypeVolume, true), "other node")
+	assert.NoError(t, checkDependency(container, volume, generated.WorkloadTypeVolume, false))
+
+	foreign := groupWorkload(Deploy, generated.ResultStateOK)
+	foreign.Workloader.(*generated.Volume).NodeId = "node1"
+	foreign.SetCustomerTid(2)
+	err := checkDependency(container, foreign, generated.WorkloadTypeVolume, true)
+	assert.True(t, errors.Is(err, ErrInvalidDependency), "foreign workload")
+
+	deleted := groupWorkload(Deleted, generated.ResultStateDeleted)
+	deleted.Workloader.(*generated.Volume).NodeId = "node1"
+	assert.Error(t, checkDependency(container, deleted, generated.WorkloadTypeVolume, true))
+}
+
+func TestDependencyStateOf(t *testing.T) {
+	ok := groupWorkload(Deploy, generated.ResultStateOK)
+	pending := groupWorkload(Deploy, generated.ResultStateOK)
+	pending.SetResult(generated.Result{})
+	failed := groupWorkload(Delete, generated.ResultStateError)
+	failed.SetID(3)
+
+	state, dep := DependencyStateOf(nil)
+	assert.Equal(t, DependenciesReady, state)
+	assert.Nil(t, dep)
+
+	state, _ = DependencyStateOf([]WorkloaderType{ok, ok})
+	assert.Equal(t, DependenciesReady, state)
+
+	state, _ = DependencyStateOf([]WorkloaderType{ok, pending})
+	assert.Equal(t, DependenciesPending, state)
+
+	state, dep = DependencyStateOf([]WorkloaderType{pending, failed})
+	assert.Equal(t, DependenciesFailed, state)
+	require.NotNil(t, dep)
+	assert.Equal(t, schema.ID(3), dep.GetID())
+}
+
+func TestLatestVersion(t *testing.T) {
+	version := func(id, original schema.ID, revision int64, action generated.NextActionEnum) WorkloaderType {
+		return WorkloaderType{Workloader: &generated.PublicIP{
+			ReservationInfo: generated.ReservationInfo{
+				ID:           id,
+				Original:     original,
+				Revision:     revision,
+				NextAction:   action,
+				WorkloadType: generated.WorkloadTypePublicIP,
+			},
+		}}
+	}
+
+	first := version(1, 0, 0, Deleted)
+	second := version(2, 1, 1, Deploy)
+	failed := version(3, 1, 2, Invalid)
+
+	assert.Equal(t, schema.ID(2), latestVersion(first, []WorkloaderType{second, failed}).GetID(), "a superseded workload is replaced by its latest active version")
+	assert.Equal(t, schema.ID(1), latestVersion(first, []WorkloaderType{failed}).GetID(), "a deleted workload which was not superseded stays deleted")
+
+	active := version(1, 0, 0, Deploy)
+	assert.Equal(t, schema.ID(1), latestVersion(active, []WorkloaderType{second}).GetID(), "an active workload is its own latest version")
+}
@@ -0,0 +1,169 @@
+package types
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	generated "github.com/threefoldtech/tfexplorer/models/generated/workloads"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// EventCollection db collection name
+	EventCollection = "workload-event"
+)
+
+// EventKind is the kind of an event of the timeline of a workload
+type EventKind string
+
+const (
+	// EventCreated the workload is created by the customer
+	EventCreated EventKind = "created"
+	// EventSigned a signer signed the provisioning or the deletion of the
+	// workload
+	EventSigned EventKind = "signed"
+	// EventQueued the workload is scheduled for the node
+	EventQueued EventKind = "queued"
+	// EventFetched the node fetched the workload
+	EventFetched EventKind = "fetched"
+	// EventNotFetched the node polls its workloads but did not fetch the
+	// workload in time after it was queued
+	EventNotFetched EventKind = "not_fetched"
+	// EventResult the node reported the result of the workload
+	EventResult EventKind = "result"
+	// EventInvalid the workload is refused and never deployed
+	EventInvalid EventKind = "invalid"
+	// EventDeleteRequested the workload is scheduled for deletion
+	EventDeleteRequested EventKind = "delete_requested"
+	// EventDeleted the workload is deleted
+	EventDeleted EventKind = "deleted"
+)
+
+// WorkloadEvent is an entry of the timeline of a workload. The timeline is
+// append only, it keeps the history the workload itself overwrites, like
+// the results of a workload deployed again after a reboot of the node
+type WorkloadEvent struct {
+	WorkloadID schema.ID   `bson:"workload_id" json:"workload_id"`
+	Kind       EventKind   `bson:"kind" json:"kind"`
+	Epoch      schema.Date `bson:"epoch" json:"epoch"`
+	// NodeID is the node which fetched the workload or reported its result
+	NodeID string `bson:"node_id,omitempty" json:"node_id,omitempty"`
+	// Tid is the user which created or signed the workload
+	Tid int64 `bson:"tid,omitempty" json:"tid,omitempty"`
+	// State is the state of a result
+	State string `bson:"state,omitempty" json:"state,omitempty"`
+	// Message is the message of a result, or the signing request which is
+	// signed
+	Message string `bson:"message,omitempty" json:"message,omitempty"`
+}
+
+// NewWorkloadEvent returns an event of the workload which happens now
+func NewWorkloadEvent(id schema.ID, kind EventKind) WorkloadEvent {
+	return WorkloadEvent{
+		WorkloadID: id,
+		Kind:       kind,
+		Epoch:      schema.Date{Time: time.Now()},
+	}
+}
+
+// ResultEvent returns the event of the result of a workload
+func ResultEvent(id schema.ID, result Result) WorkloadEvent {
+	event := NewWorkloadEvent(id, EventResult)
+	event.NodeID = result.NodeId
+	event.State = result.State.String()
+	event.Message = result.Message
+	return event
+}
+
+// actionEvent returns the kind of the event of the change of the next action
+// of a workload, if the change is part of the timeline
+func actionEvent(action generated.NextActionEnum) (EventKind, bool) {
+	switch action {
+	case Invalid:
+		return EventInvalid, true
+	case Delete:
+		return EventDeleteRequested, true
+	case Deleted:
+		return EventDeleted, true
+	}
+
+	return "", false
+}
+
+// signedRequest returns the signing request the signatures of the mode sign
+func signedRequest(mode SignatureMode) string {
+	if mode == SignatureDelete {
+		return "delete"
+	}
+
+	return "provision"
+}
+
+// WorkloadEventPush appends the event to the timeline of the workload. The
+// timeline is informative, an error is logged and doesn't fail the change it
+// records
+func WorkloadEventPush(ctx context.Context, db *mongo.Database, event WorkloadEvent) {
+	col := db.Collection(EventCollection)
+	if _, err := col.InsertOne(ctx, event); err != nil {
+		log.Error().Err(err).Int64("id", int64(event.WorkloadID)).Str("kind", string(event.Kind)).Msg("failed to record workload event")
+	}
+}
+
+// WorkloadQueuedPush records the workload is scheduled for its node
+func WorkloadQueuedPush(ctx context.Context, db *mongo.Database, w WorkloaderType) {
+	event := NewWorkloadEvent(w.GetID(), EventQueued)
+	event.NodeID = w.GetNodeID()
+	WorkloadEventPush(ctx, db, event)
+}
+
+// WorkloadFetchedPush records the first fetch of the workload by the node,
+// the node fetches a queued workload until it reports its result
+func WorkloadFetchedPush(ctx context.Context, db *mongo.Database, id schema.ID, nodeID string) {
+	workloadEventPushOnce(ctx, db, id, EventFetched, nodeID)
+}
+
+// WorkloadNotFetchedPush records the node did not fetch the workload in time,
+// only once per node. It returns true if the workload was not flagged yet
+func WorkloadNotFetchedPush(ctx context.Context, db *mongo.Database, id schema.ID, nodeID string) bool {
+	return workloadEventPushOnce(ctx, db, id, EventNotFetched, nodeID)
+}
+
+// workloadEventPushOnce appends the event of the node to the timeline of the
+// workload, unless the timeline already has one. It returns true if the event
+// is appended
+func workloadEventPushOnce(ctx context.Context, db *mongo.Database, id schema.ID, kind EventKind, nodeID string) bool {
+	event := NewWorkloadEvent(id, kind)
+	event.NodeID = nodeID
+
+	col := db.Collection(EventCollection)
+	filter := bson.M{"workload_id": id, "kind": kind, "node_id": nodeID}
+	result, err := col.UpdateOne(ctx, filter, bson.M{"$setOnInsert": event}, options.Update().SetUpsert(true))
+	if err != nil {
+		log.Error().Err(err).Int64("id", int64(id)).Str("kind", string(kind)).Msg("failed to record workload event")
+		return false
+	}
+
+	return result.UpsertedCount > 0
+}
+
+// WorkloadEvents returns the timeline of the workload, in the order the
+// events happened
+func WorkloadEvents(ctx context.Context, db *mongo.Database, id schema.ID) ([]WorkloadEvent, error) {
+	col := db.Collection(EventCollection)
+	opts := options.Find().SetSort(bson.D{{Key: "epoch.time", Value: 1}, {Key: "_id", Value: 1}})
+	cur, err := col.Find(ctx, bson.M{"workload_id": id}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	events := []WorkloadEvent{}
+	if err := cur.All(ctx, &events); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
@@ -0,0 +1,55 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	generated "github.com/threefoldtech/tfexplorer/models/generated/workloads"
+	"github.com/threefoldtech/tfexplorer/schema"
+)
+
+func TestActionEvent(t *testing.T) {
+	cases := []struct {
+		action   generated.NextActionEnum
+		kind     EventKind
+		recorded bool
+	}{
+		{action: Create},
+		{action: Sign},
+		{action: Pay},
+		{action: Deploy},
+		{action: Update},
+		{action: Invalid, kind: EventInvalid, recorded: true},
+		{action: Delete, kind: EventDeleteRequested, recorded: true},
+		{action: Deleted, kind: EventDeleted, recorded: true},
+	}
+
+	for _, c := range cases {
+		kind, ok := actionEvent(c.action)
+		assert.Equal(t, c.recorded, ok, "action %s", c.action)
+		assert.Equal(t, c.kind, kind, "action %s", c.action)
+	}
+}
+
+func TestResultEvent(t *testing.T) {
+	result := Result{
+		WorkloadId: "12-1",
+		NodeId:     "node1",
+		State:      generated.ResultStateError,
+		Message:    "failed to download flist",
+		Epoch:      schema.Date{Time: time.Now()},
+	}
+
+	event := ResultEvent(12, result)
+	assert.Equal(t, schema.ID(12), event.WorkloadID)
+	assert.Equal(t, EventResult, event.Kind)
+	assert.Equal(t, "node1", event.NodeID)
+	assert.Equal(t, "error", event.State)
+	assert.Equal(t, "failed to download flist", event.Message)
+}
+
+func TestSignedRequest(t *testing.T) {
+	assert.Equal(t, "provision", signedRequest(SignatureProvision))
+	assert.Equal(t, "delete", signedRequest(SignatureDelete))
+}
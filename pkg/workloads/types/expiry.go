@@ -0,0 +1,89 @@
+package types
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"github.com/threefoldtech/zos/pkg/crypto"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ExpiryExtension moves the expiry time of a workload later. The expiry time
+// is not part of the signature of the workload, so the extension is signed by
+// the customer on its own
+type ExpiryExtension struct {
+	ExpiresAt schema.Date `json:"expires_at"`
+	Epoch     schema.Date `json:"epoch"`
+	Signature string      `json:"signature"`
+}
+
+// Validate the extension of the expiry time of the workload
+func (e *ExpiryExtension) Validate(workload WorkloaderType) error {
+	if e.ExpiresAt.Before(time.Now()) {
+		return fmt.Errorf("expires_at must be in the future")
+	}
+
+	if current := workload.GetExpiresAt(); !current.IsZero() && !e.ExpiresAt.After(current.Time) {
+		return fmt.Errorf("expires_at can only be extended, workload expires at %s", current.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// SignatureChallenge returns the data signed by the customer. It covers the
+// workload, so the signature of an extension can't be replayed on another
+// workload
+func (e *ExpiryExtension) SignatureChallenge(id schema.ID) []byte {
+	return []byte(fmt.Sprintf("expiry%d%d%d", id, e.Epoch.Unix(), e.ExpiresAt.Unix()))
+}
+
+// Sign the extension with the key of the customer
+func (e *ExpiryExtension) Sign(id schema.ID, sk ed25519.PrivateKey) error {
+	msg := sha256.Sum256(e.SignatureChallenge(id))
+	sig, err := crypto.Sign(sk, msg[:])
+	if err != nil {
+		return err
+	}
+
+	e.Signature = hex.EncodeToString(sig)
+	return nil
+}
+
+// Verify the customer signature of the extension
+// pk is the public key used as verification key in hex encoded format
+func (e *ExpiryExtension) Verify(id schema.ID, pk string) error {
+	key, err := crypto.KeyFromHex(pk)
+	if err != nil {
+		return errors.Wrap(err, "invalid verification key")
+	}
+
+	sig, err := hex.DecodeString(e.Signature)
+	if err != nil {
+		return errors.Wrap(err, "invalid signature format, expecting hex encoded string")
+	}
+
+	msg := sha256.Sum256(e.SignatureChallenge(id))
+	return crypto.Verify(key, msg[:], sig)
+}
+
+// WithExpiredAt filter the workloads which expire at or before the given time
+func (f WorkloadFilter) WithExpiredAt(at time.Time) WorkloadFilter {
+	return append(f, bson.E{Key: "expires_at.time", Value: bson.M{"$lte": at}})
+}
+
+// WorkloadSetExpiresAt sets the expiry time of a workload
+func WorkloadSetExpiresAt(ctx context.Context, db *mongo.Database, id schema.ID, expires schema.Date) error {
+	col := db.Collection(WorkloadCollection)
+	_, err := col.UpdateOne(ctx, WorkloadFilter{}.WithID(id), bson.M{
+		"$set": bson.M{"expires_at": expires},
+	})
+
+	return err
+}
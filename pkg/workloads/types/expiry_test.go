@@ -0,0 +1,85 @@
+package types
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	generated "github.com/threefoldtech/tfexplorer/models/generated/workloads"
+	"github.com/threefoldtech/zos/pkg/identity"
+
+	"github.com/threefoldtech/tfexplorer/schema"
+)
+
+func TestWorkloadExpired(t *testing.T) {
+	workload := groupWorkload(Deploy, generated.ResultStateOK)
+	assert.False(t, workload.Expired())
+
+	workload.SetExpiresAt(schema.Date{Time: time.Now().Add(time.Hour)})
+	assert.False(t, workload.Expired())
+
+	workload.SetExpiresAt(schema.Date{Time: time.Now().Add(-time.Second)})
+	assert.True(t, workload.Expired())
+}
+
+func TestPipelineExpiredWorkload(t *testing.T) {
+	workload := groupWorkload(Deploy, generated.ResultStateOK)
+	workload.SetExpiresAt(schema.Date{Time: time.Now().Add(-time.Second)})
+
+	pl, err := NewWorkloaderPipeline(workload)
+	require.NoError(t, err)
+
+	next, modified := pl.Next()
+	assert.True(t, modified)
+	assert.Equal(t, Delete, next.GetNextAction())
+}
+
+func TestValidateExpiresAt(t *testing.T) {
+	workload := groupWorkload(Create, 0)
+	workload.SetCustomerSignature("signature")
+	workload.SetPoolID(1)
+
+	workload.SetExpiresAt(schema.Date{Time: time.Now().Add(time.Hour)})
+	require.NoError(t, workload.Validate())
+
+	workload.SetExpiresAt(schema.Date{Time: time.Now().Add(-time.Hour)})
+	assert.Error(t, workload.Validate())
+}
+
+func TestExpiryExtensionValidate(t *testing.T) {
+	now := time.Now()
+	workload := groupWorkload(Deploy, generated.ResultStateOK)
+
+	extension := ExpiryExtension{ExpiresAt: schema.Date{Time: now.Add(time.Hour)}}
+	assert.NoError(t, extension.Validate(workload))
+
+	workload.SetExpiresAt(schema.Date{Time: now.Add(2 * time.Hour)})
+	assert.Error(t, extension.Validate(workload), "the expiry time can't be moved earlier")
+
+	extension.ExpiresAt = schema.Date{Time: now.Add(3 * time.Hour)}
+	assert.NoError(t, extension.Validate(workload))
+
+	extension.ExpiresAt = schema.Date{Time: now.Add(-time.Hour)}
+	assert.Error(t, extension.Validate(groupWorkload(Deploy, generated.ResultStateOK)))
+}
+
+func TestExpiryExtensionVerify(t *testing.T) {
+	kp, err := identity.GenerateKeyPair()
+	require.NoError(t, err)
+	pk := hex.EncodeToString(kp.PublicKey)
+
+	extension := ExpiryExtension{
+		ExpiresAt: schema.Date{Time: time.Now().Add(time.Hour)},
+		Epoch:     schema.Date{Time: time.Now()},
+	}
+	require.NoError(t, extension.Sign(1, kp.PrivateKey))
+	assert.NoError(t, extension.Verify(1, pk))
+
+	// the signature is bound to the workload
+	assert.Error(t, extension.Verify(2, pk))
+
+	extension.ExpiresAt = schema.Date{Time: extension.ExpiresAt.Add(time.Hour)}
+	assert.Error(t, extension.Verify(1, pk))
+}
@@ -0,0 +1,207 @@
+package types
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/tfexplorer/models"
+	generated "github.com/threefoldtech/tfexplorer/models/generated/workloads"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"github.com/threefoldtech/zos/pkg/crypto"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// GroupCollection db collection name
+	GroupCollection = "deployment-group"
+
+	// maxGroupSize is the maximum number of workloads in a deployment group
+	maxGroupSize = 100
+)
+
+// GroupState is the aggregated state of the workloads of a deployment group
+type GroupState string
+
+const (
+	// GroupStateDeploying is the state of a group until all the workloads
+	// are deployed
+	GroupStateDeploying GroupState = "deploying"
+	// GroupStateOK is the state of a group which workloads are all deployed
+	GroupStateOK GroupState = "ok"
+	// GroupStateError is the state of a group with a failed workload
+	GroupStateError GroupState = "error"
+	// GroupStateDeleted is the state of a group which workloads are all deleted
+	GroupStateDeleted GroupState = "deleted"
+)
+
+// DeploymentGroup is a bundle of workloads created in one request, and
+// tracked as a unit
+type DeploymentGroup struct {
+	ID                schema.ID   `bson:"_id" json:"id"`
+	CustomerTid       int64       `bson:"customer_tid" json:"customer_tid"`
+	CustomerSignature string      `bson:"customer_signature" json:"customer_signature"`
+	Epoch             schema.Date `bson:"epoch" json:"epoch"`
+	// Rollback deletes all the workloads of the group as soon as one of
+	// them reports an error
+	Rollback bool `bson:"rollback" json:"rollback"`
+	// WorkloadIDs are the ids of the workloads of the group, in the order
+	// of the workloads of the request
+	WorkloadIDs []schema.ID `bson:"workload_ids" json:"workload_ids"`
+
+	// Workloads of the group. They are sent in the creation request, and
+	// loaded with the group, they are not saved with the group
+	Workloads []WorkloaderType `bson:"-" json:"workloads"`
+	// State is the aggregated state of the workloads of the group
+	State GroupState `bson:"-" json:"state,omitempty"`
+}
+
+// Validate the group request
+func (g *DeploymentGroup) Validate() error {
+	if len(g.Workloads) == 0 {
+		return fmt.Errorf("a deployment group must have at least one workload")
+	}
+
+	if len(g.Workloads) > maxGroupSize {
+		return fmt.Errorf("a deployment group can't have more than %d workloads", maxGroupSize)
+	}
+
+	for i, w := range g.Workloads {
+		if w.Workloader == nil {
+			return fmt.Errorf("workload %d of the group is not set", i)
+		}
+
+		if w.GetCustomerTid() != g.CustomerTid {
+			return fmt.Errorf("workload %d of the group is not owned by the group customer", i)
+		}
+	}
+
+	return nil
+}
+
+// SignatureChallenge returns the data signed by the customer. The group
+// signature covers the rollback policy and the challenges of all the
+// workloads, so workloads can't be added to or removed from the group
+func (g *DeploymentGroup) SignatureChallenge() ([]byte, error) {
+	b := &bytes.Buffer{}
+
+	if _, err := fmt.Fprintf(b, "%d", g.CustomerTid); err != nil {
+		return nil, err
+	}
+	if _, err := fmt.Fprintf(b, "%t", g.Rollback); err != nil {
+		return nil, err
+	}
+
+	for _, w := range g.Workloads {
+		challenge, err := w.SignatureChallenge()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := b.Write(challenge); err != nil {
+			return nil, err
+		}
+	}
+
+	return b.Bytes(), nil
+}
+
+// Verify the customer signature of the group
+// pk is the public key used as verification key in hex encoded format
+func (g *DeploymentGroup) Verify(pk string) error {
+	key, err := crypto.KeyFromHex(pk)
+	if err != nil {
+		return errors.Wrap(err, "invalid verification key")
+	}
+
+	signature, err := hex.DecodeString(g.CustomerSignature)
+	if err != nil {
+		return errors.Wrap(err, "invalid signature format, expecting hex encoded string")
+	}
+
+	b, err := g.SignatureChallenge()
+	if err != nil {
+		return err
+	}
+
+	msg := sha256.Sum256(b)
+
+	return crypto.Verify(key, msg[:], signature)
+}
+
+// GroupStateOf aggregates the state of the workloads of a group
+func GroupStateOf(workloads []WorkloaderType) GroupState {
+	var deployed, deleted int
+	for _, w := range workloads {
+		result := w.GetResult()
+		// the result is empty until the node reports it
+		reported := len(result.WorkloadId) != 0
+
+		switch {
+		case w.GetNextAction() == Invalid || (reported && result.State == generated.ResultStateError):
+			return GroupStateError
+		case w.IsAny(Delete, Deleted):
+			deleted++
+		case w.IsAny(Deploy) && reported && result.State == generated.ResultStateOK:
+			deployed++
+		}
+	}
+
+	switch {
+	case len(workloads) == 0:
+		return GroupStateDeploying
+	case deleted == len(workloads):
+		return GroupStateDeleted
+	case deployed == len(workloads):
+		return GroupStateOK
+	}
+
+	return GroupStateDeploying
+}
+
+// GroupFilter type
+type GroupFilter bson.D
+
+// WithID filter group with ID
+func (f GroupFilter) WithID(id schema.ID) GroupFilter {
+	return append(f, bson.E{Key: "_id", Value: id})
+}
+
+// WithWorkloadID filter the group of a workload
+func (f GroupFilter) WithWorkloadID(id schema.ID) GroupFilter {
+	return append(f, bson.E{Key: "workload_ids", Value: id})
+}
+
+// Get gets single group that matches the filter
+func (f GroupFilter) Get(ctx context.Context, db *mongo.Database) (DeploymentGroup, error) {
+	if f == nil {
+		f = GroupFilter{}
+	}
+
+	var group DeploymentGroup
+	result := db.Collection(GroupCollection).FindOne(ctx, f)
+	if err := result.Err(); err != nil {
+		return group, err
+	}
+
+	if err := result.Decode(&group); err != nil {
+		return group, errors.Wrap(err, "could not decode deployment group")
+	}
+
+	return group, nil
+}
+
+// GroupCreate saves a new deployment group to the database
+func GroupCreate(ctx context.Context, db *mongo.Database, group DeploymentGroup) (schema.ID, error) {
+	id := models.MustID(ctx, db, GroupCollection)
+	group.ID = id
+
+	if _, err := db.Collection(GroupCollection).InsertOne(ctx, group); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
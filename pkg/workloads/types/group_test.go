@@ -0,0 +1,95 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg/crypto"
+	"github.com/threefoldtech/zos/pkg/identity"
+
+	generated "github.com/threefoldtech/tfexplorer/models/generated/workloads"
+)
+
+func groupWorkload(action generated.NextActionEnum, state generated.ResultStateEnum) WorkloaderType {
+	return WorkloaderType{Workloader: &generated.Volume{
+		ReservationInfo: generated.ReservationInfo{
+			CustomerTid:  1,
+			NextAction:   action,
+			Result:       generated.Result{WorkloadId: "1-1", State: state},
+			WorkloadType: generated.WorkloadTypeVolume,
+		},
+	}}
+}
+
+func TestGroupStateOf(t *testing.T) {
+	ok := groupWorkload(Deploy, generated.ResultStateOK)
+	pending := groupWorkload(Deploy, generated.ResultStateOK)
+	pending.SetResult(generated.Result{})
+	failed := groupWorkload(Delete, generated.ResultStateError)
+	deleted := groupWorkload(Deleted, generated.ResultStateDeleted)
+
+	tests := []struct {
+		name      string
+		workloads []WorkloaderType
+		want      GroupState
+	}{
+		{name: "empty", want: GroupStateDeploying},
+		{name: "deploying", workloads: []WorkloaderType{ok, pending}, want: GroupStateDeploying},
+		{name: "ok", workloads: []WorkloaderType{ok, ok}, want: GroupStateOK},
+		{name: "error", workloads: []WorkloaderType{ok, failed, pending}, want: GroupStateError},
+		{name: "invalid", workloads: []WorkloaderType{ok, groupWorkload(Invalid, generated.ResultStateOK)}, want: GroupStateError},
+		{name: "partly_deleted", workloads: []WorkloaderType{ok, deleted}, want: GroupStateDeploying},
+		{name: "deleted", workloads: []WorkloaderType{deleted, deleted}, want: GroupStateDeleted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, GroupStateOf(tt.workloads))
+		})
+	}
+}
+
+func TestGroupValidate(t *testing.T) {
+	group := DeploymentGroup{CustomerTid: 1}
+	assert.Error(t, group.Validate())
+
+	group.Workloads = []WorkloaderType{groupWorkload(Create, 0), groupWorkload(Create, 0)}
+	assert.NoError(t, group.Validate())
+
+	other := groupWorkload(Create, 0)
+	other.Workloader.(*generated.Volume).CustomerTid = 2
+	group.Workloads = append(group.Workloads, other)
+	assert.Error(t, group.Validate())
+}
+
+func TestGroupVerify(t *testing.T) {
+	kp, err := identity.GenerateKeyPair()
+	require.NoError(t, err)
+
+	group := DeploymentGroup{
+		CustomerTid: 1,
+		Rollback:    true,
+		Workloads:   []WorkloaderType{groupWorkload(Create, 0), groupWorkload(Create, 0)},
+	}
+
+	challenge, err := group.SignatureChallenge()
+	require.NoError(t, err)
+	msg := sha256.Sum256(challenge)
+	signature, err := crypto.Sign(kp.PrivateKey, msg[:])
+	require.NoError(t, err)
+	group.CustomerSignature = hex.EncodeToString(signature)
+
+	assert.NoError(t, group.Verify(hex.EncodeToString(kp.PublicKey)))
+
+	// the signature covers the rollback policy
+	group.Rollback = false
+	assert.Error(t, group.Verify(hex.EncodeToString(kp.PublicKey)))
+	group.Rollback = true
+
+	// and the workloads of the group
+	group.Workloads = group.Workloads[:1]
+	assert.Error(t, group.Verify(hex.EncodeToString(kp.PublicKey)))
+}
@@ -0,0 +1,96 @@
+package types
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"github.com/threefoldtech/zos/pkg/crypto"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// LabelResource is the kind of resource of a labels update
+type LabelResource string
+
+const (
+	// LabelResourceWorkload labels update of a workload
+	LabelResourceWorkload LabelResource = "workload"
+	// LabelResourcePool labels update of a capacity pool
+	LabelResourcePool LabelResource = "pool"
+)
+
+// LabelsUpdate replaces the labels of a workload or a pool. The labels are
+// not part of the signature of the resource, so the update is signed by the
+// customer on its own
+type LabelsUpdate struct {
+	Labels    schema.Labels `json:"labels"`
+	Epoch     schema.Date   `json:"epoch"`
+	Signature string        `json:"signature"`
+}
+
+// SignatureChallenge returns the data signed by the customer. It covers the
+// resource, so the signature of an update can't be replayed on another
+// resource
+func (u *LabelsUpdate) SignatureChallenge(resource LabelResource, id schema.ID) []byte {
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "%s%d%d", resource, id, u.Epoch.Unix())
+	for _, pair := range u.Labels.Pairs() {
+		b.WriteString(pair)
+	}
+
+	return b.Bytes()
+}
+
+// Sign the update with the key of the customer
+func (u *LabelsUpdate) Sign(resource LabelResource, id schema.ID, sk ed25519.PrivateKey) error {
+	msg := sha256.Sum256(u.SignatureChallenge(resource, id))
+	sig, err := crypto.Sign(sk, msg[:])
+	if err != nil {
+		return err
+	}
+
+	u.Signature = hex.EncodeToString(sig)
+	return nil
+}
+
+// Verify the customer signature of the update
+// pk is the public key used as verification key in hex encoded format
+func (u *LabelsUpdate) Verify(resource LabelResource, id schema.ID, pk string) error {
+	key, err := crypto.KeyFromHex(pk)
+	if err != nil {
+		return errors.Wrap(err, "invalid verification key")
+	}
+
+	sig, err := hex.DecodeString(u.Signature)
+	if err != nil {
+		return errors.Wrap(err, "invalid signature format, expecting hex encoded string")
+	}
+
+	msg := sha256.Sum256(u.SignatureChallenge(resource, id))
+	return crypto.Verify(key, msg[:], sig)
+}
+
+// WithLabels filter workloads which have all the labels of the selector
+func (f WorkloadFilter) WithLabels(selector schema.Labels) WorkloadFilter {
+	if len(selector) == 0 {
+		return f
+	}
+
+	return append(f, bson.E{Key: "labels", Value: bson.M{"$all": selector.Pairs()}})
+}
+
+// WorkloadSetLabels replaces the labels of a workload
+func WorkloadSetLabels(ctx context.Context, db *mongo.Database, id schema.ID, labels schema.Labels) error {
+	col := db.Collection(WorkloadCollection)
+	_, err := col.UpdateOne(ctx, WorkloadFilter{}.WithID(id), bson.M{
+		"$set": bson.M{"labels": labels},
+	})
+
+	return err
+}
@@ -0,0 +1,63 @@
+package types
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg/identity"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/threefoldtech/tfexplorer/schema"
+)
+
+func TestLabelsUpdateVerify(t *testing.T) {
+	kp, err := identity.GenerateKeyPair()
+	require.NoError(t, err)
+	pk := hex.EncodeToString(kp.PublicKey)
+
+	update := LabelsUpdate{
+		Labels: schema.Labels{"project": "web", "env": "prod"},
+		Epoch:  schema.Date{Time: time.Now()},
+	}
+	require.NoError(t, update.Sign(LabelResourceWorkload, 1, kp.PrivateKey))
+	assert.NoError(t, update.Verify(LabelResourceWorkload, 1, pk))
+
+	// the signature is bound to the resource
+	assert.Error(t, update.Verify(LabelResourceWorkload, 2, pk))
+	assert.Error(t, update.Verify(LabelResourcePool, 1, pk))
+
+	update.Labels["env"] = "dev"
+	assert.Error(t, update.Verify(LabelResourceWorkload, 1, pk))
+}
+
+func TestLabelsNotSigned(t *testing.T) {
+	workload := groupWorkload(Create, 0)
+	challenge, err := workload.SignatureChallenge()
+	require.NoError(t, err)
+
+	workload.SetLabels(schema.Labels{"project": "web"})
+	labeled, err := workload.SignatureChallenge()
+	require.NoError(t, err)
+
+	assert.Equal(t, challenge, labeled)
+}
+
+func TestWithLabels(t *testing.T) {
+	assert.Equal(t, WorkloadFilter(nil), WorkloadFilter(nil).WithLabels(nil))
+	assert.Equal(t, WorkloadFilter{
+		{Key: "labels", Value: bson.M{"$all": []string{"env=prod", "project=web"}}},
+	}, WorkloadFilter{}.WithLabels(schema.Labels{"project": "web", "env": "prod"}))
+}
+
+func TestValidateLabels(t *testing.T) {
+	workload := groupWorkload(Create, 0)
+	workload.SetCustomerSignature("signature")
+	workload.SetPoolID(1)
+	require.NoError(t, workload.Validate())
+
+	workload.SetLabels(schema.Labels{"project": "a,b"})
+	assert.Error(t, workload.Validate())
+}
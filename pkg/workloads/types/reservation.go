@@ -3,6 +3,8 @@ package types
 import (
 	"bytes"
 	"context"
+	"crypto/ed25519"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -15,6 +17,7 @@ import (
 	"github.com/threefoldtech/tfexplorer/models/generated/workloads"
 	generated "github.com/threefoldtech/tfexplorer/models/generated/workloads"
 	"github.com/threefoldtech/tfexplorer/schema"
+	"github.com/threefoldtech/zos/pkg"
 	"github.com/threefoldtech/zos/pkg/crypto"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -42,6 +45,8 @@ const (
 	Invalid = generated.NextActionInvalid
 	// Deleted action
 	Deleted = generated.NextActionDeleted
+	// Update action
+	Update = generated.NextActionUpdate
 )
 
 // ApplyQueryFilter parese the query string
@@ -689,6 +694,46 @@ func (r *Result) Verify(pk string) error {
 	// return crypto.Verify(key, bytes, sig)
 }
 
+// DeletedReport is sent by a node once it deleted a workload. The node signs
+// the id of the workload and the time of the report
+type DeletedReport struct {
+	WorkloadId string      `json:"workload_id"`
+	Epoch      schema.Date `json:"epoch"`
+	Signature  string      `json:"signature"`
+}
+
+// encode the data signed by the node
+func (d *DeletedReport) encode() []byte {
+	return []byte(fmt.Sprintf("%s%d", d.WorkloadId, d.Epoch.Unix()))
+}
+
+// Sign the report with the key of the node
+func (d *DeletedReport) Sign(sk ed25519.PrivateKey) error {
+	sig, err := crypto.Sign(sk, d.encode())
+	if err != nil {
+		return err
+	}
+
+	d.Signature = hex.EncodeToString(sig)
+	return nil
+}
+
+// Verify that the signature matches the report data
+// pk is the id of the node which deleted the workload
+func (d *DeletedReport) Verify(pk string) error {
+	sig, err := hex.DecodeString(d.Signature)
+	if err != nil {
+		return errors.Wrap(err, "invalid signature expecting hex encoded")
+	}
+
+	key, err := crypto.KeyFromID(pkg.StrIdentifier(pk))
+	if err != nil {
+		return errors.Wrap(err, "invalid verification key")
+	}
+
+	return crypto.Verify(key, d.encode(), sig)
+}
+
 // ResultPush pushes result to a reservation result array.
 // NOTE: this is just a crud operation, no validation is done here
 func ResultPush(ctx context.Context, db *mongo.Database, id schema.ID, result Result) error {
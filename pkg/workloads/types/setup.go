@@ -6,6 +6,7 @@ import (
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // Setup sets up indexes for types, must be called at least
@@ -73,6 +74,72 @@ func Setup(ctx context.Context, db *mongo.Database) error {
 		{
 			Keys: bson.M{"public_ip": 1},
 		},
+		{
+			Keys: bson.M{"original": 1},
+		},
+		{
+			Keys: bson.M{"depends_on": 1},
+		},
+		{
+			Keys: bson.M{"result.state": 1},
+		},
+		{
+			Keys: bson.M{"epoch.time": 1},
+		},
+		{
+			Keys: bson.M{"labels": 1},
+		},
+		{
+			Keys: bson.M{"expires_at.time": 1},
+		},
+	}
+
+	if _, err := col.Indexes().CreateMany(ctx, indexes); err != nil {
+		return err
+	}
+
+	col = db.Collection(ApprovalCollection)
+	indexes = []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "workload_id", Value: 1}, {Key: "kind", Value: 1}},
+		},
+		{
+			Keys: bson.M{"tid": 1},
+		},
+	}
+
+	if _, err := col.Indexes().CreateMany(ctx, indexes); err != nil {
+		return err
+	}
+
+	col = db.Collection(GroupCollection)
+	indexes = []mongo.IndexModel{
+		{
+			Keys: bson.M{"workload_ids": 1},
+		},
+	}
+
+	if _, err := col.Indexes().CreateMany(ctx, indexes); err != nil {
+		return err
+	}
+
+	col = db.Collection(EventCollection)
+	indexes = []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "workload_id", Value: 1}, {Key: "kind", Value: 1}, {Key: "node_id", Value: 1}},
+		},
+	}
+
+	if _, err := col.Indexes().CreateMany(ctx, indexes); err != nil {
+		return err
+	}
+
+	col = db.Collection(NodePollCollection)
+	indexes = []mongo.IndexModel{
+		{
+			Keys:    bson.M{"node_id": 1},
+			Options: options.Index().SetUnique(true),
+		},
 	}
 
 	if _, err := col.Indexes().CreateMany(ctx, indexes); err != nil {
@@ -9,7 +9,10 @@ import (
 	"fmt"
 	"math"
 	"net/http"
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/threefoldtech/tfexplorer/models"
@@ -18,6 +21,7 @@ import (
 	"github.com/threefoldtech/tfexplorer/schema"
 	"github.com/threefoldtech/zos/pkg/crypto"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -53,9 +57,138 @@ func ApplyQueryFilterWorkload(r *http.Request, filter WorkloadFilter) (WorkloadF
 		}
 		filter = filter.WithWorkloadType(workloads.WorkloadTypeEnum(workloadType))
 	}
+	poolID, err := models.QueryInt(r, "pool_id")
+	if err != nil {
+		return nil, errors.Wrap(err, "pool_id should be an integer")
+	}
+	if poolID != 0 {
+		filter = filter.WithPoolID(poolID)
+	}
+	if nodeID := r.FormValue("node_id"); len(nodeID) != 0 {
+		filter = filter.WithNodeID(nodeID)
+	}
+	from, err := models.QueryInt(r, "from_epoch")
+	if err != nil {
+		return nil, errors.Wrap(err, "from_epoch should be a unix timestamp")
+	}
+	to, err := models.QueryInt(r, "to_epoch")
+	if err != nil {
+		return nil, errors.Wrap(err, "to_epoch should be a unix timestamp")
+	}
+	if from != 0 || to != 0 {
+		filter = filter.WithEpochRange(from, to)
+	}
+	if sState := r.FormValue("result_state"); len(sState) != 0 {
+		state := WorkloadResultState(sState)
+		if !state.Valid() {
+			return nil, fmt.Errorf("result_state should be one of %v", WorkloadResultStates)
+		}
+		filter = filter.WithResultState(state)
+	}
+	if metadata := r.FormValue("metadata"); len(metadata) != 0 {
+		filter = filter.WithMetadata(metadata)
+	}
+	labels, err := schema.ParseLabels(r.FormValue("labels"))
+	if err != nil {
+		return nil, errors.Wrap(err, "labels should be a list of key=value")
+	}
+	filter = filter.WithLabels(labels)
+	if sActive := r.FormValue("active"); len(sActive) != 0 {
+		active, err := strconv.ParseBool(sActive)
+		if err != nil {
+			return nil, errors.Wrap(err, "active should be a boolean")
+		}
+		if active {
+			filter = filter.WithActive()
+		}
+	}
 	return filter, nil
 }
 
+// workloadSortFields are the fields the workloads can be sorted by, with the
+// name used in the query string
+var workloadSortFields = map[string]string{
+	"id":            "_id",
+	"epoch":         "epoch.time",
+	"pool_id":       "pool_id",
+	"node_id":       "node_id",
+	"next_action":   "next_action",
+	"workload_type": "workload_type",
+}
+
+// SortWorkloadFromRequest parses the sort query string, a comma separated
+// list of fields prefixed with - for descending order. It returns nil if the
+// request has no sort. The workload id is always used as last sort key, so
+// the order of the pages is stable
+func SortWorkloadFromRequest(r *http.Request) (bson.D, error) {
+	value := r.FormValue("sort")
+	if len(value) == 0 {
+		return nil, nil
+	}
+
+	var sort bson.D
+	seen := make(map[string]bool)
+	for _, field := range strings.Split(value, ",") {
+		order := 1
+		if strings.HasPrefix(field, "-") {
+			field = strings.TrimPrefix(field, "-")
+			order = -1
+		}
+
+		key, ok := workloadSortFields[field]
+		if !ok {
+			return nil, fmt.Errorf("can't sort workloads by '%s'", field)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("workloads are sorted twice by '%s'", field)
+		}
+		seen[key] = true
+
+		sort = append(sort, bson.E{Key: key, Value: order})
+	}
+
+	if !seen["_id"] {
+		sort = append(sort, bson.E{Key: "_id", Value: 1})
+	}
+
+	return sort, nil
+}
+
+// WorkloadResultState is the state of a workload result, as searched by the
+// customers
+type WorkloadResultState string
+
+const (
+	// WorkloadResultOK workloads deployed by the node
+	WorkloadResultOK WorkloadResultState = "ok"
+	// WorkloadResultError workloads the node failed to deploy
+	WorkloadResultError WorkloadResultState = "error"
+	// WorkloadResultDeleted workloads deleted by the node
+	WorkloadResultDeleted WorkloadResultState = "deleted"
+	// WorkloadResultPending workloads without result from the node yet
+	WorkloadResultPending WorkloadResultState = "pending"
+)
+
+// WorkloadResultStates are all the result states, in the order of the
+// states summary of the workloads list
+var WorkloadResultStates = []WorkloadResultState{
+	WorkloadResultOK,
+	WorkloadResultError,
+	WorkloadResultDeleted,
+	WorkloadResultPending,
+}
+
+// Valid checks the state is a known result state
+func (s WorkloadResultState) Valid() bool {
+	for _, state := range WorkloadResultStates {
+		if s == state {
+			return true
+		}
+	}
+
+	return false
+}
+
 // WorkloadFilter type
 type WorkloadFilter bson.D
 
@@ -64,6 +197,11 @@ func (f WorkloadFilter) WithID(id schema.ID) WorkloadFilter {
 	return append(f, bson.E{Key: "_id", Value: id})
 }
 
+// WithIDs filter workloads with one of the IDs
+func (f WorkloadFilter) WithIDs(ids []schema.ID) WorkloadFilter {
+	return append(f, bson.E{Key: "_id", Value: bson.M{"$in": ids}})
+}
+
 // WithIDGE return find workloads with
 func (f WorkloadFilter) WithIDGE(id schema.ID) WorkloadFilter {
 	return append(f, bson.E{
@@ -113,6 +251,74 @@ func (f WorkloadFilter) WithPublicIP(publicIP schema.ID) WorkloadFilter {
 	})
 }
 
+// WithVersionsOf filters all the versions of an updated workload, including
+// the original workload
+func (f WorkloadFilter) WithVersionsOf(original schema.ID) WorkloadFilter {
+	return append(f, bson.E{
+		Key: "$or", Value: bson.A{
+			bson.M{"_id": original},
+			bson.M{"original": original},
+		},
+	})
+}
+
+// WithRevision filters a version of an updated workload, revision 0 being
+// the original workload
+func (f WorkloadFilter) WithRevision(original schema.ID, revision int64) WorkloadFilter {
+	if revision == 0 {
+		return f.WithID(original)
+	}
+
+	return append(f,
+		bson.E{Key: "original", Value: original},
+		bson.E{Key: "revision", Value: revision},
+	)
+}
+
+// WithNodeIDs filter workloads deployed on one of the nodes
+func (f WorkloadFilter) WithNodeIDs(ids []string) WorkloadFilter {
+	return append(f, bson.E{Key: "node_id", Value: bson.M{"$in": ids}})
+}
+
+// WithEpochRange filter workloads created between the from and to unix
+// timestamps, both included. A zero bound is not used
+func (f WorkloadFilter) WithEpochRange(from, to int64) WorkloadFilter {
+	epoch := bson.M{}
+	if from != 0 {
+		epoch["$gte"] = time.Unix(from, 0)
+	}
+	if to != 0 {
+		epoch["$lte"] = time.Unix(to, 0)
+	}
+
+	return append(f, bson.E{Key: "epoch.time", Value: epoch})
+}
+
+// WithResultState filter workloads on the state of their result. The result
+// of a workload is empty until the node reports it
+func (f WorkloadFilter) WithResultState(state WorkloadResultState) WorkloadFilter {
+	reported := bson.E{Key: "result.workload_id", Value: bson.M{"$nin": bson.A{nil, ""}}}
+
+	switch state {
+	case WorkloadResultOK:
+		return append(f, reported, bson.E{Key: "result.state", Value: generated.ResultStateOK})
+	case WorkloadResultError:
+		return append(f, reported, bson.E{Key: "result.state", Value: generated.ResultStateError})
+	case WorkloadResultDeleted:
+		return append(f, reported, bson.E{Key: "result.state", Value: generated.ResultStateDeleted})
+	}
+
+	return append(f, bson.E{Key: "result.workload_id", Value: bson.M{"$in": bson.A{nil, ""}}})
+}
+
+// WithMetadata filter workloads which metadata contains the text, ignoring
+// the case
+func (f WorkloadFilter) WithMetadata(text string) WorkloadFilter {
+	return append(f, bson.E{
+		Key: "metadata", Value: primitive.Regex{Pattern: regexp.QuoteMeta(text), Options: "i"},
+	})
+}
+
 // Or returns filter that reads as (f or o)
 func (f WorkloadFilter) Or(o WorkloadFilter) WorkloadFilter {
 	return WorkloadFilter{
@@ -353,6 +559,13 @@ func (w *WorkloaderType) IsAny(status ...generated.NextActionEnum) bool {
 	return false
 }
 
+// Expired checks if the workload has an expiry time, and the time is
+// exceeded
+func (w *WorkloaderType) Expired() bool {
+	expires := w.GetExpiresAt()
+	return !expires.IsZero() && time.Until(expires.Time) <= 0
+}
+
 //ResultOf return result of a workload ID
 func (w *WorkloaderType) ResultOf(id string) *Result {
 	if w.GetResult().WorkloadId == id {
@@ -404,6 +617,10 @@ func WorkloadCreate(ctx context.Context, db *mongo.Database, w WorkloaderType) (
 		return 0, err
 	}
 
+	event := NewWorkloadEvent(id, EventCreated)
+	event.Tid = w.GetCustomerTid()
+	WorkloadEventPush(ctx, db, event)
+
 	return id, nil
 }
 
@@ -416,9 +633,11 @@ func WorkloadsLastID(ctx context.Context, db *mongo.Database) (schema.ID, error)
 func WorkloadSetNextAction(ctx context.Context, db *mongo.Database, id schema.ID, action generated.NextActionEnum) error {
 	var filter WorkloadFilter
 	filter = filter.WithID(id)
+	// only the changes of the action are recorded in the timeline
+	filter = append(filter, bson.E{Key: "next_action", Value: bson.M{"$ne": action}})
 
 	col := db.Collection(WorkloadCollection)
-	_, err := col.UpdateOne(ctx, filter, bson.M{
+	result, err := col.UpdateOne(ctx, filter, bson.M{
 		"$set": bson.M{
 			"next_action": action,
 		},
@@ -428,6 +647,10 @@ func WorkloadSetNextAction(ctx context.Context, db *mongo.Database, id schema.ID
 		return err
 	}
 
+	if kind, ok := actionEvent(action); ok && result.ModifiedCount > 0 {
+		WorkloadEventPush(ctx, db, NewWorkloadEvent(id, kind))
+	}
+
 	return nil
 }
 
@@ -444,6 +667,22 @@ func WorkloadToDeploy(ctx context.Context, db *mongo.Database, w WorkloaderType)
 		return errors.Wrap(err, "failed to schedule workload for deploying")
 	}
 
+	WorkloadQueuedPush(ctx, db, w)
+	return nil
+}
+
+// WorkloadToUpdate marks a new version of a workload to update and schedule
+// it for the nodes
+func WorkloadToUpdate(ctx context.Context, db *mongo.Database, w WorkloaderType) error {
+	if err := WorkloadSetNextAction(ctx, db, w.GetID(), Update); err != nil {
+		return errors.Wrap(err, "failed to set workload to UPDATE state")
+	}
+
+	if err := WorkloadTypePush(ctx, db, w); err != nil {
+		return errors.Wrap(err, "failed to schedule workload for updating")
+	}
+
+	WorkloadQueuedPush(ctx, db, w)
 	return nil
 }
 
@@ -460,8 +699,16 @@ func WorkloadPushSignature(ctx context.Context, db *mongo.Database, id schema.ID
 			string(mode): signature,
 		},
 	})
+	if err != nil {
+		return err
+	}
 
-	return err
+	event := NewWorkloadEvent(id, EventSigned)
+	event.Tid = signature.Tid
+	event.Message = signedRequest(mode)
+	WorkloadEventPush(ctx, db, event)
+
+	return nil
 }
 
 // WorkloadTypePush pushes a workload to the queue
@@ -494,8 +741,12 @@ func WorkloadResultPush(ctx context.Context, db *mongo.Database, id schema.ID, r
 			},
 		},
 	)
+	if err != nil {
+		return err
+	}
 
-	return err
+	WorkloadEventPush(ctx, db, ResultEvent(id, result))
+	return nil
 }
 
 // Validate that the reservation is valid
@@ -512,6 +763,14 @@ func (w *WorkloaderType) Validate() error {
 		return fmt.Errorf("metadata can not be bigger than 1024 bytes")
 	}
 
+	if err := w.GetLabels().Validate(); err != nil {
+		return err
+	}
+
+	if expires := w.GetExpiresAt(); !expires.IsZero() && expires.Before(time.Now()) {
+		return fmt.Errorf("expires_at must be in the future")
+	}
+
 	if w.GetPoolID() == 0 {
 		return errors.New("pool is required")
 	}
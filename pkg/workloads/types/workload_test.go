@@ -0,0 +1,76 @@
+package types
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	generated "github.com/threefoldtech/tfexplorer/models/generated/workloads"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestApplyQueryFilterWorkload(t *testing.T) {
+	r := httptest.NewRequest("GET", "/workloads?pool_id=12&node_id=node&from_epoch=100&to_epoch=200&result_state=error&metadata=my.app", nil)
+
+	filter, err := ApplyQueryFilterWorkload(r, nil)
+	require.NoError(t, err)
+	require.Equal(t, WorkloadFilter{
+		{Key: "pool_id", Value: int64(12)},
+		{Key: "node_id", Value: "node"},
+		{Key: "epoch.time", Value: bson.M{"$gte": time.Unix(100, 0), "$lte": time.Unix(200, 0)}},
+		{Key: "result.workload_id", Value: bson.M{"$nin": bson.A{nil, ""}}},
+		{Key: "result.state", Value: generated.ResultStateError},
+		{Key: "metadata", Value: primitive.Regex{Pattern: `my\.app`, Options: "i"}},
+	}, filter)
+}
+
+func TestApplyQueryFilterWorkloadInvalid(t *testing.T) {
+	for _, query := range []string{
+		"pool_id=abc",
+		"from_epoch=yesterday",
+		"to_epoch=tomorrow",
+		"result_state=unknown",
+	} {
+		r := httptest.NewRequest("GET", "/workloads?"+query, nil)
+		_, err := ApplyQueryFilterWorkload(r, nil)
+		require.Error(t, err, query)
+	}
+}
+
+func TestWithResultStatePending(t *testing.T) {
+	filter := WorkloadFilter{}.WithResultState(WorkloadResultPending)
+	require.Equal(t, WorkloadFilter{
+		{Key: "result.workload_id", Value: bson.M{"$in": bson.A{nil, ""}}},
+	}, filter)
+}
+
+func TestSortWorkloadFromRequest(t *testing.T) {
+	tests := []struct {
+		query string
+		sort  bson.D
+		err   bool
+	}{
+		{query: "", sort: nil},
+		{query: "sort=epoch", sort: bson.D{{Key: "epoch.time", Value: 1}, {Key: "_id", Value: 1}}},
+		{query: "sort=-pool_id,node_id", sort: bson.D{{Key: "pool_id", Value: -1}, {Key: "node_id", Value: 1}, {Key: "_id", Value: 1}}},
+		{query: "sort=-id", sort: bson.D{{Key: "_id", Value: -1}}},
+		{query: "sort=customer_signature", err: true},
+		{query: "sort=epoch,-epoch", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/workloads?"+test.query, nil)
+			sort, err := SortWorkloadFromRequest(r)
+			if test.err {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.sort, sort)
+		})
+	}
+}
@@ -61,7 +61,7 @@ func (p *WorkloadPipeline) Next() (WorkloaderType, bool) {
 
 	// reseration expiration time must be checked, once expiration time is exceeded
 	// the reservation must be deleted
-	if p.checkDeleteSignatures() {
+	if p.w.Expired() || p.checkDeleteSignatures() {
 		// reservation has expired
 		// set its status (next action) to delete
 		slog.Debug().Msg("expired or to be deleted")
@@ -89,6 +89,9 @@ func (p *WorkloadPipeline) Next() (WorkloaderType, bool) {
 		case generated.NextActionDeploy:
 			//nothing to do
 			slog.Debug().Msg("let's deploy")
+		case generated.NextActionUpdate:
+			//nothing to do, the node replaces the previous version
+			slog.Debug().Msg("let's update")
 		}
 
 		if current == p.w.GetNextAction() {
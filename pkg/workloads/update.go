@@ -0,0 +1,250 @@
+package workloads
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	generated "github.com/threefoldtech/tfexplorer/models/generated/workloads"
+	"github.com/threefoldtech/tfexplorer/mw"
+	capacitytypes "github.com/threefoldtech/tfexplorer/pkg/capacity/types"
+	"github.com/threefoldtech/tfexplorer/pkg/workloads/types"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"github.com/zaibon/httpsig"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// original returns the id of the first version of the workload
+func original(workload types.WorkloaderType) schema.ID {
+	if id := workload.GetOriginal(); id != 0 {
+		return id
+	}
+
+	return workload.GetID()
+}
+
+// publicIP returns the public ip workload used by a workload
+func publicIP(workload types.WorkloaderType) schema.ID {
+	switch w := workload.Workloader.(type) {
+	case *generated.K8S:
+		return w.PublicIP
+	case *generated.VirtualMachine:
+		return w.PublicIP
+	}
+
+	return 0
+}
+
+// checkUpdate checks the new version of a workload only changes the fields
+// which can be updated in place
+func checkUpdate(previous, workload types.WorkloaderType) error {
+	if workload.GetWorkloadType() != previous.GetWorkloadType() {
+		return fmt.Errorf("the type of a workload can't be updated")
+	}
+	if workload.GetWorkloadType() == generated.WorkloadTypePublicIP {
+		return fmt.Errorf("public ip workloads can't be updated")
+	}
+	if workload.GetCustomerTid() != previous.GetCustomerTid() {
+		return fmt.Errorf("the customer of a workload can't be updated")
+	}
+	if workload.GetNodeID() != previous.GetNodeID() {
+		return fmt.Errorf("the node of a workload can't be updated")
+	}
+	if workload.GetPoolID() != previous.GetPoolID() {
+		return fmt.Errorf("the pool of a workload can't be updated")
+	}
+	if publicIP(workload) != publicIP(previous) {
+		return fmt.Errorf("the public ip of a workload can't be updated")
+	}
+
+	id := original(previous)
+	if workload.GetOriginal() != id || workload.GetRevision() != previous.GetRevision()+1 {
+		return fmt.Errorf("the update of workload '%d' must be revision %d of workload '%d'", previous.GetID(), previous.GetRevision()+1, id)
+	}
+
+	return nil
+}
+
+// update creates a new version of a deployed workload. The new version is
+// sent to the node with the update action, and replaces the previous version
+// once the node deployed it
+func (a *API) update(r *http.Request) (interface{}, mw.Response) {
+	defer r.Body.Close()
+
+	requestUserID, err := strconv.ParseInt(httpsig.KeyIDFromContext(r.Context()), 10, 64)
+	if err != nil {
+		return nil, mw.BadRequest(errors.Wrap(err, "failed to parse request user id"))
+	}
+
+	id, err := a.parseID(mux.Vars(r)["res_id"])
+	if err != nil {
+		return nil, mw.BadRequest(fmt.Errorf("invalid reservation id"))
+	}
+
+	db := mw.Database(r)
+	previous, err := a.workloadpipeline(types.WorkloadFilter{}.WithID(id).Get(r.Context(), db))
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, mw.NotFound(err)
+		}
+		return nil, mw.Error(err)
+	}
+
+	if previous.GetCustomerTid() != requestUserID {
+		return nil, mw.UnAuthorized(fmt.Errorf("request user identity does not match the workload customer-tid"))
+	}
+
+	if !previous.IsAny(types.Deploy) || previous.GetResult().State != generated.ResultStateOK {
+		return nil, mw.Conflict(fmt.Errorf("only deployed workloads can be updated, workload '%d' is in state '%s'", id, previous.GetNextAction()))
+	}
+
+	workload, err := decodeWorkload(r)
+	if err != nil {
+		return nil, mw.BadRequest(err)
+	}
+
+	if err := workload.Validate(); err != nil {
+		return nil, mw.BadRequest(err)
+	}
+
+	if err := checkUpdate(previous, workload); err != nil {
+		return nil, mw.BadRequest(err)
+	}
+
+	// an update doesn't remove the expiry time of a workload
+	if workload.GetExpiresAt().IsZero() {
+		workload.SetExpiresAt(previous.GetExpiresAt())
+	}
+
+	pending, err := types.WorkloadFilter{}.
+		WithVersionsOf(original(previous)).
+		WithNextAction(types.Update).
+		Count(r.Context(), db)
+	if err != nil {
+		return nil, mw.Error(err)
+	}
+
+	if pending > 0 {
+		return nil, mw.Conflict(fmt.Errorf("workload '%d' already has an update in progress", id))
+	}
+
+	if err := verifyCustomerSignature(r, db, workload); err != nil {
+		return nil, err
+	}
+
+	if err := resolveDependencies(r.Context(), db, workload); err != nil {
+		return nil, err
+	}
+
+	workload.SetEpoch(schema.Date{Time: time.Now()})
+
+	if workload.GetWorkloadType() == generated.WorkloadTypeKubernetes {
+		if err := a.handleKubernetesSize(r.Context(), db, workload); err != nil {
+			return nil, err
+		}
+	}
+
+	allowed, err := a.capacityPlanner.HasUpdateCapacity(workload, previous, minCapacitySeconds)
+	if err != nil {
+		if errors.Is(err, capacitytypes.ErrPoolNotFound) {
+			return nil, mw.NotFound(errors.New("pool does not exist"))
+		}
+		log.Error().Err(err).Msg("failed to load workload capacity pool")
+		return nil, mw.Error(errors.New("could not load the required capacity pool"))
+	}
+
+	if !allowed {
+		return nil, mw.PaymentRequired(errors.New("pool needs additional capacity to support this update"))
+	}
+
+	workload.SetNextAction(types.Update)
+	newID, err := types.WorkloadCreate(r.Context(), db, workload)
+	if err != nil {
+		log.Error().Err(err).Msg("could not create workload")
+		return nil, mw.Error(err)
+	}
+
+	if err := types.WorkloadToUpdate(r.Context(), db, workload); err != nil {
+		log.Error().Err(err).Msg("failed to schedule the workload to update")
+		return nil, mw.Error(errors.New("could not schedule workload to update"))
+	}
+
+	return ReservationCreateResponse{ID: newID}, mw.Created()
+}
+
+// workloadUpdated handles the result of an update. Once the node deployed
+// the new version, it replaces the previous version in the pool. If the
+// update failed, the node keeps the previous version running
+func (a *API) workloadUpdated(ctx context.Context, db *mongo.Database, workload types.WorkloaderType, result types.Result) (interface{}, mw.Response) {
+	if result.State == generated.ResultStateError {
+		if err := types.WorkloadSetNextAction(ctx, db, workload.GetID(), types.Invalid); err != nil {
+			return nil, mw.Error(err)
+		}
+		return nil, mw.Created()
+	}
+
+	if result.State != generated.ResultStateOK {
+		return nil, mw.Created()
+	}
+
+	previous, err := types.WorkloadFilter{}.
+		WithRevision(workload.GetOriginal(), workload.GetRevision()-1).
+		Get(ctx, db)
+	if err != nil {
+		return nil, mw.Error(errors.Wrap(err, "failed to load the previous version of the workload"))
+	}
+
+	if err := a.capacityPlanner.AddUsedCapacity(workload); err != nil {
+		log.Error().Err(err).Msg("failed to increase used capacity in pool")
+		return nil, mw.Error(err)
+	}
+
+	if err := a.capacityPlanner.RemoveUsedCapacity(previous); err != nil {
+		log.Error().Err(err).Msg("failed to decrease used capacity in pool")
+		return nil, mw.Error(err)
+	}
+
+	if err := types.WorkloadSetNextAction(ctx, db, previous.GetID(), types.Deleted); err != nil {
+		return nil, mw.Error(err)
+	}
+
+	if err := types.WorkloadSetNextAction(ctx, db, workload.GetID(), types.Deploy); err != nil {
+		return nil, mw.Error(err)
+	}
+
+	return nil, mw.Created()
+}
+
+// versions lists all the versions of a workload, from the original workload
+// to the latest update
+func (a *API) versions(r *http.Request) (interface{}, mw.Response) {
+	id, err := a.parseID(mux.Vars(r)["res_id"])
+	if err != nil {
+		return nil, mw.BadRequest(fmt.Errorf("invalid reservation id"))
+	}
+
+	db := mw.Database(r)
+	workload, err := types.WorkloadFilter{}.WithID(id).Get(r.Context(), db)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, mw.NotFound(err)
+		}
+		return nil, mw.Error(err)
+	}
+
+	versions, err := types.WorkloadFilter{}.
+		WithVersionsOf(original(workload)).
+		Find(r.Context(), db, options.Find().SetSort(bson.D{{Key: "revision", Value: 1}}))
+	if err != nil {
+		return nil, mw.Error(err)
+	}
+
+	return versions, nil
+}
@@ -0,0 +1,106 @@
+package workloads
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/threefoldtech/tfexplorer/models/generated/workloads"
+	"github.com/threefoldtech/tfexplorer/pkg/workloads/types"
+	"github.com/threefoldtech/tfexplorer/schema"
+)
+
+func Test_checkUpdate(t *testing.T) {
+	container := func(id, original, revision int64) *workloads.Container {
+		return &workloads.Container{
+			ReservationInfo: workloads.ReservationInfo{
+				ID:           schema.ID(id),
+				Original:     schema.ID(original),
+				Revision:     revision,
+				NodeId:       "node1",
+				PoolId:       1,
+				CustomerTid:  1,
+				WorkloadType: workloads.WorkloadTypeContainer,
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		previous workloads.Workloader
+		update   func(w *workloads.Container)
+		err      bool
+	}{
+		{
+			name:     "first_update",
+			previous: container(1, 0, 0),
+			update:   func(w *workloads.Container) { w.Original, w.Revision = 1, 1 },
+		},
+		{
+			name:     "next_update",
+			previous: container(3, 1, 1),
+			update:   func(w *workloads.Container) { w.Original, w.Revision = 1, 2 },
+		},
+		{
+			name:     "wrong_original",
+			previous: container(3, 1, 1),
+			update:   func(w *workloads.Container) { w.Original, w.Revision = 3, 2 },
+			err:      true,
+		},
+		{
+			name:     "wrong_revision",
+			previous: container(3, 1, 1),
+			update:   func(w *workloads.Container) { w.Original, w.Revision = 1, 3 },
+			err:      true,
+		},
+		{
+			name:     "node_changed",
+			previous: container(1, 0, 0),
+			update:   func(w *workloads.Container) { w.Original, w.Revision, w.NodeId = 1, 1, "node2" },
+			err:      true,
+		},
+		{
+			name:     "pool_changed",
+			previous: container(1, 0, 0),
+			update:   func(w *workloads.Container) { w.Original, w.Revision, w.PoolId = 1, 1, 2 },
+			err:      true,
+		},
+		{
+			name:     "customer_changed",
+			previous: container(1, 0, 0),
+			update:   func(w *workloads.Container) { w.Original, w.Revision, w.CustomerTid = 1, 1, 2 },
+			err:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := container(0, 0, 0)
+			tt.update(w)
+
+			err := checkUpdate(types.WorkloaderType{Workloader: tt.previous}, types.WorkloaderType{Workloader: w})
+			if tt.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_checkUpdateType(t *testing.T) {
+	previous := &workloads.Volume{ReservationInfo: workloads.ReservationInfo{ID: 1, WorkloadType: workloads.WorkloadTypeVolume}}
+	update := &workloads.ZDB{ReservationInfo: workloads.ReservationInfo{Original: 1, Revision: 1, WorkloadType: workloads.WorkloadTypeZDB}}
+	assert.Error(t, checkUpdate(types.WorkloaderType{Workloader: previous}, types.WorkloaderType{Workloader: update}))
+
+	ip := &workloads.PublicIP{ReservationInfo: workloads.ReservationInfo{ID: 1, WorkloadType: workloads.WorkloadTypePublicIP}}
+	ipUpdate := &workloads.PublicIP{ReservationInfo: workloads.ReservationInfo{Original: 1, Revision: 1, WorkloadType: workloads.WorkloadTypePublicIP}}
+	assert.Error(t, checkUpdate(types.WorkloaderType{Workloader: ip}, types.WorkloaderType{Workloader: ipUpdate}))
+
+	vm := &workloads.VirtualMachine{ReservationInfo: workloads.ReservationInfo{ID: 1, WorkloadType: workloads.WorkloadTypeVirtualMachine}, PublicIP: 5}
+	vmUpdate := &workloads.VirtualMachine{ReservationInfo: workloads.ReservationInfo{Original: 1, Revision: 1, WorkloadType: workloads.WorkloadTypeVirtualMachine}, PublicIP: 6}
+	assert.Error(t, checkUpdate(types.WorkloaderType{Workloader: vm}, types.WorkloaderType{Workloader: vmUpdate}))
+
+	vmUpdate.PublicIP = 5
+	assert.NoError(t, checkUpdate(types.WorkloaderType{Workloader: vm}, types.WorkloaderType{Workloader: vmUpdate}))
+}
@@ -88,6 +88,12 @@ func (r *CapacityReservationBuilder) WithNodeIDs(nodeIDs []string) *CapacityRese
 	return r
 }
 
+// WithPromoCode sets the promotion code to the reservation
+func (r *CapacityReservationBuilder) WithPromoCode(code string) *CapacityReservationBuilder {
+	r.reservation.DataReservation.PromoCode = code
+	return r
+}
+
 // WithCurrencies sets the currencies to the reservation
 func (r *CapacityReservationBuilder) WithCurrencies(currencies []string) *CapacityReservationBuilder {
 	r.reservation.DataReservation.Currencies = currencies
@@ -3,6 +3,7 @@ package provision
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/threefoldtech/tfexplorer"
@@ -10,6 +11,7 @@ import (
 	"github.com/threefoldtech/tfexplorer/models/generated/workloads"
 	"github.com/threefoldtech/tfexplorer/pkg/capacity/types"
 	wrklds "github.com/threefoldtech/tfexplorer/pkg/workloads"
+	wrkldstypes "github.com/threefoldtech/tfexplorer/pkg/workloads/types"
 	"github.com/threefoldtech/tfexplorer/schema"
 )
 
@@ -67,6 +69,47 @@ func (r *ReservationClient) DryRun(workload workloads.Workloader) (workloads.Wor
 	return workload, nil
 }
 
+// MigrateReservations converts the legacy reservations of the user to
+// workloads in new capacity pools, it returns the converted workloads. Nothing
+// is returned once the reservations are converted
+func (r *ReservationClient) MigrateReservations() ([]workloads.Workloader, error) {
+	list, err := r.explorer.Workloads.ConversionList()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get the reservations to convert")
+	}
+
+	if len(list) == 0 {
+		return nil, nil
+	}
+
+	signer, err := client.NewSigner(r.userID.Key().PrivateKey.Seed())
+	if err != nil {
+		return nil, errors.Wrap(err, "could not load signer")
+	}
+
+	// the converted workloads are the same as the reservations, only the
+	// signature of the customer is added
+	for _, workload := range list {
+		msg, err := workload.SignatureChallenge()
+		if err != nil {
+			return nil, err
+		}
+
+		_, signature, err := signer.SignHex(msg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to sign workload %s", workload.GetReference())
+		}
+
+		workload.SetCustomerSignature(signature)
+	}
+
+	if err := r.explorer.Workloads.ConversionPost(list); err != nil {
+		return nil, errors.Wrap(err, "failed to convert the reservations")
+	}
+
+	return list, nil
+}
+
 // DeployCapacityPool deploys the reservation
 func (r *ReservationClient) DeployCapacityPool(reservation types.Reservation, currencies []string) (wrklds.CapacityPoolCreateResponse, error) {
 	reservationToCreate, err := r.DryRunCapacity(reservation, currencies)
@@ -137,3 +180,47 @@ func (r *ReservationClient) DeleteReservation(resID int64) error {
 	fmt.Printf("Reservation %v marked as to be deleted\n", resID)
 	return nil
 }
+
+// DeleteWorkloads signs the deletion of all the active workloads of a pool,
+// or with the labels, in a single request
+func (r *ReservationClient) DeleteWorkloads(poolID int64, labels schema.Labels) ([]wrkldstypes.BulkDeleteResult, error) {
+	filter := client.WorkloadFilter{}.WithLabels(labels).WithActive(true)
+	if poolID != 0 {
+		filter = filter.WithPool(poolID)
+	}
+
+	// the signature covers the ids of all the workloads the explorer selects
+	var ids []schema.ID
+	pager := client.Cursor("", 100)
+	for {
+		list, err := r.explorer.Workloads.Search(filter, pager)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list the workloads to delete")
+		}
+
+		for _, workload := range list {
+			ids = append(ids, workload.GetID())
+		}
+
+		if len(pager.Next()) == 0 {
+			break
+		}
+		pager = client.Cursor(pager.Next(), 100)
+	}
+
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	request := wrkldstypes.BulkDelete{
+		PoolID: poolID,
+		Labels: labels,
+		Tid:    int64(r.userID.ThreebotID),
+		Epoch:  schema.Date{Time: time.Now()},
+	}
+	if err := request.Sign(ids, r.userID.Key().PrivateKey); err != nil {
+		return nil, errors.Wrap(err, "failed to sign the deletion of the workloads")
+	}
+
+	return r.explorer.Workloads.BulkDelete(request)
+}
@@ -0,0 +1,135 @@
+package schema
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+const (
+	// MaxLabels is the maximum number of labels of a resource
+	MaxLabels = 32
+)
+
+var (
+	labelKeyRe   = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._/-]{0,62}$`)
+	labelValueRe = regexp.MustCompile(`^[a-zA-Z0-9._/-]{0,63}$`)
+)
+
+// Labels are user defined key/value pairs attached to a resource, to group
+// the resources by project, environment, owner...
+//
+// Labels are stored in the database as a sorted array of key=value strings,
+// so they can be indexed and searched without knowing the keys in advance
+type Labels map[string]string
+
+// Validate checks the keys and the values of the labels
+func (l Labels) Validate() error {
+	if len(l) > MaxLabels {
+		return fmt.Errorf("a resource can't have more than %d labels", MaxLabels)
+	}
+
+	for key, value := range l {
+		if !labelKeyRe.MatchString(key) {
+			return fmt.Errorf("invalid label key '%s', expecting up to 63 letters, digits, '.', '_', '/' or '-'", key)
+		}
+		if !labelValueRe.MatchString(value) {
+			return fmt.Errorf("invalid value '%s' of label '%s', expecting up to 63 letters, digits, '.', '_', '/' or '-'", value, key)
+		}
+	}
+
+	return nil
+}
+
+// Pairs returns the labels as key=value strings, sorted by key
+func (l Labels) Pairs() []string {
+	pairs := make([]string, 0, len(l))
+	for key, value := range l {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", key, value))
+	}
+	sort.Strings(pairs)
+
+	return pairs
+}
+
+// Match checks that the labels have all the labels of the selector
+func (l Labels) Match(selector Labels) bool {
+	for key, value := range selector {
+		if v, ok := l[key]; !ok || v != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// String returns the labels in the selector format
+func (l Labels) String() string {
+	return strings.Join(l.Pairs(), ",")
+}
+
+// ParseLabels parses labels in the selector format key=value,key=value
+func ParseLabels(txt string) (Labels, error) {
+	labels := Labels{}
+	if len(txt) == 0 {
+		return labels, nil
+	}
+
+	for _, pair := range strings.Split(txt, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid label '%s', expecting key=value", pair)
+		}
+
+		key := strings.TrimSpace(kv[0])
+		if _, ok := labels[key]; ok {
+			return nil, fmt.Errorf("label '%s' is set twice", key)
+		}
+		labels[key] = strings.TrimSpace(kv[1])
+	}
+
+	return labels, labels.Validate()
+}
+
+// MarshalBSONValue dumps the labels as an array of key=value strings
+func (l Labels) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	if l == nil {
+		return bson.MarshalValue(nil)
+	}
+
+	return bson.MarshalValue(l.Pairs())
+}
+
+// UnmarshalBSONValue loads the labels from an array of key=value strings
+func (l *Labels) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	if t == bsontype.Null {
+		*l = nil
+		return nil
+	}
+
+	if t != bsontype.Array {
+		return fmt.Errorf("invalid labels bson type '%s'", t.String())
+	}
+
+	var pairs []string
+	raw := bson.RawValue{Type: t, Value: data}
+	if err := raw.Unmarshal(&pairs); err != nil {
+		return err
+	}
+
+	labels := make(Labels, len(pairs))
+	for _, pair := range pairs {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid bson label '%s'", pair)
+		}
+		labels[kv[0]] = kv[1]
+	}
+
+	*l = labels
+	return nil
+}
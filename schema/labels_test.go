@@ -0,0 +1,56 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParseLabels(t *testing.T) {
+	labels, err := ParseLabels("project=web, env=prod")
+	require.NoError(t, err)
+	require.Equal(t, Labels{"project": "web", "env": "prod"}, labels)
+	require.Equal(t, "env=prod,project=web", labels.String())
+
+	labels, err = ParseLabels("")
+	require.NoError(t, err)
+	require.Empty(t, labels)
+
+	for _, input := range []string{"project", "project=web,project=api", "=web", "project=a,b", "pro ject=web", "env=$prod"} {
+		_, err := ParseLabels(input)
+		require.Error(t, err, input)
+	}
+}
+
+func TestLabelsMatch(t *testing.T) {
+	labels := Labels{"project": "web", "env": "prod"}
+
+	require.True(t, labels.Match(nil))
+	require.True(t, labels.Match(Labels{"env": "prod"}))
+	require.False(t, labels.Match(Labels{"env": "dev"}))
+	require.False(t, labels.Match(Labels{"owner": "bob"}))
+}
+
+func TestLabelsBSON(t *testing.T) {
+	type resource struct {
+		Labels Labels `bson:"labels,omitempty"`
+	}
+
+	data, err := bson.Marshal(resource{Labels: Labels{"project": "web", "env": "prod"}})
+	require.NoError(t, err)
+
+	var raw struct {
+		Labels []string `bson:"labels"`
+	}
+	require.NoError(t, bson.Unmarshal(data, &raw))
+	require.Equal(t, []string{"env=prod", "project=web"}, raw.Labels)
+
+	var loaded resource
+	require.NoError(t, bson.Unmarshal(data, &loaded))
+	require.Equal(t, Labels{"project": "web", "env": "prod"}, loaded.Labels)
+
+	data, err = bson.Marshal(resource{})
+	require.NoError(t, err)
+	require.NoError(t, bson.Unmarshal(data, &loaded))
+}
@@ -0,0 +1,97 @@
+package tfexplorer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tyler-smith/go-bip39"
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/threefoldtech/tfexplorer/pkg/phonebook/types"
+	"github.com/threefoldtech/zos/pkg/identity"
+	"github.com/threefoldtech/zos/pkg/versioned"
+)
+
+// tokenVersion is the version of the token identity file
+var tokenVersion = versioned.MustParse("1.0.0")
+
+// TokenIdentity defines serializable struct to identify a scoped API token
+// of a user. It can be used by the client instead of the UserIdentity so the
+// user key doesn't need to be shared
+type TokenIdentity struct {
+	// TokenID is the id of the token registered in the explorer
+	TokenID string `json:"token_id"`
+	// Mnemonic words of the token private key
+	Mnemonic string `json:"mnemonic"`
+	// ThreebotID of the user owning the token
+	ThreebotID uint64 `json:"threebotid"`
+	// Internal keypair not exported
+	key identity.KeyPair
+}
+
+// NewTokenIdentity create a new TokenIdentity from existing key
+func NewTokenIdentity(tokenID string, key identity.KeyPair, threebotid uint64) *TokenIdentity {
+	return &TokenIdentity{
+		TokenID:    tokenID,
+		key:        key,
+		ThreebotID: threebotid,
+	}
+}
+
+// Key returns the internal KeyPair
+func (t *TokenIdentity) Key() identity.KeyPair {
+	return t.key
+}
+
+// Load fetch a token file and initialize key based on mnemonic
+func (t *TokenIdentity) Load(path string) error {
+	version, buf, err := versioned.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if version.NE(tokenVersion) {
+		return fmt.Errorf("unsupported token version")
+	}
+
+	if err := json.Unmarshal(buf, &t); err != nil {
+		return err
+	}
+
+	seed, err := bip39.EntropyFromMnemonic(t.Mnemonic)
+	if err != nil {
+		return err
+	}
+
+	t.key, err = identity.FromSeed(seed)
+	return err
+}
+
+// Save dumps TokenIdentity into a versioned file
+func (t *TokenIdentity) Save(path string) error {
+	var err error
+
+	t.Mnemonic, err = bip39.NewMnemonic(t.key.PrivateKey.Seed())
+	if err != nil {
+		return err
+	}
+
+	buf, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	log.Info().Str("filename", path).Msg("writing token identity")
+	return versioned.WriteFile(path, tokenVersion, buf, 0400)
+}
+
+// PrivateKey implements the client.Identity interface
+func (t *TokenIdentity) PrivateKey() ed25519.PrivateKey {
+	return t.Key().PrivateKey
+}
+
+// Identity implements the Identifier interface
+func (t *TokenIdentity) Identity() string {
+	return types.TokenKeyID(t.TokenID)
+}